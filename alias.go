@@ -0,0 +1,46 @@
+package linkmap
+
+import "fmt"
+
+// WithAlias declares name and alias to be the same variable: whichever of
+// them an input template binds, output templates may use either. This lets
+// a map migrate from numbered to named variables one rule at a time, e.g.
+// WithAlias("1", "slug") allows both "docs/$1.md https://example.com/$slug"
+// and "docs/$slug.md https://example.com/$1". Names are given without the
+// leading '$'. An input template may not bind both names.
+func WithAlias(name, alias string) Option {
+	return func(m *Map) {
+		m.aliases = append(m.aliases, [2]string{name, alias})
+	}
+}
+
+// checkAliases checks that an input template does not bind two aliases of
+// the same variable.
+func (m *Map) checkAliases(in Template) error {
+	if len(m.aliases) == 0 {
+		return nil
+	}
+	bound := make(map[string]bool)
+	for _, v := range in.Variables() {
+		bound[v] = true
+	}
+	for _, a := range m.aliases {
+		if a[0] != a[1] && bound[a[0]] && bound[a[1]] {
+			return fmt.Errorf("linkmap: variables $%s and $%s are aliases and cannot both be bound", a[0], a[1])
+		}
+	}
+	return nil
+}
+
+// bindAliases adds the aliases of the variables in vars.
+func (m *Map) bindAliases(vars map[string]string) {
+	for _, a := range m.aliases {
+		if v, ok := vars[a[0]]; ok {
+			if _, ok := vars[a[1]]; !ok {
+				vars[a[1]] = v
+			}
+		} else if v, ok := vars[a[1]]; ok {
+			vars[a[0]] = v
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	src := strings.Join([]string{
+		"docs/$1.md https://example.com/docs/$slug",
+		"blog/$slug.md https://example.com/blog/$1",
+		"wiki/$page.md https://example.com/wiki/$page",
+	}, "\n")
+	m, err := Parse(strings.NewReader(src), WithAlias("1", "slug"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"docs/intro.md", "https://example.com/docs/intro"},
+		{"blog/hello.md", "https://example.com/blog/hello"},
+		{"wiki/home.md", "https://example.com/wiki/home"},
+	}
+	for _, tt := range tests {
+		got, vars, err := m.EvaluateVars(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+		if strings.HasPrefix(tt.path, "docs/") && vars["1"] != vars["slug"] {
+			t.Errorf("EvaluateVars(%q) vars = %v; want aliases bound", tt.path, vars)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(src)); err != nil {
+		t.Errorf("Parse() without aliases error: %v", err)
+	}
+	if diags := Analyze(src); len(diags) != 2 {
+		t.Errorf("Analyze() without aliases = %v; want 2 unbound variables", diags)
+	}
+	if diags := Analyze(src, WithAlias("1", "slug")); len(diags) != 0 {
+		t.Errorf("Analyze() with aliases = %v", diags)
+	}
+	if _, err := Parse(strings.NewReader("$1/$slug.md x/$1"), WithAlias("1", "slug")); err == nil {
+		t.Errorf("Parse() binding both aliases succeeded")
+	}
+}
+
+func TestNamedVariables(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		vars    []string
+		wantErr bool
+	}{
+		{"docs/$slug.md", []string{"slug"}, false},
+		{"$year/$month_2/$1", []string{"year", "month_2", "1"}, false},
+		{"$1abc", []string{"1"}, false},
+		{"$_", []string{"_"}, false},
+		{"$.md", nil, true},
+		{"$a$b", nil, true},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTemplate(%q) error = %v; want error %v", tt.tmpl, err, tt.wantErr)
+			continue
+		}
+		if err == nil && strings.Join(tmpl.Variables(), ",") != strings.Join(tt.vars, ",") {
+			t.Errorf("ParseTemplate(%q).Variables() = %v; want %v", tt.tmpl, tmpl.Variables(), tt.vars)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsafeLink is returned, wrapped, for a generated link which an
+// Allowlist rejects.
+var ErrUnsafeLink = errors.New("linkmap: unsafe link")
+
+// An Allowlist restricts the links a map generates, so that a path crafted
+// to bind a variable to something like "//evil.example" cannot turn the
+// map into an open redirector. Its lists have the meaning of the Linter's
+// allowlists, but are checked against every link as it is generated,
+// rather than against the literal text of outputs.
+//
+// Beyond the lists, a scheme or host which is not fixed by the literal
+// text at the start of a rule's output, and so came from a variable, is
+// rejected unless the corresponding list allows it.
+type Allowlist struct {
+	// Schemes lists the schemes links may use. If empty, any scheme
+	// written in an output is allowed.
+	Schemes []string
+	// Hosts lists the hosts links may point at. An entry beginning with
+	// "." also matches any subdomain. If empty, any host written in an
+	// output is allowed.
+	Hosts []string
+	// Paths lists the prefixes relative links may begin with. If Hosts is
+	// set and Paths is empty, relative links are rejected.
+	Paths []string
+}
+
+// WithAllowlist checks every link the map generates against a, failing
+// the evaluation with an error wrapping ErrUnsafeLink if it is not
+// allowed.
+func WithAllowlist(a Allowlist) Option {
+	return func(m *Map) {
+		m.allow = &a
+	}
+}
+
+// check checks a link built from out.
+func (a *Allowlist) check(out Template, link string) error {
+	unsafe := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w %q: %s", ErrUnsafeLink, link, fmt.Sprintf(format, args...))
+	}
+	// Browsers read backslashes in URLs as slashes, and any number of
+	// slashes after the scheme as the start of the host.
+	s := strings.ReplaceAll(link, `\`, "/")
+	u, err := url.Parse(s)
+	if err != nil {
+		return unsafe("%v", err)
+	}
+	fixedScheme, fixedHost := out.fixedOrigin()
+	if u.Scheme == "" && !strings.HasPrefix(s, "//") {
+		if len(a.Paths) > 0 || len(a.Hosts) > 0 {
+			if !hasAnyPrefix(link, a.Paths) {
+				return unsafe("relative link is not under an allowed path")
+			}
+		}
+		return nil
+	}
+	if u.Scheme != "" {
+		switch {
+		case len(a.Schemes) > 0 && !containsFold(a.Schemes, u.Scheme):
+			return unsafe("scheme %q is not allowed", u.Scheme)
+		case len(a.Schemes) == 0 && !fixedScheme:
+			return unsafe("scheme %q comes from a variable", u.Scheme)
+		}
+	}
+	if u.Opaque != "" {
+		if hierarchical[strings.ToLower(u.Scheme)] {
+			// Browsers read "https:evil.example" as a host.
+			return unsafe("link has no \"//\" before its host")
+		}
+		// e.g. mailto:, which has no host.
+		return nil
+	}
+	switch host := u.Hostname(); {
+	case len(a.Hosts) > 0 && !hostAllowed(host, a.Hosts):
+		return unsafe("host %q is not allowed", host)
+	case len(a.Hosts) == 0 && !fixedHost:
+		return unsafe("host %q comes from a variable", host)
+	}
+	return nil
+}
+
+// hierarchical holds the schemes whose URLs browsers always read as
+// having a host.
+var hierarchical = map[string]bool{"http": true, "https": true, "ws": true, "wss": true, "ftp": true}
+
+// fixedOrigin reports whether the scheme and host of the links built from
+// t are fixed by its literal prefix, rather than depending on variables.
+func (t Template) fixedOrigin() (scheme, host bool) {
+	if t.text != nil || t.segs.len() == 0 || t.segs.at(0).typ != segmentTypeString {
+		return false, false
+	}
+	prefix := strings.ReplaceAll(t.segs.at(0).val, `\`, "/")
+	if t.segs.len() == 1 {
+		return true, true
+	}
+	i := strings.IndexAny(prefix, ":/?#")
+	scheme = i > 0 && prefix[i] == ':'
+	rest := prefix
+	if scheme {
+		rest = prefix[i+1:]
+	}
+	if !strings.HasPrefix(rest, "//") {
+		// No authority: the host is fixed as none, unless a variable
+		// starts the path with "//".
+		return scheme, rest != "" && rest != "/"
+	}
+	return scheme, strings.ContainsAny(rest[2:], "/?#")
+}
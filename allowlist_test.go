@@ -0,0 +1,79 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	const src = `r/$1 $1
+h/$1 https://$1.example.com/
+d/$1 https://example.com/$1
+m/$1 mailto:$1
+s/$1 https:$1
+b/$1 /\$1
+`
+	cases := []struct {
+		allow  Allowlist
+		path   string
+		expect string
+	}{
+		{Allowlist{}, "r/docs", "docs"},
+		{Allowlist{}, "r/https:evil.example", ""},
+		{Allowlist{Schemes: []string{"https"}}, "r/https:evil.example", ""},
+		{Allowlist{}, "h/x", ""},
+		{Allowlist{Hosts: []string{".example.com"}}, "h/x", "https://x.example.com/"},
+		{Allowlist{Hosts: []string{".example.com"}}, "h/evil.example#", ""},
+		{Allowlist{}, "d/a", "https://example.com/a"},
+		{Allowlist{Schemes: []string{"http"}}, "d/a", ""},
+		{Allowlist{Hosts: []string{"example.org"}}, "d/a", ""},
+		{Allowlist{}, "m/a@example.com", "mailto:a@example.com"},
+		{Allowlist{}, "s/evil.example", ""},
+		{Allowlist{}, "b/evil.example", ""},
+		{Allowlist{Hosts: []string{"example.com"}}, "r/docs", ""},
+		{Allowlist{Hosts: []string{"example.com"}, Paths: []string{"docs"}}, "r/docs", "docs"},
+	}
+	for _, c := range cases {
+		m, err := Parse(strings.NewReader(src), WithAllowlist(c.allow))
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		got, err := m.Evaluate(c.path)
+		if c.expect == "" {
+			if !errors.Is(err, ErrUnsafeLink) {
+				t.Errorf("%+v: Evaluate(%q) = %q, %v; want ErrUnsafeLink", c.allow, c.path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.expect {
+			t.Errorf("%+v: Evaluate(%q) = %q, %v; want %q", c.allow, c.path, got, err, c.expect)
+		}
+	}
+}
+
+func TestFixedOrigin(t *testing.T) {
+	cases := []struct {
+		tmpl         string
+		scheme, host bool
+	}{
+		{"https://example.com/$1", true, true},
+		{"https://example.com", true, true},
+		{"https://$1.example.com/", true, false},
+		{"https://example.com$1", true, false},
+		{"$1", false, false},
+		{"/$1", false, false},
+		{"docs/$1", false, true},
+		{"mailto:$1", true, false},
+		{"tmpl:https://example.com/{{.x}}", false, false},
+	}
+	for _, c := range cases {
+		tmpl, err := ParseTemplate(c.tmpl, WithTmplOutputs())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scheme, host := tmpl.fixedOrigin(); scheme != c.scheme || host != c.host {
+			t.Errorf("fixedOrigin(%q) = %v, %v; want %v, %v", c.tmpl, scheme, host, c.scheme, c.host)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Position is a location in linkmap source. Lines and columns are
+// zero-based byte offsets, as in the Language Server Protocol.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// A Range is a half-open span of linkmap source.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Severity describes how serious a diagnostic is.
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	case SeverityOff:
+		return "off"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so that severities are
+// written to JSON by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < SeverityError || s > SeverityHint {
+		return nil, fmt.Errorf("linkmap: invalid severity %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Severity) UnmarshalText(text []byte) error {
+	for v := SeverityError; v <= SeverityHint; v++ {
+		if v.String() == string(text) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("linkmap: unknown severity %q", text)
+}
+
+// A TextEdit replaces the text in Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"new_text"`
+}
+
+// A Fix is a suggested change which resolves a diagnostic.
+type Fix struct {
+	Title string     `json:"title"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// Related is a location related to a diagnostic, such as another rule
+// involved in the problem.
+type Related struct {
+	File    string `json:"file,omitempty"`
+	Range   Range  `json:"range"`
+	Message string `json:"message"`
+}
+
+// A Diagnostic is a problem found in linkmap source, or in the files a
+// map is evaluated against. It is written to JSON in the same form by
+// every analysis, so that tools such as code review bots can ingest the
+// results uniformly.
+type Diagnostic struct {
+	// File is the source named by WithSource, if any, or the file the
+	// problem concerns.
+	File     string    `json:"file,omitempty"`
+	Range    Range     `json:"range"`
+	Severity Severity  `json:"severity"`
+	Code     string    `json:"code"`
+	Message  string    `json:"message"`
+	Related  []Related `json:"related,omitempty"`
+	Fixes    []Fix     `json:"fixes,omitempty"`
+}
+
+// Diagnostic codes reported by Analyze.
+const (
+	CodeInvalidLine     = "invalid-line"
+	CodeWhitespace      = "whitespace"
+	CodeInvalidTemplate = "invalid-template"
+	CodeUnboundVariable = "unbound-variable"
+	CodeOutputExtension = "output-extension"
+	CodeDuplicateRule   = "duplicate-rule"
+)
+
+// Analyze checks linkmap source and returns any problems found, in source
+// order. Unlike Parse, it does not stop at the first error, which makes it
+// suitable for running on every keystroke in an editor. Options which
+// affect parsing, such as WithSegment, should match those passed to Parse.
+// Diagnostics suppressed by ignore comments, as described for Linter, are
+// left out.
+func Analyze(text string, opts ...Option) []Diagnostic {
+	m := newMap(opts)
+	diags := suppress(analyze(text, m), text, nil)
+	for i := range diags {
+		diags[i].File = m.source
+	}
+	return diags
+}
+
+// analyze returns the diagnostics of Analyze, without suppressing any.
+func analyze(text string, m *Map) []Diagnostic {
+	var (
+		diags []Diagnostic
+		// seen holds the range of the first input template of each key.
+		seen = make(map[string]Range)
+	)
+	for n, l := range strings.Split(text, "\n") {
+		if lineKind(l) != RuleLine {
+			continue
+		}
+		toks := ruleFields(l, m.textOutputs)
+		lineRange := Range{Start: Position{n, 0}, End: Position{n, len(l)}}
+		if len(toks) != 2 {
+			diags = append(diags, Diagnostic{
+				Range:    lineRange,
+				Severity: SeverityError,
+				Code:     CodeInvalidLine,
+				Message:  fmt.Sprintf("expected 2 space-separated templates, found %d", len(toks)),
+			})
+			continue
+		}
+		if canonical := toks[0].text + " " + toks[1].text; canonical != l {
+			diags = append(diags, Diagnostic{
+				Range:    lineRange,
+				Severity: SeverityError,
+				Code:     CodeWhitespace,
+				Message:  "templates must be separated by a single space",
+				Fixes: []Fix{{
+					Title: "Normalize whitespace",
+					Edits: []TextEdit{{Range: lineRange, NewText: canonical}},
+				}},
+			})
+		}
+		in, inErr := m.parseInput(toks[0].text)
+		if inErr != nil {
+			diags = append(diags, toks[0].diagnostic(n, CodeInvalidTemplate, inErr))
+		}
+		outT, outErr := m.parseOutput(toks[1].text)
+		out := outT.segs
+		if outErr != nil {
+			diags = append(diags, toks[1].diagnostic(n, CodeInvalidTemplate, outErr))
+		}
+		if inErr != nil || outErr != nil {
+			continue
+		}
+		bound := make(map[string]bool)
+		for _, v := range in.Variables() {
+			bound[v] = true
+		}
+		for _, a := range m.aliases {
+			if bound[a[0]] || bound[a[1]] {
+				bound[a[0]], bound[a[1]] = true, true
+			}
+		}
+		out.walk(func(s segment) {
+			switch {
+			case (s.typ == segmentTypeVariable || s.typ == segmentTypeCustom) && !bound[s.name()] && !m.derived(s.name()):
+				diags = append(diags, toks[1].diagnostic(n, CodeUnboundVariable,
+					fmt.Errorf("variable %s is not bound by the input template", s.val)))
+			case s.typ == segmentTypeExtension:
+				diags = append(diags, toks[1].diagnostic(n, CodeOutputExtension,
+					fmt.Errorf("extension group %s cannot be used in an output template", s.val)))
+			}
+		})
+		key := in.String()
+		if first, ok := seen[key]; ok {
+			d := toks[0].diagnostic(n, CodeDuplicateRule,
+				fmt.Errorf("input template is already used on line %d; this rule is unreachable", first.Start.Line+1))
+			d.Severity = SeverityWarning
+			d.Related = []Related{{
+				File:    m.source,
+				Range:   first,
+				Message: "the rule which takes precedence",
+			}}
+			d.Fixes = []Fix{{
+				Title: "Remove rule",
+				Edits: []TextEdit{{
+					Range: Range{Start: Position{n, 0}, End: Position{n + 1, 0}},
+				}},
+			}}
+			diags = append(diags, d)
+			continue
+		}
+		seen[key] = toks[0].rangeAt(n)
+	}
+	return diags
+}
+
+type token struct {
+	text  string
+	start int
+}
+
+func (t token) rangeAt(line int) Range {
+	return Range{
+		Start: Position{line, t.start},
+		End:   Position{line, t.start + len(t.text)},
+	}
+}
+
+func (t token) diagnostic(line int, code string, err error) Diagnostic {
+	return Diagnostic{
+		Range:    t.rangeAt(line),
+		Severity: SeverityError,
+		Code:     code,
+		Message:  strings.TrimPrefix(err.Error(), "linkmap: "),
+	}
+}
+
+// fields splits l around runs of spaces and tabs, recording byte offsets.
+func fields(l string) []token {
+	var (
+		toks  []token
+		start = -1
+	)
+	for i := 0; i <= len(l); i++ {
+		if i == len(l) || l[i] == ' ' || l[i] == '\t' {
+			if start >= 0 {
+				toks = append(toks, token{text: l[start:i], start: start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return toks
+}
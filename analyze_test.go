@@ -0,0 +1,106 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	cases := []struct {
+		text  string
+		codes []string
+	}{
+		{
+			text: "foo/$1.{md,mdx} https://example.com/posts/$1\n",
+		},
+		{
+			text:  "foo/$1.md\n",
+			codes: []string{CodeInvalidLine},
+		},
+		{
+			text:  "foo/$1.md  https://example.com/$1",
+			codes: []string{CodeWhitespace},
+		},
+		{
+			text:  "foo/$.md https://example.com/",
+			codes: []string{CodeInvalidTemplate},
+		},
+		{
+			text:  "foo/$1.md https://example.com/$2",
+			codes: []string{CodeUnboundVariable},
+		},
+		{
+			text:  "foo/$1.{md} https://example.com/$1.{html}",
+			codes: []string{CodeOutputExtension},
+		},
+		{
+			text:  "foo/$1.md https://a.com/$1\nfoo/$1.md https://b.com/$1\n",
+			codes: []string{CodeDuplicateRule},
+		},
+	}
+	for _, c := range cases {
+		diags := Analyze(c.text)
+		if len(diags) != len(c.codes) {
+			t.Errorf("Analyze(%q) = %v; want codes %v", c.text, diags, c.codes)
+			continue
+		}
+		for i, d := range diags {
+			if d.Code != c.codes[i] {
+				t.Errorf("Analyze(%q)[%d].Code = %q; want %q", c.text, i, d.Code, c.codes[i])
+			}
+		}
+	}
+}
+
+func TestAnalyzeRange(t *testing.T) {
+	diags := Analyze("a/$1 b/$1\nfoo/$1.md https://example.com/$2")
+	if len(diags) != 1 {
+		t.Fatalf("Analyze() = %v; want 1 diagnostic", diags)
+	}
+	want := Range{Start: Position{1, 10}, End: Position{1, 32}}
+	if diags[0].Range != want {
+		t.Errorf("Analyze()[0].Range = %v; want %v", diags[0].Range, want)
+	}
+	diags = Analyze("bad", WithSource("web/.linkmap"))
+	if len(diags) != 1 || diags[0].File != "web/.linkmap" {
+		t.Errorf("Analyze() with source = %+v; want 1 diagnostic in web/.linkmap", diags)
+	}
+}
+
+func TestAnalyzeFix(t *testing.T) {
+	diags := Analyze("foo/$1.md \t https://example.com/$1")
+	if len(diags) != 1 || len(diags[0].Fixes) != 1 {
+		t.Fatalf("Analyze() = %v; want 1 diagnostic with a fix", diags)
+	}
+	if got := diags[0].Fixes[0].Edits[0].NewText; got != "foo/$1.md https://example.com/$1" {
+		t.Errorf("fix NewText = %q", got)
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	diags := Analyze("foo/$1.md https://a.com/$1\nfoo/$1.md https://b.com/$1\n", WithSource("a.linkmap"))
+	if len(diags) != 1 || len(diags[0].Related) != 1 {
+		t.Fatalf("Analyze() = %+v; want 1 diagnostic with a related rule", diags)
+	}
+	if got, want := diags[0].Related[0].Range, (Range{Start: Position{0, 0}, End: Position{0, 9}}); got != want {
+		t.Errorf("Related[0].Range = %v; want %v", got, want)
+	}
+	buf, err := json.Marshal(diags[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"file":"a.linkmap","range":{"start":{"line":1,"column":0},"end":{"line":1,"column":9}},` +
+		`"severity":"warning","code":"duplicate-rule","message":"input template is already used on line 1; this rule is unreachable",` +
+		`"related":[{"file":"a.linkmap","range":{"start":{"line":0,"column":0},"end":{"line":0,"column":9}},"message":"the rule which takes precedence"}],` +
+		`"fixes":[{"title":"Remove rule","edits":[{"range":{"start":{"line":1,"column":0},"end":{"line":2,"column":0}},"new_text":""}]}]}`
+	if string(buf) != want {
+		t.Errorf("json.Marshal() = %s; want %s", buf, want)
+	}
+	var d Diagnostic
+	if err := json.Unmarshal(buf, &d); err != nil || d.Severity != SeverityWarning || d.Related[0].Range != diags[0].Related[0].Range {
+		t.Errorf("json.Unmarshal() = %+v, %v", d, err)
+	}
+	if _, err := json.Marshal(Diagnostic{}); err == nil {
+		t.Error("json.Marshal() accepted a zero severity")
+	}
+}
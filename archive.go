@@ -0,0 +1,190 @@
+package linkmap
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenArchive opens a zip file, or a tar file which may be gzipped, as an
+// fs.FS for EvaluateFS, LookupFile or Coverage. The format is chosen by
+// the file's extension: .zip, .tar, .tar.gz or .tgz. Release tarballs often
+// hold a single top-level directory, which fs.Sub can strip. The returned
+// Closer must be closed when the FS is no longer used.
+func OpenArchive(name string) (fs.FS, io.Closer, error) {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		r, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("linkmap: opening archive: %w", err)
+		}
+		return r, r, nil
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("linkmap: opening archive: %w", err)
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if !strings.HasSuffix(name, ".tar") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, nil, fmt.Errorf("linkmap: opening archive: %w", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		fsys, err := TarFS(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fsys, nopCloser{}, nil
+	}
+	return nil, nil, fmt.Errorf("linkmap: unknown archive format: %s", name)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// TarFS reads a tar archive into memory and returns its regular files and
+// directories as an fs.FS. Other entries, such as links, are left out.
+func TarFS(r io.Reader) (fs.FS, error) {
+	fsys := tarFS{".": &tarEntry{name: ".", mode: fs.ModeDir | 0o755}}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("linkmap: reading tar archive: %w", err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		e := &tarEntry{name: path.Base(name), mode: hdr.FileInfo().Mode(), modTime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if e.data, err = io.ReadAll(tr); err != nil {
+				return nil, fmt.Errorf("linkmap: reading tar archive: %w", err)
+			}
+		case tar.TypeDir:
+		default:
+			continue
+		}
+		fsys.add(name, e)
+	}
+	return fsys, nil
+}
+
+// A tarFS is an in-memory file system keyed by path.
+type tarFS map[string]*tarEntry
+
+type tarEntry struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+	// children holds the names of a directory's entries.
+	children map[string]bool
+}
+
+// add adds e at name, creating parent directories as needed.
+func (fsys tarFS) add(name string, e *tarEntry) {
+	if old, ok := fsys[name]; ok && old.IsDir() && e.IsDir() {
+		old.mode, old.modTime = e.mode, e.modTime
+		return
+	}
+	fsys[name] = e
+	for name != "." {
+		dir := path.Dir(name)
+		parent, ok := fsys[dir]
+		if !ok {
+			parent = &tarEntry{name: path.Base(dir), mode: fs.ModeDir | 0o755}
+			fsys[dir] = parent
+		}
+		if parent.children == nil {
+			parent.children = make(map[string]bool)
+		}
+		parent.children[path.Base(name)] = true
+		name = dir
+	}
+}
+
+func (fsys tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := fsys[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f := &tarFile{tarEntry: e, r: bytes.NewReader(e.data)}
+	if e.IsDir() {
+		names := make([]string, 0, len(e.children))
+		for child := range e.children {
+			names = append(names, child)
+		}
+		sort.Strings(names)
+		for _, child := range names {
+			f.dir = append(f.dir, fs.FileInfoToDirEntry(fsys[path.Join(name, child)]))
+		}
+	}
+	return f, nil
+}
+
+// tarEntry implements fs.FileInfo.
+func (e *tarEntry) Name() string       { return e.name }
+func (e *tarEntry) Size() int64        { return int64(len(e.data)) }
+func (e *tarEntry) Mode() fs.FileMode  { return e.mode }
+func (e *tarEntry) ModTime() time.Time { return e.modTime }
+func (e *tarEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *tarEntry) Sys() interface{}   { return nil }
+
+type tarFile struct {
+	*tarEntry
+	r   *bytes.Reader
+	dir []fs.DirEntry
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.tarEntry, nil }
+func (f *tarFile) Read(b []byte) (int, error) {
+	if f.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return f.r.Read(b)
+}
+func (f *tarFile) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (f *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if n <= 0 {
+		entries := f.dir
+		f.dir = nil
+		return entries, nil
+	}
+	if len(f.dir) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.dir) {
+		n = len(f.dir)
+	}
+	entries := f.dir[:n]
+	f.dir = f.dir[n:]
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+package linkmap
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var archiveFiles = []struct {
+	name, body string
+}{
+	{"pkg/docs/a.md", "# A"},
+	{"pkg/docs/b.md", "# B"},
+	{"pkg/README", "readme"},
+}
+
+func writeTar(t *testing.T, w io.Writer) {
+	t.Helper()
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: "pkg/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archiveFiles {
+		hdr := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f.body)), ModTime: time.Unix(0, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, f.body)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "pkg/link", Typeflag: tar.TypeSymlink, Linkname: "README"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTarFS(t *testing.T) {
+	var buf bytes.Buffer
+	writeTar(t, &buf)
+	fsys, err := TarFS(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "pkg/docs/a.md", "pkg/docs/b.md", "pkg/README"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "pkg/link"); err == nil {
+		t.Error("symlink was included")
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	dir := t.TempDir()
+	var tarBuf, tgzBuf, zipBuf bytes.Buffer
+	writeTar(t, &tarBuf)
+	gz := gzip.NewWriter(&tgzBuf)
+	gz.Write(tarBuf.Bytes())
+	gz.Close()
+	zw := zip.NewWriter(&zipBuf)
+	for _, f := range archiveFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.body)
+	}
+	zw.Close()
+
+	m, err := Parse(strings.NewReader("docs/$1.md https://example.com/$1?size=$size\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{
+		"release.tar":    tarBuf.Bytes(),
+		"release.tar.gz": tgzBuf.Bytes(),
+		"release.tgz":    tgzBuf.Bytes(),
+		"release.zip":    zipBuf.Bytes(),
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		fsys, closer, err := OpenArchive(p)
+		if err != nil {
+			t.Fatalf("OpenArchive(%s): %v", name, err)
+		}
+		sub, err := fs.Sub(fsys, "pkg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := m.Coverage(sub)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.Files != 3 || c.Linked != 2 || len(c.Unlinked) != 1 || c.Unlinked[0] != "README" {
+			t.Errorf("%s: Coverage() = %+v", name, c)
+		}
+		if link, err := m.EvaluateFile(sub, "docs/a.md"); err != nil || link != "https://example.com/a?size=3" {
+			t.Errorf("%s: EvaluateFile() = %q, %v", name, link, err)
+		}
+		closer.Close()
+	}
+	if _, _, err := OpenArchive(filepath.Join(dir, "release.rar")); err == nil {
+		t.Error("OpenArchive() accepted an unknown format")
+	}
+}
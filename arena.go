@@ -0,0 +1,87 @@
+package linkmap
+
+import "unicode/utf8"
+
+const (
+	// arenaChunk is the number of segments a segmentArena allocates at
+	// once.
+	arenaChunk = 4096
+	// extChunk is the number of segExts it allocates at once, which few
+	// segments need.
+	extChunk = 256
+)
+
+// A segmentArena allocates the tables of many templates from large shared
+// chunks, rather than each template from its own slices, which cuts the
+// allocations of parsing a large map and keeps the tables of rules tried
+// one after another close together in memory. Together with segment
+// values which are offsets into the map's source rather than copies,
+// parsing a map takes a handful of allocations per thousand rules instead
+// of several per rule.
+type segmentArena struct {
+	refs []segRef
+	ext  []segExt
+}
+
+// alloc returns a template of refs and ext, if not nil, over src, copying
+// the tables. A nil arena allocates the copies alone. Templates share
+// chunks but not capacity, so appending to the tables of one never
+// overwrites another.
+func (a *segmentArena) alloc(src string, refs []segRef, ext []segExt) template {
+	if len(refs) == 0 {
+		return template{}
+	}
+	if a == nil {
+		t := template{src: src, refs: append([]segRef(nil), refs...)}
+		if ext != nil {
+			t.ext = append([]segExt(nil), ext...)
+		}
+		return t
+	}
+	if cap(a.refs)-len(a.refs) < len(refs) {
+		a.refs = make([]segRef, 0, chunkSize(len(refs), arenaChunk))
+	}
+	start := len(a.refs)
+	a.refs = append(a.refs, refs...)
+	t := template{src: src, refs: a.refs[start:len(a.refs):len(a.refs)]}
+	if ext != nil {
+		if cap(a.ext)-len(a.ext) < len(ext) {
+			a.ext = make([]segExt, 0, chunkSize(len(ext), extChunk))
+		}
+		start := len(a.ext)
+		a.ext = append(a.ext, ext...)
+		t.ext = a.ext[start:len(a.ext):len(a.ext)]
+	}
+	return t
+}
+
+// chunkSize returns the size of a chunk to allocate n entries from, given
+// the usual size.
+func chunkSize(n, size int) int {
+	if n > size {
+		return n
+	}
+	return size
+}
+
+// A span is a substring of src which grows one rune at a time, as a
+// strings.Builder fed the runes of src in order would, but without copying
+// them.
+type span struct {
+	src        string
+	start, end int
+}
+
+// write appends the rune at src[i], which must follow the span's end
+// unless the span is empty.
+func (b *span) write(i int) {
+	if b.start == b.end {
+		b.start = i
+	}
+	_, size := utf8.DecodeRuneInString(b.src[i:])
+	b.end = i + size
+}
+
+func (b *span) Len() int       { return b.end - b.start }
+func (b *span) String() string { return b.src[b.start:b.end] }
+func (b *span) Reset()         { b.start, b.end = 0, 0 }
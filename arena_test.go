@@ -0,0 +1,105 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeMap returns the source of a map of n rules.
+func largeMap(n int) string {
+	var src strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&src, "section%d/$slug/page%d.{md,mdx} https://example.com/s%d/$slug/p%d\n", i%100, i, i%100, i)
+	}
+	return src.String()
+}
+
+func TestSegmentArena(t *testing.T) {
+	var a segmentArena
+	x, err := parseTemplateIn("docs/$1.{md,mdx}", nil, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := parseTemplateIn("blog/$slug|upper", nil, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = append(x.refs, segRef{})
+	if x.String() != "docs/$1.{md,mdx}" || y.String() != "blog/$slug|upper" {
+		t.Errorf("templates = %q, %q", x.String(), y.String())
+	}
+	if &x.refs[0] == &y.refs[0] || cap(x.refs) != len(x.refs) {
+		t.Error("templates share segments")
+	}
+	if x.ext != nil || len(y.ext) != y.len() || len(y.at(1).filters) != 1 {
+		t.Errorf("ext = %v, %v; want only the filtered template's", x.ext, y.ext)
+	}
+	big := make([]segRef, arenaChunk+1)
+	if got := a.alloc("", big, nil); got.len() != len(big) {
+		t.Errorf("alloc() of %d segments returned %d", len(big), got.len())
+	}
+}
+
+func TestMakeTemplate(t *testing.T) {
+	segs := []segment{
+		{typ: segmentTypeString, val: "docs/"},
+		{typ: segmentTypeVariable, val: "$1"},
+		{typ: segmentTypeExtension, val: "{md,mdx}"},
+	}
+	tmpl := makeTemplate(segs)
+	if tmpl.src != "docs/$1{md,mdx}" || tmpl.ext != nil {
+		t.Errorf("makeTemplate() = %+v", tmpl)
+	}
+	for i, s := range tmpl.segments() {
+		if s.typ != segs[i].typ || s.val != segs[i].val {
+			t.Errorf("segment %d = %+v; want %+v", i, s, segs[i])
+		}
+	}
+	if makeTemplate(nil).len() != 0 {
+		t.Error("makeTemplate(nil) is not empty")
+	}
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	src := largeMap(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(strings.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseBinaryLarge(b *testing.B) {
+	m, err := Parse(strings.NewReader(largeMap(100000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluateLarge(b *testing.B) {
+	m, err := Parse(strings.NewReader(largeMap(100000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Evaluate("section42/intro/page99942.md"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SegmentKind identifies the kind of a template segment.
+type SegmentKind int
+
+const (
+	// LiteralSegment matches its text exactly.
+	LiteralSegment SegmentKind = iota
+	// VariableSegment matches any text and binds it to a variable.
+	VariableSegment
+	// ExtensionSegment matches one of a group of file extensions.
+	ExtensionSegment
+	// CustomSegment matches using a kind registered with WithSegment.
+	CustomSegment
+	// DestSegment is replaced by a destination looked up with the map's
+	// Resolver. It may only be used in output templates.
+	DestSegment
+	// CondSegment is replaced by Then or Else, depending on the value of a
+	// variable. It may only be used in output templates.
+	CondSegment
+)
+
+// A Segment is a node of a template's syntax tree.
+type Segment struct {
+	Kind SegmentKind
+	// Value is the literal text, the variable name without its leading
+	// '$', the custom segment kind, the destination key, or the variable
+	// a conditional tests. It is unused for extension groups.
+	Value string
+	// Extensions are the alternatives of an extension group.
+	Extensions []string
+	// Filters transform the value of a variable in an output, such as
+	// "trimprefix:docs/" or "lower".
+	Filters []string
+	// Op is how a conditional tests its variable: "" for being non-empty,
+	// or "=" or "!=" for comparing it with Operand.
+	Op, Operand string
+	// Then and Else are the parts of a conditional.
+	Then, Else AST
+}
+
+// Literal returns a segment matching s exactly.
+func Literal(s string) Segment {
+	return Segment{Kind: LiteralSegment, Value: s}
+}
+
+// Var returns a segment binding the variable with the given name, without
+// its leading '$'.
+func Var(name string) Segment {
+	return Segment{Kind: VariableSegment, Value: name}
+}
+
+// Ext returns an extension group matching any of exts. An extension ending
+// in '*' matches any extension with that prefix, and an empty extension
+// matches no extension at all.
+func Ext(exts ...string) Segment {
+	return Segment{Kind: ExtensionSegment, Extensions: exts}
+}
+
+// Custom returns a segment of a custom kind registered with WithSegment.
+func Custom(kind string) Segment {
+	return Segment{Kind: CustomSegment, Value: kind}
+}
+
+// Dest returns a segment replaced by the destination for key.
+func Dest(key string) Segment {
+	return Segment{Kind: DestSegment, Value: key}
+}
+
+// Cond returns a conditional which is replaced by then if the variable name
+// compares with operand as op says, or else by els. See Segment.Op.
+func Cond(name, op, operand string, then, els AST) Segment {
+	return Segment{Kind: CondSegment, Value: name, Op: op, Operand: operand, Then: then, Else: els}
+}
+
+// An AST is the syntax tree of a template: a sequence of segments. It can
+// be built programmatically and compiled into a Template.
+type AST []Segment
+
+// String returns the template source for the AST.
+func (a AST) String() string {
+	var b strings.Builder
+	for i, s := range a {
+		switch s.Kind {
+		case LiteralSegment:
+			b.WriteString(s.Value)
+		case VariableSegment:
+			b.WriteString("$" + s.Value)
+			for _, f := range s.Filters {
+				b.WriteString("|" + f)
+			}
+			if len(s.Filters) > 0 && i < len(a)-1 {
+				b.WriteByte('|')
+			}
+		case ExtensionSegment:
+			b.WriteString("{" + strings.Join(s.Extensions, ",") + "}")
+		case CustomSegment:
+			b.WriteString("<" + s.Value + ">")
+		case DestSegment:
+			b.WriteString(destPrefix + s.Value + ")")
+		case CondSegment:
+			b.WriteString("{if:$" + s.Value + s.Op + s.Operand + "}" + s.Then.String())
+			if len(s.Else) > 0 {
+				b.WriteString("{else}" + s.Else.String())
+			}
+			b.WriteString("{end}")
+		}
+	}
+	return b.String()
+}
+
+// Compile checks the AST and returns the equivalent Template. Adjacent
+// literals are merged. Options other than WithSegment have no effect.
+func (a AST) Compile(opts ...Option) (Template, error) {
+	merged, err := a.merge()
+	if err != nil {
+		return Template{}, err
+	}
+	src := merged.String()
+	t, err := ParseTemplate(src, opts...)
+	if err != nil {
+		return Template{}, err
+	}
+	if got := t.AST(); !got.equal(merged) {
+		return Template{}, fmt.Errorf("linkmap: template %q is ambiguous and would parse as %v", src, got)
+	}
+	return t, nil
+}
+
+// merge checks the AST and merges its adjacent literals, including those in
+// conditionals.
+func (a AST) merge() (AST, error) {
+	var merged AST
+	for _, s := range a {
+		if err := s.check(); err != nil {
+			return nil, err
+		}
+		switch s.Kind {
+		case LiteralSegment:
+			if s.Value == "" {
+				continue
+			}
+			if n := len(merged); n > 0 && merged[n-1].Kind == LiteralSegment {
+				merged[n-1].Value += s.Value
+				continue
+			}
+		case CondSegment:
+			var err error
+			if s.Then, err = s.Then.merge(); err != nil {
+				return nil, err
+			}
+			if s.Else, err = s.Else.merge(); err != nil {
+				return nil, err
+			}
+		}
+		merged = append(merged, s)
+	}
+	return merged, nil
+}
+
+func (s Segment) check() error {
+	switch s.Kind {
+	case LiteralSegment:
+		if strings.ContainsAny(s.Value, "${}< \t\n") {
+			return fmt.Errorf("linkmap: literal %q contains reserved characters", s.Value)
+		}
+	case VariableSegment, CustomSegment, DestSegment:
+		if s.Value == "" || strings.ContainsAny(s.Value, "${},<>() \t\n|") {
+			return fmt.Errorf("linkmap: invalid name %q", s.Value)
+		}
+		for _, f := range s.Filters {
+			if s.Kind != VariableSegment {
+				return fmt.Errorf("linkmap: filter %q on a segment which is not a variable", f)
+			}
+			if _, n, _, err := parseFilters("|" + f); err != nil || n != len(f)+1 {
+				return fmt.Errorf("linkmap: invalid filter %q", f)
+			}
+		}
+	case CondSegment:
+		if !validName(s.Value) {
+			return fmt.Errorf("linkmap: invalid name %q", s.Value)
+		}
+		if s.Op != "" && s.Op != "=" && s.Op != "!=" || s.Op == "" && s.Operand != "" {
+			return fmt.Errorf("linkmap: invalid condition %q", s.Op+s.Operand)
+		}
+		if strings.ContainsAny(s.Operand, "${}<>= \t\n") {
+			return fmt.Errorf("linkmap: operand %q contains reserved characters", s.Operand)
+		}
+	case ExtensionSegment:
+		if len(s.Extensions) == 0 {
+			return fmt.Errorf("linkmap: empty extension group")
+		}
+		for _, ext := range s.Extensions {
+			if strings.ContainsAny(ext, "${},<> \t\n") || strings.Contains(strings.TrimSuffix(ext, "*"), "*") {
+				return fmt.Errorf("linkmap: invalid extension %q", ext)
+			}
+		}
+	default:
+		return fmt.Errorf("linkmap: unknown segment kind %d", s.Kind)
+	}
+	return nil
+}
+
+func (a AST) equal(b AST) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Kind != b[i].Kind || a[i].Value != b[i].Value ||
+			strings.Join(a[i].Extensions, ",") != strings.Join(b[i].Extensions, ",") ||
+			strings.Join(a[i].Filters, "|") != strings.Join(b[i].Filters, "|") ||
+			a[i].Op != b[i].Op || a[i].Operand != b[i].Operand ||
+			!a[i].Then.equal(b[i].Then) || !a[i].Else.equal(b[i].Else) {
+			return false
+		}
+	}
+	return true
+}
+
+// AST returns the template's syntax tree.
+func (t Template) AST() AST {
+	a := make(AST, t.segs.len())
+	for i, s := range t.segs.segments() {
+		switch s.typ {
+		case segmentTypeString:
+			a[i] = Literal(s.val)
+		case segmentTypeVariable:
+			a[i] = Var(s.name())
+			for _, f := range s.filters {
+				a[i].Filters = append(a[i].Filters, f.String())
+			}
+		case segmentTypeExtension:
+			a[i] = Ext(s.extensions()...)
+		case segmentTypeCustom:
+			a[i] = Custom(s.name())
+		case segmentTypeDest:
+			a[i] = Dest(s.destKey())
+		case segmentTypeCond:
+			c := s.cond
+			a[i] = Cond(c.name, c.op, c.value, Template{segs: c.then}.AST(), Template{segs: c.els}.AST())
+		}
+	}
+	return a
+}
@@ -0,0 +1,51 @@
+package linkmap
+
+import "testing"
+
+func TestASTCompile(t *testing.T) {
+	cases := []struct {
+		ast     AST
+		expect  string
+		wantErr bool
+	}{
+		{
+			ast:    AST{Literal("foo/"), Literal("posts/"), Var("1"), Literal("."), Ext("md", "mdx")},
+			expect: "foo/posts/$1.{md,mdx}",
+		},
+		{
+			ast:    AST{Literal("https://example.com/"), Var("1"), Literal("/"), Var("2")},
+			expect: "https://example.com/$1/$2",
+		},
+		{ast: AST{Literal("a$b")}, wantErr: true},
+		{ast: AST{Var("1"), Var("2")}, wantErr: true},
+		{ast: AST{Var("1"), Literal("2.md")}, wantErr: true},
+		{ast: AST{Ext()}, wantErr: true},
+		{ast: AST{Custom("uuid")}, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := c.ast.Compile()
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("Compile(%v) = %q; want error", c.ast, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Compile(%v) error: %v", c.ast, err)
+		} else if got.String() != c.expect {
+			t.Errorf("Compile(%v) = %q; want %q", c.ast, got, c.expect)
+		}
+	}
+}
+
+func TestASTRoundTrip(t *testing.T) {
+	for _, src := range []string{"foo/posts/$1.{md,mdx}", "foo/$1/bar/$2.{html}", "https://example.com/posts/$1"} {
+		tmpl, err := ParseTemplate(src)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) error: %v", src, err)
+		}
+		if got := tmpl.AST().String(); got != src {
+			t.Errorf("AST().String() = %q; want %q", got, src)
+		}
+	}
+}
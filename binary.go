@@ -0,0 +1,476 @@
+package linkmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BinaryExt is the conventional extension of a map compiled with
+// MarshalBinary.
+const BinaryExt = ".linkmap.bin"
+
+// binaryMagic begins every compiled map.
+var binaryMagic = []byte("linkmap\x00")
+
+// binaryVersion is the version of the compiled format. It changes whenever
+// the encoding does; ParseBinary rejects other versions, so that compiled
+// maps are rebuilt rather than misread.
+//
+// A compiled map is the magic, the version, and the length of a header
+// followed by the header and its CRC-32. The header holds the number of
+// rules and a bucket for each literal first path element of rule inputs,
+// plus one, with the empty key, for the other rules. Each bucket records
+// its key and the offset, length and CRC-32 of its body. The bodies
+// follow the header; a body holds the rules of its bucket, each with its
+// index in evaluation order. Buckets can thus be checked and decoded one
+// at a time, as LoadBinary does.
+const binaryVersion = 5
+
+// Kinds of templates in the compiled format.
+const (
+	binarySegments = iota
+	binaryText
+	binaryRegexp
+	// binaryPrefix is a template of segments ending in '*'.
+	binaryPrefix
+)
+
+// ErrBinaryFormat is returned, wrapped, for data which is not a compiled
+// map of the supported version, or which is corrupt.
+var ErrBinaryFormat = errors.New("linkmap: invalid compiled map")
+
+// IsBinary reports whether data begins like a compiled map.
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, binaryMagic)
+}
+
+// A binaryBucket locates the rules of a bucket in a compiled map.
+type binaryBucket struct {
+	key    string
+	offset uint64
+	length uint64
+	sum    uint32
+}
+
+// bucketKey returns the bucket of rules with input t: the literal first
+// path element of the input, or "" if it has none.
+func bucketKey(t Template) string {
+	if t.re != nil || t.segs.len() == 0 || t.segs.at(0).typ != segmentTypeString {
+		return ""
+	}
+	first := t.segs.at(0).val
+	if i := strings.IndexByte(first, '/'); i >= 0 {
+		return first[:i]
+	}
+	if t.segs.len() == 1 {
+		return first
+	}
+	return ""
+}
+
+// pathKey returns the bucket whose rules, besides those of the "" bucket,
+// may match the path p.
+func pathKey(p string) string {
+	if i := strings.IndexByte(p, '/'); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. It compiles the map's
+// rules, in evaluation order, to a compact form which ParseBinary and
+// LoadBinary load without parsing templates, for services whose maps are
+// large enough for parsing to slow their startup. The data carries a
+// format version and CRC-32 checksums. Options are not included.
+func (m *Map) MarshalBinary() ([]byte, error) {
+	bodies := make(map[string]*binaryEncoder)
+	for i, r := range m.rules {
+		key := bucketKey(r.Input)
+		e := bodies[key]
+		if e == nil {
+			e = &binaryEncoder{}
+			bodies[key] = e
+		}
+		e.uint(uint64(i))
+		e.rule(r)
+	}
+	keys := make([]string, 0, len(bodies))
+	for k := range bodies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var header, body binaryEncoder
+	header.uint(uint64(len(m.rules)))
+	header.uint(uint64(len(keys)))
+	for _, k := range keys {
+		b := bodies[k].buf.Bytes()
+		header.string(k)
+		header.uint(uint64(body.buf.Len()))
+		header.uint(uint64(len(b)))
+		header.uint(uint64(crc32.ChecksumIEEE(b)))
+		body.buf.Write(b)
+	}
+	var out binaryEncoder
+	out.buf.Write(binaryMagic)
+	out.uint(binaryVersion)
+	out.uint(uint64(header.buf.Len()))
+	out.buf.Write(header.buf.Bytes())
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(header.buf.Bytes()))
+	out.buf.Write(sum[:])
+	out.buf.Write(body.buf.Bytes())
+	return out.buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing m with
+// the map compiled in data. Use ParseBinary to pass options.
+func (m *Map) UnmarshalBinary(data []byte) error {
+	p, err := ParseBinary(data)
+	if err != nil {
+		return err
+	}
+	*m = *p
+	return nil
+}
+
+// ParseBinary loads a map compiled by MarshalBinary. Options should match
+// those the map was parsed with; custom segment kinds used by its rules
+// must be registered with WithSegment. Rules keep the order they were
+// compiled in.
+func ParseBinary(data []byte, opts ...Option) (*Map, error) {
+	m := newMap(opts)
+	h, err := readBinaryHeader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]Rule, h.rules)
+	seen := make([]bool, h.rules)
+	for _, b := range h.buckets {
+		body := data[h.bodies+int64(b.offset):][:b.length]
+		if err := m.decodeBucket(b, body, func(i int, r Rule) error {
+			if i >= len(rules) || seen[i] {
+				return fmt.Errorf("%w: bad rule index", ErrBinaryFormat)
+			}
+			rules[i], seen[i] = r, true
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for i := range seen {
+		if !seen[i] {
+			return nil, fmt.Errorf("%w: missing rule %d", ErrBinaryFormat, i)
+		}
+	}
+	for i := range rules {
+		if err := m.limits.checkRule(i+1, rules[i]); err != nil {
+			return nil, err
+		}
+	}
+	m.setCompiledRules(rules)
+	return m, nil
+}
+
+// setCompiledRules sets the map's rules, which are in evaluation order.
+func (m *Map) setCompiledRules(rules []Rule) {
+	m.rules = rules
+	if m.counting {
+		m.counters = make([]uint64, len(rules))
+	}
+	if m.prefiltering {
+		m.prefilter = newPrefilter(rules)
+	}
+}
+
+// A binaryHeader is the decoded header of a compiled map.
+type binaryHeader struct {
+	rules   int
+	buckets []binaryBucket
+	// bodies is the offset of the first bucket body.
+	bodies int64
+}
+
+// readBinaryHeader reads and checks the header of a compiled map of the
+// given size.
+func readBinaryHeader(r io.ReaderAt, size int64) (*binaryHeader, error) {
+	// The magic, and two varints of at most ten bytes.
+	prefix := make([]byte, len(binaryMagic)+2*binary.MaxVarintLen64)
+	n, _ := r.ReadAt(prefix, 0)
+	prefix = prefix[:n]
+	if !IsBinary(prefix) {
+		return nil, fmt.Errorf("%w: missing header", ErrBinaryFormat)
+	}
+	d := binaryDecoder{data: prefix[len(binaryMagic):]}
+	if v := d.uint(); d.err == nil && v != binaryVersion {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrBinaryFormat, v, binaryVersion)
+	}
+	hlen := d.uint()
+	start := int64(n - len(d.data))
+	if d.err != nil || size-start < 4 || hlen > uint64(size-start-4) {
+		return nil, fmt.Errorf("%w: missing header", ErrBinaryFormat)
+	}
+	buf := make([]byte, hlen+4)
+	if _, err := r.ReadAt(buf, start); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBinaryFormat, err)
+	}
+	if crc32.ChecksumIEEE(buf[:hlen]) != binary.BigEndian.Uint32(buf[hlen:]) {
+		return nil, fmt.Errorf("%w: header checksum mismatch", ErrBinaryFormat)
+	}
+	h := &binaryHeader{bodies: start + int64(len(buf))}
+	d = binaryDecoder{data: buf[:hlen], text: string(buf[:hlen])}
+	rules, buckets := d.uint(), d.uint()
+	if rules > uint64(size) || buckets > hlen {
+		d.fail("header")
+	}
+	h.rules = int(rules)
+	for i := uint64(0); i < buckets && d.err == nil; i++ {
+		b := binaryBucket{key: d.string(), offset: d.uint(), length: d.uint(), sum: uint32(d.uint())}
+		if b.offset > uint64(size-h.bodies) || b.length > uint64(size-h.bodies)-b.offset {
+			d.fail("bucket")
+		}
+		h.buckets = append(h.buckets, b)
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("header")
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return h, nil
+}
+
+// decodeBucket checks the body of bucket b and passes each of its rules,
+// with its index in evaluation order, to add.
+func (m *Map) decodeBucket(b binaryBucket, body []byte, add func(int, Rule) error) error {
+	if crc32.ChecksumIEEE(body) != b.sum {
+		return fmt.Errorf("%w: checksum mismatch in bucket %q", ErrBinaryFormat, b.key)
+	}
+	if uint64(len(body)) > math.MaxUint32 {
+		// Templates locate their segments in body with 32-bit offsets.
+		return fmt.Errorf("%w: bucket %q is too large", ErrBinaryFormat, b.key)
+	}
+	d := binaryDecoder{data: body, text: string(body), kinds: m.kinds, tmpl: m.tmplOutputs, arena: &segmentArena{}}
+	for len(d.data) > 0 && d.err == nil {
+		i := d.uint()
+		r := d.rule()
+		if d.err == nil {
+			if err := add(int(i), r); err != nil {
+				return err
+			}
+		}
+	}
+	return d.err
+}
+
+type binaryEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *binaryEncoder) uint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (e *binaryEncoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *binaryEncoder) rule(r Rule) {
+	e.template(r.Input)
+	e.template(r.Output)
+	e.uint(uint64(r.Line))
+	e.string(r.Source)
+	e.uint(uint64(r.Status))
+	e.string(r.CacheControl)
+	e.string(r.Description)
+	e.uint(uint64(len(r.Conditions)))
+	for _, c := range r.Conditions {
+		e.string(c.Var)
+		e.string(c.Pattern)
+	}
+	e.uint(uint64(len(r.Weighted)))
+	for _, w := range r.Weighted {
+		e.template(w.Output)
+		e.uint(uint64(w.Weight))
+	}
+	e.uint(uint64(len(r.Scheduled)))
+	for _, s := range r.Scheduled {
+		e.string(s.At.Format(time.RFC3339Nano))
+		e.template(s.Output)
+	}
+}
+
+func (e *binaryEncoder) template(t Template) {
+	switch {
+	case t.text != nil:
+		e.uint(binaryText)
+		e.string(t.text.src)
+	case t.re != nil:
+		e.uint(binaryRegexp)
+		e.string(t.re.src)
+	default:
+		if t.prefix {
+			e.uint(binaryPrefix)
+		} else {
+			e.uint(binarySegments)
+		}
+		e.uint(uint64(t.segs.len()))
+		for _, r := range t.segs.refs {
+			e.uint(uint64(r.typ))
+			e.string(t.segs.src[r.start:r.end])
+		}
+	}
+}
+
+// A binaryDecoder reads a compiled map. After the first error, its methods
+// return zero values.
+type binaryDecoder struct {
+	data []byte
+	// text is the whole of data as a string, which decoded strings are
+	// substrings of and the backing string of decoded templates, and
+	// arena holds their tables.
+	text  string
+	kinds map[string]SegmentMatcher
+	// tmpl accepts text/template outputs.
+	tmpl  bool
+	arena *segmentArena
+	err   error
+}
+
+func (d *binaryDecoder) fail(what string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: bad %s", ErrBinaryFormat, what)
+	}
+}
+
+func (d *binaryDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("integer")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *binaryDecoder) string() string {
+	start, end := d.span()
+	return d.text[start:end]
+}
+
+// span reads a string, returning its offsets in d.text.
+func (d *binaryDecoder) span() (start, end int) {
+	n := d.uint()
+	if d.err != nil {
+		return 0, 0
+	}
+	if n > uint64(len(d.data)) {
+		d.fail("string")
+		return 0, 0
+	}
+	start = len(d.text) - len(d.data)
+	d.data = d.data[n:]
+	return start, start + int(n)
+}
+
+func (d *binaryDecoder) rule() Rule {
+	r := Rule{Input: d.template(), Output: d.template()}
+	r.Line = int(d.uint())
+	r.Source = d.string()
+	r.Status = int(d.uint())
+	r.CacheControl = d.string()
+	r.Description = d.string()
+	conds := d.uint()
+	for j := uint64(0); j < conds && d.err == nil; j++ {
+		r.Conditions = append(r.Conditions, Condition{Var: d.string(), Pattern: d.string()})
+	}
+	weighted := d.uint()
+	for j := uint64(0); j < weighted && d.err == nil; j++ {
+		r.Weighted = append(r.Weighted, WeightedOutput{Output: d.template(), Weight: int(d.uint())})
+	}
+	scheduled := d.uint()
+	for j := uint64(0); j < scheduled && d.err == nil; j++ {
+		at, err := time.Parse(time.RFC3339Nano, d.string())
+		if err != nil {
+			d.fail("cutover time")
+		}
+		r.Scheduled = append(r.Scheduled, ScheduledOutput{At: at, Output: d.template()})
+	}
+	return r
+}
+
+func (d *binaryDecoder) template() Template {
+	switch kind := d.uint(); kind {
+	case binaryText:
+		src := d.string()
+		if !d.tmpl && d.err == nil {
+			d.err = fmt.Errorf("linkmap: %v", errTextDisabled)
+		}
+		text, err := parseText(src)
+		if err != nil && d.err == nil {
+			d.err = fmt.Errorf("%w: %v", ErrBinaryFormat, err)
+		}
+		return Template{text: text}
+	case binaryRegexp:
+		re, err := parseRegexpInput(d.string())
+		if err != nil && d.err == nil {
+			d.err = fmt.Errorf("%w: %v", ErrBinaryFormat, err)
+		}
+		return Template{re: re}
+	case binarySegments, binaryPrefix:
+		n := d.uint()
+		if n > uint64(len(d.data)) {
+			d.fail("segment count")
+		}
+		var buf [8]segRef
+		t := tables{refs: buf[:0]}
+		for i := uint64(0); i < n && d.err == nil; i++ {
+			typ := d.uint()
+			start, end := d.span()
+			s := segment{typ: segmentType(typ), val: d.text[start:end]}
+			switch {
+			case typ > uint64(segmentTypeCond) || s.typ != segmentTypeString && len(s.val) < 2:
+				d.fail("segment")
+			case s.typ == segmentTypeCustom:
+				kind := s.name()
+				if s.matcher = d.kinds[kind]; s.matcher == nil && d.err == nil {
+					d.err = fmt.Errorf("linkmap: unknown segment kind %q", kind)
+				}
+			case s.typ == segmentTypeVariable && strings.IndexByte(s.val, '|') >= 0:
+				filters, n, _, err := parseFilters(s.val[strings.IndexByte(s.val, '|'):])
+				if err != nil || len(filters) == 0 || strings.IndexByte(s.val, '|')+n != len(s.val) {
+					d.fail("filters")
+				}
+				s.filters = filters
+			case s.typ == segmentTypeCond:
+				c, err := parseTemplateIn(s.val, d.kinds, d.arena)
+				if err != nil || c.len() != 1 || c.at(0).typ != segmentTypeCond {
+					d.fail("conditional")
+				} else {
+					s.cond = c.at(0).cond
+				}
+			}
+			if s.matcher != nil || s.filters != nil || s.cond != nil {
+				t = t.addExt(s.typ, start, end, segExt{matcher: s.matcher, filters: s.filters, cond: s.cond})
+			} else {
+				t = t.add(s.typ, start, end)
+			}
+		}
+		return Template{segs: d.arena.alloc(d.text, t.refs, t.ext), prefix: kind == binaryPrefix}
+	default:
+		d.fail("template kind")
+		return Template{}
+	}
+}
@@ -0,0 +1,75 @@
+package linkmap
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	upper := RegexpSegment(regexp.MustCompile(`[A-Z]+`))
+	const src = `[[rule]]
+from = "docs/$slug.{md,mdx}"
+to = "https://example.com/$slug"
+status = 301
+cache_control = "public"
+description = "Docs"
+conditions = { slug = "[a-z]*" }
+
+[[rule]]
+from = "codes/<upper>.txt"
+to = "$dest(codes)/$upper"
+
+[[rule]]
+from = "re:blog/([0-9]+)/(.+)\\.md"
+to = "tmpl:https://example.com/{{index . \"2\"}}?y={{index . \"1\"}}"
+`
+	opts := []Option{WithSegment("upper", upper), WithResolver(MapResolver{"codes": "https://codes.example.com"}), WithTmplOutputs()}
+	m, err := ParseTOML(strings.NewReader(src), append(opts, WithSource("redirects.toml"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsBinary(data) {
+		t.Error("IsBinary() = false")
+	}
+	got, err := ParseBinary(data, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash() != m.Hash() {
+		t.Error("ParseBinary() changed the rules")
+	}
+	for i, r := range got.Rules() {
+		want := m.rules[i]
+		if r.Line != want.Line || r.Source != want.Source || r.Description != want.Description {
+			t.Errorf("rule %d = %+v; want %+v", i, r, want)
+		}
+	}
+	for _, p := range []string{"docs/intro.mdx", "docs/Intro.md", "codes/ABC.txt", "codes/abc.txt", "blog/2024/hello.md"} {
+		want, wantErr := m.Evaluate(p)
+		if link, err := got.Evaluate(p); link != want || (err == nil) != (wantErr == nil) {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, %v", p, link, err, want, wantErr)
+		}
+	}
+
+	if _, err := ParseBinary(data, WithTmplOutputs()); err == nil || !strings.Contains(err.Error(), "unknown segment kind") {
+		t.Errorf("ParseBinary() without the segment kind: %v", err)
+	}
+	var um Map
+	if err := um.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("UnmarshalBinary(truncated) = %v; want ErrBinaryFormat", err)
+	}
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(binaryMagic)+3] ^= 0xff
+	if _, err := ParseBinary(corrupt, opts...); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("ParseBinary(corrupt) = %v; want ErrBinaryFormat", err)
+	}
+	if _, err := ParseBinary([]byte("docs/$1 x/$1\n")); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("ParseBinary(text) = %v; want ErrBinaryFormat", err)
+	}
+}
@@ -0,0 +1,118 @@
+package linkmap
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EvaluateInto is like Map.EvaluateVars but binds the variables captured
+// from fpath to the fields of a T, which must be a struct, as BindVars
+// does:
+//
+//	type PostVars struct {
+//		Slug string
+//		Lang string
+//		Year int `linkmap:"1"`
+//	}
+//	link, vars, err := linkmap.EvaluateInto[PostVars](m, "blog/2024/hello.fr.md")
+func EvaluateInto[T any](m *Map, fpath string) (link string, vars T, err error) {
+	res := m.Lookup(fpath)
+	if res.Err != nil {
+		return "", vars, res.Err
+	}
+	if err := BindVars(res.Vars, &vars); err != nil {
+		return res.Link, vars, err
+	}
+	return res.Link, vars, nil
+}
+
+// BindVars sets the fields of the struct v points to from vars, as
+// returned by Map.EvaluateVars. A field is set from the variable named by
+// its `linkmap:"name"` tag, or else from the variable whose name equals
+// the field's ignoring case; fields tagged `linkmap:"-"`, unexported
+// fields and fields without a variable are left alone. Fields may be
+// strings, booleans, integers, floats or implement
+// encoding.TextUnmarshaler.
+func BindVars(vars map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("linkmap: cannot bind variables to %T; want a pointer to a struct", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, tagged := f.Tag.Lookup("linkmap")
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			name = lookupFold(vars, f.Name)
+		}
+		val, ok := vars[name]
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(i), val); err != nil {
+			return fmt.Errorf("linkmap: binding $%s to field %s: %w", name, f.Name, err)
+		}
+	}
+	return nil
+}
+
+// lookupFold returns the name of the variable which equals name ignoring
+// case, or name if there is none.
+func lookupFold(vars map[string]string, name string) string {
+	if _, ok := vars[name]; ok {
+		return name
+	}
+	for k := range vars {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
+
+func setField(f reflect.Value, s string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type lang string
+
+func (l *lang) UnmarshalText(text []byte) error {
+	*l = lang(strings.ToUpper(string(text)))
+	return nil
+}
+
+func TestEvaluateInto(t *testing.T) {
+	m, err := Parse(strings.NewReader("blog/$1/$slug.$lang.md https://example.com/$lang/$1/$slug\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type PostVars struct {
+		Slug    string
+		Lang    lang
+		Year    int    `linkmap:"1"`
+		Ignored string `linkmap:"-"`
+		Missing bool
+		hidden  string
+	}
+	link, vars, err := EvaluateInto[PostVars](m, "blog/2024/hello.fr.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PostVars{Slug: "hello", Lang: "FR", Year: 2024}
+	if link != "https://example.com/fr/2024/hello" || vars != want {
+		t.Errorf("EvaluateInto() = %q, %+v; want %+v", link, vars, want)
+	}
+	if _, _, err := EvaluateInto[PostVars](m, "blog/next/hello.fr.md"); err == nil || !strings.Contains(err.Error(), "$1 to field Year") {
+		t.Errorf("EvaluateInto() with a bad year: %v", err)
+	}
+	if _, _, err := EvaluateInto[PostVars](m, "other.md"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("EvaluateInto() of a miss = %v; want ErrNoMatches", err)
+	}
+	if _, _, err := EvaluateInto[string](m, "blog/2024/hello.fr.md"); err == nil {
+		t.Error("EvaluateInto[string]() succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package linkmap
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithBudget limits the work done by a single evaluation: at most maxRules
+// rules are tried, and evaluation stops once timeout has elapsed. When
+// either limit is reached before a rule matches, the evaluation fails with
+// a *BudgetError. Zero disables the corresponding limit.
+//
+// The timeout is checked after the first rule, after every 16 rules, and
+// once every rule has been tried. The evaluation of a single rule cannot be
+// interrupted, so an evaluation may overrun timeout by the time taken by
+// the rules tried since the last check.
+func WithBudget(maxRules int, timeout time.Duration) Option {
+	return func(m *Map) {
+		m.maxRules = maxRules
+		m.timeout = timeout
+	}
+}
+
+// A BudgetError is returned when an evaluation exceeds the budget set with
+// WithBudget.
+type BudgetError struct {
+	Path string
+	// Tried is the number of rules tried.
+	Tried int
+	// Elapsed is the time spent evaluating, if a timeout was set.
+	Elapsed time.Duration
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("linkmap: evaluation budget exceeded for %q after trying %d rules", e.Path, e.Tried)
+}
+
+// budget tracks the work done by one evaluation.
+type budget struct {
+	maxRules int
+	start    time.Time
+	deadline time.Time
+}
+
+func (m *Map) budget() budget {
+	b := budget{maxRules: m.maxRules}
+	if m.timeout > 0 {
+		b.start = time.Now()
+		b.deadline = b.start.Add(m.timeout)
+	}
+	return b
+}
+
+// check returns an error if trying another rule, having already tried
+// tried rules, would exceed the budget.
+func (b budget) check(fpath string, tried int) error {
+	if b.maxRules > 0 && tried >= b.maxRules {
+		return &BudgetError{Path: fpath, Tried: tried}
+	}
+	// Reading the clock is relatively expensive, so only do it now and then.
+	if tried == 1 || tried%16 == 15 {
+		return b.expired(fpath, tried)
+	}
+	return nil
+}
+
+// expired returns an error if the timeout has elapsed, having tried tried
+// rules.
+func (b budget) expired(fpath string, tried int) error {
+	if b.deadline.IsZero() {
+		return nil
+	}
+	if now := time.Now(); now.After(b.deadline) {
+		return &BudgetError{Path: fpath, Tried: tried, Elapsed: now.Sub(b.start)}
+	}
+	return nil
+}
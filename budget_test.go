@@ -0,0 +1,57 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetMaxRules(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&b, "dir%d/$1 https://example.com/%d/$1\n", i, i)
+	}
+	m, err := Parse(strings.NewReader(b.String()), WithBudget(5, 0))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	var be *BudgetError
+	if _, err := m.Evaluate("nope"); !errors.As(err, &be) || be.Tried != 5 {
+		t.Errorf("Evaluate(miss) error = %v; want BudgetError after 5 rules", err)
+	}
+	third := strings.TrimSuffix(m.Rules()[2].Input.String(), "$1") + "x"
+	if _, err := m.Evaluate(third); err != nil {
+		t.Errorf("Evaluate(%q) error: %v", third, err)
+	}
+}
+
+func TestBudgetTimeout(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "dir%d/$1 https://example.com/%d/$1\n", i, i)
+	}
+	m, err := Parse(strings.NewReader(b.String()), WithBudget(0, time.Nanosecond))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	var be *BudgetError
+	if _, err := m.Evaluate("nope"); !errors.As(err, &be) {
+		t.Errorf("Evaluate() error = %v; want BudgetError", err)
+	}
+}
+
+func TestBudgetTimeoutFewRules(t *testing.T) {
+	m, err := Parse(strings.NewReader("a/$1 https://example.com/$1\n"), WithBudget(0, time.Nanosecond))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	var be *BudgetError
+	if _, err := m.Evaluate("nope"); !errors.As(err, &be) || be.Tried != 1 {
+		t.Errorf("Evaluate() error = %v; want BudgetError after 1 rule", err)
+	}
+	if res := m.EvaluateAll("nope"); len(res) != 1 || !errors.As(res[0].Err, &be) {
+		t.Errorf("EvaluateAll() = %v; want a BudgetError", res)
+	}
+}
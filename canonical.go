@@ -0,0 +1,105 @@
+package linkmap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Canonicalization is a set of rewrites applied to paths before they are
+// checked against the path model and matched, so that loosely formed
+// paths, such as those taken from request URLs, hit the rules they should.
+// The rewrites are applied in the order of the constants below.
+type Canonicalization uint
+
+const (
+	// StripQuery removes a query or fragment, beginning with '?' or '#'.
+	StripQuery Canonicalization = 1 << iota
+	// DecodePercent decodes percent-encoded bytes, such as "%20". A path
+	// with an invalid encoding is rejected.
+	DecodePercent
+	// CollapseSlashes collapses runs of slashes into one and removes
+	// leading and trailing slashes, so that "//docs//intro.md" is
+	// evaluated as "docs/intro.md".
+	CollapseSlashes
+)
+
+var canonicalizationNames = []struct {
+	c    Canonicalization
+	name string
+}{
+	{StripQuery, "query"},
+	{DecodePercent, "percent"},
+	{CollapseSlashes, "slashes"},
+}
+
+// ParseCanonicalization parses a comma-separated list of the names query,
+// percent and slashes, as returned by Canonicalization.String.
+func ParseCanonicalization(s string) (Canonicalization, error) {
+	var c Canonicalization
+	if s == "" {
+		return 0, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		found := false
+		for _, n := range canonicalizationNames {
+			if strings.TrimSpace(name) == n.name {
+				c |= n.c
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("linkmap: unknown canonicalization %q", name)
+		}
+	}
+	return c, nil
+}
+
+// String returns the names of the rewrites in c, separated by commas.
+func (c Canonicalization) String() string {
+	var names []string
+	for _, n := range canonicalizationNames {
+		if c&n.c != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// WithCanonicalization rewrites every path evaluated by the map as c
+// describes. The rewritten path is still checked against the path model,
+// so that, for example, a decoded "%2E%2E" is subject to the map's
+// TraversalPolicy. Results carry the path as passed in.
+func WithCanonicalization(c Canonicalization) Option {
+	return func(m *Map) {
+		m.canonical = c
+	}
+}
+
+// canonicalize applies the map's Canonicalization to p.
+func (m *Map) canonicalize(p string) (string, error) {
+	c := m.canonical
+	if c&StripQuery != 0 {
+		if i := strings.IndexAny(p, "?#"); i >= 0 {
+			p = p[:i]
+		}
+	}
+	if c&DecodePercent != 0 {
+		d, err := url.PathUnescape(p)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q: %v", ErrInvalidPath, p, err)
+		}
+		p = d
+	}
+	if c&CollapseSlashes != 0 {
+		elems := strings.Split(p, "/")
+		kept := elems[:0]
+		for _, e := range elems {
+			if e != "" {
+				kept = append(kept, e)
+			}
+		}
+		p = strings.Join(kept, "/")
+	}
+	return p, nil
+}
@@ -0,0 +1,54 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalization(t *testing.T) {
+	const src = "docs/$1.md https://example.com/$1\n"
+	all := StripQuery | DecodePercent | CollapseSlashes
+	cases := []struct {
+		c      Canonicalization
+		path   string
+		expect string
+	}{
+		{0, "//docs//intro.md", ""},
+		{CollapseSlashes, "//docs//intro.md", "https://example.com/intro"},
+		{CollapseSlashes, "docs/intro.md/", "https://example.com/intro"},
+		{StripQuery, "docs/intro.md?utm=x#top", "https://example.com/intro"},
+		{DecodePercent, "docs/getting%20started.md", "https://example.com/getting started"},
+		{DecodePercent, "docs/%zz.md", ""},
+		{DecodePercent, "docs/%2E%2E/secret.md", ""},
+		{DecodePercent, "docs/a%3Fb.md", "https://example.com/a?b"},
+		{all, "/docs//a%3Fb.md?x", "https://example.com/a?b"},
+		{all, "docs%2F%2Fintro.md", "https://example.com/intro"},
+	}
+	for _, c := range cases {
+		m, err := Parse(strings.NewReader(src), WithCanonicalization(c.c))
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		res := m.Lookup(c.path)
+		if c.expect == "" {
+			if !errors.Is(res.Err, ErrInvalidPath) {
+				t.Errorf("%v: Lookup(%q) = %q, %v; want ErrInvalidPath", c.c, c.path, res.Link, res.Err)
+			}
+			continue
+		}
+		if res.Err != nil || res.Link != c.expect || res.Path != c.path {
+			t.Errorf("%v: Lookup(%q) = %q, %q, %v; want %q", c.c, c.path, res.Path, res.Link, res.Err, c.expect)
+		}
+	}
+}
+
+func TestParseCanonicalization(t *testing.T) {
+	c, err := ParseCanonicalization("slashes, query")
+	if err != nil || c != CollapseSlashes|StripQuery || c.String() != "query,slashes" {
+		t.Errorf("ParseCanonicalization() = %v, %v", c, err)
+	}
+	if _, err := ParseCanonicalization("case"); err == nil {
+		t.Error("ParseCanonicalization(case) succeeded")
+	}
+}
@@ -0,0 +1,58 @@
+package linkmap
+
+import "errors"
+
+// A ChainedMap evaluates a path through several maps in turn, feeding the
+// link built by each map to the next as its path, for staged
+// transformations such as repository path to site path to CDN URL. A map
+// which matches nothing passes its path on unchanged. Links passed on must
+// be valid paths for the next map (see NormalizePath).
+type ChainedMap struct {
+	maps []*Map
+}
+
+// Chain returns a ChainedMap evaluating paths through maps, in order.
+func Chain(maps ...*Map) *ChainedMap {
+	return &ChainedMap{maps: append([]*Map(nil), maps...)}
+}
+
+// Stages evaluates fpath through each map, returning the Result of each
+// map which was tried. Evaluation stops at the first error other than
+// ErrNoMatches, which is the last Result.
+func (c *ChainedMap) Stages(fpath string) []Result {
+	results := make([]Result, 0, len(c.maps))
+	p := fpath
+	for _, m := range c.maps {
+		res := m.Lookup(p)
+		results = append(results, res)
+		switch {
+		case res.Err == nil:
+			p = res.Link
+		case !errors.Is(res.Err, ErrNoMatches):
+			return results
+		}
+	}
+	return results
+}
+
+// Lookup evaluates fpath through the maps. The Result's Link is the link
+// built by the last map which matched, and its Rule and Vars are those of
+// that map's match; its Path is fpath as passed in. If no map matched, the
+// error wraps ErrNoMatches. If a map fails otherwise, its error is
+// returned.
+func (c *ChainedMap) Lookup(fpath string) Result {
+	last := Result{Path: fpath, Err: ErrNoMatches}
+	for _, res := range c.Stages(fpath) {
+		if !errors.Is(res.Err, ErrNoMatches) {
+			last = res
+		}
+	}
+	last.Path = fpath
+	return last
+}
+
+// Evaluate is like Lookup but returns only the link.
+func (c *ChainedMap) Evaluate(fpath string) (string, error) {
+	res := c.Lookup(fpath)
+	return res.Link, res.Err
+}
@@ -0,0 +1,46 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	parse := func(src string) *Map {
+		t.Helper()
+		m, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	c := Chain(
+		parse("content/$1.md site/$1\n"),
+		parse("site/blog/$1 blog/$1\n"),
+		parse("site/$1 https://cdn.example.com/$1\nblog/$1 https://blog.example.com/$1\n"),
+	)
+	tests := []struct {
+		path, link string
+		stages     int
+	}{
+		{"content/docs/intro.md", "https://cdn.example.com/docs/intro", 3},
+		{"content/blog/hello.md", "https://blog.example.com/hello", 3},
+		{"blog/direct", "https://blog.example.com/direct", 3},
+	}
+	for _, tt := range tests {
+		if link, err := c.Evaluate(tt.path); link != tt.link || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.path, link, err, tt.link)
+		}
+		if n := len(c.Stages(tt.path)); n != tt.stages {
+			t.Errorf("Stages(%q) has %d results; want %d", tt.path, n, tt.stages)
+		}
+	}
+	if res := c.Lookup("other/x"); !errors.Is(res.Err, ErrNoMatches) || res.Path != "other/x" {
+		t.Errorf("Lookup(other/x) = %+v; want ErrNoMatches", res)
+	}
+	c = Chain(parse("a/$1 /abs/$1\n"), parse("abs/$1 https://example.com/$1\n"))
+	if _, err := c.Evaluate("a/x"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Evaluate(a/x) = %v; want ErrInvalidPath", err)
+	}
+}
@@ -0,0 +1,221 @@
+// Command linkmap-server serves the linkmap evaluation API over HTTP.
+//
+// Each map is read from a file in the directory given by -dir, named after
+// its key with a ".linkmap" extension, ".toml" for the TOML format, or
+// ".linkmap.bin" for a map compiled with linkmap compile, which loads
+// fastest.
+// Maps are loaded on first use and reloaded with POST /reload on the admin
+// address given by -admin-addr, which also serves /sandbox, /divergences
+// and /debug, and must not be public. With
+// -redirect-addr, the server also redirects requests on that address,
+// evaluating their paths against the map named by -redirect-map;
+// -preserve-query carries query parameters over to the links, and requests
+// which are not redirected are proxied to -upstream, if set, so that the
+// server can sit in front of an existing site. -cache-permanent sets the
+// Cache-Control header of permanent redirects whose rules set none, and
+// -hsts adds a Strict-Transport-Security header to every redirect.
+//
+// With -shadow-dir, every evaluation is also compared with the map of the
+// same key in that directory, if any, serving the live result and logging
+// divergences to stderr; GET /divergences reports the recent ones. This
+// validates a large change to a map on real traffic before it goes live.
+//
+// With -log-format, every evaluation is logged to stderr, as text or as
+// JSON lines for log pipelines. With -audit-file, evaluations are also
+// appended to an audit log as JSON lines, sampled by -audit-sample.
+//
+// -rate limits the requests per second of each client IP address, and
+// -block-agents rejects user agents containing any of a comma-separated
+// list of substrings. -allow-hosts rejects links to hosts not in a
+// comma-separated list, and links whose host comes from a path otherwise
+// (see linkmap.Allowlist). -canonicalize rewrites request paths before
+// matching, e.g. with "query,percent,slashes" (see
+// linkmap.ParseCanonicalization). Without -canonicalize, request paths
+// which are not clean relative paths, such as "/docs/intro.md" or
+// "docs//intro.md", are rejected with the code INVALID_PATH, where older
+// versions matched them as is; "-canonicalize slashes" accepts them.
+//
+// Outputs prefixed with tmpl:, which are Go text/templates, are rejected
+// unless -tmpl-outputs is set. Only set it if every map in -dir is trusted.
+//
+// Usage:
+//
+//	linkmap-server -dir ./maps -addr :8080 -log-format json
+//	linkmap-server -dir ./maps -redirect-addr :80 -redirect-map go -preserve-query
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+	"github.com/operandinc/linkmap/service"
+)
+
+func main() {
+	var (
+		dir       = flag.String("dir", ".", "directory containing <key>.linkmap files")
+		addr      = flag.String("addr", ":8080", "address to listen on")
+		adminAddr = flag.String("admin-addr", "localhost:8081", "private address to serve admin operations on, or empty for none")
+		maxMaps   = flag.Int("max-maps", 0, "maximum number of loaded maps, or 0 for no limit")
+		maxBatch  = flag.Int("max-batch", 1000, "maximum number of paths per batch request")
+		logFmt    = flag.String("log-format", "", "log every evaluation to stderr as text or json")
+		audit     = flag.String("audit-file", "", "append evaluations to an audit log `file`")
+		sample    = flag.Float64("audit-sample", 0, "fraction of evaluations written to the audit log, or 0 for all")
+		rate      = flag.Float64("rate", 0, "requests per second allowed per client IP, or 0 for no limit")
+		burst     = flag.Int("burst", 0, "requests a client may make at once, or 0 for -rate rounded up")
+		agents    = flag.String("block-agents", "", "comma-separated user agent substrings to reject")
+		hosts     = flag.String("allow-hosts", "", "comma-separated hosts links may point at; \".example.com\" includes subdomains")
+		canon     = flag.String("canonicalize", "", "comma-separated path rewrites applied before matching: query, percent, slashes")
+		rdAddr    = flag.String("redirect-addr", "", "address to serve redirects on, if any")
+		rdMap     = flag.String("redirect-map", "", "`key` of the map redirects are evaluated against")
+		keepQ     = flag.Bool("preserve-query", false, "carry the query of redirected requests over to their links")
+		upstream  = flag.String("upstream", "", "`URL` of a site requests which are not redirected are proxied to")
+		cachePerm = flag.String("cache-permanent", "", "Cache-Control header of permanent redirects, e.g. \"public, max-age=86400\"")
+		hsts      = flag.String("hsts", "", "Strict-Transport-Security header of redirects, e.g. \"max-age=63072000\"")
+		shadowDir = flag.String("shadow-dir", "", "`directory` of candidate maps to compare evaluations with")
+		tmplOut   = flag.Bool("tmpl-outputs", false, "accept tmpl: outputs, which are programs; only for trusted maps")
+	)
+	flag.Parse()
+	logEval, err := service.NewLog(*logFmt, os.Stderr)
+	if err != nil {
+		log.Fatalf("linkmap-server: %v", err)
+	}
+	if *audit != "" {
+		f, err := os.OpenFile(*audit, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			log.Fatalf("linkmap-server: %v", err)
+		}
+		a := &service.Auditor{
+			Sink:       &service.FileSink{W: f},
+			SampleRate: *sample,
+			OnError:    func(err error) { log.Print(err) },
+		}
+		if next := logEval; next != nil {
+			logEval = func(e service.LogEntry) {
+				next(e)
+				a.Log(e)
+			}
+		} else {
+			logEval = a.Log
+		}
+	}
+
+	c, err := linkmap.ParseCanonicalization(*canon)
+	if err != nil {
+		log.Fatalf("linkmap-server: %v", err)
+	}
+	var allow linkmap.Allowlist
+	if *hosts != "" {
+		allow.Hosts = strings.Split(*hosts, ",")
+	}
+	maps := &linkmap.MapSet{
+		Load:    load(*dir),
+		Parse:   parse,
+		Options: []linkmap.Option{linkmap.WithLimits(linkmap.DefaultLimits), linkmap.WithCounters(), linkmap.WithPrefilter(), linkmap.WithAllowlist(allow), linkmap.WithCanonicalization(c)},
+		MaxMaps: *maxMaps,
+	}
+	if *tmplOut {
+		maps.Options = append(maps.Options, linkmap.WithTmplOutputs())
+	}
+	maps.PublishExpvar("linkmap")
+	s := &service.Service{Maps: maps, Log: logEval}
+	if *shadowDir != "" {
+		s.Shadow = &service.Shadow{
+			Maps: &linkmap.MapSet{Load: load(*shadowDir), Parse: parse, Options: maps.Options, MaxMaps: *maxMaps},
+			Record: func(d service.Divergence) {
+				log.Printf("linkmap-server: divergence in %s for %s: live %q %s, candidate %q %s", d.Map, d.Path, d.Link, d.Code, d.CandidateLink, d.CandidateCode)
+			},
+		}
+		log.Printf("linkmap-server: comparing with candidate maps from %s", *shadowDir)
+	}
+	log.Printf("linkmap-server: serving maps from %s on %s", *dir, *addr)
+	l := &service.Limiter{Rate: *rate, Burst: *burst}
+	if *agents != "" {
+		l.BlockAgents = strings.Split(*agents, ",")
+	}
+	if *rdAddr != "" {
+		rd := &service.Redirector{Service: s, Map: *rdMap, PreserveQuery: *keepQ}
+		if *cachePerm != "" {
+			rd.CacheControl = map[int]string{
+				http.StatusMovedPermanently:  *cachePerm,
+				http.StatusPermanentRedirect: *cachePerm,
+			}
+		}
+		if *hsts != "" {
+			rd.Header = http.Header{"Strict-Transport-Security": {*hsts}}
+		}
+		if *upstream != "" {
+			u, err := url.Parse(*upstream)
+			if err != nil {
+				log.Fatalf("linkmap-server: %v", err)
+			}
+			rd.Next = httputil.NewSingleHostReverseProxy(u)
+		}
+		log.Printf("linkmap-server: redirecting with map %q on %s", *rdMap, *rdAddr)
+		go func() {
+			log.Fatal(http.ListenAndServe(*rdAddr, l.Handler(rd)))
+		}()
+	}
+	if *adminAddr != "" {
+		log.Printf("linkmap-server: serving admin operations on %s", *adminAddr)
+		go func() {
+			log.Fatal(http.ListenAndServe(*adminAddr, s.AdminHandler(*maxBatch)))
+		}()
+	}
+	log.Fatal(http.ListenAndServe(*addr, l.Handler(s.Handler(*maxBatch))))
+}
+
+// load returns a MapSet loader reading maps from dir.
+func load(dir string) func(key string) ([]byte, error) {
+	return func(key string) ([]byte, error) {
+		if !fs.ValidPath(key) || key == "." {
+			return nil, fmt.Errorf("invalid map key %q", key)
+		}
+		base := filepath.Join(dir, filepath.FromSlash(key))
+		var src []byte
+		err := fs.ErrNotExist
+		for _, ext := range []string{".linkmap", ".toml", linkmap.BinaryExt} {
+			if src, err = os.ReadFile(base + ext); !errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+		}
+		return src, err
+	}
+}
+
+// parse parses a map in the text format, the TOML format, which starts
+// with a [[rule]] table, or the compiled format.
+func parse(r io.Reader, opts ...linkmap.Option) (*linkmap.Map, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if linkmap.IsBinary(src) {
+		return linkmap.ParseBinary(src, opts...)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if strings.HasPrefix(l, "[[") {
+			return linkmap.ParseTOML(bytes.NewReader(src), opts...)
+		}
+		break
+	}
+	return linkmap.Parse(bytes.NewReader(src), opts...)
+}
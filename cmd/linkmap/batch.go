@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/operandinc/linkmap"
+	"github.com/operandinc/linkmap/service"
+)
+
+// defaultFormat is the output format of batch.
+const defaultFormat = `{{.Path}}\t{{.Link}}`
+
+// record is the data a batch format is executed with.
+type record struct {
+	Path string
+	// Link is empty if Err is set.
+	Link string
+	// Rule is the zero Rule if none matched.
+	Rule linkmap.Rule
+	Vars map[string]string
+	Err  string
+}
+
+// batch evaluates the paths read from stdin, one per line, and writes a
+// line for each, formatted by a text/template, so that linkmap can be
+// used as a filter in shell pipelines. Blank lines are skipped. The escapes \t, \n and \\ are
+// interpreted in the format, as shells pass them through single quotes.
+func batch(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("batch")
+	format := fs.String("format", defaultFormat, "output `template` for each path, executed with .Path, .Link, .Rule, .Vars and .Err")
+	failOnMiss := fs.Bool("fail-on-miss", false, "exit with status 1 if any path has no link")
+	logFmt := fs.String("log-format", "", "log every evaluation to stderr as text or json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments; paths are read from stdin")
+	}
+	tmpl, err := template.New("format").Option("missingkey=zero").Parse(unescape(*format) + "\n")
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	logEval, err := service.NewLog(*logFmt, os.Stderr)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	in, out := bufio.NewReader(stdin), bufio.NewWriter(stdout)
+	var paths, misses int
+	for {
+		l, err := in.ReadString('\n')
+		if l = strings.TrimRight(l, "\r\n"); l != "" {
+			start := time.Now()
+			res := m.Lookup(l)
+			if logEval != nil {
+				logEval(service.NewLogEntry("", res, start))
+			}
+			rec := record{Path: res.Path, Link: res.Link, Vars: res.Vars}
+			if res.Rule != nil {
+				rec.Rule = *res.Rule
+			}
+			if res.Err != nil {
+				rec.Err = res.Err.Error()
+				misses++
+			}
+			paths++
+			if err := tmpl.Execute(out, rec); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if *failOnMiss && misses > 0 {
+				return &exitError{exitMiss, fmt.Errorf("%d of %d paths have no link", misses, paths)}
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Flush whenever the input runs dry, so that the output of an
+		// interactive or streamed input is not held back.
+		if in.Buffered() == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// unescape interprets the escapes \t, \n and \\ in s.
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(s)
+}
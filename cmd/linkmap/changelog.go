@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/operandinc/linkmap"
+)
+
+// changelog writes a Markdown changelog of the changes to a map since an
+// older version of it, for release notes.
+func changelog(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags, file := newFlagSet("changelog")
+	dir := flags.String("files", "", "count the files in `dir` whose link changes")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageErrorf("expected the old map")
+	}
+	old, err := loadMap(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	var fsys fs.FS
+	if *dir != "" {
+		fsys = os.DirFS(*dir)
+	}
+	return linkmap.DiffMaps(old, m).WriteChangelog(stdout, fsys)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// compile writes a map in the binary format, which services load without
+// parsing. The output file defaults to the map file with its extension
+// replaced by .linkmap.bin; "-" writes to stdout.
+func compile(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("compile")
+	out := fs.String("o", "", "output `file`, or - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(strings.TrimSuffix(*file, ".toml"), ".linkmap") + linkmap.BinaryExt
+	}
+	return os.WriteFile(*out, data, 0o644)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// explain prints each rule tried for a path, with the segment which failed
+// to match marked, followed by the link. If there is none, the error is
+// returned.
+func explain(args []string, _ io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("explain")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf("expected a single path")
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	e := m.Explain(fs.Arg(0))
+	writeExplanation(stdout, e)
+	if e.Result.Err != nil {
+		return &exitError{exitMiss, e.Result.Err}
+	}
+	return nil
+}
+
+// writeExplanation writes e in the form printed by explain:
+//
+//	docs/a.md
+//	  no  .linkmap:1  blog/$1.md https://example.com/blog/$1
+//	      blog/$1.md
+//	      ^^^^^ blog/ does not match "docs/a.md"
+//	  yes .linkmap:2  docs/$1.md https://example.com/$1
+//	=> https://example.com/a
+func writeExplanation(w io.Writer, e linkmap.Explanation) {
+	fmt.Fprintln(w, e.Path)
+	for _, s := range e.Steps {
+		mark := "no "
+		if s.Matched && s.Reason == "" {
+			mark = "yes"
+		}
+		fmt.Fprintf(w, "  %s %s  %s\n", mark, s.Rule.Origin(), s.Rule)
+		if s.Reason == "" {
+			continue
+		}
+		if s.Segment < 0 {
+			fmt.Fprintf(w, "      %s\n", s.Reason)
+			continue
+		}
+		in, start, end := highlight(s.Rule.Input.AST(), s.Segment)
+		fmt.Fprintf(w, "      %s\n      %s%s %s\n", in, strings.Repeat(" ", start), strings.Repeat("^", end-start), s.Reason)
+	}
+	if e.Result.Err == nil {
+		fmt.Fprintf(w, "=> %s\n", e.Result.Link)
+	}
+}
+
+// highlight returns the source of a template along with the span of its
+// segment i, or a single column after its end if i is past the last
+// segment.
+func highlight(a linkmap.AST, i int) (src string, start, end int) {
+	for j, s := range a {
+		seg := linkmap.AST{s}.String()
+		if j == i {
+			start, end = len(src), len(src)+len(seg)
+		}
+		src += seg
+	}
+	if i >= len(a) {
+		start, end = len(src), len(src)+1
+	}
+	return src, start, end
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// hash prints the digest of a map's rules, so that CI can tell changes in
+// meaning from changes in formatting by comparing it across revisions.
+func hash(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("hash")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, m.Hash())
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/operandinc/linkmap"
+)
+
+// importRedirects converts a redirect dump read from stdin into a map,
+// reporting the lines it skipped on stderr. The map is written in the TOML
+// format if any redirect has a status, which the text format cannot hold.
+func importRedirects(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("linkmap import", flag.ContinueOnError)
+	out := fs.String("o", "-", "output `file`, or - for stdout")
+	compress := fs.Bool("compress", false, "generalize the redirects into templated rules")
+	header := fs.Bool("header", false, "skip the first line")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+	m, report, err := linkmap.ImportRedirects(stdin, linkmap.ImportOptions{Header: *header, Compress: *compress})
+	if err != nil {
+		return err
+	}
+	for _, s := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", s.Line, s.Reason, s.Text)
+	}
+	var b bytes.Buffer
+	toml := false
+	for _, r := range m.Rules() {
+		toml = toml || r.Status != 0
+	}
+	if toml {
+		_, err = m.WriteTOML(&b)
+	} else {
+		_, err = m.WriteTo(&b)
+	}
+	if err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, b.Bytes(), 0o644)
+}
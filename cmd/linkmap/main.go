@@ -0,0 +1,193 @@
+// Command linkmap inspects and debugs linkmap files.
+//
+// Usage:
+//
+//	linkmap <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	batch      evaluate paths read from stdin, one per line
+//	changelog  describe the changes since an older map in Markdown
+//	compile    compile a map to the binary format, for fast loading
+//	explain    trace how a path is evaluated, rule by rule
+//	hash       print a digest of the map's rules, unaffected by formatting
+//	import     convert a redirect dump read from stdin into a map
+//	report     write an HTML report of a map's coverage, problems and changes
+//	shard      split a map into compiled shards by path prefix
+//	tui        explore a map interactively
+//
+// Every command but import reads the map from the file given by -map,
+// .linkmap by default, which may be in the text, the TOML or the compiled
+// format.
+//
+// # Paths
+//
+// Paths must be slash-separated, relative to the root of the repository,
+// and clean. Paths which are not, such as the URL-style "/docs/intro.md"
+// or "docs/../intro.md", which older versions matched as is, are reported
+// as invalid rather than evaluated; convert them first, e.g. by removing
+// the leading slash.
+//
+// # Exit status
+//
+// Scripts can rely on the exit status:
+//
+//	0  success: every path had a link
+//	1  some path had no link; batch only fails so with -fail-on-miss
+//	2  usage error, such as an unknown flag or a missing argument
+//	3  the map could not be read or parsed
+//	4  any other error, such as failing to read input or write output
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// A command is a subcommand of linkmap.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"batch", "batch [-map file] [-format template] [-fail-on-miss] [-log-format text|json] < paths", batch},
+	{"changelog", "changelog [-map file] [-files dir] old-map", changelog},
+	{"compile", "compile [-map file] [-o file]", compile},
+	{"explain", "explain [-map file] path", explain},
+	{"hash", "hash [-map file]", hash},
+	{"import", "import [-compress] [-header] [-o file] < dump", importRedirects},
+	{"report", "report [-map file] [-files dir] [-old file] [-lint-config file] [-title title] [-o file]", report},
+	{"shard", "shard [-map file] [-o dir] prefix...", shard},
+	{"tui", "tui [-map file]", tui},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			err := c.run(os.Args[2:], os.Stdin, os.Stdout)
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "linkmap %s: %v\n", c.name, err)
+				os.Exit(exitCode(err))
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "linkmap: unknown command %q\n", os.Args[1])
+	usage()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "\tlinkmap %s\n", c.usage)
+	}
+	os.Exit(2)
+}
+
+// Exit statuses, as documented above.
+const (
+	exitMiss  = 1
+	exitUsage = 2
+	exitMap   = 3
+	exitOther = 4
+)
+
+// An exitError is an error which makes linkmap exit with a given status.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode returns the exit status for err.
+func exitCode(err error) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return exitOther
+}
+
+// usageErrorf returns a usage error.
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitError{exitUsage, fmt.Errorf(format, args...)}
+}
+
+// parseFlags parses the flags of a command, reporting a usage error if
+// they are invalid.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{exitUsage, err}
+	}
+	return nil
+}
+
+// newFlagSet returns the flag set of the named command, with the -map flag
+// every command takes.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("linkmap "+name, flag.ContinueOnError)
+	return fs, fs.String("map", linkmap.MapFile, "linkmap file, in the text or TOML format")
+}
+
+// loadMap parses the map in file, which may be in either the text or the
+// TOML format, which starts with a [[rule]] table.
+func loadMap(file string) (*linkmap.Map, error) {
+	m, err := parseMap(file)
+	if err != nil {
+		return nil, &exitError{exitMap, err}
+	}
+	return m, nil
+}
+
+func parseMap(file string) (*linkmap.Map, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// Local files are as trusted as the command itself, so they may use
+	// text/template outputs.
+	opts := []linkmap.Option{linkmap.WithSource(file), linkmap.WithTmplOutputs()}
+	switch {
+	case linkmap.IsBinary(src):
+		return linkmap.ParseBinary(src, opts...)
+	case isTOML(src):
+		return linkmap.ParseTOML(bytes.NewReader(src), opts...)
+	}
+	return linkmap.Parse(bytes.NewReader(src), opts...)
+}
+
+// isTOML reports whether src is in the TOML format, rather than the text
+// one: whether its first line which is not blank or a comment starts a
+// [[rule]] table.
+func isTOML(src []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		return strings.HasPrefix(l, "[[")
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// run runs the named command as main does.
+func run(t *testing.T, name string, args []string, stdin string) error {
+	t.Helper()
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(args, strings.NewReader(stdin), io.Discard)
+		}
+	}
+	t.Fatalf("no command %q", name)
+	return nil
+}
+
+func TestExitCodes(t *testing.T) {
+	file := writeMap(t, "docs/$1.md https://example.com/$1\n")
+	broken := writeMap(t, "docs/$1.md\n")
+	missing := filepath.Join(t.TempDir(), "missing.linkmap")
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		code  int
+	}{
+		{"batch", []string{"-map", file}, "docs/a.md\n", 0},
+		{"batch", []string{"-map", file}, "docs/a.md\nx\n", 0},
+		{"batch", []string{"-map", file, "-fail-on-miss"}, "docs/a.md\n\ndocs/b.md\n", 0},
+		{"batch", []string{"-map", file, "-fail-on-miss"}, "docs/a.md\nx\n", exitMiss},
+		{"batch", []string{"-map", file, "-nope"}, "", exitUsage},
+		{"batch", []string{"-map", file, "docs/a.md"}, "", exitUsage},
+		{"batch", []string{"-map", file, "-format", "{{.Path"}, "", exitUsage},
+		{"batch", []string{"-map", file, "-log-format", "xml"}, "", exitUsage},
+		{"batch", []string{"-map", missing}, "docs/a.md\n", exitMap},
+		{"batch", []string{"-map", broken}, "docs/a.md\n", exitMap},
+		{"explain", []string{"-map", file, "docs/a.md"}, "", 0},
+		{"explain", []string{"-map", file, "x"}, "", exitMiss},
+		{"explain", []string{"-map", file}, "", exitUsage},
+		{"explain", []string{"-map", missing, "docs/a.md"}, "", exitMap},
+		{"hash", []string{"-map", file}, "", 0},
+		{"hash", []string{"-map", file, "extra"}, "", exitUsage},
+		{"hash", []string{"-map", broken}, "", exitMap},
+		{"compile", []string{"-map", file, "-o", filepath.Join(missing, "x.bin")}, "", exitOther},
+		{"changelog", []string{"-map", file}, "", exitUsage},
+		{"changelog", []string{"-map", file, missing}, "", exitMap},
+		{"report", []string{"-map", broken}, "", exitMap},
+	}
+	for _, tt := range tests {
+		err := run(t, tt.name, tt.args, tt.stdin)
+		code := 0
+		if err != nil {
+			code = exitCode(err)
+		}
+		if code != tt.code {
+			t.Errorf("linkmap %s %s: exit status %d (%v); want %d", tt.name, strings.Join(tt.args, " "), code, err, tt.code)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	if err := run(t, "hash", []string{"-h"}, ""); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("linkmap hash -h: error = %v; want flag.ErrHelp", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if code := exitCode(errors.New("x")); code != exitOther {
+		t.Errorf("exitCode(plain error) = %d; want %d", code, exitOther)
+	}
+	wrapped := &exitError{exitMap, errors.New("x")}
+	if code := exitCode(fmt.Errorf("reading: %w", wrapped)); code != exitMap {
+		t.Errorf("exitCode(wrapped exitError) = %d; want %d", code, exitMap)
+	}
+}
+
+func TestBatchOutput(t *testing.T) {
+	file := writeMap(t, "docs/$1.md https://example.com/$1\n")
+	var out bytes.Buffer
+	err := batch([]string{"-map", file, "-format", `{{.Path}}\t{{or .Link .Err}}`}, strings.NewReader("docs/a.md\nx\n"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "docs/a.md\thttps://example.com/a" || !strings.HasPrefix(lines[1], "x\t") {
+		t.Errorf("batch output = %q", lines)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/operandinc/linkmap"
+)
+
+// report writes an HTML report of a map for content owners: its lint
+// diagnostics, its coverage of the files in -files, and with -old, the
+// changes since an older version and the files whose link they change.
+// Maps in the TOML or compiled formats are not linted.
+func report(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("report")
+	dir := fs.String("files", "", "report the coverage of the files in `dir`")
+	oldFile := fs.String("old", "", "report the changes since the map in `file`")
+	lintConfig := fs.String("lint-config", "", "read diagnostic severities from `file`, as in .linkmaplint")
+	title := fs.String("title", "", "`title` of the report")
+	out := fs.String("o", "-", "output `file`, or - for stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	r := &linkmap.Report{Title: *title}
+
+	src, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if !linkmap.IsBinary(src) && !isTOML(src) {
+		l := &linkmap.Linter{Options: []linkmap.Option{linkmap.WithSource(*file), linkmap.WithTmplOutputs()}}
+		if *lintConfig != "" {
+			f, err := os.Open(*lintConfig)
+			if err != nil {
+				return err
+			}
+			l.Severities, err = linkmap.ReadLintConfig(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		r.Diagnostics = l.Lint(string(src))
+	}
+
+	if *oldFile != "" {
+		old, err := loadMap(*oldFile)
+		if err != nil {
+			return err
+		}
+		r.Diff = linkmap.DiffMaps(old, m)
+	}
+	if *dir != "" {
+		fsys := os.DirFS(*dir)
+		if r.Coverage, err = m.Coverage(fsys); err != nil {
+			return err
+		}
+		r.Diagnostics = append(r.Diagnostics, r.Coverage.Diagnostics()...)
+		if r.Diff != nil {
+			if r.Impact, err = r.Diff.Impact(fsys); err != nil {
+				return err
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := r.WriteHTML(&b); err != nil {
+		return err
+	}
+	if *out == "-" {
+		_, err = stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(*out, b.Bytes(), 0o644)
+}
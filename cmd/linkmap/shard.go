@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/operandinc/linkmap"
+)
+
+// shard splits a map into compiled shards by input prefix, writing the
+// shard for each prefix to <dir>/<prefix>/shard.linkmap.bin and that of
+// the remaining paths to <dir>/shard.linkmap.bin, for LoadShards.
+func shard(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("shard")
+	out := fs.String("o", "shards", "output `dir`")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	m, err := loadMap(*file)
+	if err != nil {
+		return err
+	}
+	s, err := m.Shard(fs.Args()...)
+	if err != nil {
+		return usageErrorf("%v", err)
+	}
+	for _, p := range s.Prefixes() {
+		data, err := s.Shard(p).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(*out, filepath.FromSlash(p))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, linkmap.ShardFile), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+const tuiHelp = `Type a path to evaluate it, or:
+  /query   fuzzy-search rules
+  :N       open rule N in $EDITOR at its definition
+  :r       reload the map
+  :q       quit
+`
+
+// maxMatches is the number of rules a search lists.
+const maxMatches = 10
+
+// tui runs an interactive session for exploring a map. It is line based,
+// so that it works in any terminal and over pipes: each line is evaluated
+// as soon as it is entered.
+func tui(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs, file := newFlagSet("tui")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments")
+	}
+	s := &session{file: *file, out: stdout, edit: edit}
+	if err := s.reload(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %d rules. Type ? for help.\n", s.file, len(s.rules))
+	sc := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(stdout)
+			return sc.Err()
+		}
+		if !s.handle(strings.TrimSpace(sc.Text())) {
+			return nil
+		}
+	}
+}
+
+// A session is the state of tui.
+type session struct {
+	file  string
+	out   io.Writer
+	m     *linkmap.Map
+	rules []linkmap.Rule
+	// edit opens file at line in an editor.
+	edit func(file string, line int) error
+}
+
+func (s *session) reload() error {
+	m, err := loadMap(s.file)
+	if err != nil {
+		return err
+	}
+	s.m, s.rules = m, m.Rules()
+	return nil
+}
+
+// handle handles a line of input, reporting whether the session goes on.
+func (s *session) handle(l string) bool {
+	switch {
+	case l == "":
+	case l == "?":
+		fmt.Fprint(s.out, tuiHelp)
+	case l == ":q":
+		return false
+	case l == ":r":
+		if err := s.reload(); err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+			break
+		}
+		fmt.Fprintf(s.out, "%d rules\n", len(s.rules))
+	case strings.HasPrefix(l, ":"):
+		n, err := strconv.Atoi(l[1:])
+		if err != nil || n < 1 || n > len(s.rules) {
+			fmt.Fprintf(s.out, "no rule %s\n", l[1:])
+			break
+		}
+		s.open(n)
+	case strings.HasPrefix(l, "/"):
+		s.search(l[1:])
+	default:
+		s.evaluate(l)
+	}
+	return true
+}
+
+// evaluate shows the rule a path matches and its link, or why no rule
+// produced one.
+func (s *session) evaluate(fpath string) {
+	e := s.m.Explain(fpath)
+	if e.Result.Err == nil {
+		s.printRule(s.index(e.Result.Rule))
+		fmt.Fprintf(s.out, "=> %s\n", e.Result.Link)
+		return
+	}
+	for _, step := range e.Steps {
+		if step.Matched {
+			s.printRule(s.index(step.Rule))
+			fmt.Fprintf(s.out, "      %s\n", step.Reason)
+		}
+	}
+	fmt.Fprintf(s.out, "error: %v\n", e.Result.Err)
+	if sugs := s.m.Suggest(fpath, 3); len(sugs) > 0 {
+		fmt.Fprintln(s.out, "closest rules:")
+		for _, sug := range sugs {
+			fmt.Fprintf(s.out, "      %s %s\n", sug.Input, sug.Output)
+		}
+	}
+}
+
+// search lists the rules which best match query, as a subsequence of
+// their source.
+func (s *session) search(query string) {
+	type match struct{ i, score int }
+	var matches []match
+	for i, r := range s.rules {
+		if score, ok := fuzzy(strings.ToLower(r.String()), strings.ToLower(query)); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	if len(matches) > maxMatches {
+		matches = matches[:maxMatches]
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(s.out, "no rules match")
+	}
+	for _, m := range matches {
+		s.printRule(m.i)
+	}
+}
+
+// open opens rule n, numbered from 1, in the editor and reloads the map.
+func (s *session) open(n int) {
+	r := s.rules[n-1]
+	if r.Line == 0 {
+		fmt.Fprintf(s.out, "rule %d has no line\n", n)
+		return
+	}
+	if err := s.edit(s.file, r.Line); err != nil {
+		fmt.Fprintf(s.out, "error: %v\n", err)
+		return
+	}
+	if err := s.reload(); err != nil {
+		fmt.Fprintf(s.out, "error: %v\n", err)
+	}
+}
+
+func (s *session) printRule(i int) {
+	fmt.Fprintf(s.out, "%4d  %s  %s\n", i+1, s.rules[i].Origin(), s.rules[i])
+}
+
+// index returns the index of r in evaluation order.
+func (s *session) index(r *linkmap.Rule) int {
+	for i := range s.rules {
+		if s.rules[i].Line == r.Line && s.rules[i].String() == r.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// fuzzy reports whether query is a subsequence of s, and scores the match
+// by the number of characters skipped between the first and last matched,
+// so that lower is better.
+func fuzzy(s, query string) (score int, ok bool) {
+	first, j := -1, 0
+	for i := 0; i < len(s) && j < len(query); i++ {
+		if s[i] == query[j] {
+			if first < 0 {
+				first = i
+			}
+			j++
+			score = i - first + 1 - j
+		}
+	}
+	return score, j == len(query)
+}
+
+// edit opens file at line in $VISUAL or $EDITOR, falling back to vi.
+func edit(file string, line int) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), "+"+strconv.Itoa(line), file)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
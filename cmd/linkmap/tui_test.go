@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeMap writes src to a map file in a temporary directory, returning
+// its name.
+func writeMap(t *testing.T, src string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "links.linkmap")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSessionHandle(t *testing.T) {
+	file := writeMap(t, "docs/$1.md https://example.com/docs/$1\nblog/$1.md https://example.com/blog/$1\n")
+	var edited []int
+	var out bytes.Buffer
+	s := &session{file: file, out: &out, edit: func(f string, line int) error {
+		if f != file {
+			t.Errorf("edit(%q); want %q", f, file)
+		}
+		edited = append(edited, line)
+		if line == 2 {
+			return errors.New("editor failed")
+		}
+		// The edit adds a rule, which the session picks up.
+		return os.WriteFile(file, []byte("docs/$1.md https://example.com/docs/$1\nblog/$1.md https://example.com/blog/$1\nwiki/$1.md https://example.com/wiki/$1\n"), 0o644)
+	}}
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line string
+		goOn bool
+		want []string
+	}{
+		{"", true, nil},
+		{"?", true, []string{"fuzzy-search rules"}},
+		{"docs/a.md", true, []string{"docs/$1.md https://example.com/docs/$1", "=> https://example.com/docs/a"}},
+		{"docs/a.txt", true, []string{"error: ", "closest rules:", "docs/$1.md https://example.com/docs/$1"}},
+		{"/blog", true, []string{"blog/$1.md"}},
+		{"/zzz", true, []string{"no rules match"}},
+		{":9", true, []string{"no rule 9"}},
+		{":x", true, []string{"no rule x"}},
+		{":2", true, []string{"error: editor failed"}},
+		{":1", true, nil},
+		{"wiki/a.md", true, []string{"=> https://example.com/wiki/a"}},
+		{":r", true, []string{"3 rules"}},
+		{":q", false, nil},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if goOn := s.handle(tt.line); goOn != tt.goOn {
+			t.Errorf("handle(%q) = %v; want %v", tt.line, goOn, tt.goOn)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("handle(%q) wrote %q; want it to contain %q", tt.line, out.String(), w)
+			}
+		}
+	}
+	if len(edited) != 2 {
+		t.Fatalf("edited lines %v; want two edits", edited)
+	}
+	if err := os.WriteFile(file, []byte("broken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	s.handle(":r")
+	if !strings.Contains(out.String(), "error: ") || len(s.rules) != 3 {
+		t.Errorf("reloading a broken map wrote %q and left %d rules; want an error and the old rules", out.String(), len(s.rules))
+	}
+}
+
+func TestSessionSearchOrder(t *testing.T) {
+	file := writeMap(t, "dxxxxxoxxxxxcxxxxxs/$1 /a/$1\ndocs/$1 /b/$1\n")
+	var out bytes.Buffer
+	s := &session{file: file, out: &out}
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	s.search("docs")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "docs/$1 /b/$1") {
+		t.Errorf("search(docs) = %q; want the closest match first", lines)
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	tests := []struct {
+		s, query string
+		score    int
+		ok       bool
+	}{
+		{"docs/$1.md", "docs", 0, true},
+		{"docs/$1.md", "dmd", 7, true},
+		{"docs/$1.md", "", 0, true},
+		{"docs/$1.md", "blog", 0, false},
+		{"docs", "docss", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := fuzzy(tt.s, tt.query)
+		if ok != tt.ok || ok && score != tt.score {
+			t.Errorf("fuzzy(%q, %q) = %d, %v; want %d, %v", tt.s, tt.query, score, ok, tt.score, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package linkmap
+
+import "strings"
+
+// Filter returns a copy of the map, with the same configuration, holding
+// only the rules for which pred returns true, in the same order.
+func (m *Map) Filter(pred func(Rule) bool) *Map {
+	var rules []Rule
+	for _, r := range m.rules {
+		if pred(r) {
+			rules = append(rules, r)
+		}
+	}
+	return m.withRules(rules)
+}
+
+// RestrictPrefix returns a copy of the map holding only the rules which
+// can match paths in the directory prefix, such as "docs/api", so that,
+// for instance, a preview environment serves only the rules relevant to
+// the directory which changed. As for Shard, rules whose inputs start with
+// a literal prefix are kept if it is in or encloses the directory, and
+// rules which could match anywhere, such as "$1/index.md", are always
+// kept. The copy evaluates paths in the directory as the map does. The
+// prefix is a path as passed to Lookup, including the map's base
+// directory, if any.
+func (m *Map) RestrictPrefix(prefix string) *Map {
+	p := strings.Trim(prefix, "/")
+	if m.base != "" {
+		switch {
+		case p == "" || p == m.base || strings.HasPrefix(m.base+"/", p+"/"):
+			p = ""
+		case strings.HasPrefix(p, m.base+"/"):
+			p = strings.TrimPrefix(p, m.base+"/")
+		default:
+			// The directory is outside the base directory, so no rule
+			// matches paths in it.
+			return m.withRules(nil)
+		}
+	}
+	return m.Filter(func(r Rule) bool {
+		lit := literalPrefix(r.Input)
+		return p == "" || strings.HasPrefix(lit, p+"/") || strings.HasPrefix(p+"/", lit)
+	})
+}
@@ -0,0 +1,78 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(`[[rule]]
+from = "docs/$1.md"
+to = "https://example.com/$1"
+status = 301
+
+[[rule]]
+from = "blog/$1.md"
+to = "https://example.com/blog/$1"
+`), WithCounters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := m.Filter(func(r Rule) bool { return r.Status == 301 })
+	if len(f.Rules()) != 1 || len(m.Rules()) != 2 {
+		t.Fatalf("Filter() has rules %v", f.Rules())
+	}
+	if _, err := f.Evaluate("blog/a.md"); err == nil {
+		t.Error("filtered out rule still matches")
+	}
+	if link, err := f.Evaluate("docs/a.md"); link != "https://example.com/a" || err != nil {
+		t.Errorf("Evaluate() = %q, %v", link, err)
+	}
+	if hits := f.Stats().Matches[0].Matches; hits != 1 {
+		t.Errorf("filtered map counts %d hits; want 1", hits)
+	}
+}
+
+func TestRestrictPrefix(t *testing.T) {
+	const src = `docs/api/$1.md https://example.com/api/$1
+docs/$1.md https://example.com/$1
+blog/$1.md https://example.com/blog/$1
+$1/index.md https://example.com/$1/
+re:d(oc|ir)s/(.*)\.txt https://example.com/$2
+`
+	m, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"docs/api/$1.md", "docs/$1.md", "blog/$1.md", "$1/index.md", `re:d(oc|ir)s/(.*)\.txt`}},
+		{"docs/api", []string{"docs/api/$1.md", "docs/$1.md", "$1/index.md", `re:d(oc|ir)s/(.*)\.txt`}},
+		{"docs/guides/", []string{"docs/$1.md", "$1/index.md", `re:d(oc|ir)s/(.*)\.txt`}},
+		{"blog", []string{"blog/$1.md", "$1/index.md"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range m.RestrictPrefix(tt.prefix).Rules() {
+			got = append(got, r.Input.String())
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("RestrictPrefix(%q) has inputs %q; want %q", tt.prefix, got, tt.want)
+		}
+	}
+	based, err := Parse(strings.NewReader(src), WithBase("site"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(based.RestrictPrefix("site/blog").Rules()); n != 2 {
+		t.Errorf("RestrictPrefix() under the base has %d rules; want 2", n)
+	}
+	if n := len(based.RestrictPrefix("other").Rules()); n != 0 {
+		t.Errorf("RestrictPrefix() outside the base has %d rules; want 0", n)
+	}
+	if n := len(based.RestrictPrefix("").Rules()); n != 5 {
+		t.Errorf("RestrictPrefix() of the root has %d rules; want 5", n)
+	}
+}
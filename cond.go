@@ -0,0 +1,138 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errInputCond = errors.New("linkmap: conditionals can only be used in output templates")
+
+// A conditional selects between two parts of an output template by the
+// value of a variable, so that one rule can produce links of different
+// shapes instead of a rule for each combination:
+//
+//	docs/$lang/$1.md https://example.com/{if:$lang!=en}$lang/{end}$1
+//	$kind/$1.md https://example.com/{if:$kind=api}reference/$1{else}guides/$kind/$1{end}
+//
+// The condition "$name" holds if the variable is not empty, "$name=value"
+// if it equals value, and "$name!=value" if it does not. Either part may
+// itself contain conditionals. There is no space after "if", as spaces
+// separate the templates of a rule.
+type conditional struct {
+	name, op, value string
+	then, els       template
+}
+
+// parseConditional parses the conditional s starts with, returning it and
+// the length of its source.
+func parseConditional(s string, kinds map[string]SegmentMatcher, a *segmentArena) (*conditional, int, error) {
+	end := strings.IndexByte(s, '}')
+	if end < 0 {
+		return nil, 0, fmt.Errorf("linkmap: unterminated conditional %q", s)
+	}
+	c := &conditional{}
+	cond := s[len("{if:"):end]
+	switch {
+	case strings.Contains(cond, "!="):
+		c.name, c.value, _ = strings.Cut(cond, "!=")
+		c.op = "!="
+	case strings.Contains(cond, "="):
+		c.name, c.value, _ = strings.Cut(cond, "=")
+		c.op = "="
+	default:
+		c.name = cond
+	}
+	if !strings.HasPrefix(c.name, "$") || !validName(c.name[1:]) {
+		return nil, 0, fmt.Errorf("linkmap: invalid condition %q", cond)
+	}
+	c.name = c.name[1:]
+	// Find the {else} and {end} of this conditional, skipping those of
+	// conditionals nested in it.
+	body := end + 1
+	elseAt, depth := -1, 0
+	for i := body; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{if:"):
+			depth++
+		case strings.HasPrefix(s[i:], "{else}") && depth == 0:
+			if elseAt >= 0 {
+				return nil, 0, fmt.Errorf("linkmap: conditional %q has two {else}s", s[:i])
+			}
+			elseAt = i
+		case strings.HasPrefix(s[i:], "{end}"):
+			if depth > 0 {
+				depth--
+				continue
+			}
+			thenSrc, elseSrc := s[body:i], ""
+			if elseAt >= 0 {
+				thenSrc, elseSrc = s[body:elseAt], s[elseAt+len("{else}"):i]
+			}
+			var err error
+			if c.then, err = parseTemplateIn(thenSrc, kinds, a); err != nil {
+				return nil, 0, err
+			}
+			if c.els, err = parseTemplateIn(elseSrc, kinds, a); err != nil {
+				return nil, 0, err
+			}
+			return c, i + len("{end}"), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("linkmap: conditional %q has no {end}", s)
+}
+
+// hasCond reports whether the template contains a conditional.
+func (tmpl template) hasCond() bool {
+	for _, r := range tmpl.refs {
+		if r.typ == segmentTypeCond {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the source of the conditional.
+func (c *conditional) String() string {
+	s := "{if:$" + c.name + c.op + c.value + "}" + c.then.String()
+	if c.els.len() > 0 {
+		s += "{else}" + c.els.String()
+	}
+	return s + "{end}"
+}
+
+// choose returns the part of the conditional selected by vars.
+func (c *conditional) choose(vars map[string]string) (template, error) {
+	val, ok := vars[c.name]
+	if !ok {
+		return template{}, fmt.Errorf("missing variable $%s", c.name)
+	}
+	var holds bool
+	switch c.op {
+	case "=":
+		holds = val == c.value
+	case "!=":
+		holds = val != c.value
+	default:
+		holds = val != ""
+	}
+	if holds {
+		return c.then, nil
+	}
+	return c.els, nil
+}
+
+// walk calls fn for each segment of the template, including those in
+// conditionals, and a variable segment for each conditional's variable.
+func (tmpl template) walk(fn func(segment)) {
+	for i := 0; i < tmpl.len(); i++ {
+		s := tmpl.at(i)
+		if s.typ != segmentTypeCond {
+			fn(s)
+			continue
+		}
+		fn(segment{typ: segmentTypeVariable, val: "$" + s.cond.name})
+		s.cond.then.walk(fn)
+		s.cond.els.walk(fn)
+	}
+}
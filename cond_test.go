@@ -0,0 +1,73 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConditionals(t *testing.T) {
+	m, err := Parse(strings.NewReader(`docs/$lang/$1.md https://example.com/{if:$lang!=en}$lang/{end}$1
+$kind/$1.md https://example.com/{if:$kind=api}reference/$1{else}{if:$1}guides/$kind/$1{end}{end}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ path, want string }{
+		{"docs/en/intro.md", "https://example.com/intro"},
+		{"docs/fr/intro.md", "https://example.com/fr/intro"},
+		{"api/users.md", "https://example.com/reference/users"},
+		{"blog/post.md", "https://example.com/guides/blog/post"},
+	}
+	for _, tt := range tests {
+		if link, err := m.Evaluate(tt.path); link != tt.want || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.path, link, err, tt.want)
+		}
+	}
+	bin, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := mustParseBinary(t, bin).Evaluate("docs/fr/intro.md"); link != "https://example.com/fr/intro" || err != nil {
+		t.Errorf("Evaluate() after a binary round trip = %q, %v", link, err)
+	}
+	for i, r := range m.Rules() {
+		got, err := r.Output.AST().Compile()
+		if err != nil || got.String() != r.Output.String() {
+			t.Errorf("rule %d: compiled AST = %q, %v; want %q", i, got, err, r.Output)
+		}
+	}
+	renamed, err := m.RenameVariable("kind", "section")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := renamed.Rules()[1].Output.String(), "https://example.com/{if:$section=api}reference/$1{else}{if:$1}guides/$section/$1{end}{end}"; got != want {
+		t.Errorf("renamed output = %q; want %q", got, want)
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	for _, src := range []string{
+		"docs/$1.md https://example.com/{if:$1}$1\n",
+		"docs/$1.md https://example.com/{if:$1}a{else}b{else}c{end}\n",
+		"docs/$1.md https://example.com/{if 1}$1{end}\n",
+	} {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded", src)
+		}
+	}
+	for src, code := range map[string]string{
+		"docs/$1.md https://example.com/{if:$lang}$1{end}\n": CodeUnboundVariable,
+		"docs/$1.md https://example.com/{if:$1}{x,y}{end}\n": CodeOutputExtension,
+	} {
+		if diags := Analyze(src); len(diags) != 1 || diags[0].Code != code {
+			t.Errorf("Analyze(%q) = %v; want %s", src, diags, code)
+		}
+	}
+	if _, err := ParseTemplate("docs/{if:$1}a{end}$1.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(strings.NewReader("docs/{if:$1}a{end}$1.md https://example.com/$1\n")); !errors.Is(err, errInputCond) {
+		t.Errorf("conditional in input: err = %v; want %v", err, errInputCond)
+	}
+}
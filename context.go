@@ -0,0 +1,92 @@
+package linkmap
+
+import (
+	"io/fs"
+	"time"
+)
+
+// An EvalContext holds what a scope of evaluations shares, such as the
+// requests for one site, locale or release channel, so that it is built
+// once rather than on every call and its defaults apply consistently:
+//
+//	beta := &linkmap.EvalContext{Vars: map[string]string{"channel": "beta", "base": "https://beta.example.com"}}
+//	link, err := m.EvaluateContext(beta, "docs/intro.md")
+//
+// An EvalContext must not be modified while it is in use, and is then safe
+// for concurrent use.
+type EvalContext struct {
+	// Vars are default variables, which outputs and rule conditions may
+	// use. Variables bound by matching the path take precedence.
+	Vars map[string]string
+	// Resolver, if not nil, resolves destinations instead of the map's.
+	Resolver Resolver
+	// FS, if not nil, is the file tree file variables are bound from, as
+	// for LookupFile.
+	FS fs.FS
+	// Seed, if not empty, chooses between the weighted outputs of rules
+	// (see Rule.Weighted), so that evaluations with the same seed, such as
+	// a user or session ID, get the same output. Without one, outputs are
+	// chosen at random.
+	Seed string
+	// Time, if not zero, is the time scheduled outputs (see
+	// Rule.Scheduled) are chosen by, instead of the map's clock.
+	Time time.Time
+}
+
+// bind adds the context's variables which vars does not already hold.
+func (c *EvalContext) bind(vars map[string]string) {
+	for k, v := range c.Vars {
+		if _, ok := vars[k]; !ok {
+			vars[k] = v
+		}
+	}
+}
+
+// fixedTime returns the context's Time, which is zero if the context is nil.
+func (c *EvalContext) fixedTime() time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	return c.Time
+}
+
+// with returns a copy of the context with the default variable name set to
+// val. The context may be nil.
+func (c *EvalContext) with(name, val string) *EvalContext {
+	nc := &EvalContext{Vars: map[string]string{name: val}}
+	if c != nil {
+		*nc = *c
+		nc.Vars = make(map[string]string, len(c.Vars)+1)
+		for k, v := range c.Vars {
+			nc.Vars[k] = v
+		}
+		nc.Vars[name] = val
+	}
+	return nc
+}
+
+// LookupContext is like Lookup, but evaluates fpath in ctx.
+func (m *Map) LookupContext(ctx *EvalContext, fpath string) Result {
+	return m.lookup(ctx, fpath)
+}
+
+// EvaluateContext is like Evaluate, but evaluates fpath in ctx.
+func (m *Map) EvaluateContext(ctx *EvalContext, fpath string) (string, error) {
+	res := m.LookupContext(ctx, fpath)
+	return res.Link, res.Err
+}
+
+// WithContextVars declares variables which every EvalContext the map is
+// evaluated in provides, so that outputs may use them although inputs do
+// not bind them, without Analyze or RewriteOutputs reporting them as
+// unbound.
+func WithContextVars(names ...string) Option {
+	return func(m *Map) {
+		if m.contextVars == nil {
+			m.contextVars = make(map[string]bool)
+		}
+		for _, name := range names {
+			m.contextVars[name] = true
+		}
+	}
+}
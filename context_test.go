@@ -0,0 +1,57 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEvalContext(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(`[[rule]]
+from = "docs/$1.md"
+to = "$base/beta/$1"
+[rule.conditions]
+channel = "beta"
+
+[[rule]]
+from = "docs/$1.md"
+to = "$base/$lang/$1"
+
+[[rule]]
+from = "i18n/$lang/$1.md"
+to = "$base/$lang/$1?v=$hash"
+
+[[rule]]
+from = "assets/$1"
+to = "$dest(cdn)/$1"
+`), WithContextVars("base", "lang", "channel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stable := &EvalContext{
+		Vars:     map[string]string{"base": "https://example.com", "lang": "en", "channel": "stable"},
+		Resolver: MapResolver{"cdn": "https://cdn.example.com"},
+		FS:       fstest.MapFS{"i18n/fr/a.md": {Data: []byte("x")}},
+	}
+	beta := &EvalContext{Vars: map[string]string{"base": "https://beta.example.com", "lang": "en", "channel": "beta"}}
+	tests := []struct {
+		ctx        *EvalContext
+		path, want string
+	}{
+		{stable, "docs/a.md", "https://example.com/en/a"},
+		{beta, "docs/a.md", "https://beta.example.com/beta/a"},
+		{stable, "i18n/fr/a.md", "https://example.com/fr/a?v=2d711642"},
+		{stable, "assets/x.css", "https://cdn.example.com/x.css"},
+	}
+	for _, tt := range tests {
+		if link, err := m.EvaluateContext(tt.ctx, tt.path); link != tt.want || err != nil {
+			t.Errorf("EvaluateContext(%v, %q) = %q, %v; want %q", tt.ctx.Vars, tt.path, link, err, tt.want)
+		}
+	}
+	if _, err := m.Evaluate("docs/a.md"); err == nil {
+		t.Error("Evaluate() without a context succeeded")
+	}
+	if diags := Analyze("docs/$1.md $base/$lang/$1\n", WithContextVars("base", "lang")); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+}
@@ -0,0 +1,99 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Coverage summarizes how much of a file tree a map links.
+type Coverage struct {
+	// Files is the number of files evaluated; ignored files are not
+	// counted.
+	Files int
+	// Linked is the number of files with a link.
+	Linked int
+	// Unlinked lists the files which matched no rule.
+	Unlinked []string
+	// Failed lists the files which matched a rule whose link could not be
+	// built.
+	Failed []Result
+}
+
+// Ratio returns the fraction of files with a link, or 1 if there are none.
+func (c *Coverage) Ratio() float64 {
+	if c.Files == 0 {
+		return 1
+	}
+	return float64(c.Linked) / float64(c.Files)
+}
+
+// Diagnostic codes reported by Coverage.Diagnostics.
+const (
+	CodeUnlinkedFile = "unlinked-file"
+	CodeLinkFailed   = "link-failed"
+)
+
+// Diagnostics returns the coverage problems as diagnostics: a warning for
+// each unlinked file, and an error for each failed file, reported against
+// the rule which matched it.
+func (c *Coverage) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+	for _, p := range c.Unlinked {
+		diags = append(diags, Diagnostic{
+			File:     p,
+			Severity: SeverityWarning,
+			Code:     CodeUnlinkedFile,
+			Message:  "file matches no rule",
+		})
+	}
+	for _, res := range c.Failed {
+		d := Diagnostic{
+			File:     res.Path,
+			Severity: SeverityError,
+			Code:     CodeLinkFailed,
+			Message:  errMessage(res.Err),
+		}
+		if r := res.Rule; r != nil {
+			d.File, d.Range = r.Source, r.lineRange()
+			d.Message = fmt.Sprintf("%s: %s", res.Path, d.Message)
+			d.Related = []Related{{File: res.Path, Message: "the file the rule matched"}}
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// errMessage returns the message of an error building a link, without the
+// "linkmap: " prefix and the rule's origin, which diagnostics and reports
+// show apart.
+func errMessage(err error) string {
+	var oe *originError
+	if errors.As(err, &oe) {
+		err = oe.err
+	}
+	return strings.TrimPrefix(err.Error(), "linkmap: ")
+}
+
+// Coverage evaluates every file in fsys, as EvaluateFS does, and reports
+// which are linked. fsys may be a directory, or an archive opened with
+// OpenArchive.
+func (m *Map) Coverage(fsys fs.FS) (*Coverage, error) {
+	results, err := m.EvaluateFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	c := &Coverage{Files: len(results)}
+	for _, res := range results {
+		switch {
+		case res.Err == nil:
+			c.Linked++
+		case errors.Is(res.Err, ErrNoMatches):
+			c.Unlinked = append(c.Unlinked, res.Path)
+		default:
+			c.Failed = append(c.Failed, res)
+		}
+	}
+	return c, nil
+}
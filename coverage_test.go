@@ -0,0 +1,49 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCoverage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/a.md":      {},
+		"docs/b.md":      {},
+		"img/logo.png":   {},
+		"vendor/x/y.md":  {},
+		".linkmapignore": {Data: []byte("vendor/\n")},
+		"assets/c.css":   {},
+	}
+	ig, err := LoadIgnore(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(strings.NewReader("docs/$1.md https://example.com/$1\nassets/$1.css $dest(cdn)/$1.css\n"), WithIgnore(ig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Coverage(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Files != 5 || c.Linked != 2 || len(c.Failed) != 1 || c.Failed[0].Path != "assets/c.css" {
+		t.Errorf("Coverage() = %+v", c)
+	}
+	if want := []string{".linkmapignore", "img/logo.png"}; strings.Join(c.Unlinked, ",") != strings.Join(want, ",") {
+		t.Errorf("Unlinked = %v; want %v", c.Unlinked, want)
+	}
+	diags := c.Diagnostics()
+	if len(diags) != 3 || diags[0].Code != CodeUnlinkedFile || diags[0].File != ".linkmapignore" {
+		t.Fatalf("Diagnostics() = %+v", diags)
+	}
+	if d := diags[2]; d.Code != CodeLinkFailed || d.Severity != SeverityError || d.Range.Start.Line != 1 || d.Related[0].File != "assets/c.css" {
+		t.Errorf("Diagnostics()[2] = %+v", d)
+	}
+	if got := c.Ratio(); got != 0.4 {
+		t.Errorf("Ratio() = %v; want 0.4", got)
+	}
+	if got := (&Coverage{}).Ratio(); got != 1 {
+		t.Errorf("empty Ratio() = %v; want 1", got)
+	}
+}
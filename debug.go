@@ -0,0 +1,114 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"time"
+)
+
+// maxRecentErrors is the number of evaluation errors a MapSet remembers.
+const maxRecentErrors = 50
+
+// DebugInfo describes the state of a MapSet for operators.
+type DebugInfo struct {
+	Maps         []MapInfo   `json:"maps"`
+	RecentErrors []EvalError `json:"recent_errors"`
+}
+
+// MapInfo describes a loaded map.
+type MapInfo struct {
+	Key    string    `json:"key"`
+	Rules  int       `json:"rules"`
+	Loaded time.Time `json:"loaded"`
+	// LastError is the error from the most recent failed load, if the
+	// most recent load failed.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+}
+
+// An EvalError records a failed evaluation. Paths which simply match no
+// rule are not recorded.
+type EvalError struct {
+	Time  time.Time `json:"time"`
+	Key   string    `json:"key"`
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+}
+
+// DebugInfo returns the loaded maps, in key order, followed by keys which
+// failed to load, and the most recent evaluation errors, oldest first.
+func (s *MapSet) DebugInfo() DebugInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := DebugInfo{
+		Maps:         []MapInfo{},
+		RecentErrors: append([]EvalError{}, s.recent...),
+	}
+	for _, k := range s.keysLocked() {
+		e := s.entries[k]
+		mi := MapInfo{Key: k, Rules: len(e.m.m.rules), Loaded: e.loaded}
+		if f, ok := s.failures[k]; ok {
+			mi.LastError, mi.LastErrorTime = f.err.Error(), f.time
+		}
+		info.Maps = append(info.Maps, mi)
+	}
+	for _, k := range s.failureKeysLocked() {
+		if e, ok := s.entries[k]; !ok || e.m == nil {
+			f := s.failures[k]
+			info.Maps = append(info.Maps, MapInfo{Key: k, LastError: f.err.Error(), LastErrorTime: f.time})
+		}
+	}
+	return info
+}
+
+// recordError remembers a failed evaluation for DebugInfo.
+func (s *MapSet) recordError(key, fpath string, err error) {
+	if err == nil || errors.Is(err, ErrNoMatches) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.recent) == maxRecentErrors {
+		copy(s.recent, s.recent[1:])
+		s.recent = s.recent[:maxRecentErrors-1]
+	}
+	s.recent = append(s.recent, EvalError{Time: time.Now(), Key: key, Path: fpath, Error: err.Error()})
+}
+
+// DebugHandler returns a handler, suitable for mounting under
+// /debug/linkmap, which reports DebugInfo as JSON. With a "key" query
+// parameter, it instead writes the rules of that map in linkmap syntax.
+func (s *MapSet) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.URL.Query().Get("key"); key != "" {
+			// A reload replaces e.m under s.mu, so read it while holding it.
+			var sm *sharedMap
+			s.mu.Lock()
+			if e, ok := s.entries[key]; ok {
+				sm = e.m
+			}
+			s.mu.Unlock()
+			if sm == nil {
+				http.Error(w, "map not loaded", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			sm.m.WriteTo(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s.DebugInfo())
+	})
+}
+
+// PublishExpvar publishes DebugInfo as an expvar variable with the given
+// name. Like expvar.Publish, it panics if the name is already in use.
+func (s *MapSet) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return s.DebugInfo()
+	}))
+}
@@ -0,0 +1,43 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugHandler(t *testing.T) {
+	s := &MapSet{
+		Load: func(key string) ([]byte, error) {
+			return []byte("docs/$1.md https://example.com/$1\n"), nil
+		},
+	}
+	s.Evaluate("site", "docs/a.md")
+	s.Evaluate("site", "nope")
+	s.Evaluate("site", "../etc/passwd")
+
+	rec := httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/linkmap/", nil))
+	var info DebugInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("json.Unmarshal(%s) error: %v", rec.Body, err)
+	}
+	if len(info.Maps) != 1 || info.Maps[0].Key != "site" || info.Maps[0].Rules != 1 || info.Maps[0].Loaded.IsZero() {
+		t.Errorf("Maps = %+v", info.Maps)
+	}
+	if len(info.RecentErrors) != 1 || info.RecentErrors[0].Path != "../etc/passwd" {
+		t.Errorf("RecentErrors = %+v; want only the invalid path", info.RecentErrors)
+	}
+
+	rec = httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/linkmap/?key=site", nil))
+	if got := rec.Body.String(); !strings.Contains(got, "docs/$1.md https://example.com/$1") {
+		t.Errorf("rules = %q", got)
+	}
+	rec = httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/linkmap/?key=other", nil))
+	if rec.Code != 404 {
+		t.Errorf("unknown key status = %d; want 404", rec.Code)
+	}
+}
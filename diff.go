@@ -0,0 +1,292 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// A Diff lists how the rules of a map differ from those of an older
+// version, in meaning rather than formatting. Rules are identified by
+// their input: a rule whose input is only in the new map is added, one
+// only in the old map removed, and one in both modified if it does
+// something different, as Hash would tell. Line numbers and descriptions
+// are not compared.
+type Diff struct {
+	// Added is in the evaluation order of the new map, Removed in that of
+	// the old one, and Modified in that of the new one.
+	Added    []Rule
+	Removed  []Rule
+	Modified []RuleChange
+
+	old, new *Map
+}
+
+// A RuleChange is a rule whose input is in both maps of a Diff, but which
+// does something different in the new one.
+type RuleChange struct {
+	Old, New Rule
+}
+
+// DiffMaps returns the differences between the rules of old and new.
+func DiffMaps(old, new *Map) *Diff {
+	d := &Diff{old: old, new: new}
+	// Rules with the same input are paired in order.
+	olds := make(map[string][]Rule)
+	for _, r := range old.rules {
+		olds[r.Input.String()] = append(olds[r.Input.String()], r)
+	}
+	paired := make(map[string]int)
+	for _, r := range new.rules {
+		in := r.Input.String()
+		if paired[in] == len(olds[in]) {
+			d.Added = append(d.Added, r)
+			continue
+		}
+		o := olds[in][paired[in]]
+		paired[in]++
+		if meaning(o) != meaning(r) {
+			d.Modified = append(d.Modified, RuleChange{Old: o, New: r})
+		}
+	}
+	seen := make(map[string]int)
+	for _, r := range old.rules {
+		in := r.Input.String()
+		if seen[in]++; seen[in] > paired[in] {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+	return d
+}
+
+func meaning(r Rule) string {
+	var b strings.Builder
+	writeMeaning(&b, r)
+	return b.String()
+}
+
+// Empty reports whether the maps have the same rules.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// A ruleDelta is one aspect in which a modified rule changed. Old and New
+// are empty for aspects too complex to show, such as conditions.
+type ruleDelta struct {
+	what, old, new string
+	// code is set if old and new are templates or header values, rather
+	// than prose.
+	code bool
+}
+
+// deltas returns the aspects in which the rule changed.
+func (c RuleChange) deltas() []ruleDelta {
+	var deltas []ruleDelta
+	if o, n := c.Old.Output.String(), c.New.Output.String(); o != n {
+		deltas = append(deltas, ruleDelta{"output", o, n, true})
+	}
+	if c.Old.Status != c.New.Status {
+		deltas = append(deltas, ruleDelta{"status", statusText(c.Old.Status), statusText(c.New.Status), false})
+	}
+	if c.Old.CacheControl != c.New.CacheControl {
+		deltas = append(deltas, ruleDelta{"cache control", c.Old.CacheControl, c.New.CacheControl, true})
+	}
+	for _, part := range []struct {
+		what     string
+		old, new Rule
+	}{
+		{"conditions", Rule{Conditions: c.Old.Conditions}, Rule{Conditions: c.New.Conditions}},
+		{"weighted outputs", Rule{Weighted: c.Old.Weighted}, Rule{Weighted: c.New.Weighted}},
+		{"scheduled outputs", Rule{Scheduled: c.Old.Scheduled}, Rule{Scheduled: c.New.Scheduled}},
+	} {
+		if meaning(part.old) != meaning(part.new) {
+			deltas = append(deltas, ruleDelta{what: part.what})
+		}
+	}
+	return deltas
+}
+
+// markdown formats the delta as in "status 301 → 308".
+func (d ruleDelta) markdown() string {
+	if d.old == "" && d.new == "" {
+		return d.what + " changed"
+	}
+	if d.code {
+		return d.what + " " + mdCode(d.old) + " → " + mdCode(d.new)
+	}
+	return d.what + " " + d.old + " → " + d.new
+}
+
+func statusText(status int) string {
+	if status == 0 {
+		return "default"
+	}
+	return fmt.Sprint(status)
+}
+
+// mdCode returns s as a Markdown code span, or "none" if it is empty.
+func mdCode(s string) string {
+	if s == "" {
+		return "none"
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// WriteChangelog writes the diff as a Markdown changelog for release
+// notes: a summary, then the added, removed and modified rules, each in a
+// section of its own. If fsys is not nil, the changelog also counts the
+// files whose link changes, as Impact reports them, in all and for each
+// rule responsible.
+func (d *Diff) WriteChangelog(w io.Writer, fsys fs.FS) error {
+	var impact map[string]int
+	total := -1
+	if fsys != nil {
+		changes, err := d.Impact(fsys)
+		if err != nil {
+			return err
+		}
+		impact, total = d.impact(changes), len(changes)
+	}
+	var b strings.Builder
+	if d.Empty() {
+		b.WriteString("No rules changed")
+	} else {
+		fmt.Fprintf(&b, "%s added, %d removed and %d modified", plural(len(d.Added), "rule"), len(d.Removed), len(d.Modified))
+	}
+	switch {
+	case total == 0:
+		b.WriteString("; no file gets a different link")
+	case total > 0:
+		fmt.Fprintf(&b, "; %s a different link", plural(total, "file gets", "files get"))
+	}
+	b.WriteString(".\n")
+	files := func(key string) string {
+		if impact == nil {
+			return ""
+		}
+		return " (" + plural(impact[key], "file") + ")"
+	}
+	section := func(title string, n int, item func(i int) string) {
+		if n == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", title)
+		for i := 0; i < n; i++ {
+			b.WriteString("- " + item(i) + "\n")
+		}
+	}
+	section("Added", len(d.Added), func(i int) string {
+		r := d.Added[i]
+		return mdCode(r.Input.String()) + " → " + mdCode(r.Output.String()) + files("+"+r.Input.String())
+	})
+	section("Removed", len(d.Removed), func(i int) string {
+		r := d.Removed[i]
+		return mdCode(r.Input.String()) + " → " + mdCode(r.Output.String()) + files("-"+r.Input.String())
+	})
+	section("Modified", len(d.Modified), func(i int) string {
+		c := d.Modified[i]
+		changes := make([]string, 0, 3)
+		for _, d := range c.deltas() {
+			changes = append(changes, d.markdown())
+		}
+		return mdCode(c.New.Input.String()) + ": " + strings.Join(changes, ", ") + files("~"+c.New.Input.String())
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A FileChange is a file whose link or status differs between the maps of
+// a Diff.
+type FileChange struct {
+	Path     string
+	Old, New Result
+}
+
+// Impact evaluates every file in fsys against both maps, as EvaluateFS
+// does, and returns the files whose link or status changes, or which gain
+// or lose a link, in path order. Weighted outputs are chosen with the
+// file's path as the seed, so that they do not count as changes.
+func (d *Diff) Impact(fsys fs.FS) ([]FileChange, error) {
+	var changes []FileChange
+	err := fs.WalkDir(fsys, ".", func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		ctx := &EvalContext{FS: fsys, Seed: p}
+		o, n := d.old.LookupContext(ctx, p), d.new.LookupContext(ctx, p)
+		if outcome(o) != outcome(n) {
+			changes = append(changes, FileChange{Path: p, Old: o, New: n})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: walking filesystem: %w", err)
+	}
+	return changes, nil
+}
+
+// impact returns the number of changed files each change of the diff is
+// responsible for, keyed by its input prefixed with '+' for added, '-'
+// for removed and '~' for modified rules.
+func (d *Diff) impact(changes []FileChange) map[string]int {
+	kinds := make(map[string]string)
+	for _, r := range d.Added {
+		kinds[r.Input.String()] = "+"
+	}
+	for _, r := range d.Removed {
+		kinds[r.Input.String()] = "-"
+	}
+	for _, c := range d.Modified {
+		kinds[c.New.Input.String()] = "~"
+	}
+	impact := make(map[string]int)
+	for _, c := range changes {
+		keys := make(map[string]bool)
+		for _, res := range []Result{c.Old, c.New} {
+			if res.Rule == nil {
+				continue
+			}
+			in := res.Rule.Input.String()
+			if kind, ok := kinds[in]; ok {
+				keys[kind+in] = true
+			}
+		}
+		for k := range keys {
+			impact[k]++
+		}
+	}
+	return impact
+}
+
+// outcome summarizes what a result does to a file: its link and status,
+// or whether it failed.
+func outcome(res Result) string {
+	switch {
+	case errors.Is(res.Err, ErrNoMatches):
+		return "no match"
+	case res.Err != nil:
+		return "error"
+	}
+	return fmt.Sprint(res.Rule.Status, " ", res.Link)
+}
+
+// plural returns n followed by the singular or plural form of a noun; the
+// plural form defaults to the singular with an 's'.
+func plural(n int, forms ...string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, forms[0])
+	}
+	if len(forms) > 1 {
+		return fmt.Sprintf("%d %s", n, forms[1])
+	}
+	return fmt.Sprintf("%d %ss", n, forms[0])
+}
@@ -0,0 +1,153 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const diffOldSrc = `[[rule]]
+from = "docs/$1.md"
+to = "https://example.com/docs/$1"
+description = "Documentation."
+
+[[rule]]
+from = "blog/$1.md"
+to = "https://example.com/blog/$1"
+status = 301
+
+[[rule]]
+from = "old/$1.md"
+to = "https://example.com/old/$1"
+`
+
+const diffNewSrc = `[[rule]]
+from = "docs/$1.md"
+to = "https://example.com/docs/$1"
+description = "Documentation, renamed."
+
+[[rule]]
+from = "blog/$1.md"
+to = "https://blog.example.com/$1"
+status = 308
+
+[[rule]]
+from = "news/$1.md"
+to = "https://example.com/news/$1"
+`
+
+func TestDiffMaps(t *testing.T) {
+	old, err := ParseTOML(strings.NewReader(diffOldSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := ParseTOML(strings.NewReader(diffNewSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := DiffMaps(old, new)
+	if len(d.Added) != 1 || d.Added[0].Input.String() != "news/$1.md" {
+		t.Errorf("Added = %v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Input.String() != "old/$1.md" {
+		t.Errorf("Removed = %v", d.Removed)
+	}
+	if len(d.Modified) != 1 || d.Modified[0].New.Input.String() != "blog/$1.md" {
+		t.Fatalf("Modified = %v", d.Modified)
+	}
+	want := []ruleDelta{
+		{"output", "https://example.com/blog/$1", "https://blog.example.com/$1", true},
+		{"status", "301", "308", false},
+	}
+	if got := d.Modified[0].deltas(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("deltas() = %v, want %v", got, want)
+	}
+	if !DiffMaps(old, old).Empty() {
+		t.Error("DiffMaps(old, old) is not empty")
+	}
+}
+
+func TestDiffImpact(t *testing.T) {
+	old, err := ParseTOML(strings.NewReader(diffOldSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := ParseTOML(strings.NewReader(diffNewSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"docs/a.md": {}, "blog/a.md": {}, "old/a.md": {}}
+	changes, err := DiffMaps(old, new).Impact(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s: %q → %q", c.Path, c.Old.Link, c.New.Link))
+	}
+	want := []string{
+		`blog/a.md: "https://example.com/blog/a" → "https://blog.example.com/a"`,
+		`old/a.md: "https://example.com/old/a" → ""`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Impact() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestWriteChangelog(t *testing.T) {
+	old, err := ParseTOML(strings.NewReader(diffOldSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := ParseTOML(strings.NewReader(diffNewSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"docs/a.md":  {},
+		"blog/a.md":  {},
+		"blog/b.md":  {},
+		"old/a.md":   {},
+		"news/a.md":  {},
+		"news/b.md":  {},
+		"news/c.md":  {},
+		"README.txt": {},
+	}
+	var b strings.Builder
+	if err := DiffMaps(old, new).WriteChangelog(&b, fsys); err != nil {
+		t.Fatal(err)
+	}
+	want := "1 rule added, 1 removed and 1 modified; 6 files get a different link.\n" +
+		"\n### Added\n\n" +
+		"- `news/$1.md` → `https://example.com/news/$1` (3 files)\n" +
+		"\n### Removed\n\n" +
+		"- `old/$1.md` → `https://example.com/old/$1` (1 file)\n" +
+		"\n### Modified\n\n" +
+		"- `blog/$1.md`: output `https://example.com/blog/$1` → `https://blog.example.com/$1`, status 301 → 308 (2 files)\n"
+	if b.String() != want {
+		t.Errorf("WriteChangelog() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := DiffMaps(old, old).WriteChangelog(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "No rules changed.\n"; b.String() != want {
+		t.Errorf("WriteChangelog() = %q, want %q", b.String(), want)
+	}
+}
+
+func TestMDCode(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "none"},
+		{"a/$1", "`a/$1`"},
+		{"a`b", "``a`b``"},
+		{"`a", "`` `a ``"},
+	}
+	for _, tt := range tests {
+		if got := mdCode(tt.in); got != tt.want {
+			t.Errorf("mdCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
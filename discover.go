@@ -0,0 +1,137 @@
+package linkmap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrNoMapFile is returned by DiscoverFor when no directory enclosing a
+// path has a MapFile.
+var ErrNoMapFile = errors.New("linkmap: no " + MapFile + " file found")
+
+// WithBase makes the map evaluate paths relative to dir: a path within dir
+// has dir removed before it is matched, and other paths match no rule. File
+// variables are read relative to dir as well. Results keep the path as
+// passed in.
+func WithBase(dir string) Option {
+	return func(m *Map) {
+		if dir = path.Clean(dir); dir != "." {
+			m.base = dir
+		}
+	}
+}
+
+// rebase returns p relative to the map's base directory, along with fsys
+// rooted at it.
+func (m *Map) rebase(p string, fsys fs.FS) (string, fs.FS, error) {
+	if m.base == "" {
+		return p, fsys, nil
+	}
+	rel := strings.TrimPrefix(p, m.base+"/")
+	if rel == p {
+		return "", nil, fmt.Errorf("%w: %q is outside %q", ErrNoMatches, p, m.base)
+	}
+	if fsys != nil {
+		var err error
+		if fsys, err = fs.Sub(fsys, m.base); err != nil {
+			return "", nil, err
+		}
+	}
+	return rel, fsys, nil
+}
+
+// A Discoverer finds the linkmap for a path like DiscoverFor, parsing maps
+// with its Options. Parsed maps are cached by directory and content, so
+// repeated discovery only reads the file. A Discoverer is safe for
+// concurrent use.
+type Discoverer struct {
+	// Options are passed to Parse for every map.
+	Options []Option
+
+	mu    sync.Mutex
+	cache map[discoverKey]*Map
+}
+
+type discoverKey struct {
+	dir string
+	sum [sha256.Size]byte
+}
+
+// maxDiscoverCache bounds the number of maps cached by a Discoverer. When
+// it is exceeded, the cache is cleared.
+const maxDiscoverCache = 1024
+
+var defaultDiscoverer Discoverer
+
+// DiscoverFor finds the MapFile in the closest directory enclosing fpath,
+// as tools like .editorconfig do, and returns its map. The map evaluates
+// paths relative to that directory, as if parsed WithBase, so that fpath
+// can be passed to it as is. If it falls through, paths which match none
+// of its rules are evaluated by the map DiscoverFor finds for its
+// directory, as described for Router. If there is no MapFile, the error is
+// ErrNoMapFile.
+func DiscoverFor(fsys fs.FS, fpath string) (*Map, error) {
+	return defaultDiscoverer.For(fsys, fpath)
+}
+
+// For finds the map for fpath, as DiscoverFor does.
+func (d *Discoverer) For(fsys fs.FS, fpath string) (*Map, error) {
+	if !fs.ValidPath(fpath) || fpath == "." {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPath, fpath)
+	}
+	for dir := path.Dir(fpath); ; dir = path.Dir(dir) {
+		src, err := fs.ReadFile(fsys, path.Join(dir, MapFile))
+		switch {
+		case err == nil:
+			m, err := d.parse(dir, src)
+			if err != nil || !m.fallsThrough || dir == "." {
+				return m, err
+			}
+			parent, err := d.For(fsys, dir)
+			if errors.Is(err, ErrNoMapFile) {
+				return m, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			// The cached map is shared by every path in dir; link the
+			// parent from a copy.
+			fm := *m
+			fm.next = parent
+			return &fm, nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return nil, fmt.Errorf("linkmap: reading %s: %w", path.Join(dir, MapFile), err)
+		case dir == ".":
+			return nil, fmt.Errorf("%w for %q", ErrNoMapFile, fpath)
+		}
+	}
+}
+
+func (d *Discoverer) parse(dir string, src []byte) (*Map, error) {
+	key := discoverKey{dir: dir, sum: sha256.Sum256(src)}
+	d.mu.Lock()
+	m, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+	name := path.Join(dir, MapFile)
+	opts := append(append([]Option(nil), d.Options...), WithBase(dir), WithSource(name))
+	m, err := Parse(bytes.NewReader(src), opts...)
+	if err != nil {
+		return nil, sourceError(name, err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cache == nil || len(d.cache) >= maxDiscoverCache {
+		d.cache = make(map[discoverKey]*Map)
+	}
+	d.cache[key] = m
+	return m, nil
+}
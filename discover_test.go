@@ -0,0 +1,79 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiscoverFor(t *testing.T) {
+	fsys := fstest.MapFS{
+		".linkmap":           {Data: []byte("$1.md https://example.com/$1\n")},
+		"web/.linkmap":       {Data: []byte("docs/$1.md https://web.example.com/$1?size=$size\n")},
+		"web/docs/a.md":      {Data: []byte("abc")},
+		"web/docs/deep/b.md": {Data: []byte("")},
+	}
+	tests := []struct {
+		path, link string
+		err        error
+	}{
+		{"readme.md", "https://example.com/readme", nil},
+		{"web/docs/a.md", "https://web.example.com/a?size=3", nil},
+		{"web/docs/deep/b.md", "https://web.example.com/deep/b?size=0", nil},
+		{"web/other.md", "", ErrNoMatches},
+	}
+	for _, tt := range tests {
+		m, err := DiscoverFor(fsys, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := m.EvaluateFile(fsys, tt.path)
+		if link != tt.link || !errors.Is(err, tt.err) {
+			t.Errorf("EvaluateFile(%q) = %q, %v; want %q, %v", tt.path, link, err, tt.link, tt.err)
+		}
+	}
+
+	a, _ := DiscoverFor(fsys, "web/docs/a.md")
+	b, _ := DiscoverFor(fsys, "web/x")
+	if a != b {
+		t.Error("DiscoverFor() parsed the same linkmap twice")
+	}
+	if _, err := a.Evaluate("readme.md"); !errors.Is(err, ErrNoMatches) || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Evaluate() outside the base = %v", err)
+	}
+	if _, err := DiscoverFor(fstest.MapFS{"a/b.md": {}}, "a/b.md"); !errors.Is(err, ErrNoMapFile) {
+		t.Errorf("DiscoverFor() without a linkmap = %v; want ErrNoMapFile", err)
+	}
+	if _, err := DiscoverFor(fsys, "/abs"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("DiscoverFor(/abs) = %v; want ErrInvalidPath", err)
+	}
+
+	d := &Discoverer{Options: []Option{WithCounters()}}
+	m, err := d.For(fsys, "web/docs/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Evaluate("web/docs/a.md")
+	if s := m.Stats(); s.Matches == nil || s.Matches[0].Matches != 1 {
+		t.Errorf("Discoverer options not applied: %+v", s.Matches)
+	}
+}
+
+func TestWithBase(t *testing.T) {
+	m, err := Parse(strings.NewReader("$1.md https://example.com/$1\n"), WithBase("./docs/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := m.Lookup("docs/a/b.md")
+	if res.Link != "https://example.com/a/b" || res.Path != "docs/a/b.md" {
+		t.Errorf("Lookup() = %+v", res)
+	}
+	if results := m.EvaluateAll("docsx/a.md"); len(results) != 1 || !errors.Is(results[0].Err, ErrNoMatches) {
+		t.Errorf("EvaluateAll() outside the base = %v", results)
+	}
+	m, _ = Parse(strings.NewReader("$1.md https://example.com/$1\n"), WithBase("."))
+	if link, _ := m.Evaluate("a.md"); link != "https://example.com/a" {
+		t.Errorf("Evaluate() with root base = %q", link)
+	}
+}
@@ -0,0 +1,89 @@
+package linkmap
+
+import "fmt"
+
+// A Step is the outcome of trying one rule against a path, as reported by
+// Explain.
+type Step struct {
+	// Rule is the rule tried. It is shared with the Map and must not be
+	// modified.
+	Rule *Rule
+	// Matched is set if the rule's input template matched the path.
+	Matched bool
+	// Segment is the index in Rule.Input.AST() of the segment which failed
+	// to match, or len(AST()) if the path has text left over. It is -1 if
+	// the input matched, or is a regular expression.
+	Segment int
+	// Offset is the byte offset in the path at which Segment failed to
+	// match.
+	Offset int
+	// Reason describes why the rule did not produce the result, if it did
+	// not.
+	Reason string
+}
+
+// An Explanation traces the evaluation of a path, rule by rule.
+type Explanation struct {
+	// Path is the path as evaluated by the map's rules, which differs
+	// from Result.Path if the map has a base directory.
+	Path string
+	// Steps holds the rules tried, in evaluation order. The last one
+	// produced the Result, unless no rule matched.
+	Steps []Step
+	// Result is what Lookup returns for the path.
+	Result Result
+}
+
+// Explain evaluates fpath as Lookup does, recording each rule tried and,
+// for rules which did not match, the segment which failed. It is meant for
+// debugging a map; the steps are traced without a budget. Only
+// the map's own rules are traced: a path which falls through to the map of
+// a parent directory (see DiscoverFor) has no step for the rule which
+// produced its link.
+func (m *Map) Explain(fpath string) Explanation {
+	e := Explanation{Path: fpath, Result: m.Lookup(fpath)}
+	p, err := m.inputPath(fpath)
+	if err != nil || m.ignore.Match(p) {
+		return e
+	}
+	if e.Path, _, err = m.rebase(p, nil); err != nil {
+		return e
+	}
+	for i := range m.rules {
+		r := &m.rules[i]
+		step := Step{Rule: r, Segment: -1}
+		if r.Input.re != nil {
+			_, step.Matched = r.Input.re.match(e.Path)
+		} else {
+			var fail int
+			_, fail, step.Offset = r.Input.segs.matchAt(e.Path)
+			if fail == r.Input.segs.len() && r.Input.prefix {
+				_, step.Matched = r.Input.Match(e.Path)
+			}
+			step.Matched = step.Matched || fail < 0
+			if !step.Matched {
+				step.Segment = fail
+			}
+		}
+		res, ok := m.try(r, e.Path, nil, nil)
+		switch {
+		case !step.Matched && step.Segment < 0:
+			step.Reason = "regular expression does not match"
+		case !step.Matched && step.Segment == r.Input.segs.len():
+			step.Reason = fmt.Sprintf("unmatched text %q", e.Path[step.Offset:])
+		case !step.Matched:
+			step.Reason = fmt.Sprintf("%s does not match %q", r.Input.segs.at(step.Segment).val, e.Path[step.Offset:])
+		case !ok && res.Err != nil:
+			step.Reason = res.Err.Error()
+		case !ok:
+			step.Reason = "conditions not met"
+		case res.Err != nil:
+			step.Reason = res.Err.Error()
+		}
+		e.Steps = append(e.Steps, step)
+		if ok {
+			break
+		}
+	}
+	return e
+}
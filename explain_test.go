@@ -0,0 +1,50 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	src := "blog/$1.md https://example.com/blog/$1\n" +
+		"docs/$1.{mdx} https://example.com/x/$1\n" +
+		"re:^docs/(old)/.* https://example.com/old\n" +
+		"docs/$1.md https://example.com/$1\n"
+	m, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type step struct {
+		line, segment, offset int
+		matched               bool
+	}
+	tests := []struct {
+		path  string
+		steps []step
+		link  string
+	}{
+		{"docs/a.md", []step{{2, 3, 7, false}, {1, 0, 0, false}, {4, -1, 9, true}}, "https://example.com/a"},
+		{"blog/a.md.bak", []step{{2, 0, 0, false}, {1, 3, 9, false}, {4, 0, 0, false}, {3, -1, 0, false}}, ""},
+		{"docs/old/a.txt", []step{{2, 3, 11, false}, {1, 0, 0, false}, {4, 2, 5, false}, {3, -1, 0, true}}, "https://example.com/old"},
+	}
+	for _, tt := range tests {
+		e := m.Explain(tt.path)
+		if e.Result.Link != tt.link || len(e.Steps) != len(tt.steps) {
+			t.Errorf("Explain(%q) = %+v; want %d steps and link %q", tt.path, e, len(tt.steps), tt.link)
+			continue
+		}
+		for i, s := range e.Steps {
+			got := step{s.Rule.Line, s.Segment, s.Offset, s.Matched}
+			if got != tt.steps[i] {
+				t.Errorf("Explain(%q).Steps[%d] = %+v; want %+v", tt.path, i, got, tt.steps[i])
+			}
+			if s.Matched != (s.Reason == "") {
+				t.Errorf("Explain(%q).Steps[%d].Reason = %q", tt.path, i, s.Reason)
+			}
+		}
+	}
+
+	if e := m.Explain("../x"); e.Result.Err == nil || len(e.Steps) != 0 {
+		t.Errorf("Explain(../x) = %+v; want an error and no steps", e)
+	}
+}
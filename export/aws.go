@@ -0,0 +1,201 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// maxS3Rules is the maximum number of routing rules of an S3 website.
+const maxS3Rules = 50
+
+// s3RoutingRule is a routing rule of an S3 website configuration.
+type s3RoutingRule struct {
+	Condition s3Condition `xml:"Condition" json:"Condition"`
+	Redirect  s3Redirect  `xml:"Redirect" json:"Redirect"`
+}
+
+type s3Condition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals" json:"KeyPrefixEquals"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty" json:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+type s3Redirect struct {
+	Protocol             string `xml:"Protocol,omitempty" json:"Protocol,omitempty"`
+	HostName             string `xml:"HostName,omitempty" json:"HostName,omitempty"`
+	ReplaceKeyPrefixWith string `xml:"ReplaceKeyPrefixWith,omitempty" json:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string `xml:"ReplaceKeyWith,omitempty" json:"ReplaceKeyWith,omitempty"`
+	HttpRedirectCode     string `xml:"HttpRedirectCode" json:"HttpRedirectCode"`
+}
+
+// S3RoutingRules writes the routing rules of an S3 website configuration,
+// as XML, for the map's literal and prefix rules, in evaluation order. S3
+// matches literal paths as prefixes too, so that a rule for old.html also
+// redirects old.html5. Other rules, and those beyond the 50 S3 allows, are
+// skipped.
+func S3RoutingRules(w io.Writer, m *linkmap.Map, opts Options) ([]Skipped, error) {
+	rules, skipped := s3Rules(m, opts)
+	buf, err := xml.MarshalIndent(struct {
+		XMLName xml.Name        `xml:"RoutingRules"`
+		Rules   []s3RoutingRule `xml:"RoutingRule"`
+	}{Rules: rules}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return skipped, err
+}
+
+// S3RoutingRulesJSON is like S3RoutingRules but writes JSON, as used by the
+// S3 console and the AWS CLI.
+func S3RoutingRulesJSON(w io.Writer, m *linkmap.Map, opts Options) ([]Skipped, error) {
+	rules, skipped := s3Rules(m, opts)
+	if rules == nil {
+		rules = []s3RoutingRule{}
+	}
+	buf, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return skipped, err
+}
+
+func s3Rules(m *linkmap.Map, opts Options) ([]s3RoutingRule, []Skipped) {
+	rs, skipped := redirects(m, opts)
+	var rules []s3RoutingRule
+	for _, r := range rs {
+		rule, err := s3Rule(r)
+		if err == nil && len(rules) == maxS3Rules {
+			err = fmt.Errorf("S3 allows at most %d routing rules", maxS3Rules)
+		}
+		if err != nil {
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: err.Error()})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, skipped
+}
+
+// s3Rule returns the routing rule for r.
+func s3Rule(r *redirect) (s3RoutingRule, error) {
+	from, to, ok := r.literal()
+	isPrefix := !ok
+	if isPrefix {
+		if from, to, ok = r.prefix(); !ok {
+			return s3RoutingRule{}, fmt.Errorf("S3 routing rules only support literal paths and prefixes")
+		}
+	}
+	u, err := url.Parse(to)
+	if err != nil {
+		return s3RoutingRule{}, err
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil || u.Port() != "" {
+		return s3RoutingRule{}, fmt.Errorf("S3 routing rules cannot set a query, fragment, user or port")
+	}
+	rule := s3RoutingRule{
+		Condition: s3Condition{KeyPrefixEquals: strings.TrimPrefix(from, "/")},
+		Redirect: s3Redirect{
+			Protocol:         u.Scheme,
+			HostName:         u.Host,
+			HttpRedirectCode: strconv.Itoa(r.status),
+		},
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if isPrefix {
+		rule.Redirect.ReplaceKeyPrefixWith = key
+	} else {
+		rule.Redirect.ReplaceKeyWith = key
+	}
+	return rule, nil
+}
+
+// CloudFrontFunction writes the JavaScript source of a CloudFront Functions
+// viewer request handler which serves the map's redirects, in evaluation
+// order, and passes other requests through. Regular expression inputs
+// using flags or other syntax JavaScript lacks are skipped.
+func CloudFrontFunction(w io.Writer, m *linkmap.Map, opts Options) ([]Skipped, error) {
+	rs, skipped := redirects(m, opts)
+	var b strings.Builder
+	b.WriteString("// Generated by linkmap. Do not edit.\nvar rules = [\n")
+	for _, r := range rs {
+		pattern, err := jsPattern(r.pattern)
+		if err != nil {
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: err.Error()})
+			continue
+		}
+		var target []string
+		for _, p := range r.target {
+			if p.group > 0 {
+				target = append(target, fmt.Sprintf("(m[%d] || \"\")", p.group))
+			} else {
+				target = append(target, jsQuote(p.lit))
+			}
+		}
+		fmt.Fprintf(&b, "  [new RegExp(%s), %d, function (m) { return %s; }],\n",
+			jsQuote(pattern), r.status, strings.Join(target, " + "))
+	}
+	b.WriteString(`];
+
+var descriptions = ` + jsStatusText(rs) + `;
+
+function handler(event) {
+  var uri = event.request.uri;
+  for (var i = 0; i < rules.length; i++) {
+    var m = rules[i][0].exec(uri);
+    if (m) {
+      return {
+        statusCode: rules[i][1],
+        statusDescription: descriptions[rules[i][1]],
+        headers: { location: { value: rules[i][2](m) } },
+      };
+    }
+  }
+  return event.request;
+}
+`)
+	_, err := io.WriteString(w, b.String())
+	return skipped, err
+}
+
+// jsUnsupported matches group syntax which JavaScript lacks or spells
+// differently: flags and RE2's named groups are handled by jsPattern.
+var jsUnsupported = regexp.MustCompile(`\(\?[^:P]|\\[zAQE]|\[\[:`)
+
+// jsPattern converts a pattern to JavaScript syntax. Named groups become
+// plain groups, which keeps their numbers.
+func jsPattern(pattern string) (string, error) {
+	if jsUnsupported.MatchString(pattern) {
+		return "", fmt.Errorf("regular expression is not supported in JavaScript")
+	}
+	return regexp.MustCompile(`\(\?P<[^>]*>`).ReplaceAllString(pattern, "("), nil
+}
+
+// jsStatusText returns a JavaScript object literal with the reason phrase
+// of each status used by rs.
+func jsStatusText(rs []*redirect) string {
+	seen := make(map[int]bool)
+	var fields []string
+	for _, r := range rs {
+		if !seen[r.status] {
+			seen[r.status] = true
+			fields = append(fields, fmt.Sprintf("%d: %s", r.status, jsQuote(http.StatusText(r.status))))
+		}
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// jsQuote returns s as a JavaScript string literal.
+func jsQuote(s string) string {
+	buf, _ := json.Marshal(s)
+	return string(buf)
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestS3RoutingRules(t *testing.T) {
+	m := mustParse(t, `docs/$1 https://example.com/guide/$1
+old.html /new.html
+posts/$1.md https://example.com/$1
+`)
+	var b strings.Builder
+	skipped, err := S3RoutingRules(&b, m, Options{Status: 302})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Rule.Input.String() != "posts/$1.md" {
+		t.Errorf("skipped %v", skipped)
+	}
+	want := `<RoutingRules>
+  <RoutingRule>
+    <Condition>
+      <KeyPrefixEquals>docs/</KeyPrefixEquals>
+    </Condition>
+    <Redirect>
+      <Protocol>https</Protocol>
+      <HostName>example.com</HostName>
+      <ReplaceKeyPrefixWith>guide/</ReplaceKeyPrefixWith>
+      <HttpRedirectCode>302</HttpRedirectCode>
+    </Redirect>
+  </RoutingRule>
+  <RoutingRule>
+    <Condition>
+      <KeyPrefixEquals>old.html</KeyPrefixEquals>
+    </Condition>
+    <Redirect>
+      <ReplaceKeyWith>new.html</ReplaceKeyWith>
+      <HttpRedirectCode>302</HttpRedirectCode>
+    </Redirect>
+  </RoutingRule>
+</RoutingRules>
+`
+	if got := b.String(); got != want {
+		t.Errorf("S3RoutingRules() =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	if _, err := S3RoutingRulesJSON(&b, m, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want = `[
+  {
+    "Condition": {
+      "KeyPrefixEquals": "docs/"
+    },
+    "Redirect": {
+      "Protocol": "https",
+      "HostName": "example.com",
+      "ReplaceKeyPrefixWith": "guide/",
+      "HttpRedirectCode": "301"
+    }
+  },
+  {
+    "Condition": {
+      "KeyPrefixEquals": "old.html"
+    },
+    "Redirect": {
+      "ReplaceKeyWith": "new.html",
+      "HttpRedirectCode": "301"
+    }
+  }
+]
+`
+	if got := b.String(); got != want {
+		t.Errorf("S3RoutingRulesJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestS3RoutingRulesLimit(t *testing.T) {
+	var src strings.Builder
+	for i := 0; i < maxS3Rules+2; i++ {
+		src.WriteString("p" + strings.Repeat("x", i) + ".html /q\n")
+	}
+	var b strings.Builder
+	skipped, err := S3RoutingRulesJSON(&b, mustParse(t, src.String()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 2 || skipped[0].Reason != "S3 allows at most 50 routing rules" {
+		t.Errorf("skipped %v", skipped)
+	}
+}
+
+func TestCloudFrontFunction(t *testing.T) {
+	m := mustParse(t, `docs/$1.md https://example.com/$1
+re:old/(?P<id>[0-9]+) /new/$id
+re:(?i)case/(.*) /lower/$1
+`)
+	var b strings.Builder
+	skipped, err := CloudFrontFunction(&b, m, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Reason != "regular expression is not supported in JavaScript" {
+		t.Errorf("skipped %v", skipped)
+	}
+	got := b.String()
+	for _, want := range []string{
+		`  [new RegExp("^/docs/(.*?)\\.md$"), 301, function (m) { return "https://example.com/" + (m[1] || ""); }],`,
+		`  [new RegExp("^/(?:old/([0-9]+))$"), 301, function (m) { return "/new/" + (m[1] || ""); }],`,
+		`var descriptions = { 301: "Moved Permanently" };`,
+		`function handler(event) {`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("CloudFrontFunction() is missing %s in\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// CaddyConfig configures Caddyfile.
+type CaddyConfig struct {
+	Options
+	// Rewrite rewrites requests internally instead of redirecting them.
+	// Rules whose destinations are not paths are skipped.
+	Rewrite bool
+}
+
+// Caddyfile writes a Caddyfile route block, to be imported into a site
+// block, with a path_regexp matcher and a redir or rewrite directive for
+// each rule, in evaluation order.
+func Caddyfile(w io.Writer, m *linkmap.Map, cfg CaddyConfig) ([]Skipped, error) {
+	rs, skipped := redirects(m, cfg.Options)
+	var b strings.Builder
+	b.WriteString("# Generated by linkmap. Do not edit.\nroute {\n")
+	for i, r := range rs {
+		name := fmt.Sprintf("linkmap%d", i)
+		var err error
+		for _, p := range r.target {
+			if strings.ContainsAny(p.lit, "{}") {
+				err = fmt.Errorf("destinations containing braces cannot be exported to Caddy")
+			}
+		}
+		if cfg.Rewrite && !strings.HasPrefix(r.target[0].lit, "/") {
+			err = fmt.Errorf("only paths can be rewritten")
+		}
+		if err != nil {
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: err.Error()})
+			continue
+		}
+		target := r.expand(func(g int) string { return fmt.Sprintf("{re.%s.%d}", name, g) })
+		fmt.Fprintf(&b, "\t@%s path_regexp %s %s\n", name, name, caddyQuote(r.pattern))
+		if cfg.Rewrite {
+			fmt.Fprintf(&b, "\trewrite @%s %s\n", name, caddyQuote(target))
+		} else {
+			fmt.Fprintf(&b, "\tredir @%s %s %d\n", name, caddyQuote(target), r.status)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return skipped, err
+}
+
+// caddyQuote returns s as a Caddyfile token, backquoted so that its
+// backslashes are kept.
+func caddyQuote(s string) string {
+	if !strings.Contains(s, "`") {
+		return "`" + s + "`"
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaddyfile(t *testing.T) {
+	m := mustParse(t, `docs/$1.md https://example.com/$1
+old.html /new.html
+`)
+	var b strings.Builder
+	skipped, err := Caddyfile(&b, m, CaddyConfig{})
+	if err != nil || len(skipped) > 0 {
+		t.Fatal(err, skipped)
+	}
+	want := "# Generated by linkmap. Do not edit.\nroute {\n" +
+		"\t@linkmap0 path_regexp linkmap0 `^/docs/(.*?)\\.md$`\n" +
+		"\tredir @linkmap0 `https://example.com/{re.linkmap0.1}` 301\n" +
+		"\t@linkmap1 path_regexp linkmap1 `^/old\\.html$`\n" +
+		"\tredir @linkmap1 `/new.html` 301\n" +
+		"}\n"
+	if got := b.String(); got != want {
+		t.Errorf("Caddyfile() =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	skipped, err = Caddyfile(&b, m, CaddyConfig{Rewrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Reason != "only paths can be rewritten" {
+		t.Errorf("skipped %v", skipped)
+	}
+	if got := b.String(); !strings.Contains(got, "\trewrite @linkmap1 `/new.html`\n") {
+		t.Errorf("Caddyfile() =\n%s", got)
+	}
+}
+
+func TestCaddyQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`^/a\.b$`, "`^/a\\.b$`"},
+		{"a`b\"c", "\"a`b\\\"c\""},
+	}
+	for _, tt := range tests {
+		if got := caddyQuote(tt.in); got != tt.want {
+			t.Errorf("caddyQuote(%q) = %s; want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package export converts a Map into the redirect configuration of proxies,
+// CDNs and cloud platforms, so that simple redirects can be served at the
+// edge without reaching the application.
+//
+// Rule inputs are treated as request paths without their leading '/'. Not
+// every rule can be represented on every platform: rules with conditions,
+// custom segments, repeated input variables, filters, text/template
+// outputs or file variables never can, and platforms without regular
+// expressions only take literal and prefix rules. Exporters leave such
+// rules out and report them as Skipped.
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// Options configure all exporters.
+type Options struct {
+	// Status is the redirect status for rules which do not set one. If
+	// zero, 301 is used.
+	Status int
+	// Resolver resolves $dest segments in outputs. If nil, rules using
+	// them are skipped.
+	Resolver linkmap.Resolver
+}
+
+// Skipped is a rule an exporter could not represent.
+type Skipped struct {
+	Rule   linkmap.Rule
+	Reason string
+}
+
+func (s Skipped) String() string {
+	return fmt.Sprintf("%s: %s %s: %s", s.Rule.Origin(), s.Rule.Input, s.Rule.Output, s.Reason)
+}
+
+// A redirect is a rule translated into a regular expression over request
+// paths and a target built from the expression's groups.
+type redirect struct {
+	rule   linkmap.Rule
+	status int
+	// pattern is anchored at both ends and only uses syntax common to RE2
+	// and PCRE.
+	pattern string
+	// groups is the number of groups in pattern.
+	groups int
+	target []piece
+}
+
+// A piece of a redirect target is literal text or, if group is positive,
+// the text matched by that group.
+type piece struct {
+	lit   string
+	group int
+}
+
+// prefix reports whether the redirect maps a literal path prefix to a
+// literal target prefix, i.e. its input is a literal ending in '/' followed
+// by a single variable, which is also the last piece of the target. It
+// returns the input prefix with its leading '/', and the target prefix.
+func (r *redirect) prefix() (from, to string, ok bool) {
+	in := r.input()
+	if len(in) != 2 || in[0].Kind != linkmap.LiteralSegment || in[1].Kind != linkmap.VariableSegment ||
+		!strings.HasSuffix(in[0].Value, "/") {
+		return "", "", false
+	}
+	if len(r.target) != 2 || r.target[0].group != 0 || r.target[1].group != 1 {
+		return "", "", false
+	}
+	return "/" + in[0].Value, r.target[0].lit, true
+}
+
+// literal reports whether the redirect maps a single literal path to a
+// literal target, returning the path with its leading '/', and the target.
+func (r *redirect) literal() (from, to string, ok bool) {
+	in := r.input()
+	if len(in) != 1 || in[0].Kind != linkmap.LiteralSegment || len(r.target) != 1 || r.target[0].group != 0 {
+		return "", "", false
+	}
+	return "/" + in[0].Value, r.target[0].lit, true
+}
+
+// input returns the segments of the rule's input, ending, for an input
+// which matches a prefix of paths, with a variable for the rest of the
+// path.
+func (r *redirect) input() linkmap.AST {
+	in := r.rule.Input.AST()
+	if r.rule.Input.Prefix() {
+		in = append(in, linkmap.Var(restVar))
+	}
+	return in
+}
+
+// restVar names the group of the rest of the path in prefix rules; it is
+// not a valid variable name.
+const restVar = ""
+
+// expand renders the target, writing group references with ref.
+func (r *redirect) expand(ref func(group int) string) string {
+	var b strings.Builder
+	for _, p := range r.target {
+		if p.group > 0 {
+			b.WriteString(ref(p.group))
+		} else {
+			b.WriteString(p.lit)
+		}
+	}
+	return b.String()
+}
+
+// redirects translates the rules of m, in evaluation order.
+func redirects(m *linkmap.Map, opts Options) ([]*redirect, []Skipped) {
+	var (
+		rs      []*redirect
+		skipped []Skipped
+	)
+	for _, rule := range m.Rules() {
+		r, err := translate(rule, opts)
+		if err != nil {
+			skipped = append(skipped, Skipped{Rule: rule, Reason: err.Error()})
+			continue
+		}
+		rs = append(rs, r)
+	}
+	return rs, skipped
+}
+
+func translate(rule linkmap.Rule, opts Options) (*redirect, error) {
+	r := &redirect{rule: rule, status: rule.Status}
+	if r.status == 0 {
+		r.status = opts.Status
+	}
+	if r.status == 0 {
+		r.status = 301
+	}
+	if r.status < 300 || r.status > 399 {
+		return nil, fmt.Errorf("status %d is not a redirect", r.status)
+	}
+	if len(rule.Conditions) > 0 {
+		return nil, fmt.Errorf("conditions cannot be exported")
+	}
+	if len(rule.Weighted) > 0 {
+		return nil, fmt.Errorf("weighted outputs cannot be exported")
+	}
+	if len(rule.Scheduled) > 0 {
+		return nil, fmt.Errorf("scheduled outputs cannot be exported")
+	}
+	groups, err := r.translateInput()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.translateOutput(groups, opts.Resolver); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// translateInput sets the redirect's pattern, returning the group bound
+// to each variable.
+func (r *redirect) translateInput() (map[string]int, error) {
+	groups := make(map[string]int)
+	if src := r.rule.Input.String(); strings.HasPrefix(src, "re:") {
+		re, err := regexp.Compile(src[len("re:"):])
+		if err != nil {
+			return nil, err
+		}
+		for i, name := range re.SubexpNames()[1:] {
+			groups[strconv.Itoa(i+1)] = i + 1
+			if name != "" {
+				groups[name] = i + 1
+			}
+		}
+		r.pattern, r.groups = `^/(?:`+src[len("re:"):]+`)$`, re.NumSubexp()
+		return groups, nil
+	}
+	in := r.input()
+	var b strings.Builder
+	b.WriteString("^/")
+	for i, s := range in {
+		switch s.Kind {
+		case linkmap.LiteralSegment:
+			b.WriteString(regexp.QuoteMeta(s.Value))
+		case linkmap.VariableSegment:
+			if _, ok := groups[s.Value]; ok {
+				// Linkmap requires both to match the same text, which
+				// takes a back-reference; RE2 has none.
+				return nil, fmt.Errorf("repeated variable $%s cannot be exported", s.Value)
+			}
+			r.groups++
+			groups[s.Value] = r.groups
+			switch {
+			case s.Value == restVar && i > 0 && !strings.HasSuffix(in[i-1].Value, "/"):
+				// The rest starts at a path element boundary.
+				b.WriteString("((?:/.*)?)")
+			case i == len(in)-1:
+				b.WriteString("(.*)")
+			case in[i+1].Kind == linkmap.LiteralSegment && len(in[i+1].Value) == 1:
+				b.WriteString("([^" + regexp.QuoteMeta(in[i+1].Value) + "]*)")
+			default:
+				b.WriteString("(.*?)")
+			}
+		case linkmap.ExtensionSegment:
+			alts := make([]string, len(s.Extensions))
+			for j, ext := range s.Extensions {
+				if pre := strings.TrimSuffix(ext, "*"); pre != ext {
+					alts[j] = regexp.QuoteMeta(pre) + "[^/]*"
+				} else {
+					alts[j] = regexp.QuoteMeta(ext)
+				}
+			}
+			b.WriteString("(?:" + strings.Join(alts, "|") + ")")
+		default:
+			return nil, fmt.Errorf("%s cannot be exported", linkmap.AST{s})
+		}
+	}
+	b.WriteString("$")
+	r.pattern = b.String()
+	return groups, nil
+}
+
+// translateOutput sets the redirect's target.
+func (r *redirect) translateOutput(groups map[string]int, resolver linkmap.Resolver) error {
+	out := r.rule.Output.AST()
+	if r.rule.Output.Prefix() {
+		if _, ok := groups[restVar]; ok {
+			out = append(out, linkmap.Var(restVar))
+		}
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("text/template outputs cannot be exported")
+	}
+	for _, s := range out {
+		switch s.Kind {
+		case linkmap.LiteralSegment:
+			r.target = append(r.target, piece{lit: s.Value})
+		case linkmap.VariableSegment:
+			g, ok := groups[s.Value]
+			if !ok {
+				return fmt.Errorf("$%s is not bound by the input", s.Value)
+			}
+			if len(s.Filters) > 0 {
+				// Edge configurations can only copy a capture; exporting
+				// the raw value would, for hashing filters, publish what
+				// the filter hides.
+				return fmt.Errorf("%s cannot be exported", linkmap.AST{s})
+			}
+			r.target = append(r.target, piece{group: g})
+		case linkmap.DestSegment:
+			if resolver == nil {
+				return fmt.Errorf("no resolver for %s", linkmap.AST{s})
+			}
+			dest, err := resolver.Resolve(s.Value)
+			if err != nil {
+				return fmt.Errorf("resolving %s: %w", linkmap.AST{s}, err)
+			}
+			r.target = append(r.target, piece{lit: dest})
+		default:
+			return fmt.Errorf("%s cannot be exported", linkmap.AST{s})
+		}
+	}
+	// Merge adjacent literals, e.g. from destinations.
+	merged := r.target[:1]
+	for _, p := range r.target[1:] {
+		if last := &merged[len(merged)-1]; p.group == 0 && last.group == 0 {
+			last.lit += p.lit
+		} else {
+			merged = append(merged, p)
+		}
+	}
+	r.target = merged
+	// Relative outputs are taken to be paths.
+	if first := &r.target[0]; first.group == 0 && !strings.HasPrefix(first.lit, "/") && !strings.Contains(first.lit, "://") {
+		first.lit = "/" + first.lit
+	}
+	return nil
+}
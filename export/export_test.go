@@ -0,0 +1,158 @@
+package export
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/operandinc/linkmap"
+)
+
+func mustParse(t *testing.T, src string, opts ...linkmap.Option) *linkmap.Map {
+	t.Helper()
+	m, err := linkmap.Parse(strings.NewReader(src), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRedirects(t *testing.T) {
+	m := mustParse(t, `docs/$1.md https://example.com/docs/$1
+blog/$year/$slug.{md,mdx} https://example.com/$year/$slug
+assets/$1.{png*} https://cdn.example.com/$1
+wiki/$1 $dest(wiki)/$1
+re:old/(?P<id>[0-9]+) /new/$id
+about.md about
+`, linkmap.WithResolver(linkmap.MapResolver{"wiki": "https://wiki.example.com"}))
+	rs, skipped := redirects(m, Options{Resolver: linkmap.MapResolver{"wiki": "https://wiki.example.com"}})
+	if len(skipped) > 0 {
+		t.Fatalf("skipped %v", skipped)
+	}
+	paths := []string{
+		"docs/a.md", "docs/a/b.md", "blog/2020/hello.mdx", "blog/2020/x/y.md",
+		"assets/logo.png", "assets/logo.pngx", "wiki/a/b", "old/12", "old/x", "about.md",
+	}
+	for _, p := range paths {
+		want, err := m.Evaluate(p)
+		if err != nil {
+			want = ""
+		}
+		if strings.HasPrefix(want, "about") {
+			want = "/" + want
+		}
+		var got string
+		for _, r := range rs {
+			sub := regexp.MustCompile(r.pattern).FindStringSubmatch("/" + p)
+			if sub != nil {
+				got = r.expand(func(g int) string { return sub[g] })
+				break
+			}
+		}
+		if got != want {
+			t.Errorf("%s: exported redirect gives %q; want %q", p, got, want)
+		}
+	}
+}
+
+func TestRedirectsPrefix(t *testing.T) {
+	m := mustParse(t, `docs/* https://docs.example.com/*
+api* https://api.example.com*
+old/$1/* /new/$1
+`, linkmap.WithPrefixMatching())
+	rs, skipped := redirects(m, Options{})
+	if len(skipped) > 0 {
+		t.Fatalf("skipped %v", skipped)
+	}
+	for _, p := range []string{"docs/a/b.md", "api", "api/v1/users", "apis/x", "old/a/b/c"} {
+		want, err := m.Evaluate(p)
+		if err != nil {
+			want = ""
+		}
+		var got string
+		for _, r := range rs {
+			sub := regexp.MustCompile(r.pattern).FindStringSubmatch("/" + p)
+			if sub != nil {
+				got = r.expand(func(g int) string { return sub[g] })
+				break
+			}
+		}
+		if got != want {
+			t.Errorf("%s: exported redirect gives %q; want %q", p, got, want)
+		}
+	}
+	// Rules with more segments come first.
+	if from, to, ok := rs[1].prefix(); !ok || from != "/docs/" || to != "https://docs.example.com/" {
+		t.Errorf("prefix() = %q, %q, %v; want /docs/ to https://docs.example.com/", from, to, ok)
+	}
+}
+
+func TestRedirectsSkipped(t *testing.T) {
+	m := mustParse(t, `a/$1 tmpl:{{.1}}
+b/$1 https://example.com/$2
+c/$1 $dest(x)/$1
+d/$1.css https://example.com/$1.$hash.css
+e/<date> https://example.com/<date>
+f/$1 https://example.com/$1|trimprefix:a
+g/$1 https://example.com/$1|pad:4
+h/$1 https://example.com/$1|sha1:8
+i/$1/$1.md https://example.com/$1
+`, linkmap.WithSegment("date", linkmap.SegmentMatcherFunc(func(s string) int { return len(s) })), linkmap.WithTmplOutputs())
+	_, skipped := redirects(m, Options{})
+	want := map[string]string{
+		"a/$1":       "text/template outputs cannot be exported",
+		"b/$1":       "$2 is not bound by the input",
+		"c/$1":       "no resolver for $dest(x)",
+		"d/$1.css":   "$hash is not bound by the input",
+		"e/<date>":   "<date> cannot be exported",
+		"f/$1":       "$1|trimprefix:a cannot be exported",
+		"g/$1":       "$1|pad:4 cannot be exported",
+		"h/$1":       "$1|sha1:8 cannot be exported",
+		"i/$1/$1.md": "repeated variable $1 cannot be exported",
+	}
+	if len(skipped) != len(want) {
+		t.Fatalf("skipped %v", skipped)
+	}
+	for _, s := range skipped {
+		if reason := want[s.Rule.Input.String()]; s.Reason != reason {
+			t.Errorf("%s skipped for %q; want %q", s.Rule.Input, s.Reason, reason)
+		}
+	}
+
+	_, skipped = redirects(mustParse(t, "a/$1 /b/$1\n"), Options{Status: 200})
+	if len(skipped) != 1 || skipped[0].Reason != "status 200 is not a redirect" {
+		t.Errorf("skipped %v", skipped)
+	}
+}
+
+func TestRedirectShapes(t *testing.T) {
+	tests := []struct {
+		rule            string
+		literal, prefix bool
+		from, to        string
+	}{
+		{"old.md /new", true, false, "/old.md", "/new"},
+		{"docs/$1 https://example.com/guide/$1", false, true, "/docs/", "https://example.com/guide/"},
+		{"docs/$1.md https://example.com/$1", false, false, "", ""},
+		{"docs/$1 https://example.com/$1/x", false, false, "", ""},
+	}
+	for _, tt := range tests {
+		rs, _ := redirects(mustParse(t, tt.rule+"\n"), Options{})
+		from, to, ok := rs[0].literal()
+		if ok != tt.literal || ok && (from != tt.from || to != tt.to) {
+			t.Errorf("%s: literal() = %q, %q, %v", tt.rule, from, to, ok)
+		}
+		from, to, ok = rs[0].prefix()
+		if ok != tt.prefix || ok && (from != tt.from || to != tt.to) {
+			t.Errorf("%s: prefix() = %q, %q, %v", tt.rule, from, to, ok)
+		}
+	}
+}
+
+func TestSkippedString(t *testing.T) {
+	m := mustParse(t, "a/$1 tmpl:{{.1}}\n", linkmap.WithTmplOutputs())
+	_, skipped := redirects(m, Options{})
+	if got, want := skipped[0].String(), "line 1: a/$1 tmpl:{{.1}}: text/template outputs cannot be exported"; got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+}
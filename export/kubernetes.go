@@ -0,0 +1,199 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// IngressConfig configures Ingress.
+type IngressConfig struct {
+	Options
+	// Name and Namespace identify the Ingress.
+	Name, Namespace string
+	// Host is the host the redirects apply to.
+	Host string
+	// Service and Port name the backend which serves requests that are
+	// not redirected.
+	Service string
+	Port    int
+	// ClassName is the ingress class. If empty, "nginx" is used.
+	ClassName string
+}
+
+// Ingress writes a Kubernetes Ingress for ingress-nginx which serves the
+// map's redirects from a server-snippet annotation, in evaluation order.
+// Snippet annotations must be allowed by the controller. Rules with more
+// than nine variables are skipped.
+func Ingress(w io.Writer, m *linkmap.Map, cfg IngressConfig) ([]Skipped, error) {
+	rs, skipped := redirects(m, cfg.Options)
+	var snippet strings.Builder
+	for _, r := range rs {
+		if r.groups > 9 {
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: "nginx supports at most 9 captures"})
+			continue
+		}
+		target := r.expand(func(g int) string { return "$" + strconv.Itoa(g) })
+		fmt.Fprintf(&snippet, "location ~ %s {\n  return %d %s;\n}\n", nginxQuote(r.pattern), r.status, nginxQuote(target))
+	}
+	class := cfg.ClassName
+	if class == "" {
+		class = "nginx"
+	}
+	var b strings.Builder
+	b.WriteString("apiVersion: networking.k8s.io/v1\nkind: Ingress\n")
+	writeMetadata(&b, cfg.Name, cfg.Namespace)
+	b.WriteString("  annotations:\n")
+	b.WriteString("    nginx.ingress.kubernetes.io/server-snippet: |\n")
+	for _, l := range strings.SplitAfter(snippet.String(), "\n") {
+		if l != "" {
+			b.WriteString("      " + l)
+		}
+	}
+	fmt.Fprintf(&b, "spec:\n  ingressClassName: %s\n", yamlQuote(class))
+	fmt.Fprintf(&b, "  rules:\n  - host: %s\n", yamlQuote(cfg.Host))
+	b.WriteString("    http:\n      paths:\n      - path: /\n        pathType: Prefix\n")
+	fmt.Fprintf(&b, "        backend:\n          service:\n            name: %s\n            port:\n              number: %d\n",
+		yamlQuote(cfg.Service), cfg.Port)
+	_, err := io.WriteString(w, b.String())
+	return skipped, err
+}
+
+// HTTPRouteConfig configures HTTPRoute.
+type HTTPRouteConfig struct {
+	Options
+	// Name and Namespace identify the HTTPRoute.
+	Name, Namespace string
+	// Gateways are the names of the parent Gateways.
+	Gateways []string
+	// Hostnames are the hostnames the redirects apply to.
+	Hostnames []string
+}
+
+// maxRouteRules is the maximum number of rules in an HTTPRoute.
+const maxRouteRules = 16
+
+// HTTPRoute writes Gateway API HTTPRoutes with a RequestRedirect filter for
+// each rule which maps a literal path, or a literal prefix ending in '/'
+// followed by a variable, to a literal destination or one ending in the
+// same variable. Other rules, and statuses other than 301 and 302, are
+// skipped. Gateways order matches by specificity rather than by rule
+// order; routes beyond the first are suffixed with their number, as an
+// HTTPRoute holds at most 16 rules.
+func HTTPRoute(w io.Writer, m *linkmap.Map, cfg HTTPRouteConfig) ([]Skipped, error) {
+	rs, skipped := redirects(m, cfg.Options)
+	var rules []string
+	for _, r := range rs {
+		rule, err := routeRule(r)
+		if err != nil {
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: err.Error()})
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	var b strings.Builder
+	for i := 0; i < len(rules) || i == 0; i += maxRouteRules {
+		name := cfg.Name
+		if i > 0 {
+			b.WriteString("---\n")
+			name = fmt.Sprintf("%s-%d", cfg.Name, i/maxRouteRules+1)
+		}
+		b.WriteString("apiVersion: gateway.networking.k8s.io/v1\nkind: HTTPRoute\n")
+		writeMetadata(&b, name, cfg.Namespace)
+		b.WriteString("spec:\n  parentRefs:\n")
+		for _, gw := range cfg.Gateways {
+			fmt.Fprintf(&b, "  - name: %s\n", yamlQuote(gw))
+		}
+		if len(cfg.Hostnames) > 0 {
+			b.WriteString("  hostnames:\n")
+			for _, h := range cfg.Hostnames {
+				fmt.Fprintf(&b, "  - %s\n", yamlQuote(h))
+			}
+		}
+		end := i + maxRouteRules
+		if end > len(rules) {
+			end = len(rules)
+		}
+		if i == end {
+			b.WriteString("  rules: []\n")
+			break
+		}
+		b.WriteString("  rules:\n")
+		for _, rule := range rules[i:end] {
+			b.WriteString(rule)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return skipped, err
+}
+
+// routeRule returns the HTTPRoute rule for r.
+func routeRule(r *redirect) (string, error) {
+	if r.status != 301 && r.status != 302 {
+		return "", fmt.Errorf("HTTPRoute redirects only support status 301 and 302")
+	}
+	typ, replaceType, replace := "Exact", "ReplaceFullPath", "replaceFullPath"
+	from, to, ok := r.literal()
+	if !ok {
+		typ, replaceType, replace = "PathPrefix", "ReplacePrefixMatch", "replacePrefixMatch"
+		if from, to, ok = r.prefix(); !ok {
+			return "", fmt.Errorf("HTTPRoute redirects only support literal paths and prefixes")
+		}
+		if !strings.HasSuffix(to, "/") {
+			return "", fmt.Errorf("HTTPRoute prefix redirects need a destination ending in '/'")
+		}
+		// Prefixes match whole path elements, so the trailing '/' is
+		// implied.
+		from, to = strings.TrimSuffix(from, "/"), strings.TrimSuffix(to, "/")
+	}
+	u, err := url.Parse(to)
+	if err != nil {
+		return "", err
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("HTTPRoute redirects cannot set a query, fragment or user")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "  - matches:\n    - path:\n        type: %s\n        value: %s\n", typ, yamlQuote(from))
+	b.WriteString("    filters:\n    - type: RequestRedirect\n      requestRedirect:\n")
+	if u.Scheme != "" {
+		fmt.Fprintf(&b, "        scheme: %s\n", yamlQuote(u.Scheme))
+	}
+	if h := u.Hostname(); h != "" {
+		fmt.Fprintf(&b, "        hostname: %s\n", yamlQuote(h))
+	}
+	if p := u.Port(); p != "" {
+		fmt.Fprintf(&b, "        port: %s\n", p)
+	}
+	p := u.EscapedPath()
+	if typ == "PathPrefix" && p == "" {
+		p = "/"
+	}
+	if p != "" {
+		fmt.Fprintf(&b, "        path:\n          type: %s\n          %s: %s\n", replaceType, replace, yamlQuote(p))
+	}
+	fmt.Fprintf(&b, "        statusCode: %d\n", r.status)
+	return b.String(), nil
+}
+
+func writeMetadata(b *strings.Builder, name, namespace string) {
+	fmt.Fprintf(b, "metadata:\n  name: %s\n", yamlQuote(name))
+	if namespace != "" {
+		fmt.Fprintf(b, "  namespace: %s\n", yamlQuote(namespace))
+	}
+}
+
+// yamlQuote returns s as a YAML double-quoted scalar. Go's escapes are a
+// subset of YAML's.
+func yamlQuote(s string) string {
+	return strconv.Quote(s)
+}
+
+// nginxQuote returns s as an nginx double-quoted string.
+func nginxQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,148 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIngress(t *testing.T) {
+	m := mustParse(t, `docs/$1.md https://example.com/$1
+old.html /new
+`)
+	var b strings.Builder
+	skipped, err := Ingress(&b, m, IngressConfig{Name: "redirects", Namespace: "web", Host: "example.com", Service: "app", Port: 8080})
+	if err != nil || len(skipped) > 0 {
+		t.Fatal(err, skipped)
+	}
+	want := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: "redirects"
+  namespace: "web"
+  annotations:
+    nginx.ingress.kubernetes.io/server-snippet: |
+      location ~ "^/docs/(.*?)\\.md$" {
+        return 301 "https://example.com/$1";
+      }
+      location ~ "^/old\\.html$" {
+        return 301 "/new";
+      }
+spec:
+  ingressClassName: "nginx"
+  rules:
+  - host: "example.com"
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: "app"
+            port:
+              number: 8080
+`
+	if got := b.String(); got != want {
+		t.Errorf("Ingress() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHTTPRoute(t *testing.T) {
+	m := mustParse(t, `docs/$1 https://example.com/guide/$1
+old.html /new
+posts/$1.md https://example.com/$1
+`)
+	var b strings.Builder
+	skipped, err := HTTPRoute(&b, m, HTTPRouteConfig{Name: "redirects", Gateways: []string{"edge"}, Hostnames: []string{"example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Rule.Input.String() != "posts/$1.md" {
+		t.Errorf("skipped %v", skipped)
+	}
+	want := `apiVersion: gateway.networking.k8s.io/v1
+kind: HTTPRoute
+metadata:
+  name: "redirects"
+spec:
+  parentRefs:
+  - name: "edge"
+  hostnames:
+  - "example.com"
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: "/docs"
+    filters:
+    - type: RequestRedirect
+      requestRedirect:
+        scheme: "https"
+        hostname: "example.com"
+        path:
+          type: ReplacePrefixMatch
+          replacePrefixMatch: "/guide"
+        statusCode: 301
+  - matches:
+    - path:
+        type: Exact
+        value: "/old.html"
+    filters:
+    - type: RequestRedirect
+      requestRedirect:
+        path:
+          type: ReplaceFullPath
+          replaceFullPath: "/new"
+        statusCode: 301
+`
+	if got := b.String(); got != want {
+		t.Errorf("HTTPRoute() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHTTPRouteSplit(t *testing.T) {
+	var src strings.Builder
+	for i := 0; i < maxRouteRules+1; i++ {
+		fmt.Fprintf(&src, "p%d.html /q%d\n", i, i)
+	}
+	var b strings.Builder
+	if _, err := HTTPRoute(&b, mustParse(t, src.String()), HTTPRouteConfig{Name: "r"}); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if n := strings.Count(got, "kind: HTTPRoute"); n != 2 || !strings.Contains(got, "---\n") || !strings.Contains(got, `name: "r-2"`) {
+		t.Errorf("HTTPRoute() with %d rules =\n%s", maxRouteRules+1, got)
+	}
+	if n := strings.Count(got, "- matches:"); n != maxRouteRules+1 {
+		t.Errorf("HTTPRoute() wrote %d rules; want %d", n, maxRouteRules+1)
+	}
+}
+
+func TestHTTPRouteSkipped(t *testing.T) {
+	tests := []struct {
+		rule, reason string
+	}{
+		{"a/$1 https://example.com/b$1", "HTTPRoute prefix redirects need a destination ending in '/'"},
+		{"a.html https://example.com/b?x=1", "HTTPRoute redirects cannot set a query, fragment or user"},
+		{"a/$1 https://example.com/$1.html", "HTTPRoute redirects only support literal paths and prefixes"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		skipped, err := HTTPRoute(&b, mustParse(t, tt.rule+"\n"), HTTPRouteConfig{Name: "r"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(skipped) != 1 || skipped[0].Reason != tt.reason {
+			t.Errorf("%s: skipped %v; want %q", tt.rule, skipped, tt.reason)
+		}
+		if !strings.Contains(b.String(), "rules: []") {
+			t.Errorf("%s: HTTPRoute() =\n%s", tt.rule, b.String())
+		}
+	}
+	m := mustParse(t, "a.html /b\n")
+	var b strings.Builder
+	skipped, _ := HTTPRoute(&b, m, HTTPRouteConfig{Name: "r", Options: Options{Status: 308}})
+	if len(skipped) != 1 || skipped[0].Reason != "HTTPRoute redirects only support status 301 and 302" {
+		t.Errorf("skipped %v", skipped)
+	}
+}
@@ -0,0 +1,198 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// A Redirect is a redirect in the platform-neutral form plans compare: a
+// regular expression over request paths, anchored at both ends, and a
+// target referring to its groups as $1, $2 and so on.
+type Redirect struct {
+	Pattern string `json:"pattern"`
+	Target  string `json:"target"`
+	Status  int    `json:"status"`
+}
+
+func (r Redirect) String() string {
+	return fmt.Sprintf("%s -> %s (%d)", r.Pattern, r.Target, r.Status)
+}
+
+// Redirects returns the map's rules in the form plans compare, in
+// evaluation order, along with the rules which cannot be redirects.
+func Redirects(m *linkmap.Map, opts Options) ([]Redirect, []Skipped) {
+	rs, skipped := redirects(m, opts)
+	out := make([]Redirect, len(rs))
+	for i, r := range rs {
+		out[i] = r.neutral()
+	}
+	return out, skipped
+}
+
+func (r *redirect) neutral() Redirect {
+	return Redirect{
+		Pattern: r.pattern,
+		Target:  r.expand(func(g int) string { return "$" + strconv.Itoa(g) }),
+		Status:  r.status,
+	}
+}
+
+// A Change replaces the target or status of the redirect for a pattern.
+type Change struct {
+	From Redirect `json:"from"`
+	To   Redirect `json:"to"`
+}
+
+// A Plan lists the changes which bring the redirects of a platform in line
+// with a map. Redirects are identified by their pattern.
+type Plan struct {
+	Add    []Redirect `json:"add"`
+	Change []Change   `json:"change"`
+	Remove []Redirect `json:"remove"`
+	// Reorder is set if redirects present in both are in a different
+	// order, which matters on platforms where the first match wins.
+	Reorder bool `json:"reorder"`
+	// Unchanged counts the redirects which are already in place.
+	Unchanged int `json:"unchanged"`
+}
+
+// NewPlan compares the desired redirects, from Redirects or S3Redirects,
+// with those currently served, e.g. as read by ReadS3RoutingRulesJSON.
+func NewPlan(desired, current []Redirect) *Plan {
+	p := &Plan{Add: []Redirect{}, Change: []Change{}, Remove: []Redirect{}}
+	cur := make(map[string]int, len(current))
+	for i, r := range current {
+		cur[r.Pattern] = i
+	}
+	want := make(map[string]bool, len(desired))
+	last := -1
+	for _, r := range desired {
+		want[r.Pattern] = true
+		i, ok := cur[r.Pattern]
+		switch {
+		case !ok:
+			p.Add = append(p.Add, r)
+			continue
+		case current[i] != r:
+			p.Change = append(p.Change, Change{From: current[i], To: r})
+		default:
+			p.Unchanged++
+		}
+		if i < last {
+			p.Reorder = true
+		}
+		last = i
+	}
+	for _, r := range current {
+		if !want[r.Pattern] {
+			p.Remove = append(p.Remove, r)
+		}
+	}
+	return p
+}
+
+// Empty reports whether the plan makes no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Add) == 0 && len(p.Change) == 0 && len(p.Remove) == 0 && !p.Reorder
+}
+
+// String returns a summary of the plan, one change per line.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, r := range p.Add {
+		fmt.Fprintf(&b, "+ %s\n", r)
+	}
+	for _, c := range p.Change {
+		fmt.Fprintf(&b, "~ %s\n    => %s\n", c.From, c.To)
+	}
+	for _, r := range p.Remove {
+		fmt.Fprintf(&b, "- %s\n", r)
+	}
+	if p.Reorder {
+		b.WriteString("~ reorder\n")
+	}
+	fmt.Fprintf(&b, "%d to add, %d to change, %d to remove, %d unchanged.\n",
+		len(p.Add), len(p.Change), len(p.Remove), p.Unchanged)
+	return b.String()
+}
+
+// S3Redirects is like Redirects but returns only the redirects which
+// S3RoutingRules can export, as ReadS3RoutingRulesJSON reads them back.
+func S3Redirects(m *linkmap.Map, opts Options) ([]Redirect, []Skipped) {
+	rules, skipped := s3Rules(m, opts)
+	out := make([]Redirect, 0, len(rules))
+	for _, rule := range rules {
+		r, err := rule.neutral()
+		if err != nil {
+			panic(err) // s3Rule only writes valid codes
+		}
+		out = append(out, r)
+	}
+	return out, skipped
+}
+
+// ReadS3RoutingRulesJSON reads S3 website routing rules in the JSON form
+// written by S3RoutingRulesJSON, or a website configuration holding them as
+// written by aws s3api get-bucket-website. Rules conditioned on error codes
+// are not redirects and are left out.
+func ReadS3RoutingRulesJSON(r io.Reader) ([]Redirect, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("linkmap: reading S3 routing rules: %w", err)
+	}
+	var rules []s3RoutingRule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		var website struct{ RoutingRules []s3RoutingRule }
+		if json.Unmarshal(raw, &website) != nil {
+			return nil, fmt.Errorf("linkmap: reading S3 routing rules: %w", err)
+		}
+		rules = website.RoutingRules
+	}
+	var out []Redirect
+	for _, rule := range rules {
+		if rule.Condition.HttpErrorCodeReturnedEquals != "" {
+			continue
+		}
+		red, err := rule.neutral()
+		if err != nil {
+			return nil, fmt.Errorf("linkmap: reading S3 routing rules: %w", err)
+		}
+		out = append(out, red)
+	}
+	return out, nil
+}
+
+func (rule s3RoutingRule) neutral() (Redirect, error) {
+	red := Redirect{Status: 301}
+	if code := rule.Redirect.HttpRedirectCode; code != "" {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return Redirect{}, fmt.Errorf("invalid HttpRedirectCode %q", code)
+		}
+		red.Status = status
+	}
+	target := "/"
+	if h := rule.Redirect.HostName; h != "" {
+		scheme := rule.Redirect.Protocol
+		if scheme == "" {
+			scheme = "http"
+		}
+		target = (&url.URL{Scheme: scheme, Host: h}).String() + "/"
+	}
+	prefix := regexp.QuoteMeta(rule.Condition.KeyPrefixEquals)
+	if key := rule.Redirect.ReplaceKeyWith; key != "" {
+		red.Pattern = "^/" + prefix + "$"
+		red.Target = target + key
+	} else {
+		red.Pattern = "^/" + prefix + "(.*)$"
+		red.Target = target + rule.Redirect.ReplaceKeyPrefixWith + "$1"
+	}
+	return red, nil
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewPlan(t *testing.T) {
+	a := Redirect{Pattern: "^/a$", Target: "/x", Status: 301}
+	b := Redirect{Pattern: "^/b(.*)$", Target: "/y$1", Status: 301}
+	c := Redirect{Pattern: "^/c$", Target: "/z", Status: 302}
+	b2 := Redirect{Pattern: b.Pattern, Target: "/w$1", Status: 301}
+	tests := []struct {
+		name             string
+		desired, current []Redirect
+		want             Plan
+	}{
+		{"same", []Redirect{a, b}, []Redirect{a, b}, Plan{Unchanged: 2}},
+		{"add", []Redirect{a, b}, []Redirect{a}, Plan{Add: []Redirect{b}, Unchanged: 1}},
+		{"remove", []Redirect{a}, []Redirect{a, c}, Plan{Remove: []Redirect{c}, Unchanged: 1}},
+		{"change", []Redirect{a, b2}, []Redirect{a, b}, Plan{Change: []Change{{From: b, To: b2}}, Unchanged: 1}},
+		{"reorder", []Redirect{b, a}, []Redirect{a, b}, Plan{Reorder: true, Unchanged: 2}},
+	}
+	for _, tt := range tests {
+		got := NewPlan(tt.desired, tt.current)
+		for _, s := range []*[]Redirect{&tt.want.Add, &tt.want.Remove} {
+			if *s == nil {
+				*s = []Redirect{}
+			}
+		}
+		if tt.want.Change == nil {
+			tt.want.Change = []Change{}
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: NewPlan() = %+v; want %+v", tt.name, *got, tt.want)
+		}
+		if got.Empty() != (tt.name == "same") {
+			t.Errorf("%s: Empty() = %v", tt.name, got.Empty())
+		}
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	p := NewPlan(
+		[]Redirect{{Pattern: "^/a$", Target: "/x", Status: 301}, {Pattern: "^/b$", Target: "/y", Status: 301}},
+		[]Redirect{{Pattern: "^/b$", Target: "/z", Status: 301}, {Pattern: "^/c$", Target: "/w", Status: 302}},
+	)
+	want := `+ ^/a$ -> /x (301)
+~ ^/b$ -> /z (301)
+    => ^/b$ -> /y (301)
+- ^/c$ -> /w (302)
+1 to add, 1 to change, 1 to remove, 0 unchanged.
+`
+	if got := p.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	buf, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), `"add":[{"pattern":"^/a$","target":"/x","status":301}]`) {
+		t.Errorf("json.Marshal() = %s", buf)
+	}
+}
+
+func TestS3Plan(t *testing.T) {
+	m := mustParse(t, `docs/$1 https://example.com/guide/$1
+old.html /new.html
+posts/$1.md https://example.com/$1
+`)
+	var b strings.Builder
+	if _, err := S3RoutingRulesJSON(&b, m, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	current, err := ReadS3RoutingRulesJSON(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired, skipped := S3Redirects(m, Options{})
+	if len(skipped) != 1 {
+		t.Errorf("skipped %v", skipped)
+	}
+	if p := NewPlan(desired, current); !p.Empty() || p.Unchanged != 2 {
+		t.Errorf("plan against exported rules:\n%s", p)
+	}
+	// The exported patterns agree with those of regular expression
+	// platforms.
+	all, _ := Redirects(m, Options{})
+	if p := NewPlan(all, current); len(p.Add) != 1 || len(p.Change)+len(p.Remove) != 0 || p.Reorder {
+		t.Errorf("plan of all rules against S3 rules:\n%s", p)
+	}
+
+	current, err = ReadS3RoutingRulesJSON(strings.NewReader(`{
+  "IndexDocument": {"Suffix": "index.html"},
+  "RoutingRules": [
+    {"Condition": {"HttpErrorCodeReturnedEquals": "404"}, "Redirect": {"HostName": "example.com"}},
+    {"Condition": {"KeyPrefixEquals": "docs/"}, "Redirect": {"Protocol": "https", "HostName": "example.com", "ReplaceKeyPrefixWith": "guide/", "HttpRedirectCode": "302"}}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Redirect{{Pattern: "^/docs/(.*)$", Target: "https://example.com/guide/$1", Status: 302}}
+	if !reflect.DeepEqual(current, want) {
+		t.Errorf("ReadS3RoutingRulesJSON() = %v; want %v", current, want)
+	}
+	if _, err := ReadS3RoutingRulesJSON(strings.NewReader(`[{"Redirect": {"HttpRedirectCode": "x"}}]`)); err == nil {
+		t.Error("ReadS3RoutingRulesJSON() accepted an invalid code")
+	}
+}
@@ -0,0 +1,153 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrCanceled is returned by Syncer.Sync when the plan is not confirmed.
+var ErrCanceled = errors.New("linkmap: sync canceled")
+
+// A Store is a remote copy of a map's redirects, such as a key-value
+// store, a database or a CDN's API.
+type Store interface {
+	// Fetch returns the redirects in the store, in order.
+	Fetch(ctx context.Context) ([]Redirect, error)
+	// Apply makes the store hold desired. The plan is the difference from
+	// the redirects last fetched, for stores which apply changes
+	// individually.
+	Apply(ctx context.Context, desired []Redirect, p *Plan) error
+}
+
+// A Syncer pushes redirects to a Store.
+type Syncer struct {
+	Store Store
+	// DryRun makes Sync return the plan without applying it.
+	DryRun bool
+	// Confirm, if set, is called with a plan which is not empty before it
+	// is applied. If it returns false, Sync returns ErrCanceled.
+	Confirm func(*Plan) bool
+}
+
+// Drift returns the plan which would bring the store in line with
+// desired. It is empty if the store has not drifted.
+func (s *Syncer) Drift(ctx context.Context, desired []Redirect) (*Plan, error) {
+	current, err := s.Store.Fetch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: fetching redirects: %w", err)
+	}
+	return NewPlan(desired, current), nil
+}
+
+// Sync brings the store in line with desired, returning the plan it
+// applied. Nothing is applied if the plan is empty, in dry-run mode, or if
+// the plan is not confirmed.
+func (s *Syncer) Sync(ctx context.Context, desired []Redirect) (*Plan, error) {
+	p, err := s.Drift(ctx, desired)
+	if err != nil || p.Empty() || s.DryRun {
+		return p, err
+	}
+	if s.Confirm != nil && !s.Confirm(p) {
+		return p, ErrCanceled
+	}
+	if err := s.Store.Apply(ctx, desired, p); err != nil {
+		return p, fmt.Errorf("linkmap: applying redirects: %w", err)
+	}
+	return p, nil
+}
+
+// An HTTPStore keeps redirects as a JSON array in a single document which
+// is read with GET and written with PUT, as key-value stores such as
+// Cloudflare Workers KV, Consul and object stores allow. If the server
+// sends an ETag, writes are conditional on it, so that changes made since
+// the last Fetch are not overwritten.
+type HTTPStore struct {
+	// URL is the URL of the document.
+	URL string
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+	// Client is used for requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	mu   sync.Mutex
+	etag string
+}
+
+// Fetch implements Store. A missing document holds no redirects.
+func (s *HTTPStore) Fetch(ctx context.Context) ([]Redirect, error) {
+	resp, err := s.do(ctx, http.MethodGet, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var redirects []Redirect
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&redirects); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", s.URL, err)
+		}
+	case http.StatusNotFound:
+	default:
+		return nil, fmt.Errorf("GET %s: %s", s.URL, resp.Status)
+	}
+	s.mu.Lock()
+	s.etag = resp.Header.Get("ETag")
+	s.mu.Unlock()
+	return redirects, nil
+}
+
+// Apply implements Store by replacing the document.
+func (s *HTTPStore) Apply(ctx context.Context, desired []Redirect, _ *Plan) error {
+	if desired == nil {
+		desired = []Redirect{}
+	}
+	buf, err := json.MarshalIndent(desired, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	etag := s.etag
+	s.mu.Unlock()
+	resp, err := s.do(ctx, http.MethodPut, bytes.NewReader(append(buf, '\n')), etag)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return fmt.Errorf("PUT %s: document changed since it was fetched", s.URL)
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("PUT %s: %s", s.URL, resp.Status)
+	}
+	s.mu.Lock()
+	s.etag = resp.Header.Get("ETag")
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *HTTPStore) do(ctx context.Context, method string, body io.Reader, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	c := s.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return c.Do(req)
+}
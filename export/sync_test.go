@@ -0,0 +1,112 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// kvServer is an in-memory document store with ETags.
+type kvServer struct {
+	mu      sync.Mutex
+	doc     []byte
+	version int
+	puts    int
+}
+
+func (kv *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	etag := fmt.Sprintf(`"%d"`, kv.version)
+	switch r.Method {
+	case http.MethodGet:
+		if kv.doc == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(kv.doc)
+	case http.MethodPut:
+		if m := r.Header.Get("If-Match"); m != "" && m != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		kv.doc, _ = io.ReadAll(r.Body)
+		kv.version++
+		kv.puts++
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, kv.version))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	kv := &kvServer{}
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+	ctx := context.Background()
+	desired, _ := Redirects(mustParse(t, "docs/$1 /guide/$1\nold.html /new.html\n"), Options{})
+
+	dry := &Syncer{Store: &HTTPStore{URL: srv.URL}, DryRun: true}
+	p, err := dry.Sync(ctx, desired)
+	if err != nil || len(p.Add) != 2 || kv.puts != 0 {
+		t.Fatalf("dry run: %v, %v, %d puts", p, err, kv.puts)
+	}
+
+	var confirmed *Plan
+	s := &Syncer{Store: &HTTPStore{URL: srv.URL}, Confirm: func(p *Plan) bool {
+		confirmed = p
+		return false
+	}}
+	if _, err := s.Sync(ctx, desired); !errors.Is(err, ErrCanceled) || confirmed == nil || kv.puts != 0 {
+		t.Fatalf("declined sync: %v, %d puts", err, kv.puts)
+	}
+
+	s.Confirm = nil
+	if _, err := s.Sync(ctx, desired); err != nil || kv.puts != 1 {
+		t.Fatalf("sync: %v, %d puts", err, kv.puts)
+	}
+	p, err = s.Drift(ctx, desired)
+	if err != nil || !p.Empty() || p.Unchanged != 2 {
+		t.Fatalf("drift after sync: %v, %v", p, err)
+	}
+	if p, err = s.Sync(ctx, desired); err != nil || !p.Empty() || kv.puts != 1 {
+		t.Fatalf("second sync: %v, %v, %d puts", p, err, kv.puts)
+	}
+
+	// Someone edits the store between fetch and apply.
+	store := &HTTPStore{URL: srv.URL}
+	current, err := store.Fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kv.mu.Lock()
+	kv.version++
+	kv.mu.Unlock()
+	if err := store.Apply(ctx, current[:1], nil); err == nil {
+		t.Error("Apply() overwrote a concurrent change")
+	}
+}
+
+func TestHTTPStoreErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("not json"))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	if _, err := (&HTTPStore{URL: srv.URL}).Fetch(ctx); err == nil {
+		t.Error("Fetch() without credentials succeeded")
+	}
+	store := &HTTPStore{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer t"}}}
+	if _, err := store.Fetch(ctx); err == nil {
+		t.Error("Fetch() of an invalid document succeeded")
+	}
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/operandinc/linkmap"
+)
+
+// TraefikConfig configures Traefik.
+type TraefikConfig struct {
+	Options
+	// Name is the name of the chain middleware to attach to routers. The
+	// redirect middlewares it chains are named after it. If empty,
+	// "linkmap" is used.
+	Name string
+}
+
+// Traefik writes Traefik dynamic configuration, in YAML, with a
+// RedirectRegex middleware for each rule and a chain middleware applying
+// them in evaluation order. Traefik redirects are permanent or temporary,
+// so statuses other than 301, 302, 307 and 308 are skipped.
+func Traefik(w io.Writer, m *linkmap.Map, cfg TraefikConfig) ([]Skipped, error) {
+	name := cfg.Name
+	if name == "" {
+		name = "linkmap"
+	}
+	rs, skipped := redirects(m, cfg.Options)
+	var (
+		b     strings.Builder
+		chain []string
+	)
+	b.WriteString("http:\n  middlewares:\n")
+	for _, r := range rs {
+		var permanent bool
+		switch r.status {
+		case 301, 308:
+			permanent = true
+		case 302, 307:
+		default:
+			skipped = append(skipped, Skipped{Rule: r.rule, Reason: fmt.Sprintf("Traefik redirects cannot use status %d", r.status)})
+			continue
+		}
+		mw := fmt.Sprintf("%s-%d", name, len(chain))
+		chain = append(chain, mw)
+		target := r.expand(func(g int) string { return "${" + strconv.Itoa(g) + "}" })
+		fmt.Fprintf(&b, "    %s:\n      redirectRegex:\n", mw)
+		fmt.Fprintf(&b, "        regex: %s\n", yamlQuote(traefikPattern(r)))
+		fmt.Fprintf(&b, "        replacement: %s\n", yamlQuote(target))
+		fmt.Fprintf(&b, "        permanent: %t\n", permanent)
+	}
+	fmt.Fprintf(&b, "    %s:\n      chain:\n        middlewares:", name)
+	if len(chain) == 0 {
+		b.WriteString(" []\n")
+	} else {
+		b.WriteString("\n")
+		for _, mw := range chain {
+			fmt.Fprintf(&b, "        - %s\n", mw)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return skipped, err
+}
+
+// traefikPattern returns the pattern of r matched against the whole
+// request URL, as Traefik does. Variables of template inputs stop at the
+// query, which is ignored.
+func traefikPattern(r *redirect) string {
+	p := r.pattern[1 : len(r.pattern)-1]
+	if !strings.HasPrefix(r.rule.Input.String(), "re:") {
+		p = strings.NewReplacer("(.*)", "([^?]*)", "(.*?)", "([^?]*?)").Replace(p)
+	}
+	return `^[a-z]+://[^/]+` + p + `(?:\?.*)?$`
+}
@@ -0,0 +1,72 @@
+package export
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTraefik(t *testing.T) {
+	m := mustParse(t, `docs/$1.md https://example.com/$1
+old.html /new.html
+`)
+	var b strings.Builder
+	skipped, err := Traefik(&b, m, TraefikConfig{Options: Options{Status: 302}})
+	if err != nil || len(skipped) > 0 {
+		t.Fatal(err, skipped)
+	}
+	want := `http:
+  middlewares:
+    linkmap-0:
+      redirectRegex:
+        regex: "^[a-z]+://[^/]+/docs/([^?]*?)\\.md(?:\\?.*)?$"
+        replacement: "https://example.com/${1}"
+        permanent: false
+    linkmap-1:
+      redirectRegex:
+        regex: "^[a-z]+://[^/]+/old\\.html(?:\\?.*)?$"
+        replacement: "/new.html"
+        permanent: false
+    linkmap:
+      chain:
+        middlewares:
+        - linkmap-0
+        - linkmap-1
+`
+	if got := b.String(); got != want {
+		t.Errorf("Traefik() =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	skipped, err = Traefik(&b, m, TraefikConfig{Name: "r", Options: Options{Status: 303}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 2 || skipped[0].Reason != "Traefik redirects cannot use status 303" {
+		t.Errorf("skipped %v", skipped)
+	}
+	if got := b.String(); !strings.HasSuffix(got, "    r:\n      chain:\n        middlewares: []\n") {
+		t.Errorf("Traefik() =\n%s", got)
+	}
+}
+
+func TestTraefikPattern(t *testing.T) {
+	rs, _ := redirects(mustParse(t, "docs/$1 /guide/$1\n"), Options{})
+	re := regexp.MustCompile(traefikPattern(rs[0]))
+	tests := []struct {
+		url, match string
+	}{
+		{"https://example.com/docs/a/b", "a/b"},
+		{"http://example.com:8080/docs/a?x=1", "a"},
+		{"https://example.com/other/docs/a", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if m := re.FindStringSubmatch(tt.url); m != nil {
+			got = m[1]
+		}
+		if got != tt.match {
+			t.Errorf("%s matched %q; want %q", tt.url, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtensionPatterns(t *testing.T) {
+	tests := []struct {
+		tmpl  string
+		path  string
+		want  string
+		match bool
+	}{
+		{"assets/$1.{*}", "assets/logo.png", "logo", true},
+		{"assets/$1.{*}", "assets/jquery.min.js", "jquery", true},
+		{"assets/$1.{*}", "assets/img/logo.png", "img/logo", true},
+		{"docs/$1.{md*}", "docs/a.mdx", "a", true},
+		{"docs/$1.{md*}", "docs/a.markdown", "", false},
+		{"docs/$1{.md,.mdx,}", "docs/README", "README", true},
+		{"docs/$1{.md,.mdx,}", "docs/intro.mdx", "intro", true},
+		{"docs/$1{.md,.mdx,}", "docs/intro.md", "intro", true},
+		{"docs/$1{.md,.mdx}", "docs/a.md.mdx", "a.md", true},
+		{"docs/$1{.*}", "docs/intro.md", "intro", true},
+		{"docs/$1.{md,mdx}/index", "docs/a.md/index", "a", true},
+		{"docs/$1.{md,txt}", "docs/a.html", "", false},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.tmpl)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) error: %v", tt.tmpl, err)
+		}
+		vars, ok := tmpl.Match(tt.path)
+		if ok != tt.match || ok && vars["1"] != tt.want {
+			t.Errorf("%q.Match(%q) = %v, %v; want $1=%q, %v", tt.tmpl, tt.path, vars, ok, tt.want, tt.match)
+		}
+	}
+}
+
+func TestExtensionPatternAST(t *testing.T) {
+	tmpl, err := AST{Literal("docs/"), Var("1"), Ext(".md*", "")}.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tmpl.String(); got != "docs/$1{.md*,}" {
+		t.Errorf("String() = %q", got)
+	}
+	if _, err := (AST{Var("1"), Ext("m*d")}).Compile(); err == nil {
+		t.Errorf("Compile() with inner '*' succeeded")
+	}
+	if _, err := tmpl.Build(map[string]string{"1": "a"}); err == nil {
+		t.Errorf("Build() with wildcard extension succeeded")
+	}
+	m, _ := Parse(strings.NewReader("assets/$1.{*} https://cdn.example.com/$1"))
+	if got := m.Suggest("asset/logo.png", 1); len(got) != 1 || got[0].Distance != 1 {
+		t.Errorf("Suggest() = %+v; want distance 1", got)
+	}
+}
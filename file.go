@@ -0,0 +1,298 @@
+package linkmap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// A File is a linkmap parsed losslessly: comments, blank lines and the
+// original order of rules are retained, so that a program can edit the
+// rules and write the file back without disturbing its layout.
+type File struct {
+	// Lines holds every line of the file, including a final empty line if
+	// the file ends with a newline.
+	Lines []Line
+	opts  []Option
+	// newline and bom are the line ending and byte order mark of the
+	// source, which String restores.
+	newline string
+	bom     bool
+}
+
+// fallthroughDirective is a comment line which makes the map of a file
+// fall through, as if parsed WithFallthrough.
+const fallthroughDirective = "#linkmap:fallthrough"
+
+// LineKind identifies the kind of a line in a File.
+type LineKind int
+
+const (
+	BlankLine LineKind = iota
+	CommentLine
+	RuleLine
+)
+
+// A Line is a single line of a File.
+type Line struct {
+	Kind LineKind
+	// Text is the line as written, without its newline.
+	Text string
+	// Rule is the parsed rule, for rule lines.
+	Rule Rule
+}
+
+// ParseFile parses a linkmap, retaining its layout.
+func ParseFile(reader io.Reader, opts ...Option) (*File, error) {
+	m := newMap(opts)
+	m.arena = &segmentArena{}
+	buf, err := m.limits.readSource(reader)
+	if err != nil {
+		return nil, err
+	}
+	src, newline, bom, err := decodeSource(buf)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(src, "\n")
+	f := &File{Lines: make([]Line, 0, len(lines)), opts: opts, newline: newline, bom: bom}
+	var rules int
+	for n, l := range lines {
+		if err := m.limits.checkLine(n+1, l); err != nil {
+			return nil, err
+		}
+		line := Line{Kind: lineKind(l), Text: l}
+		if line.Kind == RuleLine {
+			pos := Rule{Line: n + 1, Source: m.source}
+			if line.Rule, err = m.parseLine(l); err != nil {
+				return nil, errorAt(pos, err)
+			}
+			line.Rule.Line, line.Rule.Source = pos.Line, pos.Source
+			rules++
+			if err := m.limits.checkRule(rules, line.Rule); err != nil {
+				return nil, err
+			}
+		}
+		f.Lines = append(f.Lines, line)
+	}
+	return f, nil
+}
+
+func lineKind(l string) LineKind {
+	switch {
+	case l == "":
+		return BlankLine
+	case strings.HasPrefix(l, "#"):
+		return CommentLine
+	default:
+		return RuleLine
+	}
+}
+
+// Map returns a Map of the file's rules, configured with the options the
+// file was parsed with. If the file has a "#linkmap:fallthrough" comment
+// line, the map falls through as if parsed WithFallthrough.
+func (f *File) Map() *Map {
+	opts := f.opts
+	for _, l := range f.Lines {
+		if l.Kind == CommentLine && strings.TrimSpace(l.Text) == fallthroughDirective {
+			opts = append(opts[:len(opts):len(opts)], WithFallthrough())
+			break
+		}
+	}
+	return New(f.Rules(), opts...)
+}
+
+// Rules returns the file's rules in file order, with Line set to their
+// current position.
+func (f *File) Rules() []Rule {
+	rules := make([]Rule, 0, len(f.Lines))
+	for n, l := range f.Lines {
+		if l.Kind == RuleLine {
+			r := l.Rule
+			r.Line = n + 1
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// Bytes returns the file's source.
+func (f *File) Bytes() []byte {
+	return []byte(f.String())
+}
+
+// String returns the file's source, with the line endings and byte order
+// mark it was parsed with.
+func (f *File) String() string {
+	texts := make([]string, len(f.Lines))
+	for i, l := range f.Lines {
+		texts[i] = l.Text
+	}
+	newline, bom := f.newline, ""
+	if newline == "" {
+		newline = "\n"
+	}
+	if f.bom {
+		bom = utf8BOM
+	}
+	return bom + strings.Join(texts, newline)
+}
+
+const utf8BOM = "\ufeff"
+
+// decodeSource checks that buf is valid UTF-8 and returns it without a
+// leading byte order mark and with its line endings, CRLF or CR as written
+// on Windows and classic Mac OS, replaced by LF. It also returns the line
+// ending the source used, the first one found, and whether it had a byte
+// order mark.
+func decodeSource(buf []byte) (src, newline string, bom bool, err error) {
+	if !utf8.Valid(buf) {
+		line, col := 1, 1
+		for len(buf) > 0 {
+			r, size := utf8.DecodeRune(buf)
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			if r == '\n' {
+				line, col = line+1, 0
+			}
+			buf, col = buf[size:], col+1
+		}
+		return "", "", false, fmt.Errorf("linkmap: line %d: invalid UTF-8 at column %d", line, col)
+	}
+	src = string(buf)
+	if strings.HasPrefix(src, utf8BOM) {
+		src, bom = src[len(utf8BOM):], true
+	}
+	newline = "\n"
+	if i := strings.IndexByte(src, '\r'); i >= 0 {
+		newline = "\r"
+		if strings.HasPrefix(src[i:], "\r\n") {
+			newline = "\r\n"
+		}
+		src = strings.ReplaceAll(src, "\r\n", "\n")
+		src = strings.ReplaceAll(src, "\r", "\n")
+	}
+	return src, newline, bom, nil
+}
+
+// WriteTo writes the file's source to w.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, f.String())
+	return int64(n), err
+}
+
+// AddRule appends a rule to the end of the file, before any trailing
+// newline.
+func (f *File) AddRule(r Rule) {
+	line := ruleLine(r)
+	if n := len(f.Lines); n > 0 && f.Lines[n-1].Kind == BlankLine && f.Lines[n-1].Text == "" {
+		f.Lines = append(f.Lines[:n-1], line, f.Lines[n-1])
+		return
+	}
+	f.Lines = append(f.Lines, line)
+}
+
+// RemoveRule removes the i'th rule, counting from zero in file order. It
+// reports whether there was such a rule.
+func (f *File) RemoveRule(i int) bool {
+	n := f.lineOf(i)
+	if n < 0 {
+		return false
+	}
+	f.Lines = append(f.Lines[:n], f.Lines[n+1:]...)
+	return true
+}
+
+func ruleLine(r Rule) Line {
+	r.Line = 0
+	return Line{Kind: RuleLine, Text: r.String(), Rule: r}
+}
+
+// Index returns the position, in file order, of the first rule whose input
+// template is input, or -1 if there is none.
+func (f *File) Index(input string) int {
+	for i, r := range f.Rules() {
+		if r.Input.String() == input {
+			return i
+		}
+	}
+	return -1
+}
+
+// InsertRuleAfter inserts r on the line immediately following the i'th
+// rule, counting from zero in file order, so that it joins the same group
+// of rules. If i is -1, r is inserted before the first rule.
+func (f *File) InsertRuleAfter(i int, r Rule) error {
+	if i == -1 {
+		n := f.lineOf(0)
+		if n < 0 {
+			f.AddRule(r)
+			return nil
+		}
+		f.insertLine(n, ruleLine(r))
+		return nil
+	}
+	n := f.lineOf(i)
+	if n < 0 {
+		return fmt.Errorf("linkmap: no rule %d", i)
+	}
+	f.insertLine(n+1, ruleLine(r))
+	return nil
+}
+
+// ReplaceRule replaces the i'th rule, counting from zero in file order,
+// changing only that line.
+func (f *File) ReplaceRule(i int, r Rule) error {
+	n := f.lineOf(i)
+	if n < 0 {
+		return fmt.Errorf("linkmap: no rule %d", i)
+	}
+	if f.Lines[n].Rule.String() != r.String() {
+		f.Lines[n] = ruleLine(r)
+	}
+	return nil
+}
+
+// RemoveRulesMatching removes the rules for which pred returns true and
+// returns how many were removed. Comments and blank lines are untouched.
+func (f *File) RemoveRulesMatching(pred func(Rule) bool) int {
+	var (
+		kept    []Line
+		removed int
+	)
+	for _, l := range f.Lines {
+		if l.Kind == RuleLine && pred(l.Rule) {
+			removed++
+			continue
+		}
+		kept = append(kept, l)
+	}
+	f.Lines = kept
+	return removed
+}
+
+// lineOf returns the index in Lines of the i'th rule, or -1.
+func (f *File) lineOf(i int) int {
+	if i < 0 {
+		return -1
+	}
+	for n, l := range f.Lines {
+		if l.Kind != RuleLine {
+			continue
+		}
+		if i == 0 {
+			return n
+		}
+		i--
+	}
+	return -1
+}
+
+func (f *File) insertLine(n int, l Line) {
+	f.Lines = append(f.Lines, Line{})
+	copy(f.Lines[n+1:], f.Lines[n:])
+	f.Lines[n] = l
+}
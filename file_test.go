@@ -0,0 +1,149 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+const fileSrc = `# Blog posts.
+foo/$1.{md,mdx} https://example.com/posts/$1
+
+# Nested content.
+bar/$1/baz/$2.{html} https://example.com/$1/$2
+`
+
+func TestParseFileRoundTrip(t *testing.T) {
+	f, err := ParseFile(strings.NewReader(fileSrc))
+	if err != nil {
+		t.Fatalf("ParseFile() error: %v", err)
+	}
+	if got := f.String(); got != fileSrc {
+		t.Errorf("String() = %q; want %q", got, fileSrc)
+	}
+	rules := f.Rules()
+	if len(rules) != 2 || rules[0].Line != 2 || rules[1].Line != 5 {
+		t.Errorf("Rules() = %v; want rules on lines 2 and 5", rules)
+	}
+	if got, err := f.Map().Evaluate("foo/abc.md"); err != nil || got != "https://example.com/posts/abc" {
+		t.Errorf("Map().Evaluate() = %q, %v", got, err)
+	}
+}
+
+func TestParseFileEncoding(t *testing.T) {
+	for _, src := range []string{
+		"\ufeff" + strings.ReplaceAll(fileSrc, "\n", "\r\n"),
+		strings.ReplaceAll(fileSrc, "\n", "\r"),
+	} {
+		f, err := ParseFile(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("ParseFile(%q) error: %v", src, err)
+		}
+		if got, err := f.Map().Evaluate("bar/a/baz/b.html"); err != nil || got != "https://example.com/a/b" {
+			t.Errorf("%q: Evaluate() = %q, %v", src, got, err)
+		}
+		if got := f.String(); got != src {
+			t.Errorf("String() = %q; want %q", got, src)
+		}
+	}
+	toml := "\ufeff[[rule]]\r\nfrom = \"docs/$1.md\"\r\nto = \"https://example.com/$1\"\r\n"
+	if m, err := ParseTOML(strings.NewReader(toml)); err != nil || m.Rules()[0].Input.String() != "docs/$1.md" {
+		t.Errorf("ParseTOML(%q) = %v, %v", toml, m, err)
+	}
+	invalid := "docs/$1.md https://example.com/$1\nblog/$1.md https://example.com/\xffblog/$1\n"
+	want := "linkmap: line 2: invalid UTF-8 at column 32"
+	for _, parse := range []func(string) error{
+		func(s string) error { _, err := Parse(strings.NewReader(s)); return err },
+		func(s string) error { _, err := ParseTOML(strings.NewReader(s)); return err },
+	} {
+		if err := parse(invalid); err == nil || err.Error() != want {
+			t.Errorf("parsing invalid UTF-8: err = %v; want %q", err, want)
+		}
+	}
+}
+
+func TestFileEdits(t *testing.T) {
+	f, err := ParseFile(strings.NewReader(fileSrc))
+	if err != nil {
+		t.Fatalf("ParseFile() error: %v", err)
+	}
+	r, err := newMap(nil).parseLine("docs/$1.md https://example.com/docs/$1")
+	if err != nil {
+		t.Fatalf("parseLine() error: %v", err)
+	}
+	f.AddRule(r)
+	if !f.RemoveRule(0) {
+		t.Fatalf("RemoveRule(0) = false")
+	}
+	want := `# Blog posts.
+
+# Nested content.
+bar/$1/baz/$2.{html} https://example.com/$1/$2
+docs/$1.md https://example.com/docs/$1
+`
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+	if f.RemoveRule(2) {
+		t.Errorf("RemoveRule(2) = true; want false")
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	m, err := Parse(strings.NewReader(fileSrc))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if n := len(m.Rules()); n != 2 {
+		t.Errorf("Parse() has %d rules; want 2", n)
+	}
+	if diags := Analyze(fileSrc); len(diags) != 0 {
+		t.Errorf("Analyze() = %v; want none", diags)
+	}
+}
+
+func TestFileEditOperations(t *testing.T) {
+	f, err := ParseFile(strings.NewReader(fileSrc))
+	if err != nil {
+		t.Fatalf("ParseFile() error: %v", err)
+	}
+	rule := func(l string) Rule {
+		r, err := newMap(nil).parseLine(l)
+		if err != nil {
+			t.Fatalf("parseLine(%q) error: %v", l, err)
+		}
+		return r
+	}
+	i := f.Index("foo/$1.{md,mdx}")
+	if i != 0 {
+		t.Fatalf("Index() = %d; want 0", i)
+	}
+	if err := f.InsertRuleAfter(i, rule("foo/$1.txt https://example.com/posts/$1")); err != nil {
+		t.Fatalf("InsertRuleAfter() error: %v", err)
+	}
+	if err := f.ReplaceRule(2, rule("bar/$1/baz/$2.{html,htm} https://example.com/$1/$2")); err != nil {
+		t.Fatalf("ReplaceRule() error: %v", err)
+	}
+	if err := f.InsertRuleAfter(-1, rule("index.md https://example.com/")); err != nil {
+		t.Fatalf("InsertRuleAfter(-1) error: %v", err)
+	}
+	want := `# Blog posts.
+index.md https://example.com/
+foo/$1.{md,mdx} https://example.com/posts/$1
+foo/$1.txt https://example.com/posts/$1
+
+# Nested content.
+bar/$1/baz/$2.{html,htm} https://example.com/$1/$2
+`
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+	n := f.RemoveRulesMatching(func(r Rule) bool {
+		return strings.HasPrefix(r.Input.String(), "foo/")
+	})
+	if n != 2 {
+		t.Errorf("RemoveRulesMatching() = %d; want 2", n)
+	}
+	if err := f.ReplaceRule(5, Rule{}); err == nil {
+		t.Errorf("ReplaceRule(5) succeeded; want error")
+	}
+}
@@ -0,0 +1,140 @@
+package linkmap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"strconv"
+)
+
+// A FileVar derives the value of a variable from a file, such as a digest
+// of its content. File variables are bound by LookupFile and EvaluateFS, and
+// can be used in output templates like any other variable, e.g.
+// "https://cdn.example.com/$1.$hash.css". A variable bound by the input
+// template takes precedence over a file variable of the same name.
+type FileVar func(fsys fs.FS, fpath string) (string, error)
+
+// defaultFileVars are the file variables available unless overridden.
+var defaultFileVars = map[string]FileVar{
+	"hash":  ContentHash(sha256.New, 8),
+	"mtime": ModTime("2006-01-02"),
+	"size":  Size(),
+}
+
+// ContentHash returns a FileVar which is the hex digest of the file's
+// content, truncated to length characters if length is positive.
+func ContentHash(newHash func() hash.Hash, length int) FileVar {
+	return func(fsys fs.FS, fpath string) (string, error) {
+		f, err := fsys.Open(fpath)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		h := newHash()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		if length > 0 && length < len(sum) {
+			sum = sum[:length]
+		}
+		return sum, nil
+	}
+}
+
+// ModTime returns a FileVar which is the file's modification time, in UTC,
+// formatted with layout. The default $mtime variable uses "2006-01-02"; to
+// change it, register another with WithFileVar("mtime", ModTime(layout)).
+func ModTime(layout string) FileVar {
+	return func(fsys fs.FS, fpath string) (string, error) {
+		fi, err := fs.Stat(fsys, fpath)
+		if err != nil {
+			return "", err
+		}
+		return fi.ModTime().UTC().Format(layout), nil
+	}
+}
+
+// Size returns a FileVar which is the file's size in bytes, in decimal. It
+// is the default $size variable.
+func Size() FileVar {
+	return func(fsys fs.FS, fpath string) (string, error) {
+		fi, err := fs.Stat(fsys, fpath)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(fi.Size(), 10), nil
+	}
+}
+
+// WithFileVar registers a file variable, replacing any existing variable
+// of the same name.
+func WithFileVar(name string, v FileVar) Option {
+	return func(m *Map) {
+		if m.fileVars == nil {
+			m.fileVars = make(map[string]FileVar)
+		}
+		m.fileVars[name] = v
+	}
+}
+
+// WithHash configures the $hash file variable, which by default is the
+// first 8 hex digits of the file's SHA-256 digest.
+func WithHash(newHash func() hash.Hash, length int) Option {
+	return WithFileVar("hash", ContentHash(newHash, length))
+}
+
+// LookupFile is like Lookup, but also binds file variables, such as $hash,
+// from the file at fpath in fsys. Only the file variables used by the
+// matching rule are computed.
+func (m *Map) LookupFile(fsys fs.FS, fpath string) Result {
+	return m.lookup(&EvalContext{FS: fsys}, fpath)
+}
+
+// EvaluateFile is like Evaluate, but also binds file variables from the
+// file at fpath in fsys.
+func (m *Map) EvaluateFile(fsys fs.FS, fpath string) (string, error) {
+	res := m.LookupFile(fsys, fpath)
+	return res.Link, res.Err
+}
+
+// fileVar returns the file variable called name, or nil.
+func (m *Map) fileVar(name string) FileVar {
+	if v, ok := m.fileVars[name]; ok {
+		return v
+	}
+	return defaultFileVars[name]
+}
+
+// bindFileVars binds the file variables used by out which are not already
+// bound. Text/template outputs may use any file variable, so all are
+// bound.
+func (m *Map) bindFileVars(vars map[string]string, out Template, fsys fs.FS, fpath string) error {
+	names := out.Variables()
+	if out.text != nil {
+		for name := range defaultFileVars {
+			names = append(names, name)
+		}
+		for name := range m.fileVars {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		v := m.fileVar(name)
+		if v == nil {
+			continue
+		}
+		val, err := v(fsys, fpath)
+		if err != nil {
+			return fmt.Errorf("linkmap: computing $%s for %q: %w", name, fpath, err)
+		}
+		vars[name] = val
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package linkmap
+
+import (
+	"crypto/md5"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileVars(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/site.css": {Data: []byte("body{}")},
+		"js/app.js":    {Data: []byte("alert(1)")},
+	}
+	src := "css/$1.css https://cdn.example.com/$1.$hash.css\njs/$1.js https://cdn.example.com/$1.js\n"
+	tests := []struct {
+		name string
+		opts []Option
+		path string
+		want string
+	}{
+		{"default", nil, "css/site.css", "https://cdn.example.com/site.7c98040a.css"},
+		{"md5", []Option{WithHash(md5.New, 0)}, "css/site.css", "https://cdn.example.com/site.aa676972bbd2b68e94ef8e91e81d20be.css"},
+		{"unused", nil, "js/app.js", "https://cdn.example.com/app.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(strings.NewReader(src), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.EvaluateFile(fsys, tt.path)
+			if err != nil || got != tt.want {
+				t.Errorf("EvaluateFile(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+
+	m, _ := Parse(strings.NewReader(src))
+	if _, err := m.Evaluate("css/site.css"); err == nil {
+		t.Errorf("Evaluate() without a filesystem succeeded")
+	}
+	if _, err := m.EvaluateFile(fsys, "css/missing.css"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("EvaluateFile(missing) = %v; want fs.ErrNotExist", err)
+	}
+	results, err := m.EvaluateFS(fsys)
+	if err != nil || len(results) != 2 || results[0].Link != "https://cdn.example.com/site.7c98040a.css" {
+		t.Errorf("EvaluateFS() = %+v, %v", results, err)
+	}
+	if diags := Analyze(src); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+}
+
+func TestWithFileVar(t *testing.T) {
+	fsys := fstest.MapFS{"a.txt": {Data: []byte("hi")}}
+	length := func(fsys fs.FS, p string) (string, error) {
+		b, err := fs.ReadFile(fsys, p)
+		return strings.Repeat("x", len(b)), err
+	}
+	m, err := Parse(strings.NewReader("$1.txt https://example.com/$1/$len"), WithFileVar("len", length))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.EvaluateFile(fsys, "a.txt"); err != nil || got != "https://example.com/a/xx" {
+		t.Errorf("EvaluateFile() = %q, %v", got, err)
+	}
+}
+
+func TestFileMetadata(t *testing.T) {
+	mtime := time.Date(2024, 3, 5, 23, 30, 0, 0, time.FixedZone("PST", -8*3600))
+	fsys := fstest.MapFS{"docs/a.md": {Data: []byte("hello"), ModTime: mtime}}
+	tests := []struct {
+		name string
+		opts []Option
+		out  string
+		want string
+	}{
+		{"mtime", nil, "https://example.com/$mtime/$1", "https://example.com/2024-03-06/a"},
+		{"layout", []Option{WithFileVar("mtime", ModTime("200601"))}, "https://example.com/v$mtime/$1", "https://example.com/v202403/a"},
+		{"size", nil, "https://example.com/$1?bytes=$size", "https://example.com/a?bytes=5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(strings.NewReader("docs/$1.md "+tt.out), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := m.EvaluateFile(fsys, "docs/a.md"); err != nil || got != tt.want {
+				t.Errorf("EvaluateFile() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package linkmap
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+var errInputFilter = errors.New("linkmap: filters can only be used in output templates")
+
+// A filter transforms the value of a variable in an output template, for
+// path surgery which would otherwise need a preprocessing step. Filters
+// follow the variable, each introduced by '|', and some take an argument
+// after a colon, which extends to the next '|' or the end of the template:
+//
+//	docs/$1.md https://example.com/$1|trimprefix:v2/|replace:_,-
+//	blog/$slug.md https://example.com/$slug|first:8|/index.html
+//
+// A '|' after a filter which does not introduce another ends the filters
+// and is dropped, so that text can follow. The filters are:
+//
+//	trimprefix:s      remove the prefix s
+//	trimsuffix:s      remove the suffix s
+//	replace:old,new   replace every old with new
+//	truncate:n        keep the first n characters; first:n is the same
+//	last:n            keep the last n characters
+//	lower, upper      change case
+//	default:s         replace an empty value with s
+//	pad:n             pad a number, in any base, with zeros to n digits
+//	base:n            convert a decimal number to base n, from 2 to 36
+//	sha1:n, sha256:n  keep the first n hex digits of the value's digest
+//
+// The numeric filters, pad and base, fail for values which are not
+// numbers, so that a rule with one is an error for such values. The hash
+// filters publish stable, opaque URL components, e.g. for anonymized
+// links to internal documents; values drawn from a small or guessable
+// set, such as dates or ticket numbers, can be recovered from their
+// digests by trying every candidate.
+type filter struct {
+	name, arg string
+	apply     filterFunc
+}
+
+// filterKinds builds filters by name from their arguments. hasArg reports
+// whether a filter takes an argument.
+var filterKinds = map[string]struct {
+	hasArg bool
+	build  func(arg string) (filterFunc, error)
+}{
+	"trimprefix": {true, func(arg string) (filterFunc, error) {
+		return func(s string) (string, error) { return strings.TrimPrefix(s, arg), nil }, nil
+	}},
+	"trimsuffix": {true, func(arg string) (filterFunc, error) {
+		return func(s string) (string, error) { return strings.TrimSuffix(s, arg), nil }, nil
+	}},
+	"replace": {true, func(arg string) (filterFunc, error) {
+		old, new, ok := strings.Cut(arg, ",")
+		if !ok || old == "" {
+			return nil, errors.New("want old,new")
+		}
+		return func(s string) (string, error) { return strings.ReplaceAll(s, old, new), nil }, nil
+	}},
+	"truncate": {true, firstFilter},
+	"first":    {true, firstFilter},
+	"last": {true, func(arg string) (filterFunc, error) {
+		n, err := filterCount(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string) (string, error) {
+			r := []rune(s)
+			if len(r) <= n {
+				return s, nil
+			}
+			return string(r[len(r)-n:]), nil
+		}, nil
+	}},
+	"lower": {false, func(string) (filterFunc, error) { return infallible(strings.ToLower), nil }},
+	"upper": {false, func(string) (filterFunc, error) { return infallible(strings.ToUpper), nil }},
+	"default": {true, func(arg string) (filterFunc, error) {
+		return infallible(func(s string) string {
+			if s == "" {
+				return arg
+			}
+			return s
+		}), nil
+	}},
+	"pad": {true, func(arg string) (filterFunc, error) {
+		n, err := filterCount(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(s string) (string, error) {
+			if s == "" || strings.Trim(s, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+				return "", fmt.Errorf("%q is not a number", s)
+			}
+			if len(s) < n {
+				s = strings.Repeat("0", n-len(s)) + s
+			}
+			return s, nil
+		}, nil
+	}},
+	"base": {true, func(arg string) (filterFunc, error) {
+		base, err := strconv.Atoi(arg)
+		if err != nil || base < 2 || base > 36 {
+			return nil, fmt.Errorf("invalid base %q", arg)
+		}
+		return func(s string) (string, error) {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("%q is not a number", s)
+			}
+			return strconv.FormatUint(n, base), nil
+		}, nil
+	}},
+	"sha1":   {true, hashFilter(sha1.New)},
+	"sha256": {true, hashFilter(sha256.New)},
+}
+
+// hashFilter builds a filter which replaces a value with the first n hex
+// digits of its digest.
+func hashFilter(newHash func() hash.Hash) func(arg string) (filterFunc, error) {
+	return func(arg string) (filterFunc, error) {
+		n, err := filterCount(arg)
+		if err != nil || n == 0 || n > 2*newHash().Size() {
+			return nil, fmt.Errorf("invalid length %q", arg)
+		}
+		return func(s string) (string, error) {
+			h := newHash()
+			h.Write([]byte(s))
+			return hex.EncodeToString(h.Sum(nil))[:n], nil
+		}, nil
+	}
+}
+
+// A filterFunc applies a filter to a value. Numeric filters fail for
+// values which are not numbers.
+type filterFunc func(string) (string, error)
+
+func infallible(fn func(string) string) filterFunc {
+	return func(s string) (string, error) { return fn(s), nil }
+}
+
+func firstFilter(arg string) (filterFunc, error) {
+	n, err := filterCount(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(s string) (string, error) {
+		r := []rune(s)
+		if len(r) <= n {
+			return s, nil
+		}
+		return string(r[:n]), nil
+	}, nil
+}
+
+// filterCount parses a count of characters.
+func filterCount(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q", arg)
+	}
+	return n, nil
+}
+
+// filterName returns the name of the known filter s starts with, if any.
+func filterName(s string) (string, bool) {
+	end := 0
+	for end < len(s) && (s[end] >= 'a' && s[end] <= 'z' || s[end] >= '0' && s[end] <= '9') {
+		end++
+	}
+	name := s[:end]
+	kind, ok := filterKinds[name]
+	if !ok || kind.hasArg != (end < len(s) && s[end] == ':') {
+		return "", false
+	}
+	return name, true
+}
+
+// parseFilters parses the filters s starts with, each introduced by '|'.
+// It returns the filters and the length of their source, and whether a
+// terminating '|' follows it.
+func parseFilters(s string) (filters []filter, n int, terminated bool, err error) {
+	for n < len(s) && s[n] == '|' {
+		name, ok := filterName(s[n+1:])
+		if !ok {
+			return filters, n, len(filters) > 0, nil
+		}
+		n += 1 + len(name)
+		var arg string
+		if filterKinds[name].hasArg {
+			end := strings.IndexByte(s[n+1:], '|')
+			if end < 0 {
+				end = len(s) - n - 1
+			}
+			arg = s[n+1 : n+1+end]
+			n += 1 + end
+		}
+		apply, err := filterKinds[name].build(arg)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("linkmap: filter %s: %w", name, err)
+		}
+		filters = append(filters, filter{name: name, arg: arg, apply: apply})
+	}
+	return filters, n, false, nil
+}
+
+// String returns the source of the filter, without its leading '|'.
+func (f filter) String() string {
+	if filterKinds[f.name].hasArg {
+		return f.name + ":" + f.arg
+	}
+	return f.name
+}
+
+// filter applies the segment's filters to val.
+func (s segment) filter(val string) (string, error) {
+	for _, f := range s.filters {
+		var err error
+		if val, err = f.apply(val); err != nil {
+			return "", fmt.Errorf("filter %s of $%s: %w", f, s.name(), err)
+		}
+	}
+	return val, nil
+}
@@ -0,0 +1,84 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		output string
+		vars   map[string]string
+		want   string
+	}{
+		{"https://example.com/$1|trimprefix:docs/", map[string]string{"1": "docs/intro"}, "https://example.com/intro"},
+		{"https://example.com/$1|trimsuffix:-draft|upper", map[string]string{"1": "post-draft"}, "https://example.com/POST"},
+		{"https://example.com/$1|replace:_,-", map[string]string{"1": "a_b_c"}, "https://example.com/a-b-c"},
+		{"https://example.com/$slug|first:8|/index.html", map[string]string{"slug": "a-very-long-slug"}, "https://example.com/a-very-l/index.html"},
+		{"https://example.com/$slug|truncate:3|last:2", map[string]string{"slug": "héllo"}, "https://example.com/él"},
+		{"https://example.com/$1|lower/$1", map[string]string{"1": "ABC"}, "https://example.com/abc/ABC"},
+		{"https://example.com/$1|other", map[string]string{"1": "a"}, "https://example.com/a|other"},
+		{"https://example.com/issues/$1|pad:4", map[string]string{"1": "42"}, "https://example.com/issues/0042"},
+		{"https://example.com/issues/$1|pad:2", map[string]string{"1": "12345"}, "https://example.com/issues/12345"},
+		{"https://example.com/$1|base:16|pad:4|upper", map[string]string{"1": "255"}, "https://example.com/00FF"},
+		{"https://example.com/d/$1|sha1:8|/", map[string]string{"1": "hello"}, "https://example.com/d/aaf4c61d/"},
+		{"https://example.com/d/$1|sha256:12", map[string]string{"1": "hello"}, "https://example.com/d/2cf24dba5fb0"},
+		{"https://example.com/$lang|default:en|/$1", map[string]string{"lang": "", "1": "a"}, "https://example.com/en/a"},
+		{"https://example.com/$lang|default:en", map[string]string{"lang": "fr"}, "https://example.com/fr"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseTemplate(tt.output)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q): %v", tt.output, err)
+			continue
+		}
+		if got, err := tmpl.segs.apply(tt.vars); got != tt.want || err != nil {
+			t.Errorf("%q.apply() = %q, %v; want %q", tt.output, got, err, tt.want)
+		}
+		again, err := ParseTemplate(tmpl.String())
+		if err != nil || !again.segs.equals(tmpl.segs) {
+			t.Errorf("%q does not round-trip through %q: %v", tt.output, tmpl.String(), err)
+		}
+		ast, err := tmpl.AST().Compile()
+		if err != nil || !ast.segs.equals(tmpl.segs) {
+			t.Errorf("%q does not round-trip through its AST: %v", tt.output, err)
+		}
+	}
+
+	if tmpl, err := ParseTemplate("issues/$1|pad:4"); err != nil {
+		t.Error(err)
+	} else if _, err := tmpl.segs.apply(map[string]string{"1": "4-2"}); err == nil || !strings.Contains(err.Error(), "pad:4 of $1") {
+		t.Errorf("pad of a non-number: %v", err)
+	}
+	for _, src := range []string{"$1|first:x", "$1|last:-1", "$1|replace:ab", "$1|base:37", "$1|sha1:0", "$1|sha1:41"} {
+		if _, err := ParseTemplate(src); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded", src)
+		}
+	}
+	if _, err := Parse(strings.NewReader("docs/$1|lower.md https://example.com/$1\n")); !errors.Is(err, errInputFilter) {
+		t.Errorf("Parse() with an input filter: %v", err)
+	}
+	m, err := Parse(strings.NewReader("content/$1.md https://example.com/$1|trimprefix:docs/|replace:_,-|/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mm := range []*Map{m, mustParseBinary(t, data)} {
+		if link, err := mm.Evaluate("content/docs/get_started.md"); link != "https://example.com/get-started/" || err != nil {
+			t.Errorf("Evaluate() = %q, %v", link, err)
+		}
+	}
+}
+
+func mustParseBinary(t *testing.T, data []byte) *Map {
+	t.Helper()
+	m, err := ParseBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,259 @@
+package linkmap
+
+import (
+	"io/fs"
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+)
+
+// generateAttempts bounds the paths a Generator tries for a rule before
+// giving up, e.g. because an earlier rule shadows it.
+const generateAttempts = 50
+
+// A Generator produces random paths which match given rules of a map, and
+// near misses which do not, for property-based and fuzz tests of systems
+// which consume links. Every path it returns has been checked with Lookup,
+// so a match is matched by its rule and not shadowed by an earlier one.
+//
+// Variables take short lowercase alphanumeric values, repeated variables
+// the same value, and extension groups one of their alternatives. Custom
+// segments are filled by trying random candidates, and regular expression
+// inputs by walking the expression, so rules whose inputs accept few
+// strings may yield none.
+type Generator struct {
+	m    *Map
+	rand *rand.Rand
+}
+
+// NewGenerator returns a Generator for the rules of m, drawing from src so
+// that a fixed seed reproduces the same paths.
+func NewGenerator(m *Map, src rand.Source) *Generator {
+	return &Generator{m: m, rand: rand.New(src)}
+}
+
+// A Sample is a generated path.
+type Sample struct {
+	Path string
+	// Rule is the index of the rule the path was generated for.
+	Rule int
+	// Match reports whether the rule matches the path; if not, the path is
+	// a near miss.
+	Match bool
+}
+
+// Match returns a random path which the rule at index i matches, or false
+// if none was found.
+func (g *Generator) Match(i int) (string, bool) {
+	p, _, ok := g.match(i)
+	return p, ok
+}
+
+// NearMiss returns a random path which differs from one the rule at index
+// i matches by a single character of the literal text of its input, and
+// which the rule does not match, or false if none was found. Another rule
+// may match it.
+func (g *Generator) NearMiss(i int) (string, bool) {
+	for n := 0; n < generateAttempts; n++ {
+		p, lits, ok := g.match(i)
+		if !ok {
+			return "", false
+		}
+		if len(lits) == 0 {
+			continue
+		}
+		at := lits[g.rand.Intn(len(lits))]
+		var miss string
+		if g.rand.Intn(2) == 0 {
+			miss = p[:at] + p[at+1:]
+		} else {
+			c := byte('a' + g.rand.Intn(26))
+			if c == p[at] {
+				c = 'z' - (c - 'a')
+			}
+			miss = p[:at] + string(c) + p[at+1:]
+		}
+		if g.valid(miss) && !g.matches(i, miss) {
+			return miss, true
+		}
+	}
+	return "", false
+}
+
+// Corpus returns up to n matches and n near misses for each rule, in rule
+// order.
+func (g *Generator) Corpus(n int) []Sample {
+	var samples []Sample
+	for i := range g.m.rules {
+		for j := 0; j < n; j++ {
+			if p, ok := g.Match(i); ok {
+				samples = append(samples, Sample{Path: p, Rule: i, Match: true})
+			}
+		}
+		for j := 0; j < n; j++ {
+			if p, ok := g.NearMiss(i); ok {
+				samples = append(samples, Sample{Path: p, Rule: i})
+			}
+		}
+	}
+	return samples
+}
+
+// match returns a path the rule at index i matches, along with the offsets
+// of the bytes of the path which come from literal text of its input.
+func (g *Generator) match(i int) (string, []int, bool) {
+	if i < 0 || i >= len(g.m.rules) {
+		return "", nil, false
+	}
+	in := g.m.rules[i].Input
+	var re *syntax.Regexp
+	if in.re != nil {
+		var err error
+		if re, err = syntax.Parse(in.re.src, syntax.Perl); err != nil {
+			return "", nil, false
+		}
+		re = re.Simplify()
+	}
+	for n := 0; n < generateAttempts; n++ {
+		var b pathBuilder
+		if g.m.base != "" {
+			b.WriteString(g.m.base + "/")
+		}
+		ok := true
+		if re != nil {
+			g.regexp(&b, re)
+		} else {
+			ok = g.template(&b, in.segs)
+		}
+		if p := b.String(); ok && g.valid(p) && g.matches(i, p) {
+			return p, b.lits, true
+		}
+	}
+	return "", nil, false
+}
+
+// matches reports whether the rule at index i is the one matching p.
+func (g *Generator) matches(i int, p string) bool {
+	res := g.m.Lookup(p)
+	return res.Err == nil && res.Rule == &g.m.rules[i]
+}
+
+func (g *Generator) valid(p string) bool {
+	return fs.ValidPath(p) && p != "."
+}
+
+// A pathBuilder builds a path, recording which bytes are literal.
+type pathBuilder struct {
+	strings.Builder
+	lits []int
+}
+
+func (b *pathBuilder) literal(s string) {
+	for i := 0; i < len(s); i++ {
+		b.lits = append(b.lits, b.Len()+i)
+	}
+	b.WriteString(s)
+}
+
+// template writes a path tmpl matches, or returns false if it cannot fill
+// in one of its segments.
+func (g *Generator) template(b *pathBuilder, tmpl template) bool {
+	vars := make(map[string]string)
+	for _, s := range tmpl.segments() {
+		switch s.typ {
+		case segmentTypeString:
+			b.literal(s.val)
+		case segmentTypeVariable:
+			v, ok := vars[s.name()]
+			if !ok {
+				v = g.word("abcdefghijklmnopqrstuvwxyz0123456789", 1+g.rand.Intn(8))
+				vars[s.name()] = v
+			}
+			b.WriteString(v)
+		case segmentTypeExtension:
+			exts := s.extensions()
+			ext := exts[g.rand.Intn(len(exts))]
+			if pre := strings.TrimSuffix(ext, "*"); pre != ext {
+				b.literal(pre)
+				b.WriteString(g.word("abcdefghijklmnopqrstuvwxyz", g.rand.Intn(4)))
+			} else {
+				b.literal(ext)
+			}
+		case segmentTypeCustom:
+			v, ok := g.custom(s.matcher)
+			if !ok {
+				return false
+			}
+			b.WriteString(v)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// custom returns a random string which m matches entirely.
+func (g *Generator) custom(m SegmentMatcher) (string, bool) {
+	alphabets := []string{"0123456789", "abcdefghijklmnopqrstuvwxyz", "abcdefghijklmnopqrstuvwxyz0123456789-", "0123456789abcdef"}
+	for n := 0; n < generateAttempts; n++ {
+		v := g.word(alphabets[g.rand.Intn(len(alphabets))], 1+g.rand.Intn(12))
+		if m.Match(v) == len(v) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (g *Generator) word(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[g.rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// regexp writes a random string re matches. Unbounded repetitions repeat
+// up to three times more than their minimum, and character classes are
+// sampled near the start of each range, to keep paths short and readable.
+func (g *Generator) regexp(b *pathBuilder, re *syntax.Regexp) {
+	repeat := func(min, max int) {
+		if max < 0 {
+			max = min + 3
+		}
+		for n := min + g.rand.Intn(max-min+1); n > 0; n-- {
+			g.regexp(b, re.Sub[0])
+		}
+	}
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.literal(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return
+		}
+		k := g.rand.Intn(len(re.Rune)/2) * 2
+		lo, hi := re.Rune[k], re.Rune[k+1]
+		if hi-lo > 25 {
+			hi = lo + 25
+		}
+		b.WriteRune(lo + rune(g.rand.Intn(int(hi-lo)+1)))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteString(g.word("abcdefghijklmnopqrstuvwxyz", 1))
+	case syntax.OpCapture:
+		g.regexp(b, re.Sub[0])
+	case syntax.OpStar:
+		repeat(0, -1)
+	case syntax.OpPlus:
+		repeat(1, -1)
+	case syntax.OpQuest:
+		repeat(0, 1)
+	case syntax.OpRepeat:
+		repeat(re.Min, re.Max)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.regexp(b, sub)
+		}
+	case syntax.OpAlternate:
+		g.regexp(b, re.Sub[g.rand.Intn(len(re.Sub))])
+	}
+}
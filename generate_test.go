@@ -0,0 +1,59 @@
+package linkmap
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	m, err := Parse(strings.NewReader(`docs/$1/$1.{md,mdx} https://example.com/$1
+posts/<date>-$slug.md https://example.com/blog/$slug
+assets/$1.{png,jp*} https://cdn.example.com/$1
+re:notes/(?P<year>[0-9]{4})/[a-z]+\.txt https://example.com/notes/$year
+`), WithSegment("date", RegexpSegment(regexp.MustCompile(`[0-9]{4}`))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGenerator(m, rand.NewSource(1))
+	samples := g.Corpus(20)
+	matches := make([]int, len(m.Rules()))
+	for _, s := range samples {
+		res := m.Lookup(s.Path)
+		if matched := res.Err == nil && res.Rule == &m.rules[s.Rule]; matched != s.Match {
+			t.Errorf("rule %d matches %q: %v; want %v", s.Rule, s.Path, matched, s.Match)
+		}
+		if s.Match {
+			matches[s.Rule]++
+		}
+	}
+	for i, n := range matches {
+		if n != 20 {
+			t.Errorf("rule %d has %d matches; want 20", i, n)
+		}
+	}
+	if len(samples) < 2*20*len(matches)-10 {
+		t.Errorf("Corpus(20) has %d samples", len(samples))
+	}
+	again := NewGenerator(m, rand.NewSource(1)).Corpus(20)
+	for i := range samples {
+		if samples[i] != again[i] {
+			t.Fatalf("Corpus() with the same seed differs at %d: %v, %v", i, samples[i], again[i])
+		}
+	}
+}
+
+func TestGeneratorShadowed(t *testing.T) {
+	m, err := Parse(strings.NewReader("docs/$1.md https://example.com/$1\ndocs/$1.md https://example.com/docs/$1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGenerator(m, rand.NewSource(1))
+	if p, ok := g.Match(1); ok {
+		t.Errorf("Match() of a shadowed rule = %q", p)
+	}
+	if p, ok := g.Match(2); ok {
+		t.Errorf("Match() of a missing rule = %q", p)
+	}
+}
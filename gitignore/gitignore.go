@@ -0,0 +1,188 @@
+// Package gitignore matches paths against patterns in gitignore syntax.
+//
+// Patterns follow git's rules: blank lines and lines starting with '#' are
+// skipped; a leading '!' re-includes paths excluded by an earlier pattern;
+// a trailing '/' matches only directories; a pattern containing a slash
+// other than a trailing one is relative to the root, and otherwise matches
+// at any depth. '*' matches anything but '/', '?' matches one character
+// other than '/', and "[...]" matches a character class. A leading "**/"
+// matches in all directories, a trailing "/**" matches everything inside,
+// and "/**/" matches zero or more directories. A backslash escapes the next
+// character. As in git, a path cannot be re-included if one of its parent
+// directories is excluded.
+package gitignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// A Matcher is a compiled list of patterns.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// New compiles patterns, each one line of a gitignore file.
+func New(patterns ...string) (*Matcher, error) {
+	m := &Matcher{}
+	for i, l := range patterns {
+		if err := m.add(l); err != nil {
+			return nil, fmt.Errorf("gitignore: pattern %d: %w", i+1, err)
+		}
+	}
+	return m, nil
+}
+
+// Parse reads and compiles the patterns of a gitignore file.
+func Parse(r io.Reader) (*Matcher, error) {
+	m := &Matcher{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		if err := m.add(sc.Text()); err != nil {
+			return nil, fmt.Errorf("gitignore: line %d: %w", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("gitignore: %w", err)
+	}
+	return m, nil
+}
+
+func (m *Matcher) add(l string) error {
+	l = trimTrailingSpace(strings.TrimSuffix(l, "\r"))
+	if l == "" || strings.HasPrefix(l, "#") {
+		return nil
+	}
+	var p pattern
+	if strings.HasPrefix(l, "!") {
+		p.negate = true
+		l = l[1:]
+	}
+	if strings.HasSuffix(l, "/") {
+		p.dirOnly = true
+		l = strings.TrimRight(l, "/")
+	}
+	if l == "" {
+		return fmt.Errorf("empty pattern")
+	}
+	anchored := strings.Contains(l, "/")
+	l = strings.TrimPrefix(l, "/")
+	expr, err := translate(l)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", l, err)
+	}
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	p.re, err = regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", l, err)
+	}
+	m.patterns = append(m.patterns, p)
+	return nil
+}
+
+// trimTrailingSpace removes trailing spaces which are not escaped.
+func trimTrailingSpace(l string) string {
+	for strings.HasSuffix(l, " ") && !strings.HasSuffix(l, "\\ ") {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// translate converts a pattern, without its leading '!', leading '/' or
+// trailing '/', to a regular expression.
+func translate(p string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if strings.HasPrefix(p[i:], "**") && (i == 0 || p[i-1] == '/') &&
+				(i+2 == len(p) || p[i+2] == '/') {
+				switch {
+				case i+2 == len(p) && i == 0:
+					b.WriteString(".*")
+				case i+2 == len(p):
+					// "/**" matches everything inside.
+					b.WriteString(".+")
+				default:
+					// "**/" matches zero or more directories.
+					b.WriteString("(?:.*/)?")
+					i++
+				}
+				i++
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := p[i+1 : i+1+end]
+			if end == 0 {
+				// "[]...]" includes a literal ']'.
+				end = strings.IndexByte(p[i+2:], ']')
+				if end < 0 {
+					return "", fmt.Errorf("unterminated character class")
+				}
+				end++
+				class = p[i+1 : i+1+end]
+			}
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(p) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String(), nil
+}
+
+// Match reports whether path, slash-separated and relative to the root of
+// the patterns, is ignored. isDir reports whether path is a directory.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	// A path is ignored if any parent directory is.
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' && m.matchOne(path[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(path, isDir)
+}
+
+// matchOne applies the patterns to path alone; the last matching pattern
+// wins.
+func (m *Matcher) matchOne(path string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(path) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,68 @@
+package gitignore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{[]string{"*.log"}, "a.log", false, true},
+		{[]string{"*.log"}, "logs/a.log", false, true},
+		{[]string{"*.log"}, "a.log.txt", false, false},
+		{[]string{"/build"}, "build/out.js", false, true},
+		{[]string{"/build"}, "src/build/out.js", false, false},
+		{[]string{"doc/*.txt"}, "doc/a.txt", false, true},
+		{[]string{"doc/*.txt"}, "doc/x/a.txt", false, false},
+		{[]string{"vendor/"}, "vendor", true, true},
+		{[]string{"vendor/"}, "vendor", false, false},
+		{[]string{"vendor/"}, "a/vendor/b.go", false, true},
+		{[]string{"**/foo"}, "a/b/foo", false, true},
+		{[]string{"**/foo"}, "foo", false, true},
+		{[]string{"foo/**"}, "foo/a/b", false, true},
+		{[]string{"foo/**"}, "foo", true, false},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"a/**/b"}, "ab", false, false},
+		{[]string{"*.md", "!README.md"}, "README.md", false, false},
+		{[]string{"*.md", "!README.md"}, "docs/a.md", false, true},
+		{[]string{"!README.md", "*.md"}, "README.md", false, true},
+		{[]string{"docs/", "!docs/keep.md"}, "docs/keep.md", false, true},
+		{[]string{"docs/*", "!docs/keep.md"}, "docs/keep.md", false, false},
+		{[]string{"file[0-9].txt"}, "file3.txt", false, true},
+		{[]string{"file[!0-9].txt"}, "file3.txt", false, false},
+		{[]string{"file?.txt"}, "file/.txt", false, false},
+		{[]string{`\#notes`}, "#notes", false, true},
+		{[]string{`\!important`}, "!important", false, true},
+		{[]string{"# comment", ""}, "# comment", false, false},
+		{[]string{"trailing  "}, "trailing", false, true},
+		{[]string{`space\ `}, "space ", false, true},
+	}
+	for _, tt := range tests {
+		m, err := New(tt.patterns...)
+		if err != nil {
+			t.Fatalf("New(%q) error: %v", tt.patterns, err)
+		}
+		if got := m.Match(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("New(%q).Match(%q, %v) = %v; want %v", tt.patterns, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader("# deps\nnode_modules/\n*.min.js\n!keep.min.js\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Match("web/node_modules/x/index.js", false) || m.Match("keep.min.js", false) || !m.Match("a.min.js", false) {
+		t.Errorf("Match() results wrong")
+	}
+	if _, err := Parse(strings.NewReader("ok\n[abc\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Parse(invalid) = %v; want error on line 2", err)
+	}
+}
@@ -0,0 +1,952 @@
+// Protocol buffer schema for linkmap evaluation services.
+//
+// Service implements the semantics of LinkmapService in a transport-neutral
+// way in package github.com/operandinc/linkmap/service. The gRPC server and
+// the code generated from this file live in the separate module
+// github.com/operandinc/linkmap/grpcservice, so that the linkmap module
+// itself has no dependencies; run buf generate there after changing it.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: linkmap/v1/linkmap.proto
+
+package linkmapv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ErrorCode int32
+
+const (
+	ErrorCode_ERROR_CODE_UNSPECIFIED ErrorCode = 0
+	// No rule matched the path.
+	ErrorCode_ERROR_CODE_NO_MATCH ErrorCode = 1
+	// The path does not follow the path model.
+	ErrorCode_ERROR_CODE_INVALID_PATH ErrorCode = 2
+	// The path is excluded by the map's ignore patterns.
+	ErrorCode_ERROR_CODE_IGNORED ErrorCode = 3
+	// Evaluation exceeded the map's budget.
+	ErrorCode_ERROR_CODE_BUDGET_EXCEEDED ErrorCode = 4
+	// The map could not be loaded.
+	ErrorCode_ERROR_CODE_MAP_UNAVAILABLE ErrorCode = 5
+	// Building the link failed.
+	ErrorCode_ERROR_CODE_INTERNAL ErrorCode = 6
+	// The link is rejected by the map's allowlist.
+	ErrorCode_ERROR_CODE_UNSAFE_LINK ErrorCode = 7
+)
+
+// Enum value maps for ErrorCode.
+var (
+	ErrorCode_name = map[int32]string{
+		0: "ERROR_CODE_UNSPECIFIED",
+		1: "ERROR_CODE_NO_MATCH",
+		2: "ERROR_CODE_INVALID_PATH",
+		3: "ERROR_CODE_IGNORED",
+		4: "ERROR_CODE_BUDGET_EXCEEDED",
+		5: "ERROR_CODE_MAP_UNAVAILABLE",
+		6: "ERROR_CODE_INTERNAL",
+		7: "ERROR_CODE_UNSAFE_LINK",
+	}
+	ErrorCode_value = map[string]int32{
+		"ERROR_CODE_UNSPECIFIED":     0,
+		"ERROR_CODE_NO_MATCH":        1,
+		"ERROR_CODE_INVALID_PATH":    2,
+		"ERROR_CODE_IGNORED":         3,
+		"ERROR_CODE_BUDGET_EXCEEDED": 4,
+		"ERROR_CODE_MAP_UNAVAILABLE": 5,
+		"ERROR_CODE_INTERNAL":        6,
+		"ERROR_CODE_UNSAFE_LINK":     7,
+	}
+)
+
+func (x ErrorCode) Enum() *ErrorCode {
+	p := new(ErrorCode)
+	*p = x
+	return p
+}
+
+func (x ErrorCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ErrorCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_linkmap_v1_linkmap_proto_enumTypes[0].Descriptor()
+}
+
+func (ErrorCode) Type() protoreflect.EnumType {
+	return &file_linkmap_v1_linkmap_proto_enumTypes[0]
+}
+
+func (x ErrorCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ErrorCode.Descriptor instead.
+func (ErrorCode) EnumDescriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{0}
+}
+
+type EvaluateRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Map is the key of the map to evaluate against.
+	Map string `protobuf:"bytes,1,opt,name=map,proto3" json:"map,omitempty"`
+	// Path is slash-separated and relative to the repository root.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvaluateRequest) Reset() {
+	*x = EvaluateRequest{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvaluateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateRequest) ProtoMessage() {}
+
+func (x *EvaluateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateRequest.ProtoReflect.Descriptor instead.
+func (*EvaluateRequest) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EvaluateRequest) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+func (x *EvaluateRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type EvaluateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Path  string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// Link is empty unless code is ERROR_CODE_UNSPECIFIED.
+	Link string `protobuf:"bytes,2,opt,name=link,proto3" json:"link,omitempty"`
+	// Rule is the rule which matched, if any.
+	Rule *Rule `protobuf:"bytes,3,opt,name=rule,proto3" json:"rule,omitempty"`
+	// Vars are the variables bound by the rule, keyed by name without '$'.
+	Vars          map[string]string `protobuf:"bytes,4,rep,name=vars,proto3" json:"vars,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Code          ErrorCode         `protobuf:"varint,5,opt,name=code,proto3,enum=linkmap.v1.ErrorCode" json:"code,omitempty"`
+	Error         string            `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EvaluateResponse) Reset() {
+	*x = EvaluateResponse{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EvaluateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateResponse) ProtoMessage() {}
+
+func (x *EvaluateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateResponse.ProtoReflect.Descriptor instead.
+func (*EvaluateResponse) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *EvaluateResponse) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *EvaluateResponse) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *EvaluateResponse) GetRule() *Rule {
+	if x != nil {
+		return x.Rule
+	}
+	return nil
+}
+
+func (x *EvaluateResponse) GetVars() map[string]string {
+	if x != nil {
+		return x.Vars
+	}
+	return nil
+}
+
+func (x *EvaluateResponse) GetCode() ErrorCode {
+	if x != nil {
+		return x.Code
+	}
+	return ErrorCode_ERROR_CODE_UNSPECIFIED
+}
+
+func (x *EvaluateResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type SandboxRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Source is the candidate map, in the format of the server's maps.
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	// Map, if set, is the key of the live map to compare the candidate with.
+	Map           string   `protobuf:"bytes,2,opt,name=map,proto3" json:"map,omitempty"`
+	Paths         []string `protobuf:"bytes,3,rep,name=paths,proto3" json:"paths,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxRequest) Reset() {
+	*x = SandboxRequest{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxRequest) ProtoMessage() {}
+
+func (x *SandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxRequest.ProtoReflect.Descriptor instead.
+func (*SandboxRequest) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SandboxRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SandboxRequest) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+func (x *SandboxRequest) GetPaths() []string {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+type SandboxResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Hash is the candidate's hash, as returned by Map.Hash.
+	Hash          string           `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Results       []*SandboxResult `protobuf:"bytes,2,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxResponse) Reset() {
+	*x = SandboxResponse{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxResponse) ProtoMessage() {}
+
+func (x *SandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxResponse.ProtoReflect.Descriptor instead.
+func (*SandboxResponse) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SandboxResponse) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *SandboxResponse) GetResults() []*SandboxResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type SandboxResult struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Candidate *EvaluateResponse      `protobuf:"bytes,1,opt,name=candidate,proto3" json:"candidate,omitempty"`
+	// Live is the live map's response, if the request named one.
+	Live *EvaluateResponse `protobuf:"bytes,2,opt,name=live,proto3" json:"live,omitempty"`
+	// Changed reports whether the link or code differs from the live map's.
+	Changed       bool `protobuf:"varint,3,opt,name=changed,proto3" json:"changed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxResult) Reset() {
+	*x = SandboxResult{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxResult) ProtoMessage() {}
+
+func (x *SandboxResult) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxResult.ProtoReflect.Descriptor instead.
+func (*SandboxResult) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SandboxResult) GetCandidate() *EvaluateResponse {
+	if x != nil {
+		return x.Candidate
+	}
+	return nil
+}
+
+func (x *SandboxResult) GetLive() *EvaluateResponse {
+	if x != nil {
+		return x.Live
+	}
+	return nil
+}
+
+func (x *SandboxResult) GetChanged() bool {
+	if x != nil {
+		return x.Changed
+	}
+	return false
+}
+
+type GetMapRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Map           string                 `protobuf:"bytes,1,opt,name=map,proto3" json:"map,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMapRequest) Reset() {
+	*x = GetMapRequest{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMapRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMapRequest) ProtoMessage() {}
+
+func (x *GetMapRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMapRequest.ProtoReflect.Descriptor instead.
+func (*GetMapRequest) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetMapRequest) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+type Map struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Rules []*Rule                `protobuf:"bytes,1,rep,name=rules,proto3" json:"rules,omitempty"`
+	// Hash is a digest of the rules' meaning, as returned by Map.Hash.
+	Hash          string `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Map) Reset() {
+	*x = Map{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Map) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Map) ProtoMessage() {}
+
+func (x *Map) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Map.ProtoReflect.Descriptor instead.
+func (*Map) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Map) GetRules() []*Rule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *Map) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+type Rule struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Input  string                 `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Output string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	// Line is the 1-based source line, or 0.
+	Line        int32        `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Status      int32        `protobuf:"varint,4,opt,name=status,proto3" json:"status,omitempty"`
+	Description string       `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	Conditions  []*Condition `protobuf:"bytes,6,rep,name=conditions,proto3" json:"conditions,omitempty"`
+	// Source names the file the rule was parsed from, if known.
+	Source string `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`
+	// CacheControl is the Cache-Control header of redirects, if set.
+	CacheControl string `protobuf:"bytes,8,opt,name=cache_control,json=cacheControl,proto3" json:"cache_control,omitempty"`
+	// Weighted are outputs used instead of output for a percentage of
+	// evaluations each.
+	Weighted []*WeightedOutput `protobuf:"bytes,9,rep,name=weighted,proto3" json:"weighted,omitempty"`
+	// Scheduled are outputs which replace output and weighted from their
+	// cutover time on.
+	Scheduled     []*ScheduledOutput `protobuf:"bytes,10,rep,name=scheduled,proto3" json:"scheduled,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rule) Reset() {
+	*x = Rule{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rule) ProtoMessage() {}
+
+func (x *Rule) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rule.ProtoReflect.Descriptor instead.
+func (*Rule) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Rule) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *Rule) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *Rule) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Rule) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *Rule) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Rule) GetConditions() []*Condition {
+	if x != nil {
+		return x.Conditions
+	}
+	return nil
+}
+
+func (x *Rule) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Rule) GetCacheControl() string {
+	if x != nil {
+		return x.CacheControl
+	}
+	return ""
+}
+
+func (x *Rule) GetWeighted() []*WeightedOutput {
+	if x != nil {
+		return x.Weighted
+	}
+	return nil
+}
+
+func (x *Rule) GetScheduled() []*ScheduledOutput {
+	if x != nil {
+		return x.Scheduled
+	}
+	return nil
+}
+
+type Condition struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Var           string                 `protobuf:"bytes,1,opt,name=var,proto3" json:"var,omitempty"`
+	Pattern       string                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Condition) Reset() {
+	*x = Condition{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Condition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Condition) ProtoMessage() {}
+
+func (x *Condition) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Condition.ProtoReflect.Descriptor instead.
+func (*Condition) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Condition) GetVar() string {
+	if x != nil {
+		return x.Var
+	}
+	return ""
+}
+
+func (x *Condition) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+type WeightedOutput struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Output string                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	// Weight is a percentage, from 1 to 100.
+	Weight        int32 `protobuf:"varint,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeightedOutput) Reset() {
+	*x = WeightedOutput{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeightedOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeightedOutput) ProtoMessage() {}
+
+func (x *WeightedOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeightedOutput.ProtoReflect.Descriptor instead.
+func (*WeightedOutput) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WeightedOutput) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *WeightedOutput) GetWeight() int32 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+type ScheduledOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+	Output        string                 `protobuf:"bytes,2,opt,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScheduledOutput) Reset() {
+	*x = ScheduledOutput{}
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduledOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduledOutput) ProtoMessage() {}
+
+func (x *ScheduledOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_linkmap_v1_linkmap_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduledOutput.ProtoReflect.Descriptor instead.
+func (*ScheduledOutput) Descriptor() ([]byte, []int) {
+	return file_linkmap_v1_linkmap_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ScheduledOutput) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *ScheduledOutput) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+var File_linkmap_v1_linkmap_proto protoreflect.FileDescriptor
+
+const file_linkmap_v1_linkmap_proto_rawDesc = "" +
+	"\n" +
+	"\x18linkmap/v1/linkmap.proto\x12\n" +
+	"linkmap.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"7\n" +
+	"\x0fEvaluateRequest\x12\x10\n" +
+	"\x03map\x18\x01 \x01(\tR\x03map\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\x96\x02\n" +
+	"\x10EvaluateResponse\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04link\x18\x02 \x01(\tR\x04link\x12$\n" +
+	"\x04rule\x18\x03 \x01(\v2\x10.linkmap.v1.RuleR\x04rule\x12:\n" +
+	"\x04vars\x18\x04 \x03(\v2&.linkmap.v1.EvaluateResponse.VarsEntryR\x04vars\x12)\n" +
+	"\x04code\x18\x05 \x01(\x0e2\x15.linkmap.v1.ErrorCodeR\x04code\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x1a7\n" +
+	"\tVarsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"P\n" +
+	"\x0eSandboxRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x10\n" +
+	"\x03map\x18\x02 \x01(\tR\x03map\x12\x14\n" +
+	"\x05paths\x18\x03 \x03(\tR\x05paths\"Z\n" +
+	"\x0fSandboxResponse\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x123\n" +
+	"\aresults\x18\x02 \x03(\v2\x19.linkmap.v1.SandboxResultR\aresults\"\x97\x01\n" +
+	"\rSandboxResult\x12:\n" +
+	"\tcandidate\x18\x01 \x01(\v2\x1c.linkmap.v1.EvaluateResponseR\tcandidate\x120\n" +
+	"\x04live\x18\x02 \x01(\v2\x1c.linkmap.v1.EvaluateResponseR\x04live\x12\x18\n" +
+	"\achanged\x18\x03 \x01(\bR\achanged\"!\n" +
+	"\rGetMapRequest\x12\x10\n" +
+	"\x03map\x18\x01 \x01(\tR\x03map\"A\n" +
+	"\x03Map\x12&\n" +
+	"\x05rules\x18\x01 \x03(\v2\x10.linkmap.v1.RuleR\x05rules\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\"\xe9\x02\n" +
+	"\x04Rule\x12\x14\n" +
+	"\x05input\x18\x01 \x01(\tR\x05input\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\x05R\x06status\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\x125\n" +
+	"\n" +
+	"conditions\x18\x06 \x03(\v2\x15.linkmap.v1.ConditionR\n" +
+	"conditions\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x12#\n" +
+	"\rcache_control\x18\b \x01(\tR\fcacheControl\x126\n" +
+	"\bweighted\x18\t \x03(\v2\x1a.linkmap.v1.WeightedOutputR\bweighted\x129\n" +
+	"\tscheduled\x18\n" +
+	" \x03(\v2\x1b.linkmap.v1.ScheduledOutputR\tscheduled\"7\n" +
+	"\tCondition\x12\x10\n" +
+	"\x03var\x18\x01 \x01(\tR\x03var\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\"@\n" +
+	"\x0eWeightedOutput\x12\x16\n" +
+	"\x06output\x18\x01 \x01(\tR\x06output\x12\x16\n" +
+	"\x06weight\x18\x02 \x01(\x05R\x06weight\"U\n" +
+	"\x0fScheduledOutput\x12*\n" +
+	"\x02at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12\x16\n" +
+	"\x06output\x18\x02 \x01(\tR\x06output*\xea\x01\n" +
+	"\tErrorCode\x12\x1a\n" +
+	"\x16ERROR_CODE_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13ERROR_CODE_NO_MATCH\x10\x01\x12\x1b\n" +
+	"\x17ERROR_CODE_INVALID_PATH\x10\x02\x12\x16\n" +
+	"\x12ERROR_CODE_IGNORED\x10\x03\x12\x1e\n" +
+	"\x1aERROR_CODE_BUDGET_EXCEEDED\x10\x04\x12\x1e\n" +
+	"\x1aERROR_CODE_MAP_UNAVAILABLE\x10\x05\x12\x17\n" +
+	"\x13ERROR_CODE_INTERNAL\x10\x06\x12\x1a\n" +
+	"\x16ERROR_CODE_UNSAFE_LINK\x10\a2\xa1\x02\n" +
+	"\x0eLinkmapService\x12E\n" +
+	"\bEvaluate\x12\x1b.linkmap.v1.EvaluateRequest\x1a\x1c.linkmap.v1.EvaluateResponse\x12N\n" +
+	"\rEvaluateBatch\x12\x1b.linkmap.v1.EvaluateRequest\x1a\x1c.linkmap.v1.EvaluateResponse(\x010\x01\x124\n" +
+	"\x06GetMap\x12\x19.linkmap.v1.GetMapRequest\x1a\x0f.linkmap.v1.Map\x12B\n" +
+	"\aSandbox\x12\x1a.linkmap.v1.SandboxRequest\x1a\x1b.linkmap.v1.SandboxResponseB?Z=github.com/operandinc/linkmap/grpcservice/linkmapv1;linkmapv1b\x06proto3"
+
+var (
+	file_linkmap_v1_linkmap_proto_rawDescOnce sync.Once
+	file_linkmap_v1_linkmap_proto_rawDescData []byte
+)
+
+func file_linkmap_v1_linkmap_proto_rawDescGZIP() []byte {
+	file_linkmap_v1_linkmap_proto_rawDescOnce.Do(func() {
+		file_linkmap_v1_linkmap_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_linkmap_v1_linkmap_proto_rawDesc), len(file_linkmap_v1_linkmap_proto_rawDesc)))
+	})
+	return file_linkmap_v1_linkmap_proto_rawDescData
+}
+
+var file_linkmap_v1_linkmap_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_linkmap_v1_linkmap_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_linkmap_v1_linkmap_proto_goTypes = []any{
+	(ErrorCode)(0),                // 0: linkmap.v1.ErrorCode
+	(*EvaluateRequest)(nil),       // 1: linkmap.v1.EvaluateRequest
+	(*EvaluateResponse)(nil),      // 2: linkmap.v1.EvaluateResponse
+	(*SandboxRequest)(nil),        // 3: linkmap.v1.SandboxRequest
+	(*SandboxResponse)(nil),       // 4: linkmap.v1.SandboxResponse
+	(*SandboxResult)(nil),         // 5: linkmap.v1.SandboxResult
+	(*GetMapRequest)(nil),         // 6: linkmap.v1.GetMapRequest
+	(*Map)(nil),                   // 7: linkmap.v1.Map
+	(*Rule)(nil),                  // 8: linkmap.v1.Rule
+	(*Condition)(nil),             // 9: linkmap.v1.Condition
+	(*WeightedOutput)(nil),        // 10: linkmap.v1.WeightedOutput
+	(*ScheduledOutput)(nil),       // 11: linkmap.v1.ScheduledOutput
+	nil,                           // 12: linkmap.v1.EvaluateResponse.VarsEntry
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+}
+var file_linkmap_v1_linkmap_proto_depIdxs = []int32{
+	8,  // 0: linkmap.v1.EvaluateResponse.rule:type_name -> linkmap.v1.Rule
+	12, // 1: linkmap.v1.EvaluateResponse.vars:type_name -> linkmap.v1.EvaluateResponse.VarsEntry
+	0,  // 2: linkmap.v1.EvaluateResponse.code:type_name -> linkmap.v1.ErrorCode
+	5,  // 3: linkmap.v1.SandboxResponse.results:type_name -> linkmap.v1.SandboxResult
+	2,  // 4: linkmap.v1.SandboxResult.candidate:type_name -> linkmap.v1.EvaluateResponse
+	2,  // 5: linkmap.v1.SandboxResult.live:type_name -> linkmap.v1.EvaluateResponse
+	8,  // 6: linkmap.v1.Map.rules:type_name -> linkmap.v1.Rule
+	9,  // 7: linkmap.v1.Rule.conditions:type_name -> linkmap.v1.Condition
+	10, // 8: linkmap.v1.Rule.weighted:type_name -> linkmap.v1.WeightedOutput
+	11, // 9: linkmap.v1.Rule.scheduled:type_name -> linkmap.v1.ScheduledOutput
+	13, // 10: linkmap.v1.ScheduledOutput.at:type_name -> google.protobuf.Timestamp
+	1,  // 11: linkmap.v1.LinkmapService.Evaluate:input_type -> linkmap.v1.EvaluateRequest
+	1,  // 12: linkmap.v1.LinkmapService.EvaluateBatch:input_type -> linkmap.v1.EvaluateRequest
+	6,  // 13: linkmap.v1.LinkmapService.GetMap:input_type -> linkmap.v1.GetMapRequest
+	3,  // 14: linkmap.v1.LinkmapService.Sandbox:input_type -> linkmap.v1.SandboxRequest
+	2,  // 15: linkmap.v1.LinkmapService.Evaluate:output_type -> linkmap.v1.EvaluateResponse
+	2,  // 16: linkmap.v1.LinkmapService.EvaluateBatch:output_type -> linkmap.v1.EvaluateResponse
+	7,  // 17: linkmap.v1.LinkmapService.GetMap:output_type -> linkmap.v1.Map
+	4,  // 18: linkmap.v1.LinkmapService.Sandbox:output_type -> linkmap.v1.SandboxResponse
+	15, // [15:19] is the sub-list for method output_type
+	11, // [11:15] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_linkmap_v1_linkmap_proto_init() }
+func file_linkmap_v1_linkmap_proto_init() {
+	if File_linkmap_v1_linkmap_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_linkmap_v1_linkmap_proto_rawDesc), len(file_linkmap_v1_linkmap_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_linkmap_v1_linkmap_proto_goTypes,
+		DependencyIndexes: file_linkmap_v1_linkmap_proto_depIdxs,
+		EnumInfos:         file_linkmap_v1_linkmap_proto_enumTypes,
+		MessageInfos:      file_linkmap_v1_linkmap_proto_msgTypes,
+	}.Build()
+	File_linkmap_v1_linkmap_proto = out.File
+	file_linkmap_v1_linkmap_proto_goTypes = nil
+	file_linkmap_v1_linkmap_proto_depIdxs = nil
+}
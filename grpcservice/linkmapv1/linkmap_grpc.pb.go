@@ -0,0 +1,252 @@
+// Protocol buffer schema for linkmap evaluation services.
+//
+// Service implements the semantics of LinkmapService in a transport-neutral
+// way in package github.com/operandinc/linkmap/service. The gRPC server and
+// the code generated from this file live in the separate module
+// github.com/operandinc/linkmap/grpcservice, so that the linkmap module
+// itself has no dependencies; run buf generate there after changing it.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: linkmap/v1/linkmap.proto
+
+package linkmapv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	LinkmapService_Evaluate_FullMethodName      = "/linkmap.v1.LinkmapService/Evaluate"
+	LinkmapService_EvaluateBatch_FullMethodName = "/linkmap.v1.LinkmapService/EvaluateBatch"
+	LinkmapService_GetMap_FullMethodName        = "/linkmap.v1.LinkmapService/GetMap"
+	LinkmapService_Sandbox_FullMethodName       = "/linkmap.v1.LinkmapService/Sandbox"
+)
+
+// LinkmapServiceClient is the client API for LinkmapService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// LinkmapService evaluates paths against maps held by a central server.
+type LinkmapServiceClient interface {
+	// Evaluate evaluates a single path.
+	Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error)
+	// EvaluateBatch evaluates a stream of paths, responding to each in order.
+	EvaluateBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EvaluateRequest, EvaluateResponse], error)
+	// GetMap returns the rules of a map, in evaluation order.
+	GetMap(ctx context.Context, in *GetMapRequest, opts ...grpc.CallOption) (*Map, error)
+	// Sandbox evaluates paths against a candidate map given in the request,
+	// comparing the results with a live map's, without replacing it.
+	Sandbox(ctx context.Context, in *SandboxRequest, opts ...grpc.CallOption) (*SandboxResponse, error)
+}
+
+type linkmapServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLinkmapServiceClient(cc grpc.ClientConnInterface) LinkmapServiceClient {
+	return &linkmapServiceClient{cc}
+}
+
+func (c *linkmapServiceClient) Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EvaluateResponse)
+	err := c.cc.Invoke(ctx, LinkmapService_Evaluate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkmapServiceClient) EvaluateBatch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[EvaluateRequest, EvaluateResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LinkmapService_ServiceDesc.Streams[0], LinkmapService_EvaluateBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EvaluateRequest, EvaluateResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LinkmapService_EvaluateBatchClient = grpc.BidiStreamingClient[EvaluateRequest, EvaluateResponse]
+
+func (c *linkmapServiceClient) GetMap(ctx context.Context, in *GetMapRequest, opts ...grpc.CallOption) (*Map, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Map)
+	err := c.cc.Invoke(ctx, LinkmapService_GetMap_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *linkmapServiceClient) Sandbox(ctx context.Context, in *SandboxRequest, opts ...grpc.CallOption) (*SandboxResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SandboxResponse)
+	err := c.cc.Invoke(ctx, LinkmapService_Sandbox_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// LinkmapServiceServer is the server API for LinkmapService service.
+// All implementations must embed UnimplementedLinkmapServiceServer
+// for forward compatibility.
+//
+// LinkmapService evaluates paths against maps held by a central server.
+type LinkmapServiceServer interface {
+	// Evaluate evaluates a single path.
+	Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error)
+	// EvaluateBatch evaluates a stream of paths, responding to each in order.
+	EvaluateBatch(grpc.BidiStreamingServer[EvaluateRequest, EvaluateResponse]) error
+	// GetMap returns the rules of a map, in evaluation order.
+	GetMap(context.Context, *GetMapRequest) (*Map, error)
+	// Sandbox evaluates paths against a candidate map given in the request,
+	// comparing the results with a live map's, without replacing it.
+	Sandbox(context.Context, *SandboxRequest) (*SandboxResponse, error)
+	mustEmbedUnimplementedLinkmapServiceServer()
+}
+
+// UnimplementedLinkmapServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedLinkmapServiceServer struct{}
+
+func (UnimplementedLinkmapServiceServer) Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Evaluate not implemented")
+}
+func (UnimplementedLinkmapServiceServer) EvaluateBatch(grpc.BidiStreamingServer[EvaluateRequest, EvaluateResponse]) error {
+	return status.Error(codes.Unimplemented, "method EvaluateBatch not implemented")
+}
+func (UnimplementedLinkmapServiceServer) GetMap(context.Context, *GetMapRequest) (*Map, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetMap not implemented")
+}
+func (UnimplementedLinkmapServiceServer) Sandbox(context.Context, *SandboxRequest) (*SandboxResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Sandbox not implemented")
+}
+func (UnimplementedLinkmapServiceServer) mustEmbedUnimplementedLinkmapServiceServer() {}
+func (UnimplementedLinkmapServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeLinkmapServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LinkmapServiceServer will
+// result in compilation errors.
+type UnsafeLinkmapServiceServer interface {
+	mustEmbedUnimplementedLinkmapServiceServer()
+}
+
+func RegisterLinkmapServiceServer(s grpc.ServiceRegistrar, srv LinkmapServiceServer) {
+	// If the following call panics, it indicates UnimplementedLinkmapServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&LinkmapService_ServiceDesc, srv)
+}
+
+func _LinkmapService_Evaluate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkmapServiceServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkmapService_Evaluate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkmapServiceServer).Evaluate(ctx, req.(*EvaluateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkmapService_EvaluateBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LinkmapServiceServer).EvaluateBatch(&grpc.GenericServerStream[EvaluateRequest, EvaluateResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type LinkmapService_EvaluateBatchServer = grpc.BidiStreamingServer[EvaluateRequest, EvaluateResponse]
+
+func _LinkmapService_GetMap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkmapServiceServer).GetMap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkmapService_GetMap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkmapServiceServer).GetMap(ctx, req.(*GetMapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LinkmapService_Sandbox_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SandboxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LinkmapServiceServer).Sandbox(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LinkmapService_Sandbox_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LinkmapServiceServer).Sandbox(ctx, req.(*SandboxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// LinkmapService_ServiceDesc is the grpc.ServiceDesc for LinkmapService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LinkmapService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "linkmap.v1.LinkmapService",
+	HandlerType: (*LinkmapServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Evaluate",
+			Handler:    _LinkmapService_Evaluate_Handler,
+		},
+		{
+			MethodName: "GetMap",
+			Handler:    _LinkmapService_GetMap_Handler,
+		},
+		{
+			MethodName: "Sandbox",
+			Handler:    _LinkmapService_Sandbox_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "EvaluateBatch",
+			Handler:       _LinkmapService_EvaluateBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "linkmap/v1/linkmap.proto",
+}
@@ -0,0 +1,165 @@
+// Package grpcservice serves the linkmap evaluation service over gRPC, as
+// described by proto/linkmap/v1/linkmap.proto. It is a separate module so
+// that the linkmap module itself has no dependencies; the semantics of
+// every RPC are those of package service, which this package only adapts.
+//
+// The code in linkmapv1 is generated from the schema with buf generate.
+package grpcservice
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/operandinc/linkmap/grpcservice/linkmapv1"
+	"github.com/operandinc/linkmap/service"
+)
+
+// A Server implements linkmapv1.LinkmapServiceServer with a Service.
+type Server struct {
+	linkmapv1.UnimplementedLinkmapServiceServer
+	Service *service.Service
+	// AllowSandbox enables the Sandbox RPC. Like the operations of
+	// Service.AdminHandler, it should only be served to trusted clients,
+	// e.g. on a private address; otherwise it fails with Unimplemented.
+	AllowSandbox bool
+}
+
+// Register registers a Server for s with g, with the Sandbox RPC disabled.
+func Register(g grpc.ServiceRegistrar, s *service.Service) {
+	linkmapv1.RegisterLinkmapServiceServer(g, &Server{Service: s})
+}
+
+// Evaluate evaluates a single path. Evaluation failures are reported in the
+// response, as by Service.Evaluate.
+func (s *Server) Evaluate(ctx context.Context, req *linkmapv1.EvaluateRequest) (*linkmapv1.EvaluateResponse, error) {
+	resp, err := s.Service.Evaluate(ctx, &service.EvaluateRequest{Map: req.GetMap(), Path: req.GetPath()})
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	return evaluateResponse(resp), nil
+}
+
+// EvaluateBatch evaluates the paths of a stream, responding to each in
+// order.
+func (s *Server) EvaluateBatch(stream linkmapv1.LinkmapService_EvaluateBatchServer) error {
+	recv := func() (*service.EvaluateRequest, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return &service.EvaluateRequest{Map: req.GetMap(), Path: req.GetPath()}, nil
+	}
+	send := func(resp *service.EvaluateResponse) error {
+		return stream.Send(evaluateResponse(resp))
+	}
+	err := s.Service.EvaluateBatch(stream.Context(), recv, send)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+// GetMap returns the rules of a map. It fails with Unavailable if the map
+// cannot be loaded.
+func (s *Server) GetMap(ctx context.Context, req *linkmapv1.GetMapRequest) (*linkmapv1.Map, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	m, err := s.Service.GetMap(ctx, req.GetMap())
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &linkmapv1.Map{Hash: m.Hash}
+	for _, r := range m.Rules {
+		resp.Rules = append(resp.Rules, rule(&r))
+	}
+	return resp, nil
+}
+
+// Sandbox evaluates paths against a candidate map, as Service.Sandbox
+// does, if s.AllowSandbox is set. It fails with InvalidArgument if the
+// candidate does not parse, and with Unavailable if the live map cannot be
+// loaded.
+func (s *Server) Sandbox(ctx context.Context, req *linkmapv1.SandboxRequest) (*linkmapv1.SandboxResponse, error) {
+	if !s.AllowSandbox {
+		return s.UnimplementedLinkmapServiceServer.Sandbox(ctx, req)
+	}
+	resp, err := s.Service.Sandbox(ctx, &service.SandboxRequest{Source: req.GetSource(), Map: req.GetMap(), Paths: req.GetPaths()})
+	var serr *service.SourceError
+	switch {
+	case errors.As(err, &serr):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return nil, status.FromContextError(err).Err()
+	case err != nil:
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	out := &linkmapv1.SandboxResponse{Hash: resp.Hash}
+	for _, r := range resp.Results {
+		out.Results = append(out.Results, &linkmapv1.SandboxResult{
+			Candidate: evaluateResponse(r.Candidate),
+			Live:      evaluateResponse(r.Live),
+			Changed:   r.Changed,
+		})
+	}
+	return out, nil
+}
+
+// evaluateResponse converts a response to its message form. A nil response
+// converts to nil.
+func evaluateResponse(resp *service.EvaluateResponse) *linkmapv1.EvaluateResponse {
+	if resp == nil {
+		return nil
+	}
+	out := &linkmapv1.EvaluateResponse{
+		Path:  resp.Path,
+		Link:  resp.Link,
+		Vars:  resp.Vars,
+		Code:  errorCode(resp.Code),
+		Error: resp.Error,
+	}
+	if resp.Rule != nil {
+		out.Rule = rule(resp.Rule)
+	}
+	return out
+}
+
+// errorCode converts an error code to its enum value. The names of the
+// values are those of the codes, prefixed with ERROR_CODE_.
+func errorCode(code service.ErrorCode) linkmapv1.ErrorCode {
+	if code == "" {
+		return linkmapv1.ErrorCode_ERROR_CODE_UNSPECIFIED
+	}
+	if v, ok := linkmapv1.ErrorCode_value["ERROR_CODE_"+string(code)]; ok {
+		return linkmapv1.ErrorCode(v)
+	}
+	return linkmapv1.ErrorCode_ERROR_CODE_INTERNAL
+}
+
+// rule converts a rule to its message form.
+func rule(r *service.Rule) *linkmapv1.Rule {
+	out := &linkmapv1.Rule{
+		Input:        r.Input,
+		Output:       r.Output,
+		Line:         int32(r.Line),
+		Status:       int32(r.Status),
+		Description:  r.Description,
+		Source:       r.Source,
+		CacheControl: r.CacheControl,
+	}
+	for _, c := range r.Conditions {
+		out.Conditions = append(out.Conditions, &linkmapv1.Condition{Var: c.Var, Pattern: c.Pattern})
+	}
+	for _, w := range r.Weighted {
+		out.Weighted = append(out.Weighted, &linkmapv1.WeightedOutput{Output: w.Output, Weight: int32(w.Weight)})
+	}
+	for _, s := range r.Scheduled {
+		out.Scheduled = append(out.Scheduled, &linkmapv1.ScheduledOutput{At: timestamppb.New(s.At), Output: s.Output})
+	}
+	return out
+}
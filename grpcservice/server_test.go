@@ -0,0 +1,170 @@
+package grpcservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/operandinc/linkmap"
+	"github.com/operandinc/linkmap/grpcservice/linkmapv1"
+	"github.com/operandinc/linkmap/service"
+)
+
+// dial serves srv on an in-memory listener and returns a client for it.
+func dial(t *testing.T, srv *Server) linkmapv1.LinkmapServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	linkmapv1.RegisterLinkmapServiceServer(g, srv)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return linkmapv1.NewLinkmapServiceClient(conn)
+}
+
+func testService() *service.Service {
+	sources := map[string]string{
+		"docs": "docs/$1.md https://example.com/$1\n",
+	}
+	return &service.Service{Maps: &linkmap.MapSet{
+		Load: func(key string) ([]byte, error) {
+			src, ok := sources[key]
+			if !ok {
+				return nil, errors.New("no such map")
+			}
+			return []byte(src), nil
+		},
+	}}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := dial(t, &Server{Service: testService()})
+	tests := []struct {
+		req  *linkmapv1.EvaluateRequest
+		link string
+		code linkmapv1.ErrorCode
+	}{
+		{&linkmapv1.EvaluateRequest{Map: "docs", Path: "docs/a.md"}, "https://example.com/a", linkmapv1.ErrorCode_ERROR_CODE_UNSPECIFIED},
+		{&linkmapv1.EvaluateRequest{Map: "docs", Path: "other/a.md"}, "", linkmapv1.ErrorCode_ERROR_CODE_NO_MATCH},
+		{&linkmapv1.EvaluateRequest{Map: "docs", Path: "/abs"}, "", linkmapv1.ErrorCode_ERROR_CODE_INVALID_PATH},
+		{&linkmapv1.EvaluateRequest{Map: "nope", Path: "docs/a.md"}, "", linkmapv1.ErrorCode_ERROR_CODE_MAP_UNAVAILABLE},
+	}
+	for _, tt := range tests {
+		resp, err := c.Evaluate(context.Background(), tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetLink() != tt.link || resp.GetCode() != tt.code {
+			t.Errorf("Evaluate(%v) = %v; want link %q, code %v", tt.req, resp, tt.link, tt.code)
+		}
+	}
+	resp, _ := c.Evaluate(context.Background(), &linkmapv1.EvaluateRequest{Map: "docs", Path: "docs/a.md"})
+	if resp.GetRule().GetInput() != "docs/$1.md" || resp.GetRule().GetLine() != 1 || resp.GetVars()["1"] != "a" {
+		t.Errorf("Evaluate() rule = %v, vars = %v", resp.GetRule(), resp.GetVars())
+	}
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	c := dial(t, &Server{Service: testService()})
+	stream, err := c.EvaluateBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"docs/a.md", "docs/b.md", "x"}
+	for _, p := range paths {
+		if err := stream.Send(&linkmapv1.EvaluateRequest{Map: "docs", Path: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	var got []*linkmapv1.EvaluateResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp)
+	}
+	if len(got) != len(paths) {
+		t.Fatalf("EvaluateBatch() returned %d responses; want %d", len(got), len(paths))
+	}
+	for i, p := range paths {
+		if got[i].GetPath() != p {
+			t.Errorf("response %d is for %q; want %q", i, got[i].GetPath(), p)
+		}
+	}
+	if got[1].GetLink() != "https://example.com/b" || got[2].GetCode() != linkmapv1.ErrorCode_ERROR_CODE_NO_MATCH {
+		t.Errorf("EvaluateBatch() = %v", got)
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	c := dial(t, &Server{Service: testService()})
+	m, err := c.GetMap(context.Background(), &linkmapv1.GetMapRequest{Map: "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.GetRules()) != 1 || m.GetRules()[0].GetOutput() != "https://example.com/$1" || m.GetHash() == "" {
+		t.Errorf("GetMap() = %v", m)
+	}
+	if _, err := c.GetMap(context.Background(), &linkmapv1.GetMapRequest{Map: "nope"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetMap(nope) error = %v; want Unavailable", err)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	req := &linkmapv1.SandboxRequest{
+		Source: "docs/$1.md https://docs.example.com/$1\n",
+		Map:    "docs",
+		Paths:  []string{"docs/a.md"},
+	}
+	c := dial(t, &Server{Service: testService()})
+	if _, err := c.Sandbox(context.Background(), req); status.Code(err) != codes.Unimplemented {
+		t.Errorf("Sandbox() error = %v; want Unimplemented unless allowed", err)
+	}
+	c = dial(t, &Server{Service: testService(), AllowSandbox: true})
+	resp, err := c.Sandbox(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.GetResults()
+	if len(res) != 1 || !res[0].GetChanged() || res[0].GetCandidate().GetLink() != "https://docs.example.com/a" || res[0].GetLive().GetLink() != "https://example.com/a" {
+		t.Errorf("Sandbox() = %v", resp)
+	}
+	req.Source = "docs/$ x\n"
+	if _, err := c.Sandbox(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Sandbox(invalid) error = %v; want InvalidArgument", err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	for _, code := range []service.ErrorCode{
+		service.CodeNoMatch, service.CodeInvalidPath, service.CodeIgnored, service.CodeBudgetExceeded,
+		service.CodeMapUnavailable, service.CodeInternal, service.CodeUnsafeLink,
+	} {
+		if got := errorCode(code); got.String() != "ERROR_CODE_"+string(code) {
+			t.Errorf("errorCode(%s) = %v", code, got)
+		}
+	}
+}
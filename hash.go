@@ -0,0 +1,52 @@
+package linkmap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Hash returns a digest of the map's rules, in hex, which identifies what
+// the map does rather than how its source is written: comments, blank
+// lines, spacing, line numbers, descriptions and the choice of the text or
+// TOML format do not change it, while the order of rules does. It suits
+// cache keys, ETags and checks in CI for changes in meaning. Options, such
+// as WithResolver, are not part of the digest.
+func (m *Map) Hash() string {
+	h := sha256.New()
+	for _, r := range m.rules {
+		writeMeaning(h, r)
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeMeaning writes what r does to w, leaving out its origin, its
+// description and the formatting of its source.
+func writeMeaning(w io.Writer, r Rule) {
+	conds := append([]Condition(nil), r.Conditions...)
+	sort.Slice(conds, func(i, j int) bool {
+		if conds[i].Var != conds[j].Var {
+			return conds[i].Var < conds[j].Var
+		}
+		return conds[i].Pattern < conds[j].Pattern
+	})
+	fmt.Fprintf(w, "%q %q %d %q %d", r.Input.String(), r.Output.String(), r.Status, r.CacheControl, len(conds))
+	for _, c := range conds {
+		fmt.Fprintf(w, " %q %q", c.Var, c.Pattern)
+	}
+	if len(r.Weighted) > 0 {
+		fmt.Fprintf(w, " %d", len(r.Weighted))
+		for _, wo := range r.Weighted {
+			fmt.Fprintf(w, " %q %d", wo.Output.String(), wo.Weight)
+		}
+	}
+	if len(r.Scheduled) > 0 {
+		fmt.Fprintf(w, " scheduled %d", len(r.Scheduled))
+		for _, s := range r.Scheduled {
+			fmt.Fprintf(w, " %d %q", s.At.UnixNano(), s.Output.String())
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	parse := func(src string) *Map {
+		t.Helper()
+		m, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	base := parse("docs/$1.md https://example.com/$1\nblog/$1.md https://example.com/blog/$1\n")
+	same := parse("# Docs\n\ndocs/$1.md https://example.com/$1\n\n# Blog\nblog/$1.md https://example.com/blog/$1\n")
+	if base.Hash() != same.Hash() {
+		t.Error("Hash() changed with formatting")
+	}
+	toml, err := ParseTOML(strings.NewReader(`[[rule]]
+from = "docs/$1.md"
+to = "https://example.com/$1"
+description = "Docs"
+
+[[rule]]
+from = "blog/$1.md"
+to = "https://example.com/blog/$1"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Hash() != toml.Hash() {
+		t.Error("Hash() differs between the text and TOML formats")
+	}
+	for _, src := range []string{
+		"docs/$1.md https://example.com/$1\n",
+		"docs/$1.md https://example.org/$1\nblog/$1.md https://example.com/blog/$1\n",
+	} {
+		if parse(src).Hash() == base.Hash() {
+			t.Errorf("Hash() of %q equals the original", src)
+		}
+	}
+	status := New([]Rule{base.Rules()[0], base.Rules()[1]})
+	status.rules[0].Status = 301
+	if status.Hash() == base.Hash() {
+		t.Error("Hash() ignores status")
+	}
+}
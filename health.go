@@ -0,0 +1,104 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// loadFailure records the last failed load or reload of a key.
+type loadFailure struct {
+	err  error
+	time time.Time
+}
+
+// Health statuses reported by MapSet.Health.
+const (
+	// HealthOK means every map loaded successfully.
+	HealthOK = "ok"
+	// HealthDegraded means a reload failed and the last good map is still
+	// being served.
+	HealthDegraded = "degraded"
+	// HealthFailing means a map failed to load and there is no previous
+	// map to fall back to.
+	HealthFailing = "failing"
+)
+
+// Health summarizes the loading state of a MapSet.
+type Health struct {
+	Status string `json:"status"`
+	// Errors holds the last load error for each failing key.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// LastError returns the error from the most recent failed load or reload
+// of key, or nil if its most recent load succeeded.
+func (s *MapSet) LastError(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.failures[key]; ok {
+		return f.err
+	}
+	return nil
+}
+
+// Health reports whether the maps loaded successfully.
+func (s *MapSet) Health() Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := Health{Status: HealthOK}
+	if len(s.failures) == 0 {
+		return h
+	}
+	h.Status = HealthDegraded
+	h.Errors = make(map[string]string, len(s.failures))
+	for k, f := range s.failures {
+		h.Errors[k] = f.err.Error()
+		if e, ok := s.entries[k]; !ok || e.m == nil {
+			h.Status = HealthFailing
+		}
+	}
+	return h
+}
+
+// HealthHandler returns a handler reporting Health as JSON, suitable for
+// readiness checks. It responds with 503 Service Unavailable when the
+// status is failing, and 200 OK otherwise, so that a bad linkmap deploy
+// degrades gracefully rather than taking the service out of rotation.
+func (s *MapSet) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := s.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status == HealthFailing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
+
+// setFailureLocked records the outcome of loading key.
+func (s *MapSet) setFailureLocked(key string, err error) {
+	if err == nil {
+		delete(s.failures, key)
+		if s.loaded == nil {
+			s.loaded = make(map[string]bool)
+		}
+		s.loaded[key] = true
+		return
+	}
+	if s.failures == nil {
+		s.failures = make(map[string]loadFailure)
+	}
+	s.failures[key] = loadFailure{err: err, time: time.Now()}
+}
+
+// failureKeysLocked returns the keys with failures, in sorted order.
+func (s *MapSet) failureKeysLocked() []string {
+	keys := make([]string, 0, len(s.failures))
+	for k := range s.failures {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
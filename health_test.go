@@ -0,0 +1,73 @@
+package linkmap
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMapSetHealth(t *testing.T) {
+	sources := map[string]string{
+		"good": "docs/$1.md https://example.com/$1\n",
+	}
+	s := &MapSet{
+		Load: func(key string) ([]byte, error) {
+			src, ok := sources[key]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return []byte(src), nil
+		},
+	}
+	status := func() int {
+		rec := httptest.NewRecorder()
+		s.HealthHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		return rec.Code
+	}
+	if _, err := s.Get("good"); err != nil {
+		t.Fatalf("Get(good) error: %v", err)
+	}
+	if h := s.Health(); h.Status != HealthOK || status() != 200 {
+		t.Errorf("Health() = %+v; want ok", h)
+	}
+
+	sources["good"] = "broken\n"
+	if err := s.Reload("good"); err == nil {
+		t.Fatalf("Reload(broken) succeeded")
+	}
+	if s.LastError("good") == nil {
+		t.Errorf("LastError(good) = nil after failed reload")
+	}
+	if h := s.Health(); h.Status != HealthDegraded || status() != 200 {
+		t.Errorf("Health() = %+v; want degraded", h)
+	}
+	if got, err := s.Evaluate("good", "docs/a.md"); err != nil || got != "https://example.com/a" {
+		t.Errorf("Evaluate() after failed reload = %q, %v; want last good map", got, err)
+	}
+
+	// Keys which never loaded are not failures: anyone can ask for one.
+	if _, err := s.Get("missing"); err == nil {
+		t.Fatalf("Get(missing) succeeded")
+	}
+	if h := s.Health(); h.Status != HealthDegraded || len(h.Errors) != 1 {
+		t.Errorf("Health() after Get(missing) = %+v; want degraded by good alone", h)
+	}
+	if err := s.Reload("missing"); err == nil {
+		t.Fatalf("Reload(missing) succeeded")
+	}
+	if h := s.Health(); h.Status != HealthFailing || status() != 503 {
+		t.Errorf("Health() = %+v; want failing", h)
+	}
+	if info := s.DebugInfo(); len(info.Maps) != 2 || info.Maps[1].Key != "missing" || info.Maps[1].LastError == "" {
+		t.Errorf("DebugInfo().Maps = %+v", info.Maps)
+	}
+
+	sources["good"] = "docs/$1.md https://example.com/v2/$1\n"
+	if err := s.Reload("good"); err != nil {
+		t.Fatalf("Reload(good) error: %v", err)
+	}
+	s.Evict("missing")
+	if h := s.Health(); h.Status != HealthOK {
+		t.Errorf("Health() after recovery = %+v; want ok", h)
+	}
+}
@@ -0,0 +1,81 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/operandinc/linkmap/gitignore"
+)
+
+// IgnoreFile is the name of the ignore file read by LoadIgnore.
+const IgnoreFile = ".linkmapignore"
+
+// ErrIgnored is returned when evaluating a path excluded by the map's
+// ignore patterns.
+var ErrIgnored = errors.New("linkmap: path is ignored")
+
+// An Ignore is a list of patterns, in gitignore syntax, which exclude paths
+// from evaluation. See package gitignore for the syntax; patterns are
+// relative to the root of the evaluated paths.
+type Ignore struct {
+	m *gitignore.Matcher
+}
+
+// NewIgnore returns an Ignore excluding the paths matched by patterns, for
+// programs which configure exclusions without an ignore file.
+func NewIgnore(patterns ...string) (*Ignore, error) {
+	m, err := gitignore.New(patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: %w", err)
+	}
+	return &Ignore{m: m}, nil
+}
+
+// ParseIgnore reads an ignore file from r.
+func ParseIgnore(r io.Reader) (*Ignore, error) {
+	m, err := gitignore.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: %s: %w", IgnoreFile, err)
+	}
+	return &Ignore{m: m}, nil
+}
+
+// LoadIgnore reads the IgnoreFile at the root of fsys. If there is none, it
+// returns an empty Ignore.
+func LoadIgnore(fsys fs.FS) (*Ignore, error) {
+	f, err := fsys.Open(IgnoreFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Ignore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: reading ignore file: %w", err)
+	}
+	defer f.Close()
+	return ParseIgnore(f)
+}
+
+// Match reports whether fpath, a file path in the form passed to Evaluate,
+// is ignored.
+func (ig *Ignore) Match(fpath string) bool {
+	return ig.match(fpath, false)
+}
+
+// match reports whether fpath is ignored; isDir reports whether fpath is a
+// directory.
+func (ig *Ignore) match(fpath string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+	return ig.m.Match(fpath, isDir)
+}
+
+// WithIgnore excludes the paths matched by ig. Evaluating an ignored path
+// fails with ErrIgnored before any rule is tried, and EvaluateFS skips
+// ignored files and directories.
+func WithIgnore(ig *Ignore) Option {
+	return func(m *Map) {
+		m.ignore = ig
+	}
+}
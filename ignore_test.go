@@ -0,0 +1,80 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnore(t *testing.T) {
+	ig, err := ParseIgnore(strings.NewReader("# Generated\nvendor/\n*.gen.go\n/build\ndocs/internal/*.md\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"vendor/a/b.go", true},
+		{"src/vendor/a.go", true},
+		{"vendor", false},
+		{"api.gen.go", true},
+		{"pkg/api.gen.go", true},
+		{"build/out.js", true},
+		{"src/build/out.js", false},
+		{"docs/internal/x.md", true},
+		{"docs/internal/x/y.md", false},
+		{"docs/intro.md", false},
+	}
+	for _, tt := range tests {
+		if got := ig.Match(tt.path); got != tt.want {
+			t.Errorf("Match(%q) = %v; want %v", tt.path, got, tt.want)
+		}
+	}
+	if _, err := ParseIgnore(strings.NewReader("[")); err == nil {
+		t.Errorf("ParseIgnore(invalid) succeeded")
+	}
+
+	ig, err = NewIgnore("generated/**", "!generated/keep/", "!generated/keep/**")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ig.Match("generated/a.go") || ig.Match("generated/keep/b.go") {
+		t.Errorf("NewIgnore() re-include not applied")
+	}
+}
+
+func TestWithIgnore(t *testing.T) {
+	fsys := fstest.MapFS{
+		IgnoreFile:         {Data: []byte("vendor/\n")},
+		"docs/a.md":        {},
+		"vendor/x/b.md":    {},
+		"docs/vendor/c.md": {},
+	}
+	ig, err := LoadIgnore(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := Parse(strings.NewReader("$1.md https://example.com/$1"), WithIgnore(ig))
+	if _, err := m.Evaluate("vendor/x/b.md"); !errors.Is(err, ErrIgnored) {
+		t.Errorf("Evaluate(ignored) = %v; want ErrIgnored", err)
+	}
+	if got, err := m.Evaluate("docs/a.md"); err != nil || got != "https://example.com/docs/a" {
+		t.Errorf("Evaluate() = %q, %v", got, err)
+	}
+	results, err := m.EvaluateFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range results {
+		paths = append(paths, r.Path)
+	}
+	if got := strings.Join(paths, ","); got != ".linkmapignore,docs/a.md" {
+		t.Errorf("EvaluateFS() paths = %s", got)
+	}
+	if ig, err := LoadIgnore(fstest.MapFS{}); err != nil || ig.Match("a") {
+		t.Errorf("LoadIgnore(no file) = %v, %v", ig, err)
+	}
+}
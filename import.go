@@ -0,0 +1,326 @@
+package linkmap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Pair is an explicit mapping from a file path to a link.
+type Pair struct {
+	Path string
+	Link string
+}
+
+// ImportOptions configures ImportCSV and ImportRedirects.
+type ImportOptions struct {
+	// Header skips the first record.
+	Header bool
+	// Compress generalizes the pairs into templated rules using Infer.
+	Compress bool
+}
+
+// ImportCSV reads (path, link) records from r and returns a Map which maps
+// each path to its link. Leading and trailing slashes are trimmed from the
+// paths, which must then be valid (see fs.ValidPath). Without compression, each record becomes a literal
+// rule.
+func ImportCSV(r io.Reader, opts ImportOptions, mapOpts ...Option) (*Map, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	var pairs []Pair
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("linkmap: reading CSV: %w", err)
+		}
+		if first && opts.Header {
+			continue
+		}
+		// Paths are matched relative to the root, as by ImportRedirects.
+		p := strings.Trim(rec[0], "/")
+		if p == "" || !fs.ValidPath(p) {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("linkmap: CSV line %d: %q is not a valid path", line, rec[0])
+		}
+		pairs = append(pairs, Pair{Path: p, Link: rec[1]})
+	}
+	if opts.Compress {
+		return Infer(pairs, mapOpts...)
+	}
+	rules, err := literalRules(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return New(rules, mapOpts...), nil
+}
+
+// literalRules returns a literal rule for each pair.
+func literalRules(pairs []Pair) ([]Rule, error) {
+	seen := make(map[string]string, len(pairs))
+	rules := make([]Rule, len(pairs))
+	for i, p := range pairs {
+		if link, ok := seen[p.Path]; ok && link != p.Link {
+			return nil, fmt.Errorf("linkmap: path %q maps to both %q and %q", p.Path, link, p.Link)
+		}
+		seen[p.Path] = p.Link
+		in, err := literal(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		out, err := literal(p.Link)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = Rule{Input: in, Output: out}
+	}
+	return rules, nil
+}
+
+// literal returns a template which matches exactly s. It fails for text
+// which would not read back as such in the linkmap format: text with
+// template syntax, or starting like a comment, a regexp or a text/template.
+func literal(s string) (Template, error) {
+	if s == "" || strings.ContainsAny(s, "${}< \t\n") || strings.HasPrefix(s, "#") || strings.HasPrefix(s, rePrefix) || strings.HasPrefix(s, textPrefix) {
+		return Template{}, fmt.Errorf("linkmap: %q cannot be used as a literal template", s)
+	}
+	return Template{segs: makeTemplate([]segment{{typ: segmentTypeString, val: s}})}, nil
+}
+
+// generalize returns a rule mapping p.Path to p.Link which captures the
+// longest substring they share in a variable.
+func generalize(p Pair) (Rule, bool) {
+	i, j, n := longestCommonSubstring(p.Path, p.Link)
+	if n == 0 {
+		return Rule{}, false
+	}
+	in, err := parseTemplate(p.Path[:i] + "$1" + p.Path[i+n:])
+	if err != nil || len(in.variables()) != 1 {
+		return Rule{}, false
+	}
+	out, err := parseTemplate(p.Link[:j] + "$1" + p.Link[j+n:])
+	if err != nil || len(out.variables()) != 1 {
+		return Rule{}, false
+	}
+	if in.variables()[0] != "1" || out.variables()[0] != "1" {
+		// The text following the variable began with a digit.
+		return Rule{}, false
+	}
+	return Rule{Input: Template{segs: in}, Output: Template{segs: out}}, true
+}
+
+// longestCommonSubstring returns the offsets in a and b, and the length, of
+// the longest substring they share. Ties are broken by the earliest offset
+// in a.
+func longestCommonSubstring(a, b string) (i, j, n int) {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for x := 1; x <= len(a); x++ {
+		for y := 1; y <= len(b); y++ {
+			if a[x-1] == b[y-1] {
+				cur[y] = prev[y-1] + 1
+				if cur[y] > n {
+					i, j, n = x-cur[y], y-cur[y], cur[y]
+				}
+			} else {
+				cur[y] = 0
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return i, j, n
+}
+
+// An ImportReport describes the outcome of ImportRedirects.
+type ImportReport struct {
+	// Imported is the number of lines which became rules.
+	Imported int
+	// Skipped holds the lines which did not, other than blank lines and
+	// comments.
+	Skipped []SkippedLine
+}
+
+// A SkippedLine is a line ImportRedirects could not import.
+type SkippedLine struct {
+	// Line is the 1-based line number.
+	Line   int
+	Text   string
+	Reason string
+}
+
+// ImportRedirects reads a redirect dump in whatever "old new" text format
+// it was exported in, and returns a Map of its redirects along with a
+// report of the lines it skipped. It does its best with each line rather
+// than failing on the first it does not understand:
+//
+//   - blank lines and lines starting with '#', "//" or ';' are ignored;
+//   - the source and target may be separated by spaces, tabs, a comma, or
+//     an arrow ("->", "=>" or "→"), and quoted;
+//   - a status from 300 to 399, possibly forced with '!' as in Netlify's
+//     _redirects, may come before or after them, and Apache's Redirect,
+//     RedirectPermanent and RedirectTemp keywords are understood;
+//   - a source may be an absolute URL or start with '/'; its path, percent
+//     decoded and without leading or trailing '/', is used. Sources with
+//     query strings cannot be matched and are skipped.
+//
+// Each line becomes a literal rule, with the line's status, unless
+// opts.Compress generalizes them with Infer, separately for each status.
+// A source listed twice keeps its first target. Only a failure to read r
+// is returned as an error.
+func ImportRedirects(r io.Reader, opts ImportOptions, mapOpts ...Option) (*Map, ImportReport, error) {
+	var (
+		report ImportReport
+		// pairs holds the pairs read for each status, in order of first
+		// appearance of the status.
+		pairs    = make(map[int][]Pair)
+		statuses []int
+		seen     = make(map[string]int)
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSuffix(sc.Text(), "\r")
+		if n == 1 {
+			text = strings.TrimPrefix(text, utf8BOM)
+		}
+		l := strings.TrimSpace(text)
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") || strings.HasPrefix(l, ";") || n == 1 && opts.Header {
+			continue
+		}
+		skip := func(reason string) {
+			report.Skipped = append(report.Skipped, SkippedLine{Line: n, Text: text, Reason: reason})
+		}
+		from, to, status, err := parseRedirect(l)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+		if first, ok := seen[from]; ok {
+			skip(fmt.Sprintf("source %q is already redirected on line %d", from, first))
+			continue
+		}
+		if _, err := literal(from); err != nil {
+			skip(strings.TrimPrefix(err.Error(), "linkmap: "))
+			continue
+		}
+		if _, err := literal(to); err != nil {
+			skip(strings.TrimPrefix(err.Error(), "linkmap: "))
+			continue
+		}
+		seen[from] = n
+		if _, ok := pairs[status]; !ok {
+			statuses = append(statuses, status)
+		}
+		pairs[status] = append(pairs[status], Pair{Path: from, Link: to})
+		report.Imported++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, report, fmt.Errorf("linkmap: reading redirects: %w", err)
+	}
+	var rules []Rule
+	for _, status := range statuses {
+		group, err := literalRules(pairs[status])
+		if opts.Compress {
+			var m *Map
+			if m, err = Infer(pairs[status], mapOpts...); err == nil {
+				group = m.rules
+			}
+		}
+		if err != nil {
+			return nil, report, err
+		}
+		for _, r := range group {
+			r.Status = status
+			rules = append(rules, r)
+		}
+	}
+	return New(rules, mapOpts...), report, nil
+}
+
+// parseRedirect parses a line of a redirect dump into the path of its
+// source, its target and its status, or 0 if it has none.
+func parseRedirect(l string) (from, to string, status int, err error) {
+	if !utf8.ValidString(l) {
+		return "", "", 0, errors.New("invalid UTF-8")
+	}
+	var fields []string
+	switch {
+	case strings.Contains(l, "->") || strings.Contains(l, "=>") || strings.Contains(l, "→"):
+		l = strings.NewReplacer("->", " ", "=>", " ", "→", " ").Replace(l)
+		fields = strings.Fields(l)
+	case strings.Contains(l, "\t"):
+		fields = strings.Split(l, "\t")
+	case strings.Contains(l, ","):
+		cr := csv.NewReader(strings.NewReader(l))
+		cr.LazyQuotes, cr.TrimLeadingSpace = true, true
+		if fields, err = cr.Read(); err != nil {
+			return "", "", 0, err
+		}
+	default:
+		fields = strings.Fields(l)
+	}
+	var rest []string
+	for i, f := range fields {
+		f = strings.Trim(strings.TrimSpace(f), `"'`)
+		switch kw := strings.ToLower(f); {
+		case f == "":
+		case i == 0 && kw == "redirect":
+		case i == 0 && kw == "redirectpermanent":
+			status = 301
+		case i == 0 && kw == "redirecttemp":
+			status = 302
+		case redirectStatus(f) != 0 && (len(rest) == 0 || len(rest) == 2):
+			status = redirectStatus(f)
+		default:
+			rest = append(rest, f)
+		}
+	}
+	if len(rest) != 2 {
+		return "", "", 0, fmt.Errorf("expected a source and a target, found %d fields", len(rest))
+	}
+	from, to = rest[0], rest[1]
+	if strings.Contains(from, "://") {
+		u, err := url.Parse(from)
+		if err != nil {
+			return "", "", 0, err
+		}
+		if u.RawQuery != "" {
+			return "", "", 0, errors.New("sources with query strings cannot be matched")
+		}
+		from = u.EscapedPath()
+	}
+	if i := strings.IndexAny(from, "?#"); i >= 0 {
+		if from[i] == '?' {
+			return "", "", 0, errors.New("sources with query strings cannot be matched")
+		}
+		from = from[:i]
+	}
+	if p, err := url.PathUnescape(from); err == nil {
+		from = p
+	}
+	from = strings.Trim(from, "/")
+	if from == "" || !fs.ValidPath(from) {
+		return "", "", 0, fmt.Errorf("source %q is not a valid path", rest[0])
+	}
+	return from, to, status, nil
+}
+
+// redirectStatus returns the status f spells, such as "301" or "301!", or
+// 0 if it is not a redirect status.
+func redirectStatus(f string) int {
+	n, err := strconv.Atoi(strings.TrimSuffix(f, "!"))
+	if err != nil || n < 300 || n > 399 || len(f) > 4 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,144 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	const src = "path,url\n" +
+		"docs/intro.md,https://example.com/docs/intro\n" +
+		"docs/setup.md,https://example.com/docs/setup\n" +
+		"docs/faq.md,https://example.com/docs/faq\n" +
+		"/about.html,https://example.com/company\n"
+	cases := []struct {
+		compress bool
+		rules    int
+	}{
+		{compress: false, rules: 4},
+		{compress: true, rules: 2},
+	}
+	for _, c := range cases {
+		m, err := ImportCSV(strings.NewReader(src), ImportOptions{Header: true, Compress: c.compress})
+		if err != nil {
+			t.Fatalf("ImportCSV() error: %v", err)
+		}
+		if got := len(m.Rules()); got != c.rules {
+			t.Errorf("compress=%v: ImportCSV() has %d rules; want %d: %v", c.compress, got, c.rules, m.Rules())
+		}
+		for _, p := range []Pair{
+			{"docs/intro.md", "https://example.com/docs/intro"},
+			{"docs/faq.md", "https://example.com/docs/faq"},
+			{"about.html", "https://example.com/company"},
+		} {
+			if got, err := m.Evaluate(p.Path); err != nil || got != p.Link {
+				t.Errorf("compress=%v: Evaluate(%q) = %q, %v; want %q", c.compress, p.Path, got, err, p.Link)
+			}
+		}
+	}
+}
+
+func TestImportCSVCompressKeepsExceptions(t *testing.T) {
+	const src = "a/x.md,https://example.com/x\n" +
+		"a/y.md,https://example.com/y\n" +
+		"a/z.md,https://example.com/elsewhere\n"
+	m, err := ImportCSV(strings.NewReader(src), ImportOptions{Compress: true})
+	if err != nil {
+		t.Fatalf("ImportCSV() error: %v", err)
+	}
+	for _, p := range []Pair{
+		{"a/x.md", "https://example.com/x"},
+		{"a/y.md", "https://example.com/y"},
+		{"a/z.md", "https://example.com/elsewhere"},
+	} {
+		if got, err := m.Evaluate(p.Path); err != nil || got != p.Link {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", p.Path, got, err, p.Link)
+		}
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	for _, src := range []string{
+		"a.md,https://example.com/a\na.md,https://example.com/b\n",
+		"$1.md,https://example.com/a\n",
+		"a.md\n",
+		"../a.md,https://example.com/a\n",
+		"a//b.md,https://example.com/a\n",
+		"/,https://example.com/a\n",
+		"#a.md,https://example.com/a\n",
+		"re:a.md,https://example.com/a\n",
+		"a.md,tmpl:{{.x}}\n",
+	} {
+		if _, err := ImportCSV(strings.NewReader(src), ImportOptions{}); err == nil {
+			t.Errorf("ImportCSV(%q) succeeded; want error", src)
+		}
+	}
+}
+
+func TestImportRedirects(t *testing.T) {
+	const src = "\ufeff# exported 2019-04-01\r\n" +
+		"/old/intro.html /docs/intro 301\r\n" +
+		"https://www.example.com/old/setup.html\thttps://example.com/docs/setup\r\n" +
+		"Redirect 302 /tmp/a.html https://example.com/a\n" +
+		"RedirectPermanent /caf%C3%A9.html /cafe\n" +
+		"\"old/faq.html\",\"/docs/faq\"\n" +
+		"old/team.html -> /company 308!\n" +
+		"/search?q=a /search\n" +
+		"/old/intro.html /elsewhere\n" +
+		"/only-one-field\n" +
+		"/docs/$1 /x\n" +
+		"\n"
+	m, report, err := ImportRedirects(strings.NewReader(src), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path, link string
+		status     int
+	}{
+		{"old/intro.html", "/docs/intro", 301},
+		{"old/setup.html", "https://example.com/docs/setup", 0},
+		{"tmp/a.html", "https://example.com/a", 302},
+		{"café.html", "/cafe", 301},
+		{"old/faq.html", "/docs/faq", 0},
+		{"old/team.html", "/company", 308},
+	}
+	for _, tt := range tests {
+		res := m.Lookup(tt.path)
+		if res.Err != nil || res.Link != tt.link || res.Rule.Status != tt.status {
+			t.Errorf("Lookup(%q) = %q, %v; want %q with status %d", tt.path, res.Link, res.Err, tt.link, tt.status)
+		}
+	}
+	if report.Imported != len(tests) {
+		t.Errorf("Imported = %d; want %d", report.Imported, len(tests))
+	}
+	var lines []int
+	for _, s := range report.Skipped {
+		lines = append(lines, s.Line)
+	}
+	if want := []int{8, 9, 10, 11}; fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("skipped lines %v; want %v: %+v", lines, want, report.Skipped)
+	}
+	if s := report.Skipped[1]; s.Reason != `source "old/intro.html" is already redirected on line 2` {
+		t.Errorf("Skipped[1] = %+v", s)
+	}
+}
+
+func TestImportRedirectsCompress(t *testing.T) {
+	var b strings.Builder
+	for _, name := range []string{"intro", "setup", "faq", "install"} {
+		fmt.Fprintf(&b, "/old/%s.html /docs/%s 301\n", name, name)
+	}
+	b.WriteString("/old/team.html /company\n")
+	m, report, err := ImportRedirects(strings.NewReader(b.String()), ImportOptions{Compress: true})
+	if err != nil || report.Imported != 5 || len(report.Skipped) != 0 {
+		t.Fatalf("ImportRedirects() = %+v, %v", report, err)
+	}
+	if len(m.Rules()) != 2 {
+		t.Errorf("ImportRedirects() has rules %v; want 2", m.Rules())
+	}
+	if res := m.Lookup("old/other.html"); res.Link != "/docs/other" || res.Rule.Status != 301 {
+		t.Errorf("Lookup() = %q, %v", res.Link, res.Err)
+	}
+}
@@ -0,0 +1,323 @@
+package linkmap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An InferReport describes how Infer generalized a set of pairs.
+type InferReport struct {
+	// Rules are the generalized rules which were kept.
+	Rules []InferredRule
+	// Rejected are candidate rules which were discarded because they
+	// mapped some pairs incorrectly.
+	Rejected []InferredRule
+	// Literals is the number of pairs which could not be generalized and
+	// were kept as literal rules.
+	Literals int
+	// Coverage is the fraction of pairs covered by generalized rules.
+	Coverage float64
+}
+
+// An InferredRule is a candidate rule produced by Infer.
+type InferredRule struct {
+	Rule Rule
+	// Support is the number of pairs the rule maps correctly.
+	Support int
+	// Confidence is the fraction of the pairs matched by the rule's input
+	// which it maps correctly. Kept rules always have confidence 1.
+	Confidence float64
+}
+
+// Infer generalizes example mappings into templated rules. Components of a
+// path (the text between '/', '.' and other URL punctuation) which also
+// appear in its link become variables; components shared by every example
+// of a rule become literals again; and rules differing only in their file
+// extension are merged into an extension group. A pair sharing no whole
+// component with its link captures the longest substring they share
+// instead. A candidate rule is kept only if it maps every pair it matches
+// correctly and is supported by at least two pairs. Pairs not covered by a
+// generalized rule become literal rules, so the returned Map reproduces
+// every pair.
+func Infer(pairs []Pair, opts ...Option) (*Map, error) {
+	m, _, err := InferWithReport(pairs, opts...)
+	return m, err
+}
+
+// InferWithReport is like Infer but also reports on the rules it found.
+func InferWithReport(pairs []Pair, opts ...Option) (*Map, InferReport, error) {
+	rules, report, err := infer(pairs)
+	if err != nil {
+		return nil, InferReport{}, err
+	}
+	return New(rules, opts...), report, nil
+}
+
+func infer(pairs []Pair) ([]Rule, InferReport, error) {
+	literals, err := literalRules(pairs)
+	if err != nil {
+		return nil, InferReport{}, err
+	}
+	var report InferReport
+	cands := candidates(pairs)
+	covered := make([]bool, len(pairs))
+	var rules []Rule
+	for _, c := range cands {
+		ir, matched := verify(c, pairs)
+		if ir.Confidence < 1 || ir.Support < 2 {
+			if ir.Support >= 2 {
+				report.Rejected = append(report.Rejected, ir)
+			}
+			continue
+		}
+		report.Rules = append(report.Rules, ir)
+		rules = append(rules, c)
+		for _, i := range matched {
+			covered[i] = true
+		}
+	}
+	var n int
+	for i, r := range literals {
+		if covered[i] {
+			n++
+		} else {
+			rules = append(rules, r)
+			report.Literals++
+		}
+	}
+	if len(pairs) > 0 {
+		report.Coverage = float64(n) / float64(len(pairs))
+	}
+	return rules, report, nil
+}
+
+// candidates aligns the components of each pair, groups pairs with the same
+// shape regardless of file extension, and turns variables which are
+// constant within a group back into literals. Pairs which share no whole
+// component instead capture the longest substring they share.
+func candidates(pairs []Pair) []Rule {
+	type group struct {
+		in, out []segment
+		exts    []string
+		values  [][]string
+	}
+	var (
+		groups []*group
+		byKey  = make(map[string]*group)
+		// fallback holds the rules generalized from pairs which share no
+		// whole component, in order of first appearance.
+		fallback []Rule
+		seen     = make(map[string]bool)
+	)
+	for _, p := range pairs {
+		in, out, values, ok := align(p)
+		if !ok {
+			if r, ok := generalize(p); ok && !seen[r.String()] {
+				seen[r.String()] = true
+				fallback = append(fallback, r)
+			}
+			continue
+		}
+		in, ext := splitExtension(in)
+		key := makeTemplate(in).String() + " " + makeTemplate(out).String()
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{in: in, out: out}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		if ext != "" && !contains(g.exts, ext) {
+			g.exts = append(g.exts, ext)
+		}
+		g.values = append(g.values, values)
+	}
+	var rules []Rule
+	for _, g := range groups {
+		consts := make(map[string]string)
+		for v := range g.values[0] {
+			val := g.values[0][v]
+			constant := true
+			for _, vals := range g.values[1:] {
+				if vals[v] != val {
+					constant = false
+					break
+				}
+			}
+			if constant {
+				consts[strconv.Itoa(v+1)] = val
+			}
+		}
+		if len(consts) == len(g.values[0]) && len(g.exts) < 2 {
+			// Nothing varies: this is a literal rule.
+			continue
+		}
+		in, renames := inline(g.in, consts, nil)
+		out, _ := inline(g.out, consts, renames)
+		switch len(g.exts) {
+		case 0:
+		case 1:
+			in[len(in)-1].val += g.exts[0]
+		default:
+			sort.Strings(g.exts)
+			in = append(in, segment{typ: segmentTypeExtension, val: "{" + strings.Join(g.exts, ",") + "}"})
+		}
+		rules = append(rules, Rule{Input: Template{segs: makeTemplate(in)}, Output: Template{segs: makeTemplate(out)}})
+	}
+	return append(rules, fallback...)
+}
+
+// splitExtension removes a trailing file extension from a template whose
+// last segment is a literal, returning the extension without its dot.
+func splitExtension(tmpl []segment) ([]segment, string) {
+	last := tmpl[len(tmpl)-1]
+	dot := strings.LastIndexByte(last.val, '.')
+	if last.typ != segmentTypeString || dot < 0 || dot == len(last.val)-1 || strings.Contains(last.val[dot:], "/") {
+		return tmpl, ""
+	}
+	base := append([]segment(nil), tmpl[:len(tmpl)-1]...)
+	base = append(base, segment{typ: segmentTypeString, val: last.val[:dot+1]})
+	return base, last.val[dot+1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// align splits a pair into components and replaces each path component
+// which also appears in the link with a variable. It returns the resulting
+// templates and the variables' values.
+func align(p Pair) (in, out []segment, values []string, ok bool) {
+	pathComps := components(p.Path)
+	linkComps := components(p.Link)
+	used := make([]bool, len(linkComps))
+	linkVar := make([]string, len(linkComps))
+	var pathVar []string
+	for _, pc := range pathComps {
+		name := ""
+		for j, lc := range linkComps {
+			if !used[j] && lc.text == pc.text {
+				used[j] = true
+				values = append(values, pc.text)
+				name = strconv.Itoa(len(values))
+				linkVar[j] = name
+				break
+			}
+		}
+		pathVar = append(pathVar, name)
+	}
+	if len(values) == 0 {
+		return nil, nil, nil, false
+	}
+	return substitute(p.Path, pathComps, pathVar), substitute(p.Link, linkComps, linkVar), values, true
+}
+
+type component struct {
+	text  string
+	start int
+}
+
+// components returns the maximal runs of s which contain no separators.
+func components(s string) []component {
+	var (
+		comps []component
+		start = -1
+	)
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || strings.IndexByte("/.:?=&#", s[i]) >= 0 {
+			if start >= 0 {
+				comps = append(comps, component{text: s[start:i], start: start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return comps
+}
+
+// substitute builds a template from s, replacing each component with a
+// non-empty name with a variable of that name.
+func substitute(s string, comps []component, names []string) []segment {
+	var (
+		t   []segment
+		lit strings.Builder
+		off int
+	)
+	for i, c := range comps {
+		if names[i] == "" {
+			continue
+		}
+		lit.WriteString(s[off:c.start])
+		if lit.Len() > 0 {
+			t = append(t, segment{typ: segmentTypeString, val: lit.String()})
+			lit.Reset()
+		}
+		t = append(t, segment{typ: segmentTypeVariable, val: "$" + names[i]})
+		off = c.start + len(c.text)
+	}
+	if off < len(s) {
+		t = append(t, segment{typ: segmentTypeString, val: s[off:]})
+	}
+	return t
+}
+
+// inline replaces the variables named in consts with their values, merges
+// adjacent literals, and renumbers the remaining variables from 1. If
+// renames is nil, a renaming is computed from the order variables appear
+// in tmpl and returned.
+func inline(tmpl []segment, consts map[string]string, renames map[string]string) ([]segment, map[string]string) {
+	if renames == nil {
+		renames = make(map[string]string)
+		for _, name := range makeTemplate(tmpl).variables() {
+			if _, ok := consts[name]; !ok {
+				renames[name] = strconv.Itoa(len(renames) + 1)
+			}
+		}
+	}
+	var t []segment
+	for _, s := range tmpl {
+		if s.typ == segmentTypeVariable {
+			if val, ok := consts[s.name()]; ok {
+				s = segment{typ: segmentTypeString, val: val}
+			} else {
+				s = segment{typ: segmentTypeVariable, val: "$" + renames[s.name()]}
+			}
+		}
+		if n := len(t); n > 0 && s.typ == segmentTypeString && t[n-1].typ == segmentTypeString {
+			t[n-1].val += s.val
+			continue
+		}
+		t = append(t, s)
+	}
+	return t, renames
+}
+
+// verify measures how well r reproduces pairs, returning the indexes of the
+// pairs it maps correctly.
+func verify(r Rule, pairs []Pair) (InferredRule, []int) {
+	var (
+		matched int
+		correct []int
+	)
+	for i, p := range pairs {
+		vars, ok := r.Input.segs.match(p.Path)
+		if !ok {
+			continue
+		}
+		matched++
+		if link, err := r.Output.segs.apply(vars); err == nil && link == p.Link {
+			correct = append(correct, i)
+		}
+	}
+	ir := InferredRule{Rule: r, Support: len(correct)}
+	if matched > 0 {
+		ir.Confidence = float64(len(correct)) / float64(matched)
+	}
+	return ir, correct
+}
@@ -0,0 +1,83 @@
+package linkmap
+
+import "testing"
+
+func TestInfer(t *testing.T) {
+	pairs := []Pair{
+		{"blog/2021/hello.md", "https://example.com/posts/2021/hello"},
+		{"blog/2021/world.mdx", "https://example.com/posts/2021/world"},
+		{"blog/2022/again.md", "https://example.com/posts/2022/again"},
+		{"docs/intro.md", "https://docs.example.com/intro"},
+		{"docs/setup.md", "https://docs.example.com/setup"},
+		{"LICENSE", "https://example.com/license"},
+	}
+	m, report, err := InferWithReport(pairs)
+	if err != nil {
+		t.Fatalf("InferWithReport() error: %v", err)
+	}
+	want := map[string]bool{
+		"blog/$1/$2.{md,mdx} https://example.com/posts/$1/$2": true,
+		"docs/$1.md https://docs.example.com/$1":              true,
+		"LICENSE https://example.com/license":                 true,
+	}
+	rules := m.Rules()
+	if len(rules) != len(want) {
+		t.Errorf("Infer() rules = %v; want %d rules", rules, len(want))
+	}
+	for _, r := range rules {
+		if !want[r.String()] {
+			t.Errorf("Infer() produced unexpected rule %q", r)
+		}
+	}
+	for _, p := range pairs {
+		if got, err := m.Evaluate(p.Path); err != nil || got != p.Link {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", p.Path, got, err, p.Link)
+		}
+	}
+	if report.Literals != 1 || len(report.Rules) != 2 {
+		t.Errorf("report = %+v; want 2 rules and 1 literal", report)
+	}
+	if report.Coverage < 0.83 || report.Coverage > 0.84 {
+		t.Errorf("report.Coverage = %v; want 5/6", report.Coverage)
+	}
+}
+
+func TestInferSubstring(t *testing.T) {
+	pairs := []Pair{
+		{"products/item-123", "https://shop.example.com/p/123"},
+		{"products/item-456", "https://shop.example.com/p/456"},
+		{"about", "https://example.com/company"},
+	}
+	m, report, err := InferWithReport(pairs)
+	if err != nil {
+		t.Fatalf("InferWithReport() error: %v", err)
+	}
+	if len(report.Rules) != 1 || report.Rules[0].Rule.String() != "products/item-$1 https://shop.example.com/p/$1" {
+		t.Errorf("report.Rules = %+v; want products/item-$1", report.Rules)
+	}
+	for _, p := range pairs {
+		if got, err := m.Evaluate(p.Path); err != nil || got != p.Link {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", p.Path, got, err, p.Link)
+		}
+	}
+}
+
+func TestInferRejectsInconsistent(t *testing.T) {
+	pairs := []Pair{
+		{"a/x.md", "https://example.com/x"},
+		{"a/y.md", "https://example.com/y"},
+		{"a/z.md", "https://example.com/elsewhere"},
+	}
+	m, report, err := InferWithReport(pairs)
+	if err != nil {
+		t.Fatalf("InferWithReport() error: %v", err)
+	}
+	if len(report.Rejected) != 1 || report.Rejected[0].Support != 2 {
+		t.Errorf("report.Rejected = %+v; want one candidate with support 2", report.Rejected)
+	}
+	for _, p := range pairs {
+		if got, err := m.Evaluate(p.Path); err != nil || got != p.Link {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", p.Path, got, err, p.Link)
+		}
+	}
+}
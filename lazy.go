@@ -0,0 +1,189 @@
+package linkmap
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A LazyMap evaluates paths against a compiled map without decoding it
+// all up front: rules are decoded by bucket, keyed by the literal first
+// path element of their inputs, when a path first needs them. A service
+// with a huge map can thus answer its first request after reading only
+// the header and the buckets that request touches. A LazyMap is safe for
+// concurrent use.
+type LazyMap struct {
+	r    io.ReaderAt
+	opts []Option
+	// cfg holds the options, for preparing paths as the map's rules see
+	// them.
+	cfg     *Map
+	header  *binaryHeader
+	buckets map[string]binaryBucket
+
+	mu sync.Mutex
+	// rules holds the decoded rules of each bucket, and maps the Map
+	// evaluating the paths of each bucket.
+	rules map[string]*lazyEntry
+	maps  map[string]*lazyEntry
+}
+
+// A lazyEntry is a value loaded on first use.
+type lazyEntry struct {
+	once  sync.Once
+	rules []indexedRule
+	m     *Map
+	err   error
+}
+
+// An indexedRule is a rule with its index in evaluation order.
+type indexedRule struct {
+	i int
+	r Rule
+}
+
+// LoadBinary reads the header of a compiled map of the given size from r,
+// such as an *os.File, leaving its rules to be read as paths need them.
+// Options are as for ParseBinary. Errors reading or decoding rules are
+// reported in the Result of the Lookup which needed them.
+func LoadBinary(r io.ReaderAt, size int64, opts ...Option) (*LazyMap, error) {
+	h, err := readBinaryHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	l := &LazyMap{
+		r:       r,
+		opts:    opts,
+		cfg:     newMap(opts),
+		header:  h,
+		buckets: make(map[string]binaryBucket, len(h.buckets)),
+		rules:   make(map[string]*lazyEntry),
+		maps:    make(map[string]*lazyEntry),
+	}
+	for _, b := range h.buckets {
+		l.buckets[b.key] = b
+	}
+	return l, nil
+}
+
+// Lookup evaluates fpath as Map.Lookup does.
+func (l *LazyMap) Lookup(fpath string) Result {
+	key := ""
+	if l.cfg.urlInputs && isURL(fpath) {
+		// URL inputs are bucketed by their scheme, as in "https:", and
+		// those starting with "//" with the rules of the "" bucket.
+		if u, err := parseURLInput(fpath); err == nil {
+			key = u.scheme
+		}
+	} else if p, err := l.cfg.inputPath(fpath); err == nil {
+		if p, _, err = l.cfg.rebase(p, nil); err == nil {
+			key = pathKey(p)
+		}
+	}
+	if _, ok := l.buckets[key]; !ok {
+		key = ""
+	}
+	m, err := l.bucketMap(key)
+	if err != nil {
+		return Result{Path: fpath, Err: err}
+	}
+	return m.Lookup(fpath)
+}
+
+// Evaluate evaluates fpath as Map.Evaluate does.
+func (l *LazyMap) Evaluate(fpath string) (string, error) {
+	res := l.Lookup(fpath)
+	return res.Link, res.Err
+}
+
+// Map decodes every rule, returning the map as ParseBinary would.
+func (l *LazyMap) Map() (*Map, error) {
+	rules := make([]Rule, l.header.rules)
+	seen := make([]bool, l.header.rules)
+	for key := range l.buckets {
+		rs, err := l.bucketRules(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, ir := range rs {
+			if ir.i >= len(rules) || seen[ir.i] {
+				return nil, fmt.Errorf("%w: bad rule index", ErrBinaryFormat)
+			}
+			rules[ir.i], seen[ir.i] = ir.r, true
+		}
+	}
+	for i := range seen {
+		if !seen[i] {
+			return nil, fmt.Errorf("%w: missing rule %d", ErrBinaryFormat, i)
+		}
+	}
+	m := newMap(l.opts)
+	m.setCompiledRules(rules)
+	return m, nil
+}
+
+// entry returns the entry for key in entries, creating it if needed.
+func (l *LazyMap) entry(entries map[string]*lazyEntry, key string) *lazyEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := entries[key]
+	if e == nil {
+		e = &lazyEntry{}
+		entries[key] = e
+	}
+	return e
+}
+
+// bucketRules returns the rules of the bucket with the given key, in
+// evaluation order, reading them on first use.
+func (l *LazyMap) bucketRules(key string) ([]indexedRule, error) {
+	b, ok := l.buckets[key]
+	if !ok {
+		return nil, nil
+	}
+	e := l.entry(l.rules, key)
+	e.once.Do(func() {
+		body := make([]byte, b.length)
+		if _, err := l.r.ReadAt(body, l.header.bodies+int64(b.offset)); err != nil {
+			e.err = fmt.Errorf("linkmap: reading compiled map: %w", err)
+			return
+		}
+		e.err = l.cfg.decodeBucket(b, body, func(i int, r Rule) error {
+			e.rules = append(e.rules, indexedRule{i, r})
+			return nil
+		})
+	})
+	return e.rules, e.err
+}
+
+// bucketMap returns a Map holding the rules of the bucket with the given
+// key merged with those of the "" bucket, which any path may match, in
+// evaluation order.
+func (l *LazyMap) bucketMap(key string) (*Map, error) {
+	e := l.entry(l.maps, key)
+	e.once.Do(func() {
+		other, err := l.bucketRules("")
+		if err != nil {
+			e.err = err
+			return
+		}
+		var own []indexedRule
+		if key != "" {
+			if own, err = l.bucketRules(key); err != nil {
+				e.err = err
+				return
+			}
+		}
+		rules := make([]Rule, 0, len(own)+len(other))
+		for len(own) > 0 || len(other) > 0 {
+			if len(other) == 0 || len(own) > 0 && own[0].i < other[0].i {
+				rules, own = append(rules, own[0].r), own[1:]
+			} else {
+				rules, other = append(rules, other[0].r), other[1:]
+			}
+		}
+		e.m = newMap(l.opts)
+		e.m.setCompiledRules(rules)
+	})
+	return e.m, e.err
+}
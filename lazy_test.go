@@ -0,0 +1,100 @@
+package linkmap
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// countingReader records the bytes read from it.
+type countingReader struct {
+	*bytes.Reader
+	n int
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.n += len(p)
+	return r.Reader.ReadAt(p, off)
+}
+
+func TestLazyMap(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("$1/index.md https://example.com/$1\n")
+	for _, dir := range []string{"docs", "blog", "api", "guides"} {
+		for i := 0; i < 50; i++ {
+			src.WriteString(dir + "/" + strings.Repeat("x", i) + "$1.md https://example.com/" + dir + "/$1\n")
+		}
+	}
+	src.WriteString("$1.md https://example.com/other/$1\n")
+	m, err := Parse(strings.NewReader(src.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &countingReader{Reader: bytes.NewReader(data)}
+	l, err := LoadBinary(r, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"docs/a.md", "docs/index.md", "guides/xxxb.md", "other/c.md", "d.md", "docs/", "docs/a.txt"} {
+		want, wantErr := m.Evaluate(p)
+		if link, err := l.Evaluate(p); link != want || (err == nil) != (wantErr == nil) {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, %v", p, link, err, want, wantErr)
+		}
+	}
+	if r.n >= len(data)*3/4 {
+		t.Errorf("read %d of %d bytes; want only the buckets used", r.n, len(data))
+	}
+	all, err := l.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Hash() != m.Hash() {
+		t.Error("Map() changed the rules")
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-2] ^= 0xff
+	l, err = LoadBinary(bytes.NewReader(corrupt), int64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Map(); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("Map() of a corrupt bucket = %v; want ErrBinaryFormat", err)
+	}
+	if _, err := LoadBinary(bytes.NewReader(data[:10]), 10); !errors.Is(err, ErrBinaryFormat) {
+		t.Errorf("LoadBinary(truncated) = %v; want ErrBinaryFormat", err)
+	}
+}
+
+func TestLazyMapURLInputs(t *testing.T) {
+	const src = `https://old.example.com/docs/$1 https://docs.example.com/$1
+//any.example.com/$1 https://example.com/any/$1
+docs/$1.md https://example.com/$1
+`
+	m, err := Parse(strings.NewReader(src), WithURLInputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadBinary(bytes.NewReader(data), int64(len(data)), WithURLInputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"https://old.example.com/docs/a", "HTTPS://Old.Example.com:443/docs/a", "http://any.example.com/b", "http://old.example.com/docs/a", "docs/c.md"} {
+		want, wantErr := m.Evaluate(p)
+		if link, err := l.Evaluate(p); link != want || (err == nil) != (wantErr == nil) {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, %v", p, link, err, want, wantErr)
+		}
+	}
+	if link, _ := l.Evaluate("https://old.example.com/docs/a"); link != "https://docs.example.com/a" {
+		t.Errorf("Evaluate() = %q", link)
+	}
+}
@@ -0,0 +1,122 @@
+package linkmap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Limits bounds the size of linkmaps accepted by Parse, so that programs
+// parsing untrusted input cannot be made to use unbounded time or memory.
+// Zero fields are unlimited.
+type Limits struct {
+	// MaxBytes is the maximum size of the source.
+	MaxBytes int
+	// MaxLineLength is the maximum length of a line, in bytes.
+	MaxLineLength int
+	// MaxRules is the maximum number of rules.
+	MaxRules int
+	// MaxSegments is the maximum number of segments in a template.
+	MaxSegments int
+	// MaxExtensions is the maximum number of alternatives in an extension
+	// group.
+	MaxExtensions int
+}
+
+// DefaultLimits are generous limits suitable for parsing untrusted input.
+var DefaultLimits = Limits{
+	MaxBytes:      16 << 20,
+	MaxLineLength: 4096,
+	MaxRules:      100000,
+	MaxSegments:   64,
+	MaxExtensions: 32,
+}
+
+// WithLimits bounds the size of linkmaps accepted by Parse and ParseFile.
+func WithLimits(l Limits) Option {
+	return func(m *Map) {
+		m.limits = l
+	}
+}
+
+// A LimitError is returned when a linkmap exceeds one of its Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded field of Limits.
+	Limit string
+	// Max is the configured limit.
+	Max int
+	// Line is the 1-based line on which the limit was exceeded, or 0 if it
+	// applies to the whole source.
+	Line int
+}
+
+func (e *LimitError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("linkmap: exceeded %s of %d", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("linkmap: line %d: exceeded %s of %d", e.Line, e.Limit, e.Max)
+}
+
+// readSource reads the source, enforcing MaxBytes.
+func (l Limits) readSource(r io.Reader) ([]byte, error) {
+	if l.MaxBytes > 0 {
+		r = io.LimitReader(r, int64(l.MaxBytes)+1)
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %v", err)
+	}
+	if l.MaxBytes > 0 && len(buf) > l.MaxBytes {
+		return nil, &LimitError{Limit: "MaxBytes", Max: l.MaxBytes}
+	}
+	return buf, nil
+}
+
+// checkLine enforces MaxLineLength.
+func (l Limits) checkLine(n int, line string) error {
+	if l.MaxLineLength > 0 && len(line) > l.MaxLineLength {
+		return &LimitError{Limit: "MaxLineLength", Max: l.MaxLineLength, Line: n}
+	}
+	return nil
+}
+
+// checkRule enforces MaxRules, MaxSegments and MaxExtensions for the
+// rules'th rule.
+func (l Limits) checkRule(rules int, r Rule) error {
+	if l.MaxRules > 0 && rules > l.MaxRules {
+		return &LimitError{Limit: "MaxRules", Max: l.MaxRules, Line: r.Line}
+	}
+	check := func(t template) error {
+		if l.MaxSegments > 0 && t.len() > l.MaxSegments {
+			return &LimitError{Limit: "MaxSegments", Max: l.MaxSegments, Line: r.Line}
+		}
+		if l.MaxExtensions <= 0 {
+			return nil
+		}
+		for i := 0; i < t.len(); i++ {
+			if s := t.at(i); s.typ == segmentTypeExtension && strings.Count(s.val, ",")+1 > l.MaxExtensions {
+				return &LimitError{Limit: "MaxExtensions", Max: l.MaxExtensions, Line: r.Line}
+			}
+		}
+		return nil
+	}
+	// The templates are checked one by one rather than gathered with
+	// r.outputs, which would allocate for every rule of a large map.
+	if err := check(r.Input.segs); err != nil {
+		return err
+	}
+	if err := check(r.Output.segs); err != nil {
+		return err
+	}
+	for _, w := range r.Weighted {
+		if err := check(w.Output.segs); err != nil {
+			return err
+		}
+	}
+	for _, s := range r.Scheduled {
+		if err := check(s.Output.segs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		src    string
+		limits Limits
+		limit  string
+	}{
+		{src: "a/$1 b/$1\n", limits: Limits{MaxBytes: 5}, limit: "MaxBytes"},
+		{src: "# a long comment\n", limits: Limits{MaxLineLength: 8}, limit: "MaxLineLength"},
+		{src: "a b\nc d\ne f\n", limits: Limits{MaxRules: 2}, limit: "MaxRules"},
+		{src: "a/$1/$2 b/$1\n", limits: Limits{MaxSegments: 3}, limit: "MaxSegments"},
+		{src: "a/$1.{md,mdx,txt} b/$1\n", limits: Limits{MaxExtensions: 2}, limit: "MaxExtensions"},
+		{src: "a/$1.{md,mdx} b/$1\nc d\n", limits: DefaultLimits},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.src), WithLimits(c.limits))
+		var le *LimitError
+		if c.limit == "" {
+			if err != nil {
+				t.Errorf("Parse(%q) error: %v", c.src, err)
+			}
+			continue
+		}
+		if !errors.As(err, &le) || le.Limit != c.limit {
+			t.Errorf("Parse(%q) error = %v; want %s LimitError", c.src, err, c.limit)
+		}
+	}
+}
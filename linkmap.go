@@ -8,44 +8,287 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 )
 
 // A Map is a set of rules which map files to links.
 type Map struct {
-	rules []tuple[template, template]
+	rules     []Rule
+	traversal TraversalPolicy
+	counting  bool
+	// counters[i] counts matches of rules[i]; nil unless counting.
+	counters []uint64
+	kinds    map[string]SegmentMatcher
+	resolver Resolver
+	aliases  [][2]string
+	fileVars map[string]FileVar
+	ignore   *Ignore
+	// base is the directory paths are relative to; see WithBase.
+	base string
+	// source is recorded in parsed rules; see WithSource.
+	source string
+	// fallsThrough is set by WithFallthrough. next, if set, is the map
+	// paths which match no rule fall through to.
+	fallsThrough bool
+	next         *Map
+	// textOutputs parses every output as a text/template.
+	textOutputs bool
+	// tmplOutputs accepts text/template outputs.
+	tmplOutputs bool
+	limits      Limits
+	maxRules    int
+	timeout     time.Duration
+	// allow, if set, restricts generated links; see WithAllowlist.
+	allow     *Allowlist
+	canonical Canonicalization
+	// prefilter is built from the rules if prefiltering is set; see
+	// WithPrefilter.
+	prefiltering bool
+	prefilter    *prefilter
+	// arena, while parsing, holds the segments of templates.
+	arena      *segmentArena
+	validators map[string][]func(string) error
+	// contextVars are the variables declared with WithContextVars.
+	contextVars map[string]bool
+	// urlInputs is set by WithURLInputs.
+	urlInputs bool
+	// prefixMatching is set by WithPrefixMatching.
+	prefixMatching bool
+	// clock is set by WithClock.
+	clock func() time.Time
 }
 
-// Parse parses a linkmap and returns a Map object.
-func Parse(reader io.Reader) (*Map, error) {
-	buf, err := io.ReadAll(reader)
+// A Rule maps files matching Input to links built from Output.
+type Rule struct {
+	Input  Template `json:"input"`
+	Output Template `json:"output"`
+	// Line is the 1-based line the rule was parsed from, or 0 if the rule
+	// was not parsed from source.
+	Line int `json:"line,omitempty"`
+	// Source names where the rule came from, such as the file it was
+	// parsed from (see WithSource), or is empty if unknown.
+	Source string `json:"source,omitempty"`
+	// Status is the HTTP status code to redirect with, or 0 for the
+	// default. CacheControl is the Cache-Control header of the redirect,
+	// or empty for the server's default. Description documents the rule.
+	// Conditions further restrict the paths the rule matches. Weighted
+	// lists outputs used instead of Output for a percentage of
+	// evaluations each, chosen by EvalContext.Seed or at random; Output
+	// takes the rest. Scheduled lists outputs which replace Output and
+	// Weighted from their cutover time on, by the clock of WithClock.
+	// These can only be set in the TOML format and by programs; the
+	// linkmap text format does not preserve them.
+	Status       int               `json:"status,omitempty"`
+	CacheControl string            `json:"cache_control,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Conditions   []Condition       `json:"conditions,omitempty"`
+	Weighted     []WeightedOutput  `json:"weighted,omitempty"`
+	Scheduled    []ScheduledOutput `json:"scheduled,omitempty"`
+}
+
+// A Condition restricts a rule to paths for which a variable's value
+// matches a pattern.
+type Condition struct {
+	// Var is the name of a variable bound by the input template, or of a
+	// path variable such as mime, without the leading '$'.
+	Var string `json:"var"`
+	// Pattern is matched against the value with path.Match.
+	Pattern string `json:"pattern"`
+}
+
+// String returns the rule in linkmap syntax.
+func (r Rule) String() string {
+	return r.Input.String() + " " + r.Output.String()
+}
+
+// Origin returns where the rule came from, as "source:line", "line N" or
+// the source alone, or the empty string if both are unknown.
+func (r Rule) Origin() string {
+	switch {
+	case r.Source != "" && r.Line > 0:
+		return fmt.Sprintf("%s:%d", r.Source, r.Line)
+	case r.Line > 0:
+		return fmt.Sprintf("line %d", r.Line)
+	}
+	return r.Source
+}
+
+// lineRange returns the range of the rule's line, or the zero Range if it
+// is unknown.
+func (r Rule) lineRange() Range {
+	if r.Line == 0 {
+		return Range{}
+	}
+	return Range{Start: Position{r.Line - 1, 0}, End: Position{r.Line, 0}}
+}
+
+// originError is an error attributed to the rule at origin.
+type originError struct {
+	origin string
+	err    error
+}
+
+// errorAt attributes err to the origin of r, if known.
+func errorAt(r Rule, err error) error {
+	if r.Origin() == "" {
+		return err
+	}
+	return &originError{origin: r.Origin(), err: err}
+}
+
+func (e *originError) Error() string {
+	return "linkmap: " + e.origin + ": " + strings.TrimPrefix(e.err.Error(), "linkmap: ")
+}
+
+func (e *originError) Unwrap() error {
+	return e.err
+}
+
+// sourceError attributes err, from parsing the named source, to the source
+// unless it already names the rule at fault.
+func sourceError(name string, err error) error {
+	var oe *originError
+	if errors.As(err, &oe) {
+		return err
+	}
+	return fmt.Errorf("linkmap: parsing %s: %w", name, err)
+}
+
+// Parse parses a linkmap and returns a Map object. Empty lines and lines
+// beginning with '#' are ignored. Parsing is deterministic: the same bytes
+// and options yield maps whose rules are in the same order. Parse is safe
+// for concurrent use.
+func Parse(reader io.Reader, opts ...Option) (*Map, error) {
+	f, err := ParseFile(reader, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("io.ReadAll: %v", err)
+		return nil, err
 	}
-	lines := strings.Split(string(buf), "\n")
-	var mappings []tuple[template, template]
-	for _, l := range lines {
-		if l == "" {
-			continue
-		}
-		sub := strings.Split(l, " ")
-		if len(sub) != 2 {
-			return nil, fmt.Errorf("linkmap: invalid line %q", l)
-		}
-		in, err := parseTemplate(sub[0])
+	return f.Map(), nil
+}
+
+// parseLine parses a single rule line.
+func (m *Map) parseLine(l string) (Rule, error) {
+	input, output, ok := strings.Cut(l, " ")
+	// A text/template output extends to the end of the line.
+	if !ok || strings.Contains(output, " ") && !m.textOutputs && !strings.HasPrefix(output, textPrefix) {
+		return Rule{}, fmt.Errorf("linkmap: invalid line %q", l)
+	}
+	in, err := m.parseInput(input)
+	if err != nil {
+		return Rule{}, fmt.Errorf("linkmap: failed to parse template %q: %w", input, err)
+	}
+	out, err := m.parseOutput(output)
+	if err != nil {
+		return Rule{}, fmt.Errorf("linkmap: failed to parse template %q: %w", output, err)
+	}
+	return Rule{Input: in, Output: out}, nil
+}
+
+// parseInput parses an input template, which may be a regular expression.
+func (m *Map) parseInput(s string) (Template, error) {
+	var t Template
+	if strings.HasPrefix(s, rePrefix) {
+		re, err := parseRegexpInput(strings.TrimPrefix(s, rePrefix))
 		if err != nil {
-			return nil, fmt.Errorf("linkmap: failed to parse template %q: %w", sub[0], err)
+			return Template{}, err
 		}
-		out, err := parseTemplate(sub[1])
+		t.re = re
+	} else {
+		s, t.prefix = m.cutRestMarker(s)
+		segs, err := parseTemplateIn(s, m.kinds, m.arena)
 		if err != nil {
-			return nil, fmt.Errorf("linkmap: failed to parse template %q: %w", sub[1], err)
+			return Template{}, err
+		}
+		if segs.hasDest() {
+			return Template{}, errInputDest
+		}
+		if segs.hasCond() {
+			return Template{}, errInputCond
+		}
+		for i := 0; i < segs.len(); i++ {
+			seg := segs.at(i)
+			if len(seg.filters) > 0 {
+				return Template{}, errInputFilter
+			}
+			if seg.typ == segmentTypeVariable && seg.val == splat && i != segs.len()-1 {
+				return Template{}, errSplatLast
+			}
 		}
-		mappings = append(mappings, tuple[template, template]{first: in, second: out})
+		t.segs = segs
 	}
-	// Important to sort by complexity, i.e. longer first.
-	sort.Slice(mappings, func(i, j int) bool {
-		return len(mappings[i].first) > len(mappings[j].first)
+	if err := m.checkAliases(t); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// New returns a Map containing the given rules.
+func New(rules []Rule, opts ...Option) *Map {
+	m := newMap(opts)
+	m.setRules(rules)
+	return m
+}
+
+// Merge returns a Map containing the rules of maps, configured by opts,
+// which should match the options the maps were parsed with. Rules keep
+// their Source, and those without one take the source of their map (see
+// WithSource), so that a merged map can still say where each rule came
+// from. Rules are evaluated by complexity, as in any Map.
+func Merge(maps []*Map, opts ...Option) *Map {
+	var rules []Rule
+	for _, m := range maps {
+		for _, r := range m.rules {
+			if r.Source == "" {
+				r.Source = m.source
+			}
+			rules = append(rules, r)
+		}
+	}
+	return New(rules, opts...)
+}
+
+// newMap returns an empty Map configured by opts.
+func newMap(opts []Option) *Map {
+	m := &Map{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Map) setRules(rules []Rule) {
+	rules = append([]Rule(nil), rules...)
+	// Important to sort by complexity, i.e. longer first. The sort is
+	// stable, so rules of equal complexity keep their order and the same
+	// rules always evaluate, serialize and hash alike, whatever the
+	// platform or Go version.
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Input.segs.len() > rules[j].Input.segs.len()
 	})
-	return &Map{rules: mappings}, nil
+	m.rules = rules
+	if m.counting {
+		m.counters = make([]uint64, len(rules))
+	}
+	if m.prefiltering {
+		m.prefilter = newPrefilter(rules)
+	}
+}
+
+// WriteTo writes the map's rules in linkmap syntax, one per line, in
+// evaluation order.
+func (m *Map) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, r := range m.rules {
+		b.WriteString(r.String())
+		b.WriteByte('\n')
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Rules returns the map's rules in evaluation order.
+func (m *Map) Rules() []Rule {
+	return append([]Rule(nil), m.rules...)
 }
 
 // ErrNoMatches is returned when no matches were found.
@@ -53,188 +296,544 @@ var ErrNoMatches = errors.New("linkmap: no matches found")
 
 // Evaluate evaluates a file path against the map and returns the link.
 // If no link was found, an empty string and ErrNoMatches is returned.
+// Paths must be slash-separated, relative, and clean (see NormalizePath);
+// otherwise an error wrapping ErrInvalidPath is returned.
 func (m *Map) Evaluate(fpath string) (string, error) {
-	for _, r := range m.rules {
-		variables, didMatch := r.first.match(fpath)
-		if !didMatch {
-			continue
-		}
-		link, err := r.second.apply(variables)
-		if err != nil {
-			return "", fmt.Errorf("failed to apply template: %w", err)
-		}
-		return link, nil
-	}
-	return "", ErrNoMatches
+	res := m.Lookup(fpath)
+	return res.Link, res.Err
 }
 
-type tuple[T, E any] struct {
-	first  T
-	second E
+// EvaluateVars is like Evaluate but also returns the variables captured
+// from fpath, keyed by name without the leading '$'.
+func (m *Map) EvaluateVars(fpath string) (link string, vars map[string]string, err error) {
+	res := m.Lookup(fpath)
+	return res.Link, res.Vars, res.Err
 }
 
-type segmentType int
+type segmentType uint8
 
 const (
 	segmentTypeString segmentType = iota
 	segmentTypeVariable
 	segmentTypeExtension
+	segmentTypeCustom
+	segmentTypeDest
+	segmentTypeCond
 )
 
+// A segment is a view of one segment of a template, as returned by
+// template.at. Templates do not store segments in this form; see template.
 type segment struct {
 	typ segmentType
 	val string
+	// matcher matches custom segments.
+	matcher SegmentMatcher
+	// filters transform the values of variables in outputs; val includes
+	// their source.
+	filters []filter
+	// cond is the conditional of a conditional segment; val is its source.
+	cond *conditional
+}
+
+// A template is a sequence of segments stored as tables rather than as
+// segment structs: refs holds the kind of each segment and the offsets of
+// its value in the backing string src, and ext holds what little else
+// some segments need. The tables of a parsed map are allocated from a
+// segmentArena and the values are substrings of the map's source, so the
+// templates of a large map are a few pointer-free chunks the garbage
+// collector need not scan, rather than a struct and string per segment.
+type template struct {
+	// src is the backing store of the segments' values: the template's
+	// source when parsed, or the whole source of a compiled map.
+	src  string
+	refs []segRef
+	// ext holds the matchers, filters and conditionals of the segments,
+	// in parallel with refs, or is nil if no segment has any.
+	ext []segExt
+}
+
+// A segRef is an entry of a template's kind and offset table.
+type segRef struct {
+	start, end uint32
+	typ        segmentType
+}
+
+// A segExt holds the fields of a segment other than its kind and value.
+type segExt struct {
+	matcher SegmentMatcher
+	filters []filter
+	cond    *conditional
+}
+
+// makeTemplate returns a template of segs, whose values are copied into a
+// new backing string. It is for templates built other than by parsing.
+func makeTemplate(segs []segment) template {
+	var (
+		t   template
+		src strings.Builder
+	)
+	t.refs = make([]segRef, len(segs))
+	for i, seg := range segs {
+		t.refs[i] = segRef{start: uint32(src.Len()), end: uint32(src.Len() + len(seg.val)), typ: seg.typ}
+		src.WriteString(seg.val)
+		if seg.matcher != nil || seg.filters != nil || seg.cond != nil {
+			if t.ext == nil {
+				t.ext = make([]segExt, len(segs))
+			}
+			t.ext[i] = segExt{matcher: seg.matcher, filters: seg.filters, cond: seg.cond}
+		}
+	}
+	if len(segs) == 0 {
+		t.refs = nil
+	}
+	t.src = src.String()
+	return t
+}
+
+// len returns the number of segments of the template.
+func (tmpl template) len() int {
+	return len(tmpl.refs)
 }
 
-type template []segment
+// at returns the i'th segment of the template.
+func (tmpl template) at(i int) segment {
+	r := tmpl.refs[i]
+	s := segment{typ: r.typ, val: tmpl.src[r.start:r.end]}
+	if tmpl.ext != nil {
+		e := &tmpl.ext[i]
+		s.matcher, s.filters, s.cond = e.matcher, e.filters, e.cond
+	}
+	return s
+}
+
+// segments returns the segments of the template. Unlike at, it allocates,
+// and is meant for code outside of evaluation which edits templates.
+func (tmpl template) segments() []segment {
+	if tmpl.len() == 0 {
+		return nil
+	}
+	segs := make([]segment, tmpl.len())
+	for i := range segs {
+		segs[i] = tmpl.at(i)
+	}
+	return segs
+}
 
 func parseTemplate(s string) (template, error) {
+	return parseTemplateWith(s, nil)
+}
+
+// parseTemplateWith parses a template which may use the given custom
+// segment kinds.
+func parseTemplateWith(s string, kinds map[string]SegmentMatcher) (template, error) {
+	return parseTemplateIn(s, kinds, nil)
+}
+
+// parseTemplateIn is parseTemplateWith allocating the tables from a, if
+// not nil. The template's backing string is s itself, not a copy.
+func parseTemplateIn(s string, kinds map[string]SegmentMatcher, a *segmentArena) (template, error) {
 	var (
-		b   strings.Builder
-		t   []segment
-		ltt segmentType = segmentTypeString
+		// b is the span of s of the segment being read, if not empty.
+		b    = span{src: s}
+		buf  [8]segRef
+		ltt  = segmentTypeString
+		skip int
 	)
-	for _, r := range s {
+	// Segments are gathered in buf, unless there are many, and copied out.
+	t := tables{refs: buf[:0]}
+	for i, r := range s {
+		if i < skip {
+			continue
+		}
+		if ltt == segmentTypeCustom {
+			b.write(i)
+			if r != '>' {
+				continue
+			}
+			kind := b.String()[1 : b.Len()-1]
+			m, ok := kinds[kind]
+			if !ok {
+				return template{}, fmt.Errorf("linkmap: unknown segment kind %q", kind)
+			}
+			t = t.addExt(ltt, b.start, b.end, segExt{matcher: m})
+			b.Reset()
+			ltt = segmentTypeString
+			continue
+		}
 		switch r {
 		case '$':
 			if b.Len() > 0 {
 				if ltt == segmentTypeVariable {
-					return nil, errors.New("linkmap: found two consecutive variables")
+					return template{}, errors.New("linkmap: found two consecutive variables")
 				}
-				t = append(t, segment{
-					typ: ltt,
-					val: b.String(),
-				})
+				t = t.add(ltt, b.start, b.end)
 				b.Reset()
 			}
+			if strings.HasPrefix(s[i:], destPrefix) {
+				end := strings.IndexByte(s[i:], ')')
+				if end < 0 {
+					return template{}, fmt.Errorf("linkmap: unterminated destination %q", s[i:])
+				}
+				dest := s[i : i+end+1]
+				if key := dest[len(destPrefix) : len(dest)-1]; key == "" || strings.ContainsAny(key, "${}<>( \t") {
+					return template{}, fmt.Errorf("linkmap: invalid destination key %q", key)
+				}
+				t = t.add(segmentTypeDest, i, i+end+1)
+				skip = i + end + 1
+				ltt = segmentTypeString
+				continue
+			}
 			ltt = segmentTypeVariable
-			b.WriteRune(r)
-		case '{':
+			b.write(i)
+		case '{', '<':
+			if ltt == segmentTypeVariable && b.Len() == 1 {
+				return template{}, errors.New("linkmap: found variable without preceding number")
+			}
 			if b.Len() > 0 {
-				t = append(t, segment{
-					typ: ltt,
-					val: b.String(),
-				})
+				t = t.add(ltt, b.start, b.end)
 				b.Reset()
 			}
+			if r == '{' && strings.HasPrefix(s[i:], "{if:") {
+				c, n, err := parseConditional(s[i:], kinds, a)
+				if err != nil {
+					return template{}, err
+				}
+				t = t.addExt(segmentTypeCond, i, i+n, segExt{cond: c})
+				skip = i + n
+				ltt = segmentTypeString
+				continue
+			}
 			ltt = segmentTypeExtension
-			b.WriteRune(r)
+			if r == '<' {
+				ltt = segmentTypeCustom
+			}
+			b.write(i)
 		case '}':
-			b.WriteRune(r)
+			b.write(i)
 			if b.Len() > 0 {
-				t = append(t, segment{
-					typ: ltt,
-					val: b.String(),
-				})
+				t = t.add(ltt, b.start, b.end)
 				b.Reset()
 			}
 			ltt = segmentTypeString
 		default:
-			if ltt == segmentTypeVariable && (r < '0' || r > '9') {
-				if b.Len() > 0 {
-					t = append(t, segment{
-						typ: ltt,
-						val: b.String(),
-					})
+			if ltt == segmentTypeVariable && r == '|' && b.Len() > 1 {
+				if _, ok := filterName(s[i+1:]); ok {
+					filters, n, terminated, err := parseFilters(s[i:])
+					if err != nil {
+						return template{}, err
+					}
+					t = t.addExt(ltt, b.start, i+n, segExt{filters: filters})
+					b.Reset()
+					skip = i + n
+					if terminated {
+						skip++
+					}
+					ltt = segmentTypeString
+					continue
+				}
+			}
+			if ltt == segmentTypeVariable && !continuesName(b.String(), r) {
+				if b.Len() > 1 {
+					t = t.add(ltt, b.start, b.end)
 					b.Reset()
 				} else {
-					return nil, errors.New("linkmap: found variable without preceding number")
+					return template{}, errors.New("linkmap: found variable without preceding number")
 				}
 				ltt = segmentTypeString
 			}
-			b.WriteRune(r)
+			b.write(i)
 		}
 	}
+	if ltt == segmentTypeVariable && b.Len() == 1 {
+		return template{}, errors.New("linkmap: found variable without preceding number")
+	}
+	if ltt == segmentTypeCustom {
+		return template{}, fmt.Errorf("linkmap: unterminated segment %q", b.String())
+	}
 	if b.Len() > 0 {
-		t = append(t, segment{
-			typ: ltt,
-			val: b.String(),
-		})
+		t = t.add(ltt, b.start, b.end)
 	}
-	return t, nil
+	return a.alloc(s, t.refs, t.ext), nil
+}
+
+// tables gathers the tables of a template being parsed.
+type tables struct {
+	refs []segRef
+	// ext is nil until a segment needs it.
+	ext []segExt
+}
+
+// add returns t with a segment whose value is src[start:end] added.
+func (t tables) add(typ segmentType, start, end int) tables {
+	t.refs = append(t.refs, segRef{start: uint32(start), end: uint32(end), typ: typ})
+	if t.ext != nil {
+		t.ext = append(t.ext, segExt{})
+	}
+	return t
+}
+
+// addExt is like add for a segment which needs e.
+func (t tables) addExt(typ segmentType, start, end int, e segExt) tables {
+	if t.ext == nil {
+		t.ext = make([]segExt, len(t.refs), len(t.refs)+1)
+	}
+	t.refs = append(t.refs, segRef{start: uint32(start), end: uint32(end), typ: typ})
+	t.ext = append(t.ext, e)
+	return t
 }
 
 func (tmpl template) equals(other template) bool {
-	if len(tmpl) != len(other) {
+	if tmpl.len() != other.len() {
 		return false
 	}
-	for i := range tmpl {
-		if tmpl[i].typ != other[i].typ || tmpl[i].val != other[i].val {
+	for i, r := range tmpl.refs {
+		o := other.refs[i]
+		if r.typ != o.typ || tmpl.src[r.start:r.end] != other.src[o.start:o.end] {
 			return false
 		}
 	}
 	return true
 }
 
-func (tmpl template) match(s string) (map[string]string, bool) {
-	variables := make(map[string]string)
-	if len(tmpl) == 0 {
-		return variables, s == ""
+// String returns the source form of the template.
+func (tmpl template) String() string {
+	var b strings.Builder
+	for i := 0; i < tmpl.len(); i++ {
+		t := tmpl.at(i)
+		b.WriteString(t.val)
+		if len(t.filters) > 0 && i < tmpl.len()-1 {
+			// End the filters, which the next segment would otherwise
+			// continue.
+			b.WriteByte('|')
+		}
 	}
+	return b.String()
+}
+
+// variables returns the names of the variables in the template, including
+// those bound by custom segments, in order.
+func (tmpl template) variables() []string {
+	var vars []string
+	tmpl.walk(func(t segment) {
+		if t.typ == segmentTypeVariable || t.typ == segmentTypeCustom {
+			vars = append(vars, t.name())
+		}
+	})
+	return vars
+}
+
+// continuesName reports whether r continues the variable v. Variables are
+// either numbered, like $1, or named, like $slug: a name starts with a
+// letter or underscore and continues with letters, digits or underscores.
+// The splat, $*, is named "*".
+func continuesName(v string, r rune) bool {
+	digit := r >= '0' && r <= '9'
+	letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+	switch {
+	case v == "$":
+		return digit || letter || r == '*'
+	case v == splat:
+		return false
+	case v[1] >= '0' && v[1] <= '9':
+		return digit
+	default:
+		return digit || letter
+	}
+}
+
+// extensions returns the alternatives of an extension group.
+func (s segment) extensions() []string {
+	return strings.Split(s.val[1:len(s.val)-1], ",")
+}
+
+// matchExt returns the length of the prefix of rest matched by the first
+// matching alternative of an extension group, or -1. An alternative ending
+// in '*' matches its prefix followed by anything up to the next '/', and
+// an empty alternative matches nothing, e.g. an extensionless file. If
+// last is set, the alternative must match all of rest.
+func (s segment) matchExt(rest string, last bool) int {
+	for _, ext := range s.extensions() {
+		n := -1
+		if pre := strings.TrimSuffix(ext, "*"); pre != ext {
+			if strings.HasPrefix(rest, pre) {
+				n = len(rest)
+				if end := strings.IndexByte(rest[len(pre):], '/'); end >= 0 {
+					n = len(pre) + end
+				}
+			}
+		} else if strings.HasPrefix(rest, ext) {
+			n = len(ext)
+		}
+		if n >= 0 && (!last || n == len(rest)) {
+			return n
+		}
+	}
+	return -1
+}
+
+// destKey returns the key of a destination segment.
+func (s segment) destKey() string {
+	return s.val[len(destPrefix) : len(s.val)-1]
+}
+
+// name returns the name of the variable bound by a variable or custom
+// segment: the variable without its leading '$', or the custom kind.
+func (s segment) name() string {
+	if s.typ == segmentTypeCustom {
+		return s.val[1 : len(s.val)-1]
+	}
+	if i := strings.IndexByte(s.val, '|'); i >= 0 {
+		return s.val[1:i]
+	}
+	return s.val[1:]
+}
+
+func (tmpl template) match(s string) (map[string]string, bool) {
+	variables, fail, _ := tmpl.matchAt(s)
+	return variables, fail < 0
+}
+
+// matchAt is like match, but on failure returns the index of the segment
+// which failed to match and the offset in s it was matched at. The index
+// is tmpl.len() if s has text left over. On success, the index is -1.
+func (tmpl template) matchAt(s string) (map[string]string, int, int) {
+	// The variables are only allocated once one is bound, as most rules
+	// tried fail on their first literal.
+	var variables map[string]string
 	var offset int
 outer:
-	for i, t := range tmpl {
+	for i := 0; i < tmpl.len(); i++ {
+		t := tmpl.at(i)
 		switch t.typ {
 		case segmentTypeString:
 			if !strings.HasPrefix(s[offset:], t.val) {
-				return nil, false
+				return nil, i, offset
 			}
 			offset += len(t.val)
 		case segmentTypeExtension:
-			possible := strings.Split(t.val[1:len(t.val)-1], ",")
-			for _, ext := range possible {
-				if strings.HasSuffix(s[offset:], ext) {
-					offset += len(ext)
-					continue outer
-				}
+			if n := t.matchExt(s[offset:], i == tmpl.len()-1); n >= 0 {
+				offset += n
+				continue outer
 			}
-			return nil, false
+			return nil, i, offset
 		case segmentTypeVariable:
+			if prev, ok := variables[t.name()]; ok {
+				// A repeated variable is a back-reference, matching only
+				// the value it was first bound to.
+				if !strings.HasPrefix(s[offset:], prev) {
+					return nil, i, offset
+				}
+				offset += len(prev)
+				continue
+			}
 			val := s[offset:]
-			if i < len(tmpl)-1 {
-				next := tmpl[i+1]
+			if i < tmpl.len()-1 {
+				next := tmpl.at(i + 1)
 				if next.typ == segmentTypeString {
 					index := strings.Index(val, next.val)
 					if index == -1 {
-						return nil, false
+						return nil, i + 1, offset
 					}
 					val = val[:index]
 				} else if next.typ == segmentTypeExtension {
-					possible := strings.Split(next.val[1:len(next.val)-1], ",")
-					var index int
-					for _, ext := range possible {
-						index = strings.Index(val, ext)
-						if index != -1 {
+					index := 0
+					for ; index <= len(val); index++ {
+						if next.matchExt(val[index:], i+1 == tmpl.len()-1) >= 0 {
 							break
 						}
 					}
-					if index == -1 {
-						return nil, false
+					if index > len(val) {
+						return nil, i + 1, offset
+					}
+					val = val[:index]
+				} else if next.typ == segmentTypeCustom {
+					index := 0
+					for ; index <= len(val); index++ {
+						if next.matcher.Match(val[index:]) >= 0 {
+							break
+						}
+					}
+					if index > len(val) {
+						return nil, i + 1, offset
 					}
 					val = val[:index]
 				}
 			}
-			variables[t.val] = val
+			if variables == nil {
+				variables = make(map[string]string)
+			}
+			variables[t.name()] = val
 			offset += len(val)
+		case segmentTypeDest:
+			return nil, i, offset
+		case segmentTypeCustom:
+			n := t.matcher.Match(s[offset:])
+			if n < 0 {
+				return nil, i, offset
+			}
+			val := s[offset : offset+n]
+			if prev, ok := variables[t.name()]; ok && prev != val {
+				return nil, i, offset
+			}
+			if variables == nil {
+				variables = make(map[string]string)
+			}
+			variables[t.name()] = val
+			offset += n
 		default:
 			panic("unexpected link token type")
 		}
 	}
-	return variables, offset == len(s)
+	if offset != len(s) {
+		return nil, tmpl.len(), offset
+	}
+	if variables == nil {
+		variables = make(map[string]string)
+	}
+	return variables, -1, offset
 }
 
 func (tmpl template) apply(variables map[string]string) (string, error) {
+	return tmpl.applyWith(variables, nil)
+}
+
+// applyWith is like apply but resolves destination segments with r.
+func (tmpl template) applyWith(variables map[string]string, r Resolver) (string, error) {
 	var b strings.Builder
-	for _, t := range tmpl {
+	for i := 0; i < tmpl.len(); i++ {
+		t := tmpl.at(i)
 		switch t.typ {
 		case segmentTypeString:
 			b.WriteString(t.val)
-		case segmentTypeVariable:
-			if val, ok := variables[t.val]; ok {
+		case segmentTypeVariable, segmentTypeCustom:
+			if val, ok := variables[t.name()]; ok {
+				val, err := t.filter(val)
+				if err != nil {
+					return "", err
+				}
 				b.WriteString(val)
 			} else {
 				return "", fmt.Errorf("missing variable %s", t.val)
 			}
+		case segmentTypeDest:
+			if r == nil {
+				return "", fmt.Errorf("no resolver for %s", t.val)
+			}
+			val, err := r.Resolve(t.destKey())
+			if err != nil {
+				return "", fmt.Errorf("resolving %s: %w", t.val, err)
+			}
+			b.WriteString(val)
+		case segmentTypeCond:
+			part, err := t.cond.choose(variables)
+			if err != nil {
+				return "", err
+			}
+			val, err := part.applyWith(variables, r)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
 		case segmentTypeExtension:
 			return "", fmt.Errorf("extensions not supported")
 		default:
@@ -1,6 +1,13 @@
 package linkmap
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
 
 func TestTokenizeLink(t *testing.T) {
 	cases := []struct {
@@ -74,7 +81,7 @@ func TestTokenizeLink(t *testing.T) {
 	for _, c := range cases {
 		if got, err := parseTemplate(c.link); err != nil {
 			t.Errorf("parseTemplate(%q) error: %v", c.link, err)
-		} else if !got.equals(c.expect) {
+		} else if !got.equals(makeTemplate(c.expect)) {
 			t.Errorf("parseTemplate(%q) = %v; want %v", c.link, got, c.expect)
 		}
 	}
@@ -108,6 +115,15 @@ func TestMatch(t *testing.T) {
 				"http://example.com/posts/abc",
 			},
 		},
+		{
+			link:    "docs/$1/$1.{md,mdx}",
+			retTrue: []string{"docs/intro/intro.md", "docs/a.b/a.b.mdx"},
+			retFalse: []string{
+				"docs/intro/index.md",
+				"docs/intro/intro2.md",
+				"docs/a/b/a.md",
+			},
+		},
 	}
 	for _, c := range cases {
 		tokenized, err := parseTemplate(c.link)
@@ -172,3 +188,94 @@ func TestComplete(t *testing.T) {
 		}
 	}
 }
+
+func TestProvenance(t *testing.T) {
+	src := "# docs\n$1.md https://example.com/$1\nx/$1.txt ../$1\n"
+	m, err := Parse(strings.NewReader(src), WithSource("docs/.linkmap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := m.Lookup("a.md")
+	if res.Rule == nil || res.Rule.Origin() != "docs/.linkmap:2" {
+		t.Fatalf("Lookup(a.md).Rule = %v; want docs/.linkmap:2", res.Rule)
+	}
+	_, err = m.Evaluate("x/y.txt")
+	if !errors.Is(err, ErrTraversal) || !strings.HasPrefix(err.Error(), "linkmap: docs/.linkmap:3: ") {
+		t.Errorf("Evaluate(x/y.txt) = %v; want ErrTraversal at docs/.linkmap:3", err)
+	}
+
+	_, err = Parse(strings.NewReader("a.md b\nbad\n"), WithSource("web/.linkmap"))
+	if err == nil || !strings.HasPrefix(err.Error(), "linkmap: web/.linkmap:2: invalid line") {
+		t.Errorf("Parse() = %v; want error at web/.linkmap:2", err)
+	}
+
+	other, err := Parse(strings.NewReader("$1.txt https://example.com/t/$1\n"), WithSource("web/.linkmap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anon, err := Parse(strings.NewReader("a/b/$1 https://example.com/b/$1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := Merge([]*Map{m, other, anon})
+	var origins []string
+	for _, r := range merged.Rules() {
+		origins = append(origins, r.Origin())
+	}
+	sort.Strings(origins)
+	if got, want := strings.Join(origins, ","), "docs/.linkmap:2,docs/.linkmap:3,line 1,web/.linkmap:1"; got != want {
+		t.Errorf("Merge() origins = %s; want %s", got, want)
+	}
+
+	for _, tt := range []struct {
+		r    Rule
+		want string
+	}{
+		{Rule{Source: "a", Line: 3}, "a:3"},
+		{Rule{Line: 3}, "line 3"},
+		{Rule{Source: "a"}, "a"},
+		{Rule{}, ""},
+	} {
+		if got := tt.r.Origin(); got != tt.want {
+			t.Errorf("%+v.Origin() = %q; want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestParseDeterministic(t *testing.T) {
+	// Many rules of each complexity, so that an unstable sort would
+	// reorder them.
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "d%d/$1.md https://example.com/%d/$1\n", i, i)
+		fmt.Fprintf(&b, "e%d/$1/$2.md https://example.com/%d/$1/$2\n", i, i)
+	}
+	src := b.String()
+	want, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := want.Rules()
+	for i := 1; i < len(rules); i++ {
+		if rules[i-1].Input.segs.len() == rules[i].Input.segs.len() && rules[i-1].Line > rules[i].Line {
+			t.Fatalf("rule from line %d is ordered before line %d", rules[i-1].Line, rules[i].Line)
+		}
+	}
+	var wg sync.WaitGroup
+	hashes := make([]string, 16)
+	for i := range hashes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if m, err := Parse(strings.NewReader(src)); err == nil {
+				hashes[i] = m.Hash()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, h := range hashes {
+		if h != want.Hash() {
+			t.Errorf("concurrent Parse() %d: Hash() = %q; want %q", i, h, want.Hash())
+		}
+	}
+}
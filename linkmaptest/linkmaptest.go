@@ -0,0 +1,189 @@
+// Package linkmaptest provides helpers for testing code which uses linkmap:
+// building maps inline, checking what they evaluate paths to, comparing
+// serialized maps with golden files, and fake file trees.
+//
+//	func TestDocs(t *testing.T) {
+//		m := linkmaptest.Parse(t, "docs/$1.md https://example.com/$1")
+//		linkmaptest.Expect(t, m, map[string]string{
+//			"docs/intro.md": "https://example.com/intro",
+//			"blog/post.md":  "",
+//		})
+//	}
+package linkmaptest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/operandinc/linkmap"
+)
+
+var update = flag.Bool("linkmaptest.update", false, "rewrite golden files compared by linkmaptest.Golden")
+
+// Parse parses a map in linkmap syntax, failing the test if it is invalid.
+func Parse(tb testing.TB, src string, opts ...linkmap.Option) *linkmap.Map {
+	tb.Helper()
+	m, err := linkmap.Parse(strings.NewReader(src), opts...)
+	if err != nil {
+		tb.Fatalf("parsing map: %v", err)
+	}
+	return m
+}
+
+// ParseTOML parses a map in TOML, failing the test if it is invalid.
+func ParseTOML(tb testing.TB, src string, opts ...linkmap.Option) *linkmap.Map {
+	tb.Helper()
+	m, err := linkmap.ParseTOML(strings.NewReader(src), opts...)
+	if err != nil {
+		tb.Fatalf("parsing map: %v", err)
+	}
+	return m
+}
+
+// Expect checks that e evaluates each path in want to its link, where an
+// empty link means that the path must match no rule. Mismatches are
+// reported together as a diff of "path -> link" lines, sorted by path.
+func Expect(tb testing.TB, e linkmap.Evaluator, want map[string]string) {
+	tb.Helper()
+	paths := make([]string, 0, len(want))
+	for p := range want {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var got, exp []string
+	for _, p := range paths {
+		res := e.Lookup(p)
+		switch {
+		case res.Err == nil:
+			got = append(got, p+" -> "+res.Link)
+		case errors.Is(res.Err, linkmap.ErrNoMatches):
+			got = append(got, p+" -> (no match)")
+		default:
+			got = append(got, p+" -> error: "+res.Err.Error())
+		}
+		if want[p] == "" {
+			exp = append(exp, p+" -> (no match)")
+		} else {
+			exp = append(exp, p+" -> "+want[p])
+		}
+	}
+	if d := diff(got, exp); d != "" {
+		tb.Errorf("evaluation results differ (-got +want):\n%s", d)
+	}
+}
+
+// Golden compares m, serialized in the format the extension of name
+// implies, with the file name in the testdata directory: TOML for ".toml",
+// the binary format for linkmap.BinaryExt, and linkmap syntax otherwise.
+// Running the test with -linkmaptest.update rewrites the file instead.
+func Golden(tb testing.TB, m *linkmap.Map, name string) {
+	tb.Helper()
+	var b bytes.Buffer
+	var err error
+	switch {
+	case strings.HasSuffix(name, linkmap.BinaryExt):
+		var data []byte
+		data, err = m.MarshalBinary()
+		b.Write(data)
+	case strings.HasSuffix(name, ".toml"):
+		_, err = m.WriteTOML(&b)
+	default:
+		_, err = m.WriteTo(&b)
+	}
+	if err != nil {
+		tb.Fatalf("serializing map: %v", err)
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("reading golden file: %v (run with -linkmaptest.update to create it)", err)
+	}
+	if bytes.Equal(b.Bytes(), want) {
+		return
+	}
+	if linkmap.IsBinary(want) {
+		tb.Errorf("map differs from %s (run with -linkmaptest.update to update it)", path)
+		return
+	}
+	tb.Errorf("map differs from %s (-got +want):\n%s", path, diff(lines(b.String()), lines(string(want))))
+}
+
+// FS returns a file tree containing the given paths, as empty files, or
+// as directories if they end in '/'. Parent directories are implied.
+func FS(paths ...string) fstest.MapFS {
+	fsys := make(fstest.MapFS, len(paths))
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			fsys[strings.TrimSuffix(p, "/")] = &fstest.MapFile{Mode: os.ModeDir | 0o755}
+		} else {
+			fsys[p] = &fstest.MapFile{}
+		}
+	}
+	return fsys
+}
+
+func lines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diff returns a line diff of got and want, with lines only in got marked
+// '-', lines only in want marked '+' and common lines indented, or "" if
+// they are equal. It uses the longest common subsequence, which suits the
+// short inputs of tests.
+func diff(got, want []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of got[i:]
+	// and want[j:].
+	lcs := make([][]int, len(got)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(want)+1)
+	}
+	for i := len(got) - 1; i >= 0; i-- {
+		for j := len(want) - 1; j >= 0; j-- {
+			switch {
+			case got[i] == want[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var b strings.Builder
+	changed := false
+	i, j := 0, 0
+	for i < len(got) || j < len(want) {
+		switch {
+		case i < len(got) && j < len(want) && got[i] == want[j]:
+			fmt.Fprintf(&b, "  %s\n", got[i])
+			i, j = i+1, j+1
+		case j == len(want) || i < len(got) && lcs[i+1][j] >= lcs[i][j+1]:
+			fmt.Fprintf(&b, "- %s\n", got[i])
+			i, changed = i+1, true
+		default:
+			fmt.Fprintf(&b, "+ %s\n", want[j])
+			j, changed = j+1, true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	return b.String()
+}
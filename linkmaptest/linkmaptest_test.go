@@ -0,0 +1,118 @@
+package linkmaptest
+
+import (
+	"fmt"
+	"io/fs"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB which records failures instead of reporting
+// them.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// record runs fn with a recorder and returns the failures it recorded.
+func record(t *testing.T, fn func(tb *recorder)) []string {
+	r := &recorder{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(r)
+	}()
+	<-done
+	return r.errs
+}
+
+const src = "docs/$1.md https://example.com/$1\nblog/$slug.md https://example.com/blog/$slug\n"
+
+func TestExpect(t *testing.T) {
+	m := Parse(t, src)
+	Expect(t, m, map[string]string{
+		"docs/intro.md": "https://example.com/intro",
+		"blog/post.md":  "https://example.com/blog/post",
+		"other.md":      "",
+	})
+	errs := record(t, func(tb *recorder) {
+		Expect(tb, m, map[string]string{
+			"docs/intro.md": "https://example.com/docs/intro",
+			"other.md":      "https://example.com/other",
+			"blog/post.md":  "https://example.com/blog/post",
+		})
+	})
+	want := `evaluation results differ (-got +want):
+  blog/post.md -> https://example.com/blog/post
+- docs/intro.md -> https://example.com/intro
+- other.md -> (no match)
++ docs/intro.md -> https://example.com/docs/intro
++ other.md -> https://example.com/other
+`
+	if len(errs) != 1 || errs[0] != want {
+		t.Errorf("Expect() reported %q; want %q", errs, want)
+	}
+	if errs := record(t, func(tb *recorder) { Parse(tb, "docs/$1.md") }); len(errs) != 1 {
+		t.Errorf("Parse() of an invalid map reported %q", errs)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, Parse(t, src), "docs.linkmap")
+	errs := record(t, func(tb *recorder) {
+		Golden(tb, Parse(t, "docs/$1.md https://example.org/$1\nblog/$slug.md https://example.com/blog/$slug\n"), "docs.linkmap")
+	})
+	if len(errs) != 1 || !strings.Contains(errs[0], "- docs/$1.md https://example.org/$1\n+ docs/$1.md https://example.com/$1\n  blog/") {
+		t.Errorf("Golden() reported %q", errs)
+	}
+	if errs := record(t, func(tb *recorder) { Golden(tb, Parse(t, src), "missing.linkmap") }); len(errs) != 1 {
+		t.Errorf("Golden() of a missing file reported %q", errs)
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := FS("docs/a.md", "docs/b/c.md", "empty/")
+	var got []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			p += "/"
+		}
+		got = append(got, p)
+		return nil
+	})
+	if want := "./ docs/ docs/a.md docs/b/ docs/b/c.md empty/"; err != nil || strings.Join(got, " ") != want {
+		t.Errorf("FS() walks %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		got, want []string
+		diff      string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, ""},
+		{[]string{"a", "b", "c"}, []string{"a", "c"}, "  a\n- b\n  c\n"},
+		{[]string{"a"}, []string{"b", "a"}, "+ b\n  a\n"},
+		{nil, []string{"a"}, "+ a\n"},
+	}
+	for _, tt := range tests {
+		if d := diff(tt.got, tt.want); d != tt.diff {
+			t.Errorf("diff(%q, %q) = %q; want %q", tt.got, tt.want, d, tt.diff)
+		}
+	}
+}
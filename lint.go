@@ -0,0 +1,187 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Linter checks linkmap source for mistakes beyond those which prevent it
+// from parsing. In addition to the diagnostics reported by Analyze, it
+// always reports output templates which embed credentials and input
+// templates which look like typos of another's, and checks destinations
+// against any allowlists configured.
+//
+// A comment line "#linkmap:ignore code..." directly above a rule
+// suppresses the diagnostics with the listed codes for that rule. Codes
+// which suppress nothing are reported, so that stale comments do not hide
+// future problems.
+type Linter struct {
+	// Options are the parse options, such as WithSegment, used for the
+	// linkmap.
+	Options []Option
+
+	// AllowedSchemes lists the schemes absolute output URLs may use. If
+	// empty, any scheme is allowed.
+	AllowedSchemes []string
+	// AllowedHosts lists the hosts absolute output URLs may point at. An
+	// entry beginning with "." also matches any subdomain. If empty, any
+	// host is allowed.
+	AllowedHosts []string
+	// AllowedPaths lists the prefixes relative output links may begin with.
+	// If AllowedHosts is set and AllowedPaths is empty, relative links are
+	// reported.
+	AllowedPaths []string
+
+	// Severities overrides the severity of diagnostics by code, so that
+	// a repository can adopt the linter incrementally. The code "*"
+	// applies to codes not listed otherwise, and SeverityOff turns a code
+	// off. See ReadLintConfig.
+	Severities map[string]Severity
+}
+
+// Diagnostic codes reported by Linter.
+const (
+	CodeDisallowedScheme = "disallowed-scheme"
+	CodeDisallowedHost   = "disallowed-host"
+	CodeDynamicHost      = "dynamic-host"
+	CodeDisallowedPath   = "disallowed-path"
+)
+
+// Lint checks linkmap source and returns any problems found, in source
+// order.
+func (l *Linter) Lint(text string) []Diagnostic {
+	m := newMap(l.Options)
+	diags := analyze(text, m)
+	var typos []typoRule
+	forEachRule(text, m, func(line int, toks []token, r Rule) {
+		if shape, elems, ok := typoShape(r.Input); ok {
+			typos = append(typos, typoRule{line: line, tok: toks[0], shape: shape, elems: elems})
+		}
+		out := r.Output.segs
+		if r.Output.text != nil {
+			out = r.Output.text.approx()
+		}
+		diags = append(diags, l.checkDestination(line, toks[1], out)...)
+		if msg := findSecret(out); msg != "" {
+			diags = append(diags, toks[1].diagnostic(line, CodeEmbeddedSecret, errors.New(msg)))
+		}
+	})
+	diags = append(diags, checkTypos(typos, m.source)...)
+	diags = l.configure(suppress(diags, text, l.enabled))
+	for i := range diags {
+		diags[i].File = m.source
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Range.Start, diags[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return diags
+}
+
+// forEachRule calls fn for each well-formed rule in text.
+func forEachRule(text string, m *Map, fn func(line int, toks []token, r Rule)) {
+	for n, l := range strings.Split(text, "\n") {
+		if lineKind(l) != RuleLine {
+			continue
+		}
+		toks := ruleFields(l, m.textOutputs)
+		if len(toks) != 2 {
+			continue
+		}
+		in, err := m.parseInput(toks[0].text)
+		if err != nil {
+			continue
+		}
+		out, err := m.parseOutput(toks[1].text)
+		if err != nil {
+			continue
+		}
+		fn(n, toks, Rule{Input: in, Output: out, Line: n + 1})
+	}
+}
+
+// checkDestination checks the scheme, host, or path of an output template
+// against the allowlists.
+func (l *Linter) checkDestination(line int, tok token, out template) []Diagnostic {
+	if len(l.AllowedSchemes) == 0 && len(l.AllowedHosts) == 0 && len(l.AllowedPaths) == 0 {
+		return nil
+	}
+	// Only the literal prefix of the template is known before evaluation.
+	var prefix string
+	if out.len() > 0 && out.at(0).typ == segmentTypeString {
+		prefix = out.at(0).val
+	}
+	dynamic := out.len() > 1 || out.len() == 1 && out.at(0).typ != segmentTypeString
+	diag := func(code, format string, args ...interface{}) []Diagnostic {
+		return []Diagnostic{tok.diagnostic(line, code, fmt.Errorf(format, args...))}
+	}
+
+	scheme, rest, ok := strings.Cut(prefix, "://")
+	if !ok {
+		if strings.Contains(prefix, ":") {
+			// e.g. mailto:
+			scheme, rest = strings.SplitN(prefix, ":", 2)[0], ""
+		} else {
+			if len(l.AllowedHosts) == 0 && len(l.AllowedPaths) == 0 {
+				return nil
+			}
+			if !hasAnyPrefix(prefix, l.AllowedPaths) {
+				return diag(CodeDisallowedPath, "relative link %q is not under an allowed path", tok.text)
+			}
+			return nil
+		}
+	}
+	if len(l.AllowedSchemes) > 0 && !containsFold(l.AllowedSchemes, scheme) {
+		return diag(CodeDisallowedScheme, "scheme %q is not allowed", scheme)
+	}
+	if len(l.AllowedHosts) == 0 {
+		return nil
+	}
+	host := rest
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host = rest[:i]
+	} else if dynamic {
+		return diag(CodeDynamicHost, "host depends on a variable and cannot be checked")
+	}
+	if i := strings.LastIndexByte(host, '@'); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, ok := strings.Cut(host, ":"); ok && !strings.HasPrefix(host, "[") {
+		host = h
+	}
+	if !hostAllowed(host, l.AllowedHosts) {
+		return diag(CodeDisallowedHost, "host %q is not allowed", host)
+	}
+	return nil
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+		if host == a || strings.HasPrefix(a, ".") && (host == a[1:] || strings.HasSuffix(host, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
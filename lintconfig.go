@@ -0,0 +1,91 @@
+package linkmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LintConfigFile is the conventional name of a lint configuration file, as
+// read by ReadLintConfig.
+const LintConfigFile = ".linkmaplint"
+
+// SeverityOff turns a diagnostic code off in Linter.Severities.
+const SeverityOff Severity = 0
+
+// ReadLintConfig reads a lint configuration for Linter.Severities. Each
+// line names a diagnostic code, or "*" for every code not named, followed
+// by a severity: error, warning, info, hint or off. Blank lines and lines
+// beginning with '#' are ignored. For example, a repository adopting the
+// linter incrementally might start with:
+//
+//	# Only secrets fail the build for now.
+//	* off
+//	embedded-secret error
+//	similar-input warning
+func ReadLintConfig(r io.Reader) (map[string]Severity, error) {
+	sev := make(map[string]Severity)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || l[0] == '#' {
+			continue
+		}
+		f := strings.Fields(l)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("linkmap: %s line %d: expected a code and a severity", LintConfigFile, n)
+		}
+		var s Severity
+		if f[1] != SeverityOff.String() {
+			if err := s.UnmarshalText([]byte(f[1])); err != nil {
+				return nil, fmt.Errorf("linkmap: %s line %d: unknown severity %q", LintConfigFile, n, f[1])
+			}
+		}
+		if _, ok := sev[f[0]]; ok {
+			return nil, fmt.Errorf("linkmap: %s line %d: %s is already configured", LintConfigFile, n, f[0])
+		}
+		sev[f[0]] = s
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("linkmap: reading %s: %w", LintConfigFile, err)
+	}
+	return sev, nil
+}
+
+// severity returns the configured severity of diagnostics with code, and
+// whether one is configured.
+func (l *Linter) severity(code string) (Severity, bool) {
+	if s, ok := l.Severities[code]; ok {
+		return s, true
+	}
+	s, ok := l.Severities["*"]
+	return s, ok
+}
+
+// enabled reports whether diagnostics with code are reported.
+func (l *Linter) enabled(code string) bool {
+	s, ok := l.severity(code)
+	return !ok || s != SeverityOff
+}
+
+// configure drops the diagnostics which are turned off and sets the
+// configured severity of the rest.
+func (l *Linter) configure(diags []Diagnostic) []Diagnostic {
+	if len(l.Severities) == 0 {
+		return diags
+	}
+	kept := diags[:0]
+	for _, d := range diags {
+		s, ok := l.severity(d.Code)
+		switch {
+		case !ok:
+		case s == SeverityOff:
+			continue
+		default:
+			d.Severity = s
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadLintConfig(t *testing.T) {
+	sev, err := ReadLintConfig(strings.NewReader("# legacy repo\n* off\n\nembedded-secret error\n  similar-input   info\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Severity{"*": SeverityOff, CodeEmbeddedSecret: SeverityError, CodeSimilarInput: SeverityInfo}
+	if len(sev) != len(want) {
+		t.Fatalf("ReadLintConfig() = %v; want %v", sev, want)
+	}
+	for code, s := range want {
+		if sev[code] != s {
+			t.Errorf("ReadLintConfig()[%s] = %v; want %v", code, sev[code], s)
+		}
+	}
+
+	for _, src := range []string{"similar-input\n", "similar-input fatal\n", "a off\na error\n"} {
+		if _, err := ReadLintConfig(strings.NewReader(src)); err == nil {
+			t.Errorf("ReadLintConfig(%q) succeeded", src)
+		}
+	}
+}
+
+func TestLinterSeverities(t *testing.T) {
+	const src = "a/$1 https://example.com/$2\n" +
+		"#linkmap:ignore similar-input\n" +
+		"posts/$1 https://example.com/$1\n" +
+		"post/$1 https://example.com/$1?token=abc123\n"
+	cases := []struct {
+		sev   map[string]Severity
+		codes string
+	}{
+		{nil, "unbound-variable:error,unused-suppression:warning,similar-input:warning,embedded-secret:error"},
+		{map[string]Severity{CodeSimilarInput: SeverityError, CodeEmbeddedSecret: SeverityOff}, "unbound-variable:error,unused-suppression:warning,similar-input:error"},
+		// A suppression of a code which is off is not reported as unused.
+		{map[string]Severity{"*": SeverityOff, CodeEmbeddedSecret: SeverityWarning}, "embedded-secret:warning"},
+		{map[string]Severity{"*": SeverityHint, CodeUnusedSuppression: SeverityOff}, "unbound-variable:hint,similar-input:hint,embedded-secret:hint"},
+	}
+	for _, c := range cases {
+		l := Linter{Severities: c.sev}
+		var got []string
+		for _, d := range l.Lint(src) {
+			got = append(got, d.Code+":"+d.Severity.String())
+		}
+		if strings.Join(got, ",") != c.codes {
+			t.Errorf("Lint() with %v = %v; want %s", c.sev, got, c.codes)
+		}
+	}
+}
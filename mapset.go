@@ -0,0 +1,278 @@
+package linkmap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A MapSet holds the maps of many tenants or sites, keyed by ID. Maps are
+// loaded on first use, can be reloaded and evicted individually, and are
+// shared between keys whose sources are identical. A MapSet is safe for
+// concurrent use. Its configuration fields must not be changed after first
+// use.
+type MapSet struct {
+	// Load returns the linkmap source for key.
+	Load func(key string) ([]byte, error)
+	// Options are passed to Parse for every map.
+	Options []Option
+	// Parse parses the source of a map. If nil, the package's Parse is
+	// used; set it to ParseTOML, for example, for maps in TOML format.
+	Parse func(r io.Reader, opts ...Option) (*Map, error)
+	// MaxMaps bounds the number of loaded maps. When it is exceeded, the
+	// least recently used map is evicted. Zero means no limit.
+	MaxMaps int
+
+	mu       sync.Mutex
+	entries  map[string]*setEntry
+	shared   map[[sha256.Size]byte]*sharedMap
+	tick     uint64
+	recent   []EvalError
+	failures map[string]loadFailure
+	// loaded holds the keys which have loaded successfully, whose later
+	// load failures are recorded. Failures of other keys are not, so that
+	// callers cannot fail Health by asking for keys which do not exist.
+	loaded map[string]bool
+}
+
+type setEntry struct {
+	ready   chan struct{} // closed once the first load completes
+	m       *sharedMap
+	err     error
+	loaded  time.Time
+	lastUse uint64
+}
+
+// sharedMap is a parsed map referenced by one or more keys.
+type sharedMap struct {
+	m    *Map
+	sum  [sha256.Size]byte
+	refs int
+}
+
+// Get returns the map for key, loading it if necessary. Concurrent calls
+// for the same key share a single load.
+func (s *MapSet) Get(key string) (*Map, error) {
+	s.mu.Lock()
+	if s.entries == nil {
+		s.entries = make(map[string]*setEntry)
+		s.shared = make(map[[sha256.Size]byte]*sharedMap)
+	}
+	e, ok := s.entries[key]
+	if ok {
+		s.tick++
+		e.lastUse = s.tick
+		s.mu.Unlock()
+		<-e.ready
+		if e.err != nil {
+			return nil, e.err
+		}
+		return e.m.m, nil
+	}
+	e = &setEntry{ready: make(chan struct{})}
+	s.tick++
+	e.lastUse = s.tick
+	s.entries[key] = e
+	s.mu.Unlock()
+
+	sm, err := s.load(key)
+
+	s.mu.Lock()
+	if err == nil || s.loaded[key] {
+		s.setFailureLocked(key, err)
+	}
+	if err != nil {
+		e.err = err
+		if s.entries[key] == e {
+			// Let the next caller retry.
+			delete(s.entries, key)
+		}
+	} else {
+		e.m = sm
+		e.loaded = time.Now()
+		if s.entries[key] == e {
+			s.evictLocked()
+		} else {
+			// Reloaded or evicted while loading.
+			s.release(e)
+		}
+	}
+	close(e.ready)
+	s.mu.Unlock()
+	return sm.mapOrNil(), err
+}
+
+// Evaluate evaluates fpath against the map for key.
+func (s *MapSet) Evaluate(key, fpath string) (string, error) {
+	m, err := s.Get(key)
+	if err != nil {
+		s.recordError(key, fpath, err)
+		return "", err
+	}
+	link, err := m.Evaluate(fpath)
+	s.recordError(key, fpath, err)
+	return link, err
+}
+
+// Lookup is like Evaluate but returns the full Result. If the map cannot be
+// loaded, the Result carries the error.
+func (s *MapSet) Lookup(key, fpath string) Result {
+	m, err := s.Get(key)
+	if err != nil {
+		s.recordError(key, fpath, err)
+		return Result{Path: fpath, Err: err}
+	}
+	res := m.Lookup(fpath)
+	s.recordError(key, fpath, res.Err)
+	return res
+}
+
+// Reload loads and parses the source for key again. If loading or parsing
+// fails, the map already loaded, if any, continues to be served; the error
+// is returned and reported by LastError and Health until a later load
+// succeeds. Failures of Get are only reported for keys which have loaded
+// before.
+func (s *MapSet) Reload(key string) error {
+	sm, err := s.load(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setFailureLocked(key, err)
+	if err != nil {
+		return err
+	}
+	if s.entries == nil {
+		s.entries = make(map[string]*setEntry)
+	}
+	if e, ok := s.entries[key]; ok {
+		// If e is still loading, Get releases it when done.
+		s.release(e)
+	}
+	ready := make(chan struct{})
+	close(ready)
+	s.tick++
+	s.entries[key] = &setEntry{ready: ready, m: sm, loaded: time.Now(), lastUse: s.tick}
+	s.evictLocked()
+	return nil
+}
+
+// Evict removes the map for key. It is loaded again on next use.
+func (s *MapSet) Evict(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.removeLocked(key, e)
+	}
+	delete(s.failures, key)
+	delete(s.loaded, key)
+}
+
+// Keys returns the keys of the loaded maps, in sorted order.
+func (s *MapSet) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keysLocked()
+}
+
+func (s *MapSet) keysLocked() []string {
+	var keys []string
+	for k, e := range s.entries {
+		if e.m != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// load fetches and parses the source for key, reusing an already parsed
+// map with identical source.
+func (s *MapSet) load(key string) (*sharedMap, error) {
+	src, err := s.Load(key)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: loading %q: %w", key, err)
+	}
+	sum := sha256.Sum256(src)
+	s.mu.Lock()
+	if s.shared == nil {
+		s.shared = make(map[[sha256.Size]byte]*sharedMap)
+	}
+	if sm, ok := s.shared[sum]; ok {
+		sm.refs++
+		s.mu.Unlock()
+		return sm, nil
+	}
+	s.mu.Unlock()
+
+	parse := s.Parse
+	if parse == nil {
+		parse = Parse
+	}
+	m, err := parse(bytes.NewReader(src), s.Options...)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: parsing %q: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sm, ok := s.shared[sum]; ok {
+		// Parsed concurrently for another key.
+		sm.refs++
+		return sm, nil
+	}
+	sm := &sharedMap{m: m, sum: sum, refs: 1}
+	s.shared[sum] = sm
+	return sm, nil
+}
+
+// evictLocked evicts least recently used maps until at most MaxMaps remain.
+func (s *MapSet) evictLocked() {
+	if s.MaxMaps <= 0 {
+		return
+	}
+	for {
+		var (
+			loaded   int
+			lruKey   string
+			lruEntry *setEntry
+		)
+		for k, e := range s.entries {
+			if e.m == nil {
+				continue
+			}
+			loaded++
+			if lruEntry == nil || e.lastUse < lruEntry.lastUse {
+				lruKey, lruEntry = k, e
+			}
+		}
+		if loaded <= s.MaxMaps {
+			return
+		}
+		s.removeLocked(lruKey, lruEntry)
+	}
+}
+
+func (s *MapSet) removeLocked(key string, e *setEntry) {
+	delete(s.entries, key)
+	s.release(e)
+}
+
+// release drops an entry's reference to its shared map.
+func (s *MapSet) release(e *setEntry) {
+	if e.m == nil {
+		return
+	}
+	e.m.refs--
+	if e.m.refs == 0 {
+		delete(s.shared, e.m.sum)
+	}
+}
+
+func (sm *sharedMap) mapOrNil() *Map {
+	if sm == nil {
+		return nil
+	}
+	return sm.m
+}
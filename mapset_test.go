@@ -0,0 +1,83 @@
+package linkmap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestMapSet(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		loads   = make(map[string]int)
+		sources = map[string]string{
+			"a": "docs/$1.md https://a.example.com/$1\n",
+			"b": "docs/$1.md https://b.example.com/$1\n",
+			"c": "docs/$1.md https://a.example.com/$1\n",
+		}
+	)
+	s := &MapSet{
+		Load: func(key string) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			loads[key]++
+			src, ok := sources[key]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return []byte(src), nil
+		},
+		MaxMaps: 2,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := s.Evaluate("a", "docs/x.md"); err != nil || got != "https://a.example.com/x" {
+				t.Errorf("Evaluate(a) = %q, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads["a"] != 1 {
+		t.Errorf("loaded a %d times; want 1", loads["a"])
+	}
+
+	ma, _ := s.Get("a")
+	mc, err := s.Get("c")
+	if err != nil {
+		t.Fatalf("Get(c) error: %v", err)
+	}
+	if ma != mc {
+		t.Errorf("maps with identical sources are not shared")
+	}
+	if _, err := s.Get("b"); err != nil {
+		t.Fatalf("Get(b) error: %v", err)
+	}
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != "b" || keys[1] != "c" {
+		t.Errorf("Keys() = %v; want [b c] after evicting a", keys)
+	}
+	if _, err := s.Get("missing"); err == nil {
+		t.Errorf("Get(missing) succeeded; want error")
+	}
+
+	sources["b"] = "not a valid linkmap\n"
+	if err := s.Reload("b"); err == nil {
+		t.Errorf("Reload(invalid) succeeded; want error")
+	}
+	if got, err := s.Evaluate("b", "docs/x.md"); err != nil || got != "https://b.example.com/x" {
+		t.Errorf("Evaluate(b) after failed reload = %q, %v; want old map", got, err)
+	}
+	sources["b"] = "docs/$1.md https://new.example.com/$1\n"
+	if err := s.Reload("b"); err != nil {
+		t.Fatalf("Reload(b) error: %v", err)
+	}
+	if got, _ := s.Evaluate("b", "docs/x.md"); got != "https://new.example.com/x" {
+		t.Errorf("Evaluate(b) after reload = %q", got)
+	}
+	s.Evict("b")
+	if keys := s.Keys(); len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("Keys() after Evict = %v; want [c]", keys)
+	}
+}
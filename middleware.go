@@ -0,0 +1,145 @@
+package linkmap
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// An Evaluator evaluates paths to links. Map, Router, LazyMap, ShardedMap
+// and ChainedMap are Evaluators, and middleware wraps any Evaluator with
+// cross-cutting behavior such as caching or logging.
+type Evaluator interface {
+	Lookup(fpath string) Result
+}
+
+// EvaluatorFunc adapts a function to an Evaluator.
+type EvaluatorFunc func(fpath string) Result
+
+// Lookup calls f(fpath).
+func (f EvaluatorFunc) Lookup(fpath string) Result {
+	return f(fpath)
+}
+
+// Middleware wraps an Evaluator.
+type Middleware func(Evaluator) Evaluator
+
+// Wrap returns e wrapped in mws, the first outermost, so that
+//
+//	Wrap(m, Normalize(), Cache(1000))
+//
+// normalizes paths before looking them up in the cache.
+func Wrap(e Evaluator, mws ...Middleware) Evaluator {
+	for i := len(mws) - 1; i >= 0; i-- {
+		e = mws[i](e)
+	}
+	return e
+}
+
+// Normalize returns middleware which passes paths through NormalizePath,
+// so that OS paths and loosely formed ones such as "./docs//a.md" are
+// accepted. Paths it rejects fail with its error.
+func Normalize() Middleware {
+	return func(next Evaluator) Evaluator {
+		return EvaluatorFunc(func(fpath string) Result {
+			p, err := NormalizePath(fpath)
+			if err != nil {
+				return Result{Path: fpath, Err: err}
+			}
+			res := next.Lookup(p)
+			res.Path = fpath
+			return res
+		})
+	}
+}
+
+// Observe returns middleware which calls fn with the result of each
+// lookup and the time it took, e.g. to log evaluations or record metrics.
+// fn must be safe for concurrent use if the Evaluator is used
+// concurrently.
+func Observe(fn func(res Result, d time.Duration)) Middleware {
+	return func(next Evaluator) Evaluator {
+		return EvaluatorFunc(func(fpath string) Result {
+			start := time.Now()
+			res := next.Lookup(fpath)
+			fn(res, time.Since(start))
+			return res
+		})
+	}
+}
+
+// Fallback returns middleware which looks up paths that match nothing in
+// other, e.g. a shared map behind a team's own.
+func Fallback(other Evaluator) Middleware {
+	return func(next Evaluator) Evaluator {
+		return EvaluatorFunc(func(fpath string) Result {
+			res := next.Lookup(fpath)
+			if errors.Is(res.Err, ErrNoMatches) {
+				return other.Lookup(fpath)
+			}
+			return res
+		})
+	}
+}
+
+// Cache returns middleware which remembers the results of the size most
+// recently used paths, for hot paths on services whose maps are costly to
+// evaluate. Only links and misses are cached; other errors, such as an
+// exhausted budget, may not recur, and links of rules with weighted or
+// scheduled outputs are not either, as they change with each lookup or
+// over time. Cached results share their Vars, which
+// must not be modified, and lookups answered from the cache are not
+// counted by the map's counters. The cache is safe for concurrent use.
+func Cache(size int) Middleware {
+	return func(next Evaluator) Evaluator {
+		c := &resultCache{next: next, size: size, items: make(map[string]*list.Element), order: list.New()}
+		return EvaluatorFunc(c.lookup)
+	}
+}
+
+// A resultCache is a least recently used cache of results.
+type resultCache struct {
+	next Evaluator
+	size int
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	// order holds cacheEntries, most recently used first.
+	order *list.List
+}
+
+type cacheEntry struct {
+	path string
+	res  Result
+}
+
+func (c *resultCache) lookup(fpath string) Result {
+	c.mu.Lock()
+	if e, ok := c.items[fpath]; ok {
+		c.order.MoveToFront(e)
+		res := e.Value.(cacheEntry).res
+		c.mu.Unlock()
+		return res
+	}
+	c.mu.Unlock()
+	res := c.next.Lookup(fpath)
+	if res.Err != nil && !errors.Is(res.Err, ErrNoMatches) {
+		return res
+	}
+	if r := res.Rule; r != nil && (len(r.Weighted) > 0 || len(r.Scheduled) > 0) {
+		return res
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[fpath]; ok || c.size <= 0 {
+		return res
+	}
+	c.items[fpath] = c.order.PushFront(cacheEntry{fpath, res})
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(cacheEntry).path)
+	}
+	return res
+}
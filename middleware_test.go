@@ -0,0 +1,77 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	team, err := Parse(strings.NewReader("docs/$1.md https://team.example.com/$1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := Parse(strings.NewReader("$1.md https://shared.example.com/$1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lookups, observed int64
+	counting := func(next Evaluator) Evaluator {
+		return EvaluatorFunc(func(fpath string) Result {
+			atomic.AddInt64(&lookups, 1)
+			return next.Lookup(fpath)
+		})
+	}
+	e := Wrap(team,
+		Observe(func(Result, time.Duration) { atomic.AddInt64(&observed, 1) }),
+		Normalize(),
+		Cache(2),
+		counting,
+		Fallback(shared),
+	)
+	tests := []struct {
+		path, link string
+	}{
+		{"./docs//a.md", "https://team.example.com/a"},
+		{"docs/a.md", "https://team.example.com/a"},
+		{"blog/b.md", "https://shared.example.com/blog/b"},
+		{"docs/a.md", "https://team.example.com/a"},
+	}
+	for _, tt := range tests {
+		if res := e.Lookup(tt.path); res.Link != tt.link || res.Err != nil || res.Path != tt.path {
+			t.Errorf("Lookup(%q) = %+v; want %q", tt.path, res, tt.link)
+		}
+	}
+	if lookups != 2 || observed != 4 {
+		t.Errorf("lookups = %d, observed = %d; want 2, 4", lookups, observed)
+	}
+	for _, p := range []string{"x.txt", "y.txt", "x.txt"} {
+		if res := e.Lookup(p); !errors.Is(res.Err, ErrNoMatches) {
+			t.Errorf("Lookup(%q) = %v; want ErrNoMatches", p, res.Err)
+		}
+	}
+	if lookups != 4 {
+		t.Errorf("lookups = %d; want misses cached", lookups)
+	}
+	if res := e.Lookup("../a.md"); !errors.Is(res.Err, ErrInvalidPath) {
+		t.Errorf("Lookup(../a.md) = %v; want ErrInvalidPath", res.Err)
+	}
+}
+
+func TestCacheScheduled(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	m, err := ParseTOML(strings.NewReader(scheduledSrc), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Wrap(m, Cache(10))
+	if link := e.Lookup("docs/intro.md").Link; link != "https://canary.example.com/intro" {
+		t.Fatalf("Lookup() = %q before the cutover", link)
+	}
+	now = now.AddDate(0, 2, 0)
+	if link := e.Lookup("docs/intro.md").Link; link != "https://docs.example.com/intro" {
+		t.Errorf("Lookup() = %q after the cutover; want the cached link replaced", link)
+	}
+}
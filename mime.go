@@ -0,0 +1,85 @@
+package linkmap
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// pathVars are variables derived from the evaluated path. Like file
+// variables, they are only bound if the input template does not bind a
+// variable of the same name.
+var pathVars = map[string]func(fpath string) string{
+	// mime is the MIME type of the path's extension, without parameters,
+	// e.g. "image/png".
+	"mime": func(fpath string) string {
+		return mimeType(path.Ext(fpath))
+	},
+}
+
+// mimeType returns the MIME type for a file extension, including its dot,
+// or "application/octet-stream" if it is unknown.
+func mimeType(ext string) string {
+	t := mime.TypeByExtension(ext)
+	if t == "" {
+		return "application/octet-stream"
+	}
+	t, _, _ = strings.Cut(t, ";")
+	return strings.TrimSpace(t)
+}
+
+// bindPathVars binds the path variables used by out which are not already
+// bound.
+func bindPathVars(vars map[string]string, out Template, fpath string) {
+	names := out.Variables()
+	if out.text != nil {
+		names = nil
+		for name := range pathVars {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		if v, ok := pathVars[name]; ok {
+			vars[name] = v(fpath)
+		}
+	}
+}
+
+// derived reports whether name is a path, file or context variable, which
+// outputs may use without the input binding it.
+func (m *Map) derived(name string) bool {
+	_, ok := pathVars[name]
+	return ok || m.fileVar(name) != nil || m.contextVars[name] || m.urlInputs && name == "query"
+}
+
+// MIMEType returns a SegmentMatcher which matches a file extension, without
+// its dot, whose MIME type matches pattern. The pattern is either a full
+// type such as "application/pdf" or a wildcard such as "image/*". It lets
+// rules branch on content type without listing extensions:
+//
+//	linkmap.WithSegment("image", linkmap.MIMEType("image/*"))
+//
+// allows "assets/$1.<image> https://img.example.com/$1.<image>".
+func MIMEType(pattern string) SegmentMatcher {
+	return SegmentMatcherFunc(func(s string) int {
+		n := strings.IndexByte(s, '/')
+		if n < 0 {
+			n = len(s)
+		}
+		if n == 0 {
+			return -1
+		}
+		t := mimeType("." + s[:n])
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if !strings.HasPrefix(t, prefix) {
+				return -1
+			}
+		} else if t != pattern {
+			return -1
+		}
+		return n
+	})
+}
@@ -0,0 +1,62 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMIMEVariable(t *testing.T) {
+	src := strings.Join([]string{
+		"assets/$1 https://cdn.example.com/$1?type=$mime",
+		"docs/$1 tmpl:https://{{if hasPrefix \"image/\" .mime}}img{{else}}docs{{end}}.example.com/{{index . \"1\"}}",
+	}, "\n")
+	m, err := Parse(strings.NewReader(src), WithTmplOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"assets/a.png", "https://cdn.example.com/a.png?type=image/png"},
+		{"assets/a.unknown-ext", "https://cdn.example.com/a.unknown-ext?type=application/octet-stream"},
+		{"assets/a.html", "https://cdn.example.com/a.html?type=text/html"},
+		{"docs/a.png", "https://img.example.com/a.png"},
+		{"docs/a.pdf", "https://docs.example.com/a.pdf"},
+	}
+	for _, tt := range tests {
+		got, err := m.Evaluate(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if diags := Analyze(src, WithTmplOutputs()); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	opts := []Option{
+		WithSegment("image", MIMEType("image/*")),
+		WithSegment("pdf", MIMEType("application/pdf")),
+	}
+	src := "assets/$1.<image> https://img.example.com/$1.<image>\nassets/$1.<pdf> https://docs.example.com/$1.pdf\n"
+	m, err := Parse(strings.NewReader(src), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"assets/logo.png", "https://img.example.com/logo.png"},
+		{"assets/photo.jpeg", "https://img.example.com/photo.jpeg"},
+		{"assets/paper.pdf", "https://docs.example.com/paper.pdf"},
+		{"assets/app.js", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := m.Evaluate(tt.path); got != tt.want {
+			t.Errorf("Evaluate(%q) = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package linkmap
+
+// An Option configures a Map.
+type Option func(*Map)
+
+// TraversalPolicy controls how ".." elements in paths and generated links
+// are handled.
+type TraversalPolicy int
+
+const (
+	// TraversalReject rejects paths and links containing ".." elements
+	// with an error wrapping ErrTraversal. It is the default, although
+	// earlier versions matched such paths as is; set TraversalAllow to
+	// keep doing so.
+	TraversalReject TraversalPolicy = iota
+	// TraversalClean resolves ".." elements lexically. Paths which escape
+	// the root are still rejected.
+	TraversalClean
+	// TraversalAllow leaves ".." elements untouched.
+	TraversalAllow
+)
+
+// WithTraversalPolicy sets how ".." elements in input paths and generated
+// links are handled.
+func WithTraversalPolicy(p TraversalPolicy) Option {
+	return func(m *Map) {
+		m.traversal = p
+	}
+}
+
+// WithSource names the source a map is parsed from, usually a file path.
+// Rules record it as their Source, and it is reported along with their
+// line in errors and diagnostics.
+func WithSource(name string) Option {
+	return func(m *Map) {
+		m.source = name
+	}
+}
+
+// WithCounters enables per-rule match counters, reported by Map.Stats.
+func WithCounters() Option {
+	return func(m *Map) {
+		m.counting = true
+	}
+}
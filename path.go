@@ -0,0 +1,128 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Paths passed to Evaluate and friends follow the io/fs conventions: they
+// are slash-separated, relative to the repository root, and clean, with no
+// ".", "..", or empty elements (see fs.ValidPath). Use NormalizePath to
+// convert paths from other sources.
+//
+// Earlier versions matched any string, so URL-style paths such as
+// "/docs/intro.md" or "docs/../intro.md" are now rejected where they may
+// have matched a rule. WithCanonicalization(CollapseSlashes) accepts the
+// former, and a TraversalPolicy other than the default TraversalReject the
+// latter.
+
+// ErrInvalidPath is returned when a path does not follow the path model.
+var ErrInvalidPath = errors.New("linkmap: invalid path")
+
+// ErrTraversal is returned when a path or generated link contains ".."
+// elements and the map's TraversalPolicy does not allow them. It wraps
+// ErrInvalidPath.
+var ErrTraversal = fmt.Errorf("%w: path traversal", ErrInvalidPath)
+
+// NormalizePath converts an operating system path, or a loosely formed
+// slash-separated one, to the form expected by Evaluate. Separators are
+// converted to slashes, leading "./" and "/" are removed, and duplicate
+// slashes and "." elements are collapsed. ".." elements are resolved
+// lexically; a path which escapes the root is rejected.
+func NormalizePath(p string) (string, error) {
+	c := path.Clean(strings.TrimLeft(filepath.ToSlash(p), "/"))
+	switch {
+	case c == ".":
+		return "", fmt.Errorf("%w: empty path %q", ErrInvalidPath, p)
+	case c == ".." || strings.HasPrefix(c, "../"):
+		return "", fmt.Errorf("%w: %q escapes the root", ErrTraversal, p)
+	}
+	return c, nil
+}
+
+// inputPath canonicalizes p and checks that it follows the path model,
+// applying the map's TraversalPolicy, and returns the path to evaluate.
+func (m *Map) inputPath(p string) (string, error) {
+	if m.canonical != 0 {
+		c, err := m.canonicalize(p)
+		if err != nil {
+			return "", err
+		}
+		p = c
+	}
+	return m.checkInputPath(p)
+}
+
+func (m *Map) checkInputPath(p string) (string, error) {
+	if fs.ValidPath(p) && p != "." {
+		return p, nil
+	}
+	if !hasDotDot(p) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPath, p)
+	}
+	switch m.traversal {
+	case TraversalClean:
+		c := path.Clean(p)
+		if c == ".." || strings.HasPrefix(c, "../") {
+			return "", fmt.Errorf("%w: %q escapes the root", ErrTraversal, p)
+		}
+		return m.checkInputPath(c)
+	case TraversalAllow:
+		// The rest of the path model still applies.
+		if err := checkPathElems(p); err != nil {
+			return "", err
+		}
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrTraversal, p)
+	}
+}
+
+func checkPathElems(p string) error {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == "" || elem == "." {
+			return fmt.Errorf("%w: %q", ErrInvalidPath, p)
+		}
+	}
+	return nil
+}
+
+// outputLink applies the map's TraversalPolicy to a generated link. For
+// URLs, only the path component is considered.
+func (m *Map) outputLink(link string) (string, error) {
+	if m.traversal == TraversalAllow {
+		return link, nil
+	}
+	u, err := url.Parse(link)
+	if err != nil || !hasDotDot(u.Path) {
+		// Unparseable links are left for the caller to judge.
+		return link, nil
+	}
+	if m.traversal == TraversalReject {
+		return "", fmt.Errorf("%w: generated link %q", ErrTraversal, link)
+	}
+	c := path.Clean(u.Path)
+	if strings.HasSuffix(u.Path, "/") && c != "/" {
+		c += "/"
+	}
+	if c == ".." || strings.HasPrefix(c, "../") {
+		return "", fmt.Errorf("%w: generated link %q escapes the root", ErrTraversal, link)
+	}
+	u.Path, u.RawPath = c, ""
+	return u.String(), nil
+}
+
+// hasDotDot reports whether p has a ".." element.
+func hasDotDot(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
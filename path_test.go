@@ -0,0 +1,100 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePath(t *testing.T) {
+	cases := []struct {
+		in      string
+		expect  string
+		wantErr bool
+	}{
+		{in: "docs/intro.md", expect: "docs/intro.md"},
+		{in: "./docs/intro.md", expect: "docs/intro.md"},
+		{in: "/docs//intro.md", expect: "docs/intro.md"},
+		{in: "docs/./a/../intro.md", expect: "docs/intro.md"},
+		{in: "docs/", expect: "docs"},
+		{in: "", wantErr: true},
+		{in: "./", wantErr: true},
+		{in: "../intro.md", wantErr: true},
+		{in: "docs/../../intro.md", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := NormalizePath(c.in)
+		if c.wantErr {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("NormalizePath(%q) = %q, %v; want ErrInvalidPath", c.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.expect {
+			t.Errorf("NormalizePath(%q) = %q, %v; want %q", c.in, got, err, c.expect)
+		}
+	}
+}
+
+func TestEvaluateInvalidPath(t *testing.T) {
+	m, err := Parse(strings.NewReader("$1.md https://example.com/$1\n"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, p := range []string{"./a.md", "/a.md", "a//b.md", "../a.md", ""} {
+		if _, err := m.Evaluate(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Evaluate(%q) error = %v; want ErrInvalidPath", p, err)
+		}
+	}
+}
+
+func TestTraversalPolicy(t *testing.T) {
+	const src = "docs/$1 https://example.com/$1\nraw/$1 https://example.com/a/b/$1\n"
+	cases := []struct {
+		policy  TraversalPolicy
+		path    string
+		expect  string
+		wantErr bool
+	}{
+		{policy: TraversalReject, path: "docs/a/../b", wantErr: true},
+		{policy: TraversalReject, path: "docs/a/b", expect: "https://example.com/a/b"},
+		{policy: TraversalClean, path: "docs/a/../b", expect: "https://example.com/b"},
+		{policy: TraversalClean, path: "../docs/b", wantErr: true},
+		{policy: TraversalAllow, path: "docs/a/../b", expect: "https://example.com/a/../b"},
+		{policy: TraversalAllow, path: "docs//b", wantErr: true},
+	}
+	for _, c := range cases {
+		m, err := Parse(strings.NewReader(src), WithTraversalPolicy(c.policy))
+		if err != nil {
+			t.Fatalf("Parse() error: %v", err)
+		}
+		got, err := m.Evaluate(c.path)
+		if c.wantErr {
+			if !errors.Is(err, ErrInvalidPath) {
+				t.Errorf("policy %d: Evaluate(%q) = %q, %v; want ErrInvalidPath", c.policy, c.path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != c.expect {
+			t.Errorf("policy %d: Evaluate(%q) = %q, %v; want %q", c.policy, c.path, got, err, c.expect)
+		}
+	}
+}
+
+func TestTraversalPolicyOutput(t *testing.T) {
+	const src = "up/$1 https://example.com/a/$1/../secret\n"
+	m, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if _, err := m.Evaluate("up/x"); !errors.Is(err, ErrTraversal) {
+		t.Errorf("Evaluate() error = %v; want ErrTraversal", err)
+	}
+	m, err = Parse(strings.NewReader(src), WithTraversalPolicy(TraversalClean))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if got, err := m.Evaluate("up/x"); err != nil || got != "https://example.com/a/secret" {
+		t.Errorf("Evaluate() = %q, %v; want cleaned link", got, err)
+	}
+}
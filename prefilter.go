@@ -0,0 +1,175 @@
+package linkmap
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// prefilterHashes is the number of bits set per key; with prefilterBits
+// bits per key, about 1% of misses are false positives.
+const (
+	prefilterHashes = 7
+	prefilterBits   = 10
+)
+
+// WithPrefilter adds a Bloom filter of the literal text the map's inputs
+// start with, which lets paths that plainly match no rule, such as those
+// of crawlers probing for "wp-login.php", fail with ErrNoMatches without
+// trying any rule. A path which passes the filter is evaluated as usual,
+// so false positives cost no more than they would without it. The filter
+// has no effect on maps with a rule whose input does not start with
+// literal text, such as "$1.md", since any path may match it. Map.Stats
+// reports how well it works.
+func WithPrefilter() Option {
+	return func(m *Map) {
+		m.prefiltering = true
+	}
+}
+
+// A prefilter is a Bloom filter of the keys of a map's rules: the literal
+// text their inputs start with, up to and including the first slash.
+type prefilter struct {
+	bits []uint64
+	// lens holds the distinct lengths of keys, in increasing order.
+	lens []int
+	keys int
+
+	rejected, passed, falsePositives uint64
+}
+
+// PrefilterStats describes a map's prefilter; see WithPrefilter.
+type PrefilterStats struct {
+	// Keys is the number of distinct keys and Bits the size of the filter.
+	Keys int `json:"keys"`
+	Bits int `json:"bits"`
+	// Rejected counts paths rejected without trying any rule, Passed
+	// those which were not, and FalsePositives those of the latter which
+	// then matched no rule.
+	Rejected       uint64 `json:"rejected"`
+	Passed         uint64 `json:"passed"`
+	FalsePositives uint64 `json:"false_positives"`
+}
+
+// newPrefilter returns a prefilter for rules, or nil if any of them may
+// match any path.
+func newPrefilter(rules []Rule) *prefilter {
+	keys := make(map[string]bool)
+	for _, r := range rules {
+		key := literalPrefix(r.Input)
+		if i := strings.IndexByte(key, '/'); i >= 0 {
+			key = key[:i+1]
+		}
+		if key == "" {
+			return nil
+		}
+		keys[key] = true
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	f := &prefilter{bits: make([]uint64, (len(keys)*prefilterBits+63)/64), keys: len(keys)}
+	lens := make(map[int]bool)
+	for key := range keys {
+		f.add(hashString(key))
+		if !lens[len(key)] {
+			lens[len(key)] = true
+			f.lens = append(f.lens, len(key))
+		}
+	}
+	sort.Ints(f.lens)
+	return f
+}
+
+func (f *prefilter) add(h uint64) {
+	h = mix(h)
+	n := uint32(len(f.bits) * 64)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	for i := uint32(0); i < prefilterHashes; i++ {
+		b := (h1 + i*h2) % n
+		f.bits[b/64] |= 1 << (b % 64)
+	}
+}
+
+func (f *prefilter) has(h uint64) bool {
+	h = mix(h)
+	n := uint32(len(f.bits) * 64)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	for i := uint32(0); i < prefilterHashes; i++ {
+		b := (h1 + i*h2) % n
+		if f.bits[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rejects reports whether no rule can match p, recording the outcome.
+// A nil prefilter rejects nothing.
+func (f *prefilter) rejects(p string) bool {
+	if f == nil {
+		return false
+	}
+	// Hash p once, probing the filter at each key length along the way.
+	h := uint64(fnvOffset)
+	j := 0
+	for _, l := range f.lens {
+		if l > len(p) {
+			break
+		}
+		for ; j < l; j++ {
+			h = (h ^ uint64(p[j])) * fnvPrime
+		}
+		if f.has(h) {
+			atomic.AddUint64(&f.passed, 1)
+			return false
+		}
+	}
+	atomic.AddUint64(&f.rejected, 1)
+	return true
+}
+
+// miss records that a path which passed the filter matched no rule.
+func (f *prefilter) miss() {
+	if f != nil {
+		atomic.AddUint64(&f.falsePositives, 1)
+	}
+}
+
+func (f *prefilter) stats() *PrefilterStats {
+	if f == nil {
+		return nil
+	}
+	return &PrefilterStats{
+		Keys:           f.keys,
+		Bits:           len(f.bits) * 64,
+		Rejected:       atomic.LoadUint64(&f.rejected),
+		Passed:         atomic.LoadUint64(&f.passed),
+		FalsePositives: atomic.LoadUint64(&f.falsePositives),
+	}
+}
+
+// FNV-1a, which can be computed incrementally over a path's prefixes.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// mix spreads FNV's weak low bits over the whole hash, as MurmurHash3's
+// finalizer does.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func hashString(s string) uint64 {
+	h := uint64(fnvOffset)
+	for i := 0; i < len(s); i++ {
+		h = (h ^ uint64(s[i])) * fnvPrime
+	}
+	return h
+}
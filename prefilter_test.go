@@ -0,0 +1,80 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// prefilterMap returns a map of n rules under distinct directories.
+func prefilterMap(t testing.TB, n int, opts ...Option) *Map {
+	var src strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&src, "section%d/$1.md https://example.com/%d/$1\n", i, i)
+	}
+	src.WriteString("index.html https://example.com/\n")
+	m, err := Parse(strings.NewReader(src.String()), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestPrefilter(t *testing.T) {
+	m := prefilterMap(t, 100, WithPrefilter())
+	for _, p := range []string{"section7/a.md", "section99/b/c.md", "index.html"} {
+		if _, err := m.Evaluate(p); err != nil {
+			t.Errorf("Evaluate(%q): %v", p, err)
+		}
+	}
+	misses := 0
+	for i := 0; i < 1000; i++ {
+		p := fmt.Sprintf("probe%d/wp-login.php", i)
+		if _, err := m.Evaluate(p); !errors.Is(err, ErrNoMatches) {
+			t.Errorf("Evaluate(%q) = %v; want ErrNoMatches", p, err)
+		}
+		misses++
+	}
+	if _, err := m.Evaluate("section7/a.txt"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate(section7/a.txt) = %v; want ErrNoMatches", err)
+	}
+	s := m.Stats().Prefilter
+	if s == nil {
+		t.Fatal("Stats().Prefilter = nil")
+	}
+	if s.Keys != 101 || s.Rejected+s.Passed != uint64(misses+4) || s.Rejected < uint64(misses*95/100) || s.FalsePositives != s.Passed-3 {
+		t.Errorf("Stats().Prefilter = %+v", *s)
+	}
+
+	m, err := Parse(strings.NewReader("docs/$1.md https://example.com/$1\n$1.md https://example.com/other/$1\n"), WithPrefilter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Stats().Prefilter != nil {
+		t.Error("prefilter built for a map matching any path")
+	}
+	if link, err := m.Evaluate("wp-admin/a.md"); err != nil || link != "https://example.com/other/wp-admin/a" {
+		t.Errorf("Evaluate(wp-admin/a.md) = %q, %v", link, err)
+	}
+}
+
+func benchmarkMisses(b *testing.B, opts ...Option) {
+	m := prefilterMap(b, 1000, opts...)
+	paths := make([]string, 1024)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("wp-content/plugins/%d/readme.txt", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Lookup(paths[i%len(paths)])
+	}
+}
+
+func BenchmarkMisses(b *testing.B) {
+	benchmarkMisses(b)
+}
+
+func BenchmarkMissesPrefilter(b *testing.B) {
+	benchmarkMisses(b, WithPrefilter())
+}
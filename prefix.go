@@ -0,0 +1,78 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+)
+
+// restMarker ends templates which match a prefix of paths, or to which the
+// rest of the path is appended; see WithPrefixMatching.
+const restMarker = "*"
+
+// splat is the variable which captures the rest of the path, slashes
+// included, in inputs it ends:
+//
+//	news/$* https://example.com/blog/$*
+//
+// It is a variable like any other, named "*", so outputs can filter it,
+// and test it in conditionals.
+const splat = "$*"
+
+var errSplatLast = errors.New("linkmap: $* must end the input template")
+
+// WithPrefixMatching lets inputs end in '*' to match a prefix of paths
+// rather than the whole path, as proxies rewrite paths. An output ending in
+// '*' gets the rest of the path, after the prefix the input matched,
+// appended to it:
+//
+//	docs/* https://docs.example.com/*
+//	api/$version/* https://api.example.com/$version/*
+//	old-blog/* https://example.com/blog
+//
+// The first rule evaluates docs/guides/intro.md to
+// https://docs.example.com/guides/intro.md, and the last redirects
+// everything under old-blog to one page. The prefix ends at a path element
+// boundary, so docs* matches docs/a but not docsets/a, and is the longest
+// one the input matches. Without the option, '*' is literal text, and in
+// regular expressions and text/template outputs it keeps its meaning.
+func WithPrefixMatching() Option {
+	return func(m *Map) {
+		m.prefixMatching = true
+	}
+}
+
+// cutRestMarker returns s without the rest marker, and whether it had one,
+// when prefix matching is enabled. A '*' ending a splat is not a marker.
+func (m *Map) cutRestMarker(s string) (string, bool) {
+	if !m.prefixMatching || strings.HasSuffix(s, splat) {
+		return s, false
+	}
+	trimmed := strings.TrimSuffix(s, restMarker)
+	return trimmed, trimmed != s
+}
+
+// Prefix reports whether the template ends in '*': an input which matches
+// a prefix of paths, or an output the rest of the path is appended to.
+func (t Template) Prefix() bool {
+	return t.prefix
+}
+
+// matchRest is like Match, but also returns the rest of s after the prefix
+// a prefix template matched.
+func (t Template) matchRest(s string) (map[string]string, string, bool) {
+	if !t.prefix {
+		vars, ok := t.Match(s)
+		return vars, "", ok
+	}
+	// Try the longest prefix first, so that a trailing variable takes as
+	// many path elements as it can.
+	for i := len(s); i >= 0; i-- {
+		if i > 0 && i < len(s) && s[i] != '/' && s[i-1] != '/' {
+			continue
+		}
+		if vars, ok := t.segs.match(s[:i]); ok {
+			return vars, s[i:], true
+		}
+	}
+	return nil, "", false
+}
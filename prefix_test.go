@@ -0,0 +1,148 @@
+package linkmap
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const prefixSrc = `docs/intro.md https://example.com/start
+docs/* https://docs.example.com/*
+api/$version/* https://api.example.com/$version/*
+old-blog/* https://example.com/blog
+`
+
+func TestPrefixMatching(t *testing.T) {
+	m, err := Parse(strings.NewReader(prefixSrc), WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ in, want string }{
+		{"docs/guides/intro.md", "https://docs.example.com/guides/intro.md"},
+		{"docs/intro.md", "https://example.com/start"},
+		{"api/v2/users/1", "https://api.example.com/v2/users/1"},
+		{"old-blog/2019/hello", "https://example.com/blog"},
+	}
+	for _, tt := range tests {
+		if link, err := m.Evaluate(tt.in); link != tt.want || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.in, link, err, tt.want)
+		}
+	}
+	for _, in := range []string{"docs", "docsets/a", "api/v2"} {
+		if _, err := m.Evaluate(in); !errors.Is(err, ErrNoMatches) {
+			t.Errorf("Evaluate(%q) error = %v; want ErrNoMatches", in, err)
+		}
+	}
+	var b strings.Builder
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "docs/* https://docs.example.com/*\n") {
+		t.Errorf("WriteTo() = %q; want the '*' kept", b.String())
+	}
+	e := m.Explain("docs/a/b")
+	if len(e.Steps) == 0 || !e.Steps[len(e.Steps)-1].Matched {
+		t.Errorf("Explain(%q) = %+v; want the last step matched", "docs/a/b", e)
+	}
+}
+
+func TestPrefixMatchingElementBoundary(t *testing.T) {
+	m, err := Parse(strings.NewReader("docs* https://example.com/d*\n$1 https://example.com/other\n"), WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"docs":      "https://example.com/d",
+		"docs/a/b":  "https://example.com/d/a/b",
+		"docsets/a": "https://example.com/other",
+	} {
+		if link, err := m.Evaluate(in); link != want || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", in, link, err, want)
+		}
+	}
+}
+
+func TestPrefixMatchingDisabled(t *testing.T) {
+	m, err := Parse(strings.NewReader("docs/* https://docs.example.com/*\nre:^blog/.* https://example.com/blog\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := m.Evaluate("docs/*"); link != "https://docs.example.com/*" || err != nil {
+		t.Errorf("Evaluate(%q) = %q, %v; want the literal '*'", "docs/*", link, err)
+	}
+	if _, err := m.Evaluate("docs/a"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate(%q) error = %v; want ErrNoMatches", "docs/a", err)
+	}
+	m, err = Parse(strings.NewReader("re:^blog/.* https://example.com/blog\n"), WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := m.Evaluate("blog/a"); link != "https://example.com/blog" || err != nil {
+		t.Errorf("Evaluate(%q) = %q, %v; want the regular expression unchanged", "blog/a", link, err)
+	}
+}
+
+func TestPrefixMatchingFormats(t *testing.T) {
+	m, err := Parse(strings.NewReader(prefixSrc), WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := ParseBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := m.WriteTOML(&b); err != nil {
+		t.Fatal(err)
+	}
+	toml, err := ParseTOML(&b, WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range map[string]*Map{"binary": bin, "TOML": toml} {
+		if got.Hash() != m.Hash() {
+			t.Errorf("%s round trip changed the map", name)
+		}
+		if link, err := got.Evaluate("docs/a/b"); link != "https://docs.example.com/a/b" || err != nil {
+			t.Errorf("%s: Evaluate(%q) = %q, %v", name, "docs/a/b", link, err)
+		}
+	}
+}
+
+func TestSplat(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithPrefixMatching()}} {
+		m, err := Parse(strings.NewReader(`news/$* https://example.com/blog/$*
+shout/$* https://example.com/$*|upper
+docs/$lang/$* https://example.com/{if:$lang!=en}$lang/{end}$*
+`), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct{ in, want string }{
+			{"news/2020/04/hello.html", "https://example.com/blog/2020/04/hello.html"},
+			{"shout/a/b", "https://example.com/A/B"},
+			{"docs/en/guides/intro", "https://example.com/guides/intro"},
+			{"docs/fr/guides/intro", "https://example.com/fr/guides/intro"},
+		}
+		for _, tt := range tests {
+			if link, err := m.Evaluate(tt.in); link != tt.want || err != nil {
+				t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.in, link, err, tt.want)
+			}
+		}
+		_, vars, _ := m.EvaluateVars("news/a/b")
+		if vars["*"] != "a/b" {
+			t.Errorf("EvaluateVars(%q) = %v; want * bound to a/b", "news/a/b", vars)
+		}
+		if got := m.Rules()[0].Input.String(); got != "docs/$lang/$*" {
+			t.Errorf("Input.String() = %q; want docs/$lang/$*", got)
+		}
+	}
+	if _, err := Parse(strings.NewReader("news/$*/index.md https://example.com/$*\n")); !errors.Is(err, errSplatLast) {
+		t.Errorf("Parse() error = %v; want errSplatLast", err)
+	}
+}
@@ -0,0 +1,208 @@
+package linkmap
+
+import "fmt"
+
+// RenameVariable returns a copy of the template with the variable old
+// renamed to new. Names are given without the leading '$'.
+// Text/template outputs and regular expressions are returned unchanged.
+func (t Template) RenameVariable(old, new string) Template {
+	if t.text != nil || t.re != nil {
+		return t
+	}
+	return Template{segs: t.segs.rename(old, new), prefix: t.prefix}
+}
+
+// rename returns a copy of the template with the variable old renamed to
+// new, including in conditionals.
+func (tmpl template) rename(old, new string) template {
+	segs := tmpl.segments()
+	for i, s := range segs {
+		switch {
+		case s.typ == segmentTypeVariable && s.name() == old:
+			s.val = "$" + new + s.val[1+len(old):]
+		case s.typ == segmentTypeCond:
+			c := *s.cond
+			if c.name == old {
+				c.name = new
+			}
+			c.then, c.els = c.then.rename(old, new), c.els.rename(old, new)
+			s.cond, s.val = &c, c.String()
+		}
+		segs[i] = s
+	}
+	return makeTemplate(segs)
+}
+
+// RenameVariable returns a copy of the map in which the variable old is
+// renamed to new in every rule. An error is returned if new is not a valid
+// variable name, if a rule already uses new, if a text/template output may
+// use old, which cannot be renamed within it, or if a renamed template
+// would not parse back to the same structure.
+func (m *Map) RenameVariable(old, new string) (*Map, error) {
+	rules := make([]Rule, len(m.rules))
+	for i, r := range m.rules {
+		nr, err := m.renameRule(r, old, new)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = nr
+	}
+	return m.withRules(rules), nil
+}
+
+// RewriteOutputs returns a copy of the map with each rule's output
+// templates, including weighted ones, replaced by fn(output). An error is
+// returned if a rewritten output uses a variable its input does not bind,
+// or an extension group.
+func (m *Map) RewriteOutputs(fn func(Template) Template) (*Map, error) {
+	rules := make([]Rule, len(m.rules))
+	for i, r := range m.rules {
+		r = r.rewriteOutputs(fn)
+		if err := m.checkOutput(r); err != nil {
+			return nil, err
+		}
+		rules[i] = r
+	}
+	return m.withRules(rules), nil
+}
+
+// RenameVariable renames the variable old to new in every rule of the
+// file, as Map.RenameVariable does. Only lines containing the variable are
+// changed; comments and formatting are preserved.
+func (f *File) RenameVariable(old, new string) error {
+	m := newMap(f.opts)
+	return f.rewrite(func(r Rule) (Rule, error) {
+		return m.renameRule(r, old, new)
+	})
+}
+
+// RewriteOutputs replaces each rule's output template with fn(output), as
+// Map.RewriteOutputs does. Only lines whose output changes are rewritten.
+func (f *File) RewriteOutputs(fn func(Template) Template) error {
+	m := newMap(f.opts)
+	return f.rewrite(func(r Rule) (Rule, error) {
+		r = r.rewriteOutputs(fn)
+		return r, m.checkOutput(r)
+	})
+}
+
+// rewrite replaces each rule with fn(rule). If fn fails, the file is left
+// unchanged.
+func (f *File) rewrite(fn func(Rule) (Rule, error)) error {
+	lines := append([]Line(nil), f.Lines...)
+	for n, l := range lines {
+		if l.Kind != RuleLine {
+			continue
+		}
+		r, err := fn(l.Rule)
+		if err != nil {
+			return fmt.Errorf("linkmap: line %d: %w", n+1, err)
+		}
+		if r.String() != l.Rule.String() {
+			lines[n] = ruleLine(r)
+		}
+	}
+	f.Lines = lines
+	return nil
+}
+
+func (m *Map) renameRule(r Rule, old, new string) (Rule, error) {
+	if !validName(new) {
+		return Rule{}, fmt.Errorf("linkmap: invalid variable name %q", new)
+	}
+	if old == new {
+		return r, nil
+	}
+	for _, t := range append([]Template{r.Input}, r.outputs()...) {
+		if t.text != nil && t.text.uses(new) {
+			return Rule{}, fmt.Errorf("linkmap: rule %q already uses $%s", r, new)
+		}
+		for _, v := range t.Variables() {
+			if v == new {
+				return Rule{}, fmt.Errorf("linkmap: rule %q already uses $%s", r, new)
+			}
+		}
+	}
+	for _, t := range r.outputs() {
+		if t.text != nil && t.text.uses(old) {
+			return Rule{}, fmt.Errorf("linkmap: cannot rename $%s used by text/template output %q", old, t)
+		}
+	}
+	if r.Input.re != nil {
+		for _, v := range r.Input.Variables() {
+			if v == old {
+				return Rule{}, fmt.Errorf("linkmap: cannot rename $%s bound by regular expression %q", old, r.Input)
+			}
+		}
+	}
+	nr := r.rewriteOutputs(func(t Template) Template {
+		return t.RenameVariable(old, new)
+	})
+	nr.Input = r.Input.RenameVariable(old, new)
+	for _, t := range append([]Template{nr.Input}, nr.outputs()...) {
+		if t.text != nil || t.re != nil {
+			continue
+		}
+		parsed, err := parseTemplateWith(t.String(), m.kinds)
+		if err != nil || !parsed.equals(t.segs) {
+			return Rule{}, fmt.Errorf("linkmap: renaming $%s to $%s makes %q ambiguous", old, new, t)
+		}
+	}
+	return nr, nil
+}
+
+// checkOutput checks that the outputs of r only use variables bound by its
+// input.
+func (m *Map) checkOutput(r Rule) error {
+	bound := make(map[string]string)
+	for _, v := range r.Input.Variables() {
+		bound[v] = ""
+	}
+	m.bindAliases(bound)
+	var err error
+	for _, out := range r.outputs() {
+		out.segs.walk(func(s segment) {
+			if err != nil {
+				return
+			}
+			switch s.typ {
+			case segmentTypeVariable, segmentTypeCustom:
+				if _, ok := bound[s.name()]; !ok && !m.derived(s.name()) {
+					err = fmt.Errorf("linkmap: variable %s in %q is not bound by the input template", s.val, r)
+				}
+			case segmentTypeExtension:
+				err = fmt.Errorf("linkmap: extension group %s cannot be used in an output template", s.val)
+			}
+		})
+	}
+	return err
+}
+
+// withRules returns a copy of m, with the same configuration, whose rules
+// are rules in the given order.
+func (m *Map) withRules(rules []Rule) *Map {
+	nm := *m
+	nm.rules = rules
+	if nm.counting {
+		nm.counters = make([]uint64, len(rules))
+	}
+	if nm.prefiltering {
+		nm.prefilter = newPrefilter(rules)
+	}
+	return &nm
+}
+
+// validName reports whether name is a valid variable name.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	v := "$"
+	for _, r := range name {
+		if !continuesName(v, r) {
+			return false
+		}
+		v += string(r)
+	}
+	return true
+}
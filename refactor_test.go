@@ -0,0 +1,135 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenameVariable(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		old, new string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "rename",
+			src:  "docs/$1.md https://example.com/$1\nblog/$1/$2.md https://example.com/blog/$2?p=$1\n",
+			old:  "1", new: "slug",
+			want: "blog/$slug/$2.md https://example.com/blog/$2?p=$slug\ndocs/$slug.md https://example.com/$slug\n",
+		},
+		{
+			name: "collision",
+			src:  "docs/$1/$slug.md https://example.com/$1",
+			old:  "1", new: "slug",
+			wantErr: true,
+		},
+		{
+			name: "ambiguous",
+			src:  "docs/$1abc https://example.com/$1",
+			old:  "1", new: "slug",
+			wantErr: true,
+		},
+		{
+			name: "invalid name",
+			src:  "docs/$1.md https://example.com/$1",
+			old:  "1", new: "a-b",
+			wantErr: true,
+		},
+		{
+			name: "text output",
+			src:  "docs/$1/$2.md tmpl:https://example.com/{{index . \"2\"}}",
+			old:  "1", new: "slug",
+			want: "docs/$slug/$2.md tmpl:https://example.com/{{index . \"2\"}}\n",
+		},
+		{
+			name: "text output uses old",
+			src:  "docs/$1.md tmpl:https://example.com/{{index . \"1\" | lower}}",
+			old:  "1", new: "slug",
+			wantErr: true,
+		},
+		{
+			name: "text output uses new",
+			src:  "docs/$1/$2.md tmpl:https://example.com/{{.slug}}/{{index . \"2\"}}",
+			old:  "1", new: "slug",
+			wantErr: true,
+		},
+		{
+			name: "text output ranges over variables",
+			src:  "docs/$1.md tmpl:https://example.com/{{range .}}{{.}}{{end}}",
+			old:  "1", new: "slug",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(strings.NewReader(tt.src), WithTmplOutputs())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.RenameVariable(tt.old, tt.new)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenameVariable() error = %v; want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var b strings.Builder
+			got.WriteTo(&b)
+			if b.String() != tt.want {
+				t.Errorf("RenameVariable() =\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteOutputs(t *testing.T) {
+	src := "# Docs\ndocs/$1.md https://old.example.com/$1\n\nfoo.txt https://other.example.com/foo\n"
+	moveHost := func(t Template) Template {
+		s := strings.Replace(t.String(), "://old.example.com/", "://new.example.com/", 1)
+		nt, err := ParseTemplate(s)
+		if err != nil {
+			panic(err)
+		}
+		return nt
+	}
+
+	m, _ := Parse(strings.NewReader(src))
+	nm, err := m.RewriteOutputs(moveHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := nm.Evaluate("docs/a.md"); got != "https://new.example.com/a" {
+		t.Errorf("Evaluate() after rewrite = %q", got)
+	}
+	if got, _ := m.Evaluate("docs/a.md"); got != "https://old.example.com/a" {
+		t.Errorf("original map changed: Evaluate() = %q", got)
+	}
+
+	f, _ := ParseFile(strings.NewReader(src))
+	if err := f.RewriteOutputs(moveHost); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(src, "old.", "new.", 1); f.String() != want {
+		t.Errorf("File.RewriteOutputs() =\n%s\nwant\n%s", f, want)
+	}
+	if err := f.RenameVariable("1", "page"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(f.String(), "# Docs\ndocs/$page.md https://new.example.com/$page\n") {
+		t.Errorf("File.RenameVariable() =\n%s", f)
+	}
+
+	unbound := func(Template) Template {
+		t, _ := ParseTemplate("https://example.com/$9")
+		return t
+	}
+	if _, err := m.RewriteOutputs(unbound); err == nil {
+		t.Errorf("RewriteOutputs() with unbound variable succeeded")
+	}
+	before := f.String()
+	if err := f.RewriteOutputs(unbound); err == nil || f.String() != before {
+		t.Errorf("File.RewriteOutputs() = %v; file changed: %v", err, f.String() != before)
+	}
+}
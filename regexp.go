@@ -0,0 +1,62 @@
+package linkmap
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// rePrefix marks an input template written as a regular expression.
+const rePrefix = "re:"
+
+var errEmptyRegexp = errors.New("linkmap: empty regular expression")
+
+// A regexpInput is an input template written as a regular expression, for
+// the rare paths the template syntax cannot express, e.g.
+//
+//	re:docs/(v[0-9]+)/(.+)\.md https://example.com/$2?version=$1
+//
+// The expression must match the whole path. Its groups bind variables
+// named by their number and, for named groups, also by their name. Rules
+// with regular expression inputs are tried after all template rules.
+type regexpInput struct {
+	src string
+	re  *regexp.Regexp
+}
+
+func parseRegexpInput(src string) (*regexpInput, error) {
+	if src == "" {
+		return nil, errEmptyRegexp
+	}
+	re, err := regexp.Compile(`^(?:` + src + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	return &regexpInput{src: src, re: re}, nil
+}
+
+func (r *regexpInput) match(s string) (map[string]string, bool) {
+	m := r.re.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	vars := make(map[string]string, len(m)-1)
+	for i, name := range r.re.SubexpNames()[1:] {
+		vars[strconv.Itoa(i+1)] = m[i+1]
+		if name != "" {
+			vars[name] = m[i+1]
+		}
+	}
+	return vars, true
+}
+
+func (r *regexpInput) variables() []string {
+	var vars, names []string
+	for i, name := range r.re.SubexpNames()[1:] {
+		vars = append(vars, strconv.Itoa(i+1))
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return append(vars, names...)
+}
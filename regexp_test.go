@@ -0,0 +1,75 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegexpRules(t *testing.T) {
+	src := strings.Join([]string{
+		`re:docs/(v[0-9]+)/(.+)\.md https://example.com/$2?version=$1`,
+		`re:^(?P<year>[0-9]{4})-[0-9]{2}-(?P<slug>.+)\.md$ https://example.com/$year/$slug`,
+		`docs/$1/index.md https://example.com/docs/$1`,
+	}, "\n")
+	m, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"docs/v2/intro.md", "https://example.com/intro?version=v2"},
+		{"docs/v2/index.md", "https://example.com/docs/v2"},
+		{"docs/vx/intro.md", ""},
+		{"2024-01-hello.md", "https://example.com/2024/hello"},
+		{"x2024-01-hello.md", ""},
+	}
+	for _, tt := range tests {
+		got, _ := m.Evaluate(tt.path)
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+	if rules := m.Rules(); !strings.HasPrefix(rules[0].Input.String(), "docs/") {
+		t.Errorf("Rules()[0] = %v; want template rules before regular expressions", rules[0])
+	}
+	if diags := Analyze(src); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+}
+
+func TestParseRegexpRule(t *testing.T) {
+	tests := []struct {
+		line string
+		code string
+	}{
+		{`re:docs/(.+)\.md https://example.com/$1`, ""},
+		{`re:docs/(.+ https://example.com/$1`, CodeInvalidTemplate},
+		{`re: https://example.com/`, CodeInvalidTemplate},
+		{`re:docs/(.+)\.md https://example.com/$2`, CodeUnboundVariable},
+	}
+	for _, tt := range tests {
+		diags := Analyze(tt.line)
+		var code string
+		if len(diags) > 0 {
+			code = diags[0].Code
+		}
+		if code != tt.code {
+			t.Errorf("Analyze(%q) = %v; want %q", tt.line, diags, tt.code)
+		}
+		if _, err := Parse(strings.NewReader(tt.line)); (err != nil) != (tt.code == CodeInvalidTemplate) {
+			t.Errorf("Parse(%q) error = %v", tt.line, err)
+		}
+	}
+	tmpl, err := ParseTemplate(`re:(?P<a>x)(y)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(tmpl.Variables(), ","); got != "1,2,a" {
+		t.Errorf("Variables() = %q; want 1,2,a", got)
+	}
+	if got := tmpl.String(); got != `re:(?P<a>x)(y)` {
+		t.Errorf("String() = %q", got)
+	}
+}
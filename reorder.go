@@ -0,0 +1,69 @@
+package linkmap
+
+import "strings"
+
+// Reorder returns a copy of the map with hot rules tried first, to speed
+// up evaluation of skewed traffic. counts gives the observed matches of
+// rules, such as a CounterSnapshot's Rules or counts taken from access
+// logs; rules are identified by their String form, and rules without a
+// count are taken to be cold.
+//
+// Reordering never changes what a path evaluates to: a rule only moves
+// ahead of rules with the same number of input segments, which are tried
+// in the same pass, and only of those which it can be shown never to
+// match the same paths as, because their inputs start or end with
+// conflicting literal text, such as "docs/" and "blog/" or ".md" and
+// ".txt".
+func (m *Map) Reorder(counts []RuleCount) *Map {
+	hits := make(map[string]uint64, len(counts))
+	for _, c := range counts {
+		hits[c.Rule.String()] += c.Matches
+	}
+	type hot struct {
+		r    Rule
+		hits uint64
+	}
+	order := make([]hot, 0, len(m.rules))
+	// start is the index in order of the current group of rules with the
+	// same number of input segments.
+	start := 0
+	for _, r := range m.rules {
+		if start < len(order) && order[start].r.Input.segs.len() != r.Input.segs.len() {
+			start = len(order)
+		}
+		h := hits[r.String()]
+		i := len(order)
+		for i > start && order[i-1].hits < h && disjoint(order[i-1].r.Input, r.Input) {
+			i--
+		}
+		order = append(order, hot{})
+		copy(order[i+1:], order[i:])
+		order[i] = hot{r, h}
+	}
+	rules := make([]Rule, len(order))
+	for i, o := range order {
+		rules[i] = o.r
+	}
+	return m.withRules(rules)
+}
+
+// disjoint reports whether no path can match both a and b, as far as their
+// literal prefixes and suffixes tell.
+func disjoint(a, b Template) bool {
+	pa, pb := literalPrefix(a), literalPrefix(b)
+	if !strings.HasPrefix(pa, pb) && !strings.HasPrefix(pb, pa) {
+		return true
+	}
+	sa, sb := literalSuffix(a), literalSuffix(b)
+	return !strings.HasSuffix(sa, sb) && !strings.HasSuffix(sb, sa)
+}
+
+// literalSuffix returns the literal text every path matched by t ends
+// with. Paths matched by a prefix input end with anything.
+func literalSuffix(t Template) string {
+	n := t.segs.len()
+	if t.re != nil || t.prefix || n == 0 || t.segs.at(n-1).typ != segmentTypeString {
+		return ""
+	}
+	return t.segs.at(n - 1).val
+}
@@ -0,0 +1,59 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReorder(t *testing.T) {
+	const src = `docs/$1.md https://example.com/docs/$1
+blog/$1.md https://example.com/blog/$1
+d$1/x.md https://example.com/d/$1
+api/$1.txt https://example.com/api/$1
+$1.txt https://example.com/$1
+`
+	m, err := Parse(strings.NewReader(src), WithCounters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"api/a.txt", "api/b.txt", "api/c.txt", "dx/x.md", "dy/x.md", "blog/a.md"} {
+		m.Evaluate(p)
+	}
+	r := m.Reorder(m.SnapshotCounters().Rules)
+	var got []string
+	for _, rule := range r.Rules() {
+		got = append(got, rule.Input.String())
+	}
+	// d$1/x.md may match the paths of docs/$1.md, so stays behind it.
+	want := []string{"api/$1.txt", "blog/$1.md", "docs/$1.md", "d$1/x.md", "$1.txt"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Reorder() = %v; want %v", got, want)
+	}
+	for _, p := range []string{"api/a.txt", "blog/a.md", "docs/a.md", "docs/x.md", "dx/x.md", "a.txt"} {
+		want, _ := m.Evaluate(p)
+		if link, _ := r.Evaluate(p); link != want {
+			t.Errorf("Evaluate(%q) = %q; want %q", p, link, want)
+		}
+	}
+}
+
+func TestReorderPrefix(t *testing.T) {
+	const src = `x/$1/* https://prefix/$1/*
+x/$1.md https://md/$1
+`
+	m, err := Parse(strings.NewReader(src), WithCounters(), WithPrefixMatching())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"x/a.md", "x/b.md", "x/c.md"} {
+		m.Evaluate(p)
+	}
+	r := m.Reorder(m.SnapshotCounters().Rules)
+	// x/$1/* matches paths ending in .md too, so stays ahead.
+	for _, p := range []string{"x/a/b.md", "x/a.md"} {
+		want, _ := m.Evaluate(p)
+		if link, _ := r.Evaluate(p); link != want {
+			t.Errorf("Evaluate(%q) = %q; want %q", p, link, want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+)
+
+// A Report gathers the health of a map for people who review it without
+// reading terminal output, such as the owners of the content it links.
+// Every part is optional; WriteHTML leaves out the sections of those
+// missing.
+type Report struct {
+	// Title heads the page. It defaults to "Linkmap report".
+	Title string
+	// Coverage is the map's coverage of a file tree, from Map.Coverage.
+	Coverage *Coverage
+	// Diagnostics are problems found by Analyze, Linter or
+	// Coverage.Diagnostics.
+	Diagnostics []Diagnostic
+	// Diff is the change under review, from DiffMaps, and Impact the
+	// files it changes the link of, from Diff.Impact.
+	Diff   *Diff
+	Impact []FileChange
+}
+
+// WriteHTML writes the report as a single self-contained HTML page: its
+// styles are inline and it loads nothing, so that it can be archived as a
+// CI artifact or mailed as is.
+func (r *Report) WriteHTML(w io.Writer) error {
+	v := reportView{Title: r.Title, Coverage: r.Coverage, HasImpact: r.Impact != nil}
+	if v.Title == "" {
+		v.Title = "Linkmap report"
+	}
+	if c := r.Coverage; c != nil {
+		v.Ratio = fmt.Sprintf("%.1f%%", 100*c.Ratio())
+		for _, res := range c.Failed {
+			f := failureView{Path: res.Path, Message: errMessage(res.Err)}
+			if res.Rule != nil {
+				f.Rule = res.Rule.Origin()
+			}
+			v.Failed = append(v.Failed, f)
+		}
+	}
+	for _, d := range r.Diagnostics {
+		switch d.Severity {
+		case SeverityError:
+			v.Errors++
+		case SeverityWarning:
+			v.Warnings++
+		}
+		loc := d.File
+		if d.Range != (Range{}) {
+			loc = fmt.Sprintf("%s:%d", d.File, d.Range.Start.Line+1)
+		}
+		v.Diagnostics = append(v.Diagnostics, diagnosticView{
+			Severity: d.Severity.String(),
+			Code:     d.Code,
+			Location: loc,
+			Message:  d.Message,
+		})
+	}
+	if d := r.Diff; d != nil {
+		v.Diff = d
+		for _, c := range d.Modified {
+			m := modifiedView{Input: c.New.Input.String()}
+			for _, delta := range c.deltas() {
+				m.Deltas = append(m.Deltas, deltaView{What: delta.what, Old: delta.old, New: delta.new, Code: delta.code})
+			}
+			v.Modified = append(v.Modified, m)
+		}
+	}
+	for _, c := range r.Impact {
+		v.Impact = append(v.Impact, impactView{Path: c.Path, Old: resultText(c.Old), New: resultText(c.New)})
+	}
+	return reportTemplate.Execute(w, v)
+}
+
+// resultText describes what a result does to a file, for a report.
+func resultText(res Result) string {
+	switch {
+	case errors.Is(res.Err, ErrNoMatches):
+		return "no link"
+	case res.Err != nil:
+		return "error: " + errMessage(res.Err)
+	case res.Rule.Status != 0:
+		return fmt.Sprintf("%s (%d)", res.Link, res.Rule.Status)
+	}
+	return res.Link
+}
+
+// reportView is what the report template shows, prepared so that the
+// template only has to lay it out.
+type reportView struct {
+	Title       string
+	Coverage    *Coverage
+	Ratio       string
+	Failed      []failureView
+	Diagnostics []diagnosticView
+	Errors      int
+	Warnings    int
+	Diff        *Diff
+	Modified    []modifiedView
+	HasImpact   bool
+	Impact      []impactView
+}
+
+type failureView struct{ Path, Rule, Message string }
+
+type diagnosticView struct{ Severity, Code, Location, Message string }
+
+type modifiedView struct {
+	Input  string
+	Deltas []deltaView
+}
+
+type deltaView struct {
+	What, Old, New string
+	Code           bool
+}
+
+type impactView struct{ Path, Old, New string }
+
+var reportTemplate = htmltemplate.Must(htmltemplate.New("report").Funcs(htmltemplate.FuncMap{"plural": plural}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; color: #1f2328; max-width: 72rem; margin: 2rem auto; padding: 0 1rem; }
+h1 { font-size: 1.6rem; }
+h2 { font-size: 1.25rem; border-bottom: 1px solid #d0d7de; padding-bottom: .25rem; margin-top: 2rem; }
+.cards { display: flex; flex-wrap: wrap; gap: 1rem; }
+.card { border: 1px solid #d0d7de; border-radius: 6px; padding: .75rem 1rem; min-width: 12rem; }
+.card b { display: block; font-size: 1.5rem; }
+table { border-collapse: collapse; width: 100%; margin: .5rem 0; }
+th, td { text-align: left; vertical-align: top; padding: .35rem .6rem; border-bottom: 1px solid #eaeef2; }
+th { background: #f6f8fa; }
+code { font: 13px ui-monospace, monospace; background: #f6f8fa; padding: .1rem .3rem; border-radius: 4px; word-break: break-all; }
+.error { color: #cf222e; font-weight: 600; }
+.warning { color: #9a6700; font-weight: 600; }
+.info, .hint { color: #0969da; }
+.ok { color: #1a7f37; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="cards">
+{{- with .Coverage}}
+<div class="card"><b>{{$.Ratio}}</b>of {{plural .Files "file"}} linked</div>
+{{- end}}
+<div class="card"><b>{{len .Diagnostics}}</b>problems: {{plural .Errors "error"}}, {{plural .Warnings "warning"}}</div>
+{{- with .Diff}}
+<div class="card"><b>{{len .Added}} / {{len .Removed}} / {{len .Modified}}</b>rules added / removed / modified</div>
+{{- end}}
+{{- if .HasImpact}}
+<div class="card"><b>{{len .Impact}}</b>{{if eq (len .Impact) 1}}file{{else}}files{{end}} with a different link</div>
+{{- end}}
+</div>
+{{- with .Coverage}}
+
+<h2>Coverage</h2>
+{{- if and (not .Unlinked) (not $.Failed)}}
+<p class="ok">Every file is linked.</p>
+{{- end}}
+{{- with .Unlinked}}
+<h3>Files matching no rule</h3>
+<table>
+<tr><th>File</th></tr>
+{{- range .}}
+<tr><td><code>{{.}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with $.Failed}}
+<h3>Files whose link could not be built</h3>
+<table>
+<tr><th>File</th><th>Rule</th><th>Problem</th></tr>
+{{- range .}}
+<tr><td><code>{{.Path}}</code></td><td>{{.Rule}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+
+<h2>Problems</h2>
+{{- with .Diagnostics}}
+<table>
+<tr><th>Severity</th><th>Location</th><th>Problem</th><th>Code</th></tr>
+{{- range .}}
+<tr><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Location}}</td><td>{{.Message}}</td><td><code>{{.Code}}</code></td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="ok">No problems found.</p>
+{{- end}}
+{{- with .Diff}}
+
+<h2>Changes</h2>
+{{- if .Empty}}
+<p>No rules changed.</p>
+{{- end}}
+{{- with .Added}}
+<h3>Added</h3>
+<table>
+<tr><th>Input</th><th>Output</th></tr>
+{{- range .}}
+<tr><td><code>{{.Input}}</code></td><td><code>{{.Output}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Removed}}
+<h3>Removed</h3>
+<table>
+<tr><th>Input</th><th>Output</th></tr>
+{{- range .}}
+<tr><td><code>{{.Input}}</code></td><td><code>{{.Output}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with $.Modified}}
+<h3>Modified</h3>
+<table>
+<tr><th>Input</th><th>Changes</th></tr>
+{{- range .}}
+<tr><td><code>{{.Input}}</code></td><td>
+{{- range $i, $d := .Deltas}}{{if $i}}<br>{{end}}{{$d.What}}
+{{- if or $d.Old $d.New}} {{if $d.Code}}<code>{{or $d.Old "none"}}</code> → <code>{{or $d.New "none"}}</code>{{else}}{{$d.Old}} → {{$d.New}}{{end}}{{else}} changed{{end}}
+{{- end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- if .HasImpact}}
+
+<h2>Impact</h2>
+{{- with .Impact}}
+<table>
+<tr><th>File</th><th>Before</th><th>After</th></tr>
+{{- range .}}
+<tr><td><code>{{.Path}}</code></td><td>{{.Old}}</td><td>{{.New}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No file gets a different link.</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,75 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReportHTML(t *testing.T) {
+	old, err := ParseTOML(strings.NewReader(diffOldSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := ParseTOML(strings.NewReader(diffNewSrc), WithSource("links.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"docs/a.md":        {},
+		"blog/a.md":        {},
+		"<script>alert(1)": {},
+		"news/b.md":        {},
+		"news/c.md":        {},
+	}
+	cov, err := new.Coverage(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := DiffMaps(old, new)
+	impact, err := d.Impact(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Report{
+		Coverage:    cov,
+		Diagnostics: append(Analyze("a/$1.md b/$2\n", WithSource("links")), cov.Diagnostics()...),
+		Diff:        d,
+		Impact:      impact,
+	}
+	var b strings.Builder
+	if err := r.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, want := range []string{
+		"<title>Linkmap report</title>",
+		"<b>80.0%</b>of 5 files linked",
+		"<b>2</b>problems: 1 error, 1 warning",
+		"<b>1 / 1 / 1</b>rules added / removed / modified",
+		"<b>3</b>files with a different link",
+		"<code>&lt;script&gt;alert(1)</code>",
+		`<td class="error">error</td><td>links:1</td>`,
+		"<td><code>news/$1.md</code></td><td><code>https://example.com/news/$1</code></td>",
+		"output <code>https://example.com/blog/$1</code> → <code>https://blog.example.com/$1</code><br>status 301 → 308",
+		"<td><code>blog/a.md</code></td><td>https://example.com/blog/a (301)</td><td>https://blog.example.com/a (308)</td>",
+		"<td><code>news/b.md</code></td><td>no link</td><td>https://example.com/news/b</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report does not contain %q:\n%s", want, html)
+		}
+	}
+	for _, external := range []string{"<script", "<link", "src="} {
+		if strings.Contains(html, external) {
+			t.Errorf("report contains %q", external)
+		}
+	}
+
+	b.Reset()
+	if err := (&Report{Title: "Docs"}).WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if html := b.String(); !strings.Contains(html, "<h1>Docs</h1>") || !strings.Contains(html, "No problems found.") || strings.Contains(html, "Coverage") {
+		t.Errorf("empty report:\n%s", html)
+	}
+}
@@ -0,0 +1,57 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// destPrefix starts a destination segment, "$dest(key)".
+const destPrefix = "$dest("
+
+var errInputDest = errors.New("linkmap: destinations can only be used in output templates")
+
+// A Resolver resolves opaque destination keys. Output templates may refer
+// to a destination as $dest(key), e.g. "$dest(wiki)/$1", so that sensitive
+// URLs are kept out of the linkmap and substituted at evaluation time. The
+// resolved value is inserted verbatim and is subject to the same checks as
+// the rest of the link.
+type Resolver interface {
+	Resolve(key string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(key string) (string, error)
+
+// Resolve calls f(key).
+func (f ResolverFunc) Resolve(key string) (string, error) {
+	return f(key)
+}
+
+// MapResolver is a Resolver backed by a map. Unknown keys are an error.
+type MapResolver map[string]string
+
+// Resolve returns the destination for key.
+func (r MapResolver) Resolve(key string) (string, error) {
+	dest, ok := r[key]
+	if !ok {
+		return "", fmt.Errorf("unknown destination %q", key)
+	}
+	return dest, nil
+}
+
+// WithResolver sets the resolver for $dest(key) segments. Without one,
+// evaluating a rule which uses a destination fails.
+func WithResolver(r Resolver) Option {
+	return func(m *Map) {
+		m.resolver = r
+	}
+}
+
+// hasDest reports whether the template contains a destination segment.
+func (tmpl template) hasDest() bool {
+	var dest bool
+	tmpl.walk(func(s segment) {
+		dest = dest || s.typ == segmentTypeDest
+	})
+	return dest
+}
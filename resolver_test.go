@@ -0,0 +1,77 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	src := "docs/$1.md $dest(wiki)/$1\nsecret/$1 $dest(vault)?id=$1\n"
+	r := MapResolver{"wiki": "https://wiki.internal/pages"}
+	m, err := Parse(strings.NewReader(src), WithResolver(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"docs/intro.md", "https://wiki.internal/pages/intro", false},
+		{"secret/x", "", true},
+	}
+	for _, tt := range tests {
+		got, err := m.Evaluate(tt.path)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	m, err = Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Evaluate("docs/intro.md"); err == nil {
+		t.Errorf("Evaluate() without resolver succeeded")
+	}
+
+	boom := errors.New("boom")
+	m, _ = Parse(strings.NewReader(src), WithResolver(ResolverFunc(func(string) (string, error) { return "", boom })))
+	if _, err := m.Evaluate("docs/intro.md"); !errors.Is(err, boom) {
+		t.Errorf("Evaluate() = %v; want resolver error", err)
+	}
+}
+
+func TestParseDest(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr bool
+	}{
+		{"docs/$1 $dest(wiki)/$1", false},
+		{"docs/$1 $dest(a.b-c)$1", false},
+		{"$dest(wiki)/$1 https://example.com/$1", true},
+		{"docs/$1 $dest(wiki/$1", true},
+		{"docs/$1 $dest()/$1", true},
+		{"docs/$1 $dest($1)", true},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.line))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v; want error %v", tt.line, err, tt.wantErr)
+		}
+		if tt.wantErr != (len(Analyze(tt.line)) > 0) {
+			t.Errorf("Analyze(%q) = %v", tt.line, Analyze(tt.line))
+		}
+	}
+	tmpl, err := ParseTemplate("$dest(wiki)/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tmpl.AST(); !got.equal(AST{Dest("wiki"), Literal("/"), Var("1")}) {
+		t.Errorf("AST() = %v", got)
+	}
+	if got := tmpl.String(); got != "$dest(wiki)/$1" {
+		t.Errorf("String() = %q", got)
+	}
+}
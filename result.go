@@ -0,0 +1,272 @@
+package linkmap
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// A Result is the outcome of evaluating a single path.
+type Result struct {
+	// Path is the path as passed in.
+	Path string
+	// Link is the generated link, or empty if Err is set.
+	Link string
+	// Rule is the rule which matched, or nil if none did. It is shared
+	// with the Map and must not be modified.
+	Rule *Rule
+	// Vars holds the variables bound by matching Rule, keyed by name
+	// without the leading '$'.
+	Vars map[string]string
+	// Err is ErrNoMatches if no rule matched, or the error encountered
+	// while building the link.
+	Err error
+}
+
+// Lookup evaluates a file path against the map, returning the link along
+// with the rule which produced it and the variables it bound.
+func (m *Map) Lookup(fpath string) Result {
+	return m.lookup(nil, fpath)
+}
+
+// lookup is Lookup in ctx, if not nil.
+func (m *Map) lookup(ctx *EvalContext, fpath string) Result {
+	if m.urlInputs && isURL(fpath) {
+		return m.lookupURL(ctx, fpath)
+	}
+	var fsys fs.FS
+	if ctx != nil {
+		fsys = ctx.FS
+	}
+	p, err := m.inputPath(fpath)
+	if err != nil {
+		return Result{Path: fpath, Err: err}
+	}
+	if m.ignore.Match(p) {
+		return Result{Path: fpath, Err: fmt.Errorf("%w: %q", ErrIgnored, fpath)}
+	}
+	p, rfs, err := m.rebase(p, fsys)
+	if err == nil && m.prefilter.rejects(p) {
+		err = ErrNoMatches
+	} else if err == nil {
+		res, done := m.scan(ctx, fpath, func(*Rule) (string, bool) { return p, true }, rfs)
+		if done {
+			return res
+		}
+		m.prefilter.miss()
+		err = res.Err
+	}
+	if m.next != nil {
+		return m.next.lookup(ctx, fpath)
+	}
+	return Result{Path: fpath, Err: err}
+}
+
+// scan tries the rules in order, matching each against at(rule) unless it
+// reports false, and returns the result of the first which matches, or of
+// running out of budget, with done set. Otherwise, the result's error is
+// ErrNoMatches, or the first *VarError of a rule which matched but bound an
+// invalid variable.
+func (m *Map) scan(ctx *EvalContext, fpath string, at func(*Rule) (string, bool), fsys fs.FS) (res Result, done bool) {
+	b := m.budget()
+	var invalid error
+	for i := range m.rules {
+		if err := b.check(fpath, i); err != nil {
+			return Result{Path: fpath, Err: err}, true
+		}
+		in, ok := at(&m.rules[i])
+		if !ok {
+			continue
+		}
+		res, ok := m.try(&m.rules[i], in, fsys, ctx)
+		if ok {
+			m.count(i)
+			res.Path = fpath
+			return res, true
+		}
+		if res.Err != nil && invalid == nil {
+			invalid = res.Err
+		}
+	}
+	if err := b.expired(fpath, len(m.rules)); err != nil {
+		return Result{Path: fpath, Err: err}, true
+	}
+	if invalid != nil {
+		return Result{Path: fpath, Err: invalid}, false
+	}
+	return Result{Path: fpath, Err: ErrNoMatches}, false
+}
+
+// EvaluateAll returns a Result for every rule which matches fpath, in
+// evaluation order. The first element, if any, is what Lookup returns. If
+// fpath is invalid, or outside the map's base with no map to fall back to,
+// the only result carries the error; if the evaluation budget runs out,
+// the last result carries a *BudgetError. If no rule matches, the results
+// are those of the map it falls back to, if any, as for Lookup.
+func (m *Map) EvaluateAll(fpath string) []Result {
+	if m.urlInputs && isURL(fpath) {
+		u, err := parseURLInput(fpath)
+		if err != nil {
+			return []Result{{Path: fpath, Err: err}}
+		}
+		return m.evaluateAllURL(fpath, u)
+	}
+	p, err := m.inputPath(fpath)
+	if err != nil {
+		return []Result{{Path: fpath, Err: err}}
+	}
+	if m.ignore.Match(p) {
+		return []Result{{Path: fpath, Err: fmt.Errorf("%w: %q", ErrIgnored, fpath)}}
+	}
+	if p, _, err = m.rebase(p, nil); err == nil {
+		if results := m.all(nil, fpath, func(*Rule) (string, bool) { return p, true }); len(results) > 0 {
+			return results
+		}
+	}
+	if m.next != nil {
+		return m.next.EvaluateAll(fpath)
+	}
+	if err != nil {
+		return []Result{{Path: fpath, Err: err}}
+	}
+	return nil
+}
+
+// evaluateAllURL is EvaluateAll for a URL input.
+func (m *Map) evaluateAllURL(rawURL string, u urlInput) []Result {
+	var ctx *EvalContext
+	if results := m.all(ctx.with("query", u.query), rawURL, u.form); len(results) > 0 || m.next == nil {
+		return results
+	}
+	return m.next.evaluateAllURL(rawURL, u)
+}
+
+// all returns a Result for every rule which matches at(rule) in ctx, as
+// described for EvaluateAll.
+func (m *Map) all(ctx *EvalContext, fpath string, at func(*Rule) (string, bool)) []Result {
+	var results []Result
+	b := m.budget()
+	for i := range m.rules {
+		if err := b.check(fpath, i); err != nil {
+			return append(results, Result{Path: fpath, Err: err})
+		}
+		in, ok := at(&m.rules[i])
+		if !ok {
+			continue
+		}
+		if res, ok := m.try(&m.rules[i], in, nil, ctx); ok {
+			res.Path = fpath
+			results = append(results, res)
+		}
+	}
+	if err := b.expired(fpath, len(m.rules)); err != nil {
+		results = append(results, Result{Path: fpath, Err: err})
+	}
+	return results
+}
+
+// Batch evaluates each of paths, returning results in the same order.
+func (m *Map) Batch(paths []string) []Result {
+	results := make([]Result, len(paths))
+	for i, p := range paths {
+		results[i] = m.Lookup(p)
+	}
+	return results
+}
+
+// EvaluateFS evaluates every regular file in fsys, in lexical order, as
+// LookupFile does. Paths which match no rule are included with Err set to
+// ErrNoMatches; ignored paths are left out.
+func (m *Map) EvaluateFS(fsys fs.FS) ([]Result, error) {
+	var results []Result
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && m.ignore.match(p, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			results = append(results, m.LookupFile(fsys, p))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: walking filesystem: %w", err)
+	}
+	return results, nil
+}
+
+// try evaluates fpath against a single rule. If fsys is not nil, file
+// variables used by the rule's output are bound from the file in fsys. A
+// rule which matched but bound a variable failing validation is reported
+// as not matching, with the *VarError in the Result.
+func (m *Map) try(r *Rule, fpath string, fsys fs.FS, ctx *EvalContext) (Result, bool) {
+	vars, rest, ok := r.Input.matchRest(fpath)
+	if !ok {
+		return Result{}, false
+	}
+	m.bindAliases(vars)
+	resolver := m.resolver
+	if ctx != nil {
+		ctx.bind(vars)
+		if ctx.Resolver != nil {
+			resolver = ctx.Resolver
+		}
+	}
+	if !r.matchConditions(vars, fpath) {
+		return Result{}, false
+	}
+	if err := m.validate(r, vars); err != nil {
+		return Result{Path: fpath, Rule: r, Vars: vars, Err: err}, false
+	}
+	out := r.output(ctx, m.now)
+	bindPathVars(vars, out, fpath)
+	res := Result{Path: fpath, Rule: r, Vars: vars}
+	if fsys != nil {
+		if err := m.bindFileVars(vars, out, fsys, fpath); err != nil {
+			res.Err = errorAt(*r, err)
+			return res, true
+		}
+	}
+	link, err := out.expand(vars, resolver)
+	if err != nil {
+		res.Err = errorAt(*r, fmt.Errorf("failed to apply template: %w", err))
+		return res, true
+	}
+	if out.prefix {
+		link += rest
+	}
+	if link, err = m.outputLink(link); err != nil {
+		res.Err = errorAt(*r, err)
+		return res, true
+	}
+	if m.allow != nil {
+		if err := m.allow.check(out, link); err != nil {
+			res.Err = errorAt(*r, err)
+			return res, true
+		}
+	}
+	res.Link = link
+	return res, true
+}
+
+// matchConditions reports whether the rule's conditions hold for the
+// variables bound by matching fpath.
+func (r *Rule) matchConditions(vars map[string]string, fpath string) bool {
+	for _, c := range r.Conditions {
+		val, ok := vars[c.Var]
+		if !ok {
+			if v, isPathVar := pathVars[c.Var]; isPathVar {
+				val = v(fpath)
+			}
+		}
+		if ok, _ := path.Match(c.Pattern, val); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLookup(t *testing.T) {
+	m, err := Parse(strings.NewReader(
+		"foo/$1.{md,mdx} https://example.com/posts/$1\n" +
+			"bar/$1/baz/$2.{html} https://example.com/$1/$2\n",
+	))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	res := m.Lookup("bar/abc/baz/xyz.html")
+	if res.Err != nil {
+		t.Fatalf("Lookup() error: %v", res.Err)
+	}
+	if res.Link != "https://example.com/abc/xyz" {
+		t.Errorf("Lookup().Link = %q", res.Link)
+	}
+	if res.Rule == nil || res.Rule.Line != 2 {
+		t.Errorf("Lookup().Rule = %v; want rule on line 2", res.Rule)
+	}
+	if res.Vars["1"] != "abc" || res.Vars["2"] != "xyz" {
+		t.Errorf("Lookup().Vars = %v", res.Vars)
+	}
+	if res := m.Lookup("nope.md"); !errors.Is(res.Err, ErrNoMatches) || res.Rule != nil {
+		t.Errorf("Lookup(miss) = %+v; want ErrNoMatches", res)
+	}
+}
+
+func TestEvaluateAll(t *testing.T) {
+	m, err := Parse(strings.NewReader(
+		"docs/$1.md https://example.com/docs/$1\n" +
+			"docs/intro.md https://example.com/\n" +
+			"$1.md https://example.com/$1\n",
+	))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if got := m.EvaluateAll("docs/intro.md"); len(got) != 3 {
+		t.Errorf("EvaluateAll() = %v; want 3 results", got)
+	}
+}
+
+func TestEvaluateAllChained(t *testing.T) {
+	fsys := fstest.MapFS{
+		".linkmap":     {Data: []byte("$1/$2.md https://example.com/$1/$2\n")},
+		"web/.linkmap": {Data: []byte("#linkmap:fallthrough\ndocs/$1.md https://web.example.com/$1\ndocs/$1.md https://web.example.com/v2/$1\n")},
+	}
+	m, err := DiscoverFor(fsys, "web/docs/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		links []string
+	}{
+		{"web/docs/a.md", []string{"https://web.example.com/a", "https://web.example.com/v2/a"}},
+		{"web/a.md", []string{"https://example.com/web/a"}},
+		{"other/a.md", []string{"https://example.com/other/a"}},
+		{"a.txt", nil},
+	}
+	for _, tt := range tests {
+		got := m.EvaluateAll(tt.path)
+		if len(got) != len(tt.links) {
+			t.Errorf("EvaluateAll(%q) = %v; want %d results", tt.path, got, len(tt.links))
+			continue
+		}
+		for i, link := range tt.links {
+			if got[i].Link != link || got[i].Err != nil {
+				t.Errorf("EvaluateAll(%q)[%d] = %q, %v; want %q", tt.path, i, got[i].Link, got[i].Err, link)
+			}
+		}
+		if res := m.Lookup(tt.path); len(got) > 0 && res.Link != got[0].Link {
+			t.Errorf("Lookup(%q) = %q; EvaluateAll()[0] = %q", tt.path, res.Link, got[0].Link)
+		}
+	}
+}
+
+func TestEvaluateFS(t *testing.T) {
+	m, err := Parse(strings.NewReader("docs/$1.md https://example.com/docs/$1\n"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	fsys := fstest.MapFS{
+		"docs/a.md":  {},
+		"docs/b.md":  {},
+		"README.txt": {},
+	}
+	results, err := m.EvaluateFS(fsys)
+	if err != nil {
+		t.Fatalf("EvaluateFS() error: %v", err)
+	}
+	want := []string{"", "https://example.com/docs/a", "https://example.com/docs/b"}
+	if len(results) != len(want) {
+		t.Fatalf("EvaluateFS() = %v; want %d results", results, len(want))
+	}
+	for i, res := range results {
+		if res.Link != want[i] {
+			t.Errorf("EvaluateFS()[%d].Link = %q; want %q", i, res.Link, want[i])
+		}
+	}
+}
+
+func TestEvaluateVars(t *testing.T) {
+	m, err := Parse(strings.NewReader("blog/$1/$2.md https://example.com/$2\n"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	link, vars, err := m.EvaluateVars("blog/en/hello.md")
+	if err != nil {
+		t.Fatalf("EvaluateVars() error: %v", err)
+	}
+	if link != "https://example.com/hello" || vars["1"] != "en" || vars["2"] != "hello" {
+		t.Errorf("EvaluateVars() = %q, %v", link, vars)
+	}
+}
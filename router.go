@@ -0,0 +1,156 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MapFile is the name of the linkmap file of a directory, as found by
+// LoadRouter.
+const MapFile = ".linkmap"
+
+// A Router evaluates paths against the maps of the directories enclosing
+// them, so that each package of a monorepo can keep its own linkmap. A
+// directory's map sees paths relative to the directory: with a map for
+// "web", "web/docs/a.md" is evaluated as "docs/a.md". The map for the root,
+// if any, is registered as ".".
+//
+// Maps are scoped like .gitignore files, with the closest taking
+// precedence:
+//
+//   - The map of the closest enclosing directory with one is tried first,
+//     so a child's rules override its parents'.
+//   - If none of its rules matches, the path matches nothing, unless the
+//     map falls through (see WithFallthrough); then the map of the next
+//     closest directory is tried, and so on.
+//   - Only a path which matches no rule falls through. Invalid and ignored
+//     paths, exhausted budgets, and rules which match but fail to build a
+//     link are reported by the map which saw them.
+//
+// DiscoverFor follows the same rules. A Router is safe for concurrent use
+// once all maps have been added.
+type Router struct {
+	maps map[string]*Map
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{maps: make(map[string]*Map)}
+}
+
+// LoadRouter parses every MapFile in fsys and returns a Router with a map
+// for each directory which has one.
+func LoadRouter(fsys fs.FS, opts ...Option) (*Router, error) {
+	r := NewRouter()
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != MapFile {
+			return nil
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		m, err := Parse(f, append(append([]Option(nil), opts...), WithSource(p))...)
+		if err != nil {
+			return sourceError(p, err)
+		}
+		return r.Add(path.Dir(p), m)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Add registers the map for dir, a directory in the form of a path passed
+// to Evaluate, or "." for the root.
+func (r *Router) Add(dir string, m *Map) error {
+	if !fs.ValidPath(dir) {
+		return fmt.Errorf("%w: %q", ErrInvalidPath, dir)
+	}
+	if _, ok := r.maps[dir]; ok {
+		return fmt.Errorf("linkmap: directory %q already has a map", dir)
+	}
+	r.maps[dir] = m
+	return nil
+}
+
+// Dirs returns the directories with a map, in sorted order.
+func (r *Router) Dirs() []string {
+	dirs := make([]string, 0, len(r.maps))
+	for dir := range r.maps {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Route returns the closest directory enclosing fpath which has a map,
+// along with the map and fpath relative to the directory.
+func (r *Router) Route(fpath string) (dir string, m *Map, rel string, ok bool) {
+	for dir := path.Dir(fpath); ; dir = path.Dir(dir) {
+		if m, ok := r.maps[dir]; ok {
+			return dir, m, relPath(dir, fpath), true
+		}
+		if dir == "." || dir == "/" {
+			return "", nil, "", false
+		}
+	}
+}
+
+// relPath returns fpath relative to dir, which encloses it.
+func relPath(dir, fpath string) string {
+	if dir == "." {
+		return fpath
+	}
+	return strings.TrimPrefix(fpath, dir+"/")
+}
+
+// Lookup evaluates fpath against the maps of its enclosing directories,
+// as described for Router. The Result's Path is fpath as passed in. If no
+// directory enclosing fpath has a map, the error wraps ErrNoMatches.
+func (r *Router) Lookup(fpath string) Result {
+	if !fs.ValidPath(fpath) || fpath == "." {
+		return Result{Path: fpath, Err: fmt.Errorf("%w: %q", ErrInvalidPath, fpath)}
+	}
+	dir, m, rel, ok := r.Route(fpath)
+	if !ok {
+		return Result{Path: fpath, Err: fmt.Errorf("%w: no linkmap for %q", ErrNoMatches, fpath)}
+	}
+	for {
+		res := m.Lookup(rel)
+		res.Path = fpath
+		if dir == "." || !m.fallsThrough || !errors.Is(res.Err, ErrNoMatches) {
+			return res
+		}
+		if dir, m, rel, ok = r.Route(dir); !ok {
+			return res
+		}
+		// Route made dir relative to the parent; fpath is evaluated.
+		rel = relPath(dir, fpath)
+	}
+}
+
+// Evaluate is like Lookup but returns only the link.
+func (r *Router) Evaluate(fpath string) (string, error) {
+	res := r.Lookup(fpath)
+	return res.Link, res.Err
+}
+
+// WithFallthrough makes paths which match none of the map's rules fall
+// through to the map of the enclosing directory, when the map is used by a
+// Router or found by DiscoverFor. In a linkmap file, a comment line
+// "#linkmap:fallthrough" has the same effect.
+func WithFallthrough() Option {
+	return func(m *Map) {
+		m.fallsThrough = true
+	}
+}
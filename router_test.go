@@ -0,0 +1,130 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouter(t *testing.T) {
+	fsys := fstest.MapFS{
+		".linkmap":             {Data: []byte("$1.md https://example.com/$1\n")},
+		"web/.linkmap":         {Data: []byte("docs/$1.md https://web.example.com/$1\n")},
+		"web/admin/.linkmap":   {Data: []byte("$1.md https://admin.example.com/$1\n")},
+		"tools/lint/.linkmap":  {Data: []byte("$1 https://lint.example.com/$1\n")},
+		"web/docs/a.md":        {},
+		"web/admin/guide/b.md": {},
+	}
+	r, err := LoadRouter(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Dirs(), []string{".", "tools/lint", "web", "web/admin"}; len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Errorf("Dirs() = %v; want %v", got, want)
+	}
+	tests := []struct {
+		path, dir, link string
+		err             error
+	}{
+		{"readme.md", ".", "https://example.com/readme", nil},
+		{"web/docs/a.md", "web", "https://web.example.com/a", nil},
+		{"web/admin/guide/b.md", "web/admin", "https://admin.example.com/guide/b", nil},
+		{"tools/lint/x/y", "tools/lint", "https://lint.example.com/x/y", nil},
+		{"tools/build/x.md", ".", "https://example.com/tools/build/x", nil},
+		// The closest map decides, even if it has no match.
+		{"web/readme.md", "web", "", ErrNoMatches},
+		{"../x.md", "", "", ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		res := r.Lookup(tt.path)
+		if res.Link != tt.link || !errors.Is(res.Err, tt.err) || res.Path != tt.path {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.path, res.Link, res.Err, tt.link, tt.err)
+		}
+		if tt.dir == "" {
+			continue
+		}
+		if dir, _, _, ok := r.Route(tt.path); !ok || dir != tt.dir {
+			t.Errorf("Route(%q) = %q, %v; want %q", tt.path, dir, ok, tt.dir)
+		}
+	}
+
+	empty := NewRouter()
+	if _, err := empty.Evaluate("a.md"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate() without maps = %v; want ErrNoMatches", err)
+	}
+	if err := empty.Add("/abs", New(nil)); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Add(/abs) = %v; want ErrInvalidPath", err)
+	}
+	if err := r.Add("web", New(nil)); err == nil {
+		t.Error("Add() replaced a map")
+	}
+	if _, err := LoadRouter(fstest.MapFS{"a/.linkmap": {Data: []byte("bad\n")}}); err == nil || !strings.Contains(err.Error(), "a/.linkmap:1") {
+		t.Errorf("LoadRouter() = %v; want error at a/.linkmap:1", err)
+	}
+}
+
+func TestRouterFallthrough(t *testing.T) {
+	fsys := fstest.MapFS{
+		".linkmap":         {Data: []byte("$1.md https://example.com/$1\n")},
+		"web/.linkmap":     {Data: []byte("#linkmap:fallthrough\ndocs/$1.md https://web.example.com/$1\n")},
+		"web/api/.linkmap": {Data: []byte("$1.json https://api.example.com/$1\n")},
+		"web/app/.linkmap": {Data: []byte("# linkmap:fallthrough is only a comment\n$1.ts https://app.example.com/$1\n")},
+	}
+	r, err := LoadRouter(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path, link string
+		err        error
+	}{
+		// The child's rule wins.
+		{"web/docs/a.md", "https://web.example.com/a", nil},
+		// No rule in web matches, so the root's map is tried.
+		{"web/readme.md", "https://example.com/web/readme", nil},
+		// web/api does not fall through.
+		{"web/api/readme.md", "", ErrNoMatches},
+		{"web/app/readme.md", "", ErrNoMatches},
+	}
+	for _, tt := range tests {
+		link, err := r.Evaluate(tt.path)
+		if link != tt.link || !errors.Is(err, tt.err) {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, %v", tt.path, link, err, tt.link, tt.err)
+		}
+		m, err := DiscoverFor(fsys, tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err = m.Evaluate(tt.path)
+		if link != tt.link || !errors.Is(err, tt.err) {
+			t.Errorf("DiscoverFor(%q).Evaluate() = %q, %v; want %q, %v", tt.path, link, err, tt.link, tt.err)
+		}
+	}
+	// Results name the file of the rule which matched.
+	for fpath, want := range map[string]string{"web/docs/a.md": "web/.linkmap:2", "web/readme.md": ".linkmap:1"} {
+		if res := r.Lookup(fpath); res.Rule == nil || res.Rule.Origin() != want {
+			t.Errorf("Lookup(%q).Rule = %v; want rule at %s", fpath, res.Rule, want)
+		}
+		m, _ := DiscoverFor(fsys, fpath)
+		if res := m.Lookup(fpath); res.Rule == nil || res.Rule.Origin() != want {
+			t.Errorf("DiscoverFor(%q).Lookup().Rule = %v; want rule at %s", fpath, res.Rule, want)
+		}
+	}
+
+	// Without a parent map, the child's result stands.
+	r = NewRouter()
+	r.Add("web", New(nil, WithFallthrough()))
+	if _, err := r.Evaluate("web/a.md"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate() = %v; want ErrNoMatches", err)
+	}
+	// Only unmatched paths fall through.
+	bad, _ := Parse(strings.NewReader("$1.md $dest(x)/$1\n"), WithFallthrough())
+	root, _ := Parse(strings.NewReader("$1 https://example.com/$1\n"))
+	r = NewRouter()
+	r.Add("web", bad)
+	r.Add(".", root)
+	if _, err := r.Evaluate("web/a.md"); err == nil || errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate() of a failing rule = %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// sarifLog is a SARIF 2.1.0 log, as far as diagnostics need it.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// WriteSARIF writes diags as a SARIF 2.1.0 log, as consumed by GitHub code
+// scanning and other CI tools. Diagnostics are located by their File, which
+// should be relative to the repository root; parse with WithSource to set
+// it. Diagnostics without a File have no location. Ranges are written in
+// the one-based lines and columns SARIF uses, and an empty range at the
+// start of a file, as for an unlinked file, refers to the whole file.
+func WriteSARIF(w io.Writer, diags []Diagnostic) error {
+	codes := make(map[string]int)
+	for _, d := range diags {
+		codes[d.Code] = 0
+	}
+	rules := make([]sarifRule, 0, len(codes))
+	for code := range codes {
+		rules = append(rules, sarifRule{ID: code})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	for i, r := range rules {
+		codes[r.ID] = i
+	}
+
+	results := make([]sarifResult, 0, len(diags))
+	for _, d := range diags {
+		res := sarifResult{
+			RuleID:    d.Code,
+			RuleIndex: codes[d.Code],
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{Text: d.Message},
+		}
+		if d.File != "" {
+			res.Locations = []sarifLocation{sarifLocationOf(d.File, d.Range)}
+		}
+		for i, rel := range d.Related {
+			if rel.File == "" {
+				continue
+			}
+			loc := sarifLocationOf(rel.File, rel.Range)
+			loc.ID, loc.Message = i+1, &sarifMessage{Text: rel.Message}
+			res.RelatedLocations = append(res.RelatedLocations, loc)
+		}
+		results = append(results, res)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "linkmap",
+				InformationURI: "https://github.com/operandinc/linkmap",
+				Rules:          rules,
+			}},
+			// Linkmaps are almost always ASCII, where byte offsets and
+			// code points agree.
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	})
+}
+
+func sarifLocationOf(file string, r Range) sarifLocation {
+	loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: file},
+	}}
+	if r != (Range{}) {
+		loc.PhysicalLocation.Region = &sarifRegion{
+			StartLine:   r.Start.Line + 1,
+			StartColumn: r.Start.Column + 1,
+			EndLine:     r.End.Line + 1,
+			EndColumn:   r.End.Column + 1,
+		}
+	}
+	return loc
+}
+
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
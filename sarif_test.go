@@ -0,0 +1,46 @@
+package linkmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	diags := Analyze("foo/$1.md https://a.com/$1\nfoo/$1.md https://b.com/$2\n", WithSource("docs/.linkmap"))
+	diags = append(diags, Diagnostic{File: "img/a.png", Severity: SeverityInfo, Code: CodeUnlinkedFile, Message: "file matches no rule"})
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, diags); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("WriteSARIF() = %s", buf.Bytes())
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 3 || len(run.Results) != 3 {
+		t.Fatalf("WriteSARIF() = %s", buf.Bytes())
+	}
+	for _, res := range run.Results {
+		if run.Tool.Driver.Rules[res.RuleIndex].ID != res.RuleID {
+			t.Errorf("result %s has ruleIndex %d", res.RuleID, res.RuleIndex)
+		}
+	}
+	dup := run.Results[1]
+	if dup.RuleID != CodeDuplicateRule || dup.Level != "warning" {
+		t.Errorf("Results[1] = %+v; want a duplicate-rule warning", dup)
+	}
+	want := sarifRegion{StartLine: 2, StartColumn: 1, EndLine: 2, EndColumn: 10}
+	if loc := dup.Locations[0]; loc.PhysicalLocation.ArtifactLocation.URI != "docs/.linkmap" || *loc.PhysicalLocation.Region != want {
+		t.Errorf("Results[1].Locations = %+v; want docs/.linkmap %+v", loc, want)
+	}
+	if rel := dup.RelatedLocations; len(rel) != 1 || rel[0].PhysicalLocation.Region.StartLine != 1 {
+		t.Errorf("Results[1].RelatedLocations = %+v", rel)
+	}
+	if res := run.Results[2]; res.Level != "note" || res.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("Results[2] = %+v; want a note about the whole file", res)
+	}
+}
@@ -0,0 +1,58 @@
+package linkmap
+
+import (
+	"fmt"
+	"time"
+)
+
+// A ScheduledOutput is an output which a rule builds links with from a
+// cutover time on, so that coordinated domain migrations take effect on
+// time without a precisely timed deploy of a new map.
+type ScheduledOutput struct {
+	At     time.Time `json:"at"`
+	Output Template  `json:"output"`
+}
+
+// WithClock sets the clock scheduled outputs (see Rule.Scheduled) are
+// checked against, in place of time.Now, e.g. to test a cutover or to
+// preview a map as it will evaluate at a later time.
+func WithClock(now func() time.Time) Option {
+	return func(m *Map) {
+		m.clock = now
+	}
+}
+
+// now returns the time by the map's clock.
+func (m *Map) now() time.Time {
+	if m.clock != nil {
+		return m.clock()
+	}
+	return time.Now()
+}
+
+// scheduled returns the scheduled output of the rule in effect at now: the
+// one with the latest cutover time not after it, or false if there is
+// none.
+func (r *Rule) scheduled(now time.Time) (Template, bool) {
+	var (
+		out   Template
+		at    time.Time
+		found bool
+	)
+	for _, s := range r.Scheduled {
+		if !s.At.After(now) && (!found || s.At.After(at)) {
+			out, at, found = s.Output, s.At, true
+		}
+	}
+	return out, found
+}
+
+// parseCutover parses the cutover time of a scheduled output, in RFC 3339
+// format.
+func parseCutover(s string) (time.Time, error) {
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cutover time %q is not in RFC 3339 format", s)
+	}
+	return at, nil
+}
@@ -0,0 +1,74 @@
+package linkmap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const scheduledSrc = `[[rule]]
+from = "docs/$slug.md"
+to = "https://old.example.com/$slug"
+
+[rule.weighted]
+"https://canary.example.com/$slug" = 100
+
+[rule.scheduled]
+"2024-06-01T09:00:00Z" = "https://docs.example.com/$slug"
+"2024-07-01T09:00:00Z" = "https://example.com/docs/$slug"
+`
+
+func TestScheduledOutputs(t *testing.T) {
+	var now time.Time
+	m, err := ParseTOML(strings.NewReader(scheduledSrc), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ now, want string }{
+		{"2024-06-01T08:59:59Z", "https://canary.example.com/intro"},
+		{"2024-06-01T09:00:00Z", "https://docs.example.com/intro"},
+		{"2024-06-01T12:00:00+02:00", "https://docs.example.com/intro"},
+		{"2024-07-02T00:00:00Z", "https://example.com/docs/intro"},
+	}
+	for _, tt := range tests {
+		if now, err = time.Parse(time.RFC3339, tt.now); err != nil {
+			t.Fatal(err)
+		}
+		if link, err := m.Evaluate("docs/intro.md"); link != tt.want || err != nil {
+			t.Errorf("at %s: Evaluate() = %q, %v; want %q", tt.now, link, err, tt.want)
+		}
+	}
+}
+
+func TestScheduledOutputsFormats(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(scheduledSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := m.WriteTOML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != scheduledSrc {
+		t.Errorf("WriteTOML() = %q; want %q", b.String(), scheduledSrc)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := ParseBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin.Hash() != m.Hash() {
+		t.Error("binary round trip changed the map")
+	}
+	later := strings.Replace(scheduledSrc, "2024-07-01", "2024-07-02", 1)
+	if m2, err := ParseTOML(strings.NewReader(later)); err != nil || m2.Hash() == m.Hash() {
+		t.Errorf("Hash() ignores cutover times (error %v)", err)
+	}
+	if _, err := ParseTOML(strings.NewReader(strings.Replace(scheduledSrc, "2024-07-01T09:00:00Z", "July 1st", 1))); err == nil || !strings.Contains(err.Error(), "RFC 3339") {
+		t.Errorf("ParseTOML() error = %v; want an invalid cutover time", err)
+	}
+}
@@ -0,0 +1,79 @@
+package linkmap
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CodeEmbeddedSecret is reported by Linter for output templates which
+// appear to contain credentials.
+const CodeEmbeddedSecret = "embedded-secret"
+
+var (
+	userinfoPassword = regexp.MustCompile(`://[^/?#@\s]*:[^/?#@\s\x00]+@`)
+	tokenPatterns    = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}`)},
+		{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+		{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+		{"JSON Web Token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.`)},
+	}
+	// secretParams are query parameter names which usually carry secrets.
+	secretParams = []string{
+		"token", "access_token", "auth", "auth_token", "api_key", "apikey",
+		"key", "secret", "client_secret", "password", "passwd", "pwd",
+		"sig", "signature", "x-amz-signature", "private_token",
+	}
+)
+
+// findSecret returns a description of the credential which appears to be
+// embedded in an output template, or "" if there is none. Only literal text
+// is considered; values substituted from variables are not secrets of the
+// linkmap.
+func findSecret(out template) string {
+	// Each segment other than literal text is masked with a NUL, which no
+	// pattern accepts as part of a secret.
+	var b strings.Builder
+	for _, seg := range out.segments() {
+		if seg.typ == segmentTypeString {
+			b.WriteString(seg.val)
+		} else {
+			b.WriteByte(0)
+		}
+	}
+	src := b.String()
+	if userinfoPassword.MatchString(src) {
+		return "URL contains a password"
+	}
+	for _, lit := range strings.Split(src, "\x00") {
+		for _, p := range tokenPatterns {
+			if p.re.MatchString(lit) {
+				return "output contains what looks like a " + p.name
+			}
+		}
+	}
+	_, query, ok := strings.Cut(src, "?")
+	if !ok {
+		return ""
+	}
+	query, _, _ = strings.Cut(query, "#")
+	for _, kv := range strings.Split(query, "&") {
+		k, v, _ := strings.Cut(kv, "=")
+		if name, err := url.QueryUnescape(k); err == nil {
+			k = name
+		}
+		if v == "" || strings.IndexByte(v, 0) >= 0 {
+			continue
+		}
+		for _, p := range secretParams {
+			if strings.EqualFold(k, p) {
+				return "query parameter " + k + " contains a literal secret"
+			}
+		}
+	}
+	return ""
+}
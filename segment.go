@@ -0,0 +1,46 @@
+package linkmap
+
+import "regexp"
+
+// A SegmentMatcher matches a custom segment kind. Custom segments are
+// written as <kind> in templates, e.g. "posts/<date>/$1.md", and must be
+// registered with WithSegment. In an input template, a custom segment
+// binds the text it matched to a variable named after its kind; in an
+// output template, it is replaced by that variable. A kind used more than
+// once in an input template must match the same text each time.
+type SegmentMatcher interface {
+	// Match returns the length of the prefix of s matched by the segment,
+	// or -1 if it does not match.
+	Match(s string) int
+}
+
+// SegmentMatcherFunc adapts a function to the SegmentMatcher interface.
+type SegmentMatcherFunc func(s string) int
+
+// Match calls f(s).
+func (f SegmentMatcherFunc) Match(s string) int {
+	return f(s)
+}
+
+// RegexpSegment returns a SegmentMatcher which matches re at the start of
+// the remaining input.
+func RegexpSegment(re *regexp.Regexp) SegmentMatcher {
+	anchored := regexp.MustCompile(`^(?:` + re.String() + `)`)
+	return SegmentMatcherFunc(func(s string) int {
+		loc := anchored.FindStringIndex(s)
+		if loc == nil {
+			return -1
+		}
+		return loc[1]
+	})
+}
+
+// WithSegment registers a custom segment kind for use in templates.
+func WithSegment(kind string, m SegmentMatcher) Option {
+	return func(mp *Map) {
+		if mp.kinds == nil {
+			mp.kinds = make(map[string]SegmentMatcher)
+		}
+		mp.kinds[kind] = m
+	}
+}
@@ -0,0 +1,72 @@
+package linkmap
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCustomSegment(t *testing.T) {
+	date := WithSegment("date", RegexpSegment(regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)))
+	const src = "posts/<date>-$1.md https://example.com/<date>/$1\n" +
+		"posts/$1.md https://example.com/undated/$1\n"
+	m, err := Parse(strings.NewReader(src), date)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	cases := []struct {
+		path   string
+		expect string
+	}{
+		{path: "posts/2022-01-31-hello.md", expect: "https://example.com/2022-01-31/hello"},
+		{path: "posts/hello.md", expect: "https://example.com/undated/hello"},
+		{path: "posts/2022-1-31-hello.md", expect: "https://example.com/undated/2022-1-31-hello"},
+	}
+	for _, c := range cases {
+		if got, err := m.Evaluate(c.path); err != nil || got != c.expect {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", c.path, got, err, c.expect)
+		}
+	}
+}
+
+func TestCustomSegmentAfterVariable(t *testing.T) {
+	hex := WithSegment("hex", RegexpSegment(regexp.MustCompile(`[0-9a-f]{8}`)))
+	m, err := Parse(strings.NewReader("$1<hex>.js https://cdn.example.com/$1.js?v=<hex>\n"), hex)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if got, err := m.Evaluate("appdeadbeef.js"); err != nil || got != "https://cdn.example.com/app.js?v=deadbeef" {
+		t.Errorf("Evaluate() = %q, %v", got, err)
+	}
+	if _, err := m.Evaluate("app.js"); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("Evaluate(no hex) error = %v; want ErrNoMatches", err)
+	}
+}
+
+func TestCustomSegmentErrors(t *testing.T) {
+	for _, src := range []string{
+		"posts/<date>.md https://example.com/\n",
+		"posts/<date.md https://example.com/\n",
+		"posts/$<date>.md https://example.com/\n",
+	} {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded; want error", src)
+		}
+	}
+}
+
+func TestCustomSegmentBuild(t *testing.T) {
+	uuid := WithSegment("uuid", RegexpSegment(regexp.MustCompile(`[0-9a-f-]{36}`)))
+	tmpl, err := ParseTemplate("items/<uuid>.json", uuid)
+	if err != nil {
+		t.Fatalf("ParseTemplate() error: %v", err)
+	}
+	const id = "123e4567-e89b-12d3-a456-426614174000"
+	if got, err := tmpl.Build(map[string]string{"uuid": id}); err != nil || got != "items/"+id+".json" {
+		t.Errorf("Build() = %q, %v", got, err)
+	}
+	if _, err := tmpl.Build(map[string]string{"uuid": "nope"}); err == nil {
+		t.Errorf("Build(invalid uuid) succeeded; want error")
+	}
+}
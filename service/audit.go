@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// An AuditSink stores the records of an audit log.
+type AuditSink interface {
+	// Write stores entries, in order.
+	Write(ctx context.Context, entries []LogEntry) error
+}
+
+const (
+	// auditBuffer is the number of entries an Auditor queues before Log
+	// blocks.
+	auditBuffer = 1024
+	// auditBatch is the most entries written to a sink at once.
+	auditBatch = 100
+	// auditInterval is how long an entry may wait for its batch to fill.
+	auditInterval = time.Second
+)
+
+// An Auditor records evaluations to an AuditSink, for a compliance record
+// of which redirects were served. Its Log method can be used as
+// Service.Log. Entries are written in the background, in batches, so that
+// a slow sink does not delay evaluations until its queue fills; Close
+// writes the remaining entries.
+type Auditor struct {
+	Sink AuditSink
+	// SampleRate is the fraction of evaluations recorded, between 0 and
+	// 1. If zero, every evaluation is recorded.
+	SampleRate float64
+	// Always, if set, selects entries which are recorded regardless of
+	// sampling, such as those for regulated content.
+	Always func(LogEntry) bool
+	// OnError, if set, is called with errors writing to Sink. The entries
+	// which failed are dropped.
+	OnError func(error)
+
+	once   sync.Once
+	mu     sync.RWMutex
+	closed bool
+	ch     chan LogEntry
+	done   chan struct{}
+	// random returns a number in [0, 1) for sampling.
+	random func() float64
+}
+
+// Log records e, subject to sampling. It may be called concurrently.
+// Entries logged after Close are dropped.
+func (a *Auditor) Log(e LogEntry) {
+	a.once.Do(a.start)
+	if !a.sampled(e) {
+		return
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	a.ch <- e
+}
+
+func (a *Auditor) sampled(e LogEntry) bool {
+	if a.SampleRate == 0 || a.SampleRate >= 1 || a.Always != nil && a.Always(e) {
+		return true
+	}
+	return a.random() < a.SampleRate
+}
+
+func (a *Auditor) start() {
+	a.ch = make(chan LogEntry, auditBuffer)
+	a.done = make(chan struct{})
+	if a.random == nil {
+		a.random = rand.Float64
+	}
+	go a.run()
+}
+
+// run writes queued entries to the sink until the queue is closed.
+func (a *Auditor) run() {
+	defer close(a.done)
+	t := time.NewTicker(auditInterval)
+	defer t.Stop()
+	var batch []LogEntry
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.Sink.Write(context.Background(), batch); err != nil && a.OnError != nil {
+			a.OnError(fmt.Errorf("linkmap: writing audit log: %w", err))
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case e, ok := <-a.ch:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, e); len(batch) == auditBatch {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+// Close writes the entries still queued and stops the Auditor.
+func (a *Auditor) Close() error {
+	a.once.Do(a.start)
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.ch)
+	}
+	a.mu.Unlock()
+	<-a.done
+	return nil
+}
+
+// A FileSink writes audit entries to W as JSON lines.
+type FileSink struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Write implements AuditSink.
+func (s *FileSink) Write(_ context.Context, entries []LogEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.W.Write(buf.Bytes())
+	return err
+}
+
+// A SQLSink inserts audit entries into a database, such as SQLite, in a
+// transaction per batch. The database driver is registered by the
+// program, so that this package depends on none.
+type SQLSink struct {
+	DB *sql.DB
+	// Insert is the statement inserting an entry, given the time, map,
+	// path, rule, origin, link, status, code, error and latency in
+	// milliseconds, in that order. If empty, DefaultInsert is used.
+	Insert string
+}
+
+// DefaultInsert is the statement used by SQLSink if none is set. It uses
+// "?" placeholders, as SQLite and MySQL do.
+const DefaultInsert = `INSERT INTO linkmap_audit
+	(time, map, path, rule, origin, link, status, code, error, latency_ms)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// Write implements AuditSink.
+func (s *SQLSink) Write(ctx context.Context, entries []LogEntry) (err error) {
+	insert := s.Insert
+	if insert == "" {
+		insert = DefaultInsert
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		_, err = stmt.ExecContext(ctx, e.Time.UTC(), e.Map, e.Path, e.Rule, e.Origin, e.Link,
+			e.Status, string(e.Code), e.Error, float64(e.Latency)/float64(time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// A WebhookSink POSTs each batch of audit entries to URL as a JSON array.
+type WebhookSink struct {
+	URL string
+	// Header is added to every request, e.g. for authorization.
+	Header http.Header
+	// Client is used for requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Write implements AuditSink.
+func (s *WebhookSink) Write(ctx context.Context, entries []LogEntry) error {
+	buf, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c := s.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New("POST " + s.URL + ": " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	batches [][]LogEntry
+	err     error
+}
+
+func (s *memorySink) Write(_ context.Context, entries []LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]LogEntry(nil), entries...))
+	return s.err
+}
+
+func (s *memorySink) paths() []string {
+	var paths []string
+	for _, b := range s.batches {
+		for _, e := range b {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+func TestAuditor(t *testing.T) {
+	sink := &memorySink{}
+	a := &Auditor{Sink: sink}
+	s := testService()
+	s.Log = a.Log
+	for i := 0; i < 250; i++ {
+		s.Evaluate(context.Background(), &EvaluateRequest{Map: "docs", Path: "docs/a.md"})
+	}
+	a.Close()
+	if n := len(sink.paths()); n != 250 || len(sink.batches) < 3 {
+		t.Errorf("recorded %d entries in %d batches; want 250 in at least 3", n, len(sink.batches))
+	}
+	a.Log(LogEntry{Path: "late"})
+	if n := len(sink.paths()); n != 250 {
+		t.Errorf("recorded %d entries after Close; want 250", n)
+	}
+}
+
+func TestAuditorSampling(t *testing.T) {
+	sink := &memorySink{err: errors.New("disk full")}
+	var errs []error
+	rolls := []float64{0.05, 0.5, 0.09, 0.99}
+	a := &Auditor{
+		Sink:       sink,
+		SampleRate: 0.1,
+		Always:     func(e LogEntry) bool { return strings.HasPrefix(e.Path, "regulated/") },
+		OnError:    func(err error) { errs = append(errs, err) },
+		random: func() float64 {
+			r := rolls[0]
+			rolls = rolls[1:]
+			return r
+		},
+	}
+	for _, p := range []string{"a", "b", "regulated/x", "c", "d"} {
+		a.Log(LogEntry{Path: p})
+	}
+	a.Close()
+	if got := strings.Join(sink.paths(), ","); got != "a,regulated/x,c" {
+		t.Errorf("recorded %s; want a,regulated/x,c", got)
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError called with %v; want one error", errs)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := &FileSink{W: &buf}
+	if err := s.Write(context.Background(), []LogEntry{{Path: "a"}, {Path: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"path":"b"`) {
+		t.Errorf("FileSink wrote %q", buf.String())
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer x" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	s := &WebhookSink{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer x"}}}
+	if err := s.Write(context.Background(), []LogEntry{{Path: "a", Code: CodeNoMatch}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["path"] != "a" || got[0]["code"] != "NO_MATCH" {
+		t.Errorf("webhook received %v", got)
+	}
+	s.Header = nil
+	if err := s.Write(context.Background(), []LogEntry{{Path: "a"}}); err == nil {
+		t.Error("Write() ignored a 403")
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/operandinc/linkmap"
+)
+
+// A Client calls the HTTP API served by Service.Handler, and for Sandbox,
+// Service.AdminHandler. Maps pulled with
+// Pull are held locally and evaluated without a round trip; paths they
+// cannot evaluate, and maps which have not been pulled, fall back to the
+// server. A Client is safe for concurrent use. Its configuration fields
+// must not be changed after first use.
+type Client struct {
+	// BaseURL is the URL the API is served at, e.g.
+	// "http://linkmap.internal:8080".
+	BaseURL string
+	// AdminURL is the URL the admin API is served at, e.g.
+	// "http://localhost:8081". If empty, BaseURL is used.
+	AdminURL string
+	// HTTPClient is used for requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Options configure local maps. Configuration which is not part of a
+	// map's rules, such as WithResolver or WithSegment, must be repeated
+	// here for local evaluation to agree with the server.
+	Options []linkmap.Option
+
+	mu    sync.RWMutex
+	local map[string]*linkmap.Map
+	// etags holds the ETags of the local maps.
+	etags map[string]string
+}
+
+// Evaluate evaluates a single path, locally if the map has been pulled and
+// evaluation succeeds there or fails for a reason the server would share,
+// and otherwise on the server. As with Service.Evaluate, evaluation failures
+// are reported in the response; the error is set if the server cannot be
+// reached or responds unexpectedly.
+func (c *Client) Evaluate(ctx context.Context, req *EvaluateRequest) (*EvaluateResponse, error) {
+	if resp, ok := c.evaluateLocal(req); ok {
+		return resp, nil
+	}
+	var resp EvaluateResponse
+	if err := c.call(ctx, http.MethodPost, "/evaluate", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// evaluateLocal evaluates req against a pulled map. It reports false if
+// the map has not been pulled or the server should be asked instead.
+func (c *Client) evaluateLocal(req *EvaluateRequest) (*EvaluateResponse, bool) {
+	c.mu.RLock()
+	m, ok := c.local[req.Map]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	resp := response(m.Lookup(req.Path))
+	switch resp.Code {
+	case CodeInternal, CodeBudgetExceeded:
+		// Perhaps a resolver or limit which only the server has.
+		return nil, false
+	}
+	return resp, true
+}
+
+// EvaluateBatch evaluates many paths in a single request to the server,
+// returning responses in the same order. Local maps are not consulted.
+func (c *Client) EvaluateBatch(ctx context.Context, reqs []EvaluateRequest) ([]*EvaluateResponse, error) {
+	var resp BatchResponse
+	if err := c.call(ctx, http.MethodPost, "/batch", BatchRequest{Requests: reqs}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) != len(reqs) {
+		return nil, fmt.Errorf("linkmap: batch returned %d responses for %d requests", len(resp.Responses), len(reqs))
+	}
+	return resp.Responses, nil
+}
+
+// Sandbox evaluates paths against a candidate map on the server, as
+// Service.Sandbox does.
+func (c *Client) Sandbox(ctx context.Context, req *SandboxRequest) (*SandboxResponse, error) {
+	var resp SandboxResponse
+	admin := c.AdminURL
+	if admin == "" {
+		admin = c.BaseURL
+	}
+	if _, _, err := c.do(ctx, admin, http.MethodPost, "/sandbox", nil, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(req.Paths) {
+		return nil, fmt.Errorf("linkmap: sandbox returned %d results for %d paths", len(resp.Results), len(req.Paths))
+	}
+	return &resp, nil
+}
+
+// GetMap returns the rules of the map with the given key from the server.
+func (c *Client) GetMap(ctx context.Context, key string) (*Map, error) {
+	var resp Map
+	if err := c.call(ctx, http.MethodGet, "/rules?map="+url.QueryEscape(key), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Pull fetches the map with the given key from the server and holds it for
+// local evaluation, replacing any copy pulled before. Call it again to pick
+// up changes: the request is conditional on the ETag of the copy held, so
+// that polling a map which has not changed downloads no rules.
+func (c *Client) Pull(ctx context.Context, key string) error {
+	c.mu.RLock()
+	etag := c.etags[key]
+	c.mu.RUnlock()
+	var header http.Header
+	if etag != "" {
+		header = http.Header{"If-None-Match": {etag}}
+	}
+	var msg Map
+	status, respHeader, err := c.do(ctx, c.BaseURL, http.MethodGet, "/rules?map="+url.QueryEscape(key), header, nil, &msg)
+	if err != nil || status == http.StatusNotModified {
+		return err
+	}
+	m, err := msg.Linkmap(c.Options...)
+	if err != nil {
+		return fmt.Errorf("linkmap: map %q: %w", key, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.local == nil {
+		c.local = make(map[string]*linkmap.Map)
+		c.etags = make(map[string]string)
+	}
+	c.local[key], c.etags[key] = m, respHeader.Get("ETag")
+	return nil
+}
+
+// Drop discards the local copy of the map with the given key, if any.
+func (c *Client) Drop(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.local, key)
+	delete(c.etags, key)
+}
+
+// Linkmap builds a Map from the message form of its rules.
+func (msg *Map) Linkmap(opts ...linkmap.Option) (*linkmap.Map, error) {
+	rules := make([]linkmap.Rule, len(msg.Rules))
+	for i, r := range msg.Rules {
+		in, err := linkmap.ParseInput(r.Input, opts...)
+		if err != nil {
+			return nil, err
+		}
+		out, err := linkmap.ParseTemplate(r.Output, opts...)
+		if err != nil {
+			return nil, err
+		}
+		var weighted []linkmap.WeightedOutput
+		for _, w := range r.Weighted {
+			out, err := linkmap.ParseTemplate(w.Output, opts...)
+			if err != nil {
+				return nil, err
+			}
+			weighted = append(weighted, linkmap.WeightedOutput{Output: out, Weight: w.Weight})
+		}
+		var scheduled []linkmap.ScheduledOutput
+		for _, s := range r.Scheduled {
+			out, err := linkmap.ParseTemplate(s.Output, opts...)
+			if err != nil {
+				return nil, err
+			}
+			scheduled = append(scheduled, linkmap.ScheduledOutput{At: s.At, Output: out})
+		}
+		rules[i] = linkmap.Rule{
+			Input:        in,
+			Output:       out,
+			Line:         r.Line,
+			Source:       r.Source,
+			Status:       r.Status,
+			CacheControl: r.CacheControl,
+			Description:  r.Description,
+			Conditions:   r.Conditions,
+			Weighted:     weighted,
+			Scheduled:    scheduled,
+		}
+	}
+	return linkmap.New(rules, opts...), nil
+}
+
+// call sends a request to the server and decodes the JSON response into
+// out. Responses to /evaluate carry an EvaluateResponse whatever their
+// status; other failures carry an apiError.
+func (c *Client) call(ctx context.Context, method, path string, in, out interface{}) error {
+	_, _, err := c.do(ctx, c.BaseURL, method, path, nil, in, out)
+	return err
+}
+
+// do is like call, but sends the request to the API at base, adds header
+// to it and returns the status and header of the response. A 304 Not Modified response leaves out
+// unchanged.
+func (c *Client) do(ctx context.Context, base, method, path string, header http.Header, in, out interface{}) (int, http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return 0, nil, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("linkmap: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return resp.StatusCode, resp.Header, nil
+	case http.StatusOK:
+		// Successful responses, such as the rules of a large map, are
+		// not limited in size: the server is trusted to send what was
+		// asked for.
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, resp.Header, fmt.Errorf("linkmap: %s %s: decoding response: %w", method, path, err)
+		}
+		return resp.StatusCode, resp.Header, nil
+	}
+	// Error responses are small; a body too large to be one is cut short
+	// and reported by its status alone.
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return 0, nil, fmt.Errorf("linkmap: %s %s: %w", method, path, err)
+	}
+	if e, ok := out.(*EvaluateResponse); ok && json.Unmarshal(buf, e) == nil && e.Code != "" {
+		return resp.StatusCode, resp.Header, nil
+	}
+	var e apiError
+	if json.Unmarshal(buf, &e) != nil || e.Error == "" {
+		e.Error = http.StatusText(resp.StatusCode)
+	}
+	return resp.StatusCode, resp.Header, fmt.Errorf("linkmap: %s %s: %d %s", method, path, resp.StatusCode, e.Error)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/operandinc/linkmap"
+)
+
+func TestClient(t *testing.T) {
+	s := &Service{Maps: &linkmap.MapSet{
+		Load: func(key string) ([]byte, error) {
+			if key != "docs" {
+				return nil, errors.New("no such map")
+			}
+			return []byte("docs/$1.md https://example.com/$1\nwiki/$1.md $dest(wiki)/$1\n"), nil
+		},
+		Options: []linkmap.Option{linkmap.WithResolver(linkmap.MapResolver{"wiki": "https://wiki.example.com"})},
+	}}
+	var calls int32
+	h := s.Handler(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL}
+	ctx := context.Background()
+
+	evaluate := func(path, link string, code ErrorCode, remote bool) {
+		t.Helper()
+		before := atomic.LoadInt32(&calls)
+		resp, err := c.Evaluate(ctx, &EvaluateRequest{Map: "docs", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Link != link || resp.Code != code {
+			t.Errorf("Evaluate(%q) = %+v; want link %q, code %q", path, resp, link, code)
+		}
+		if got := atomic.LoadInt32(&calls) != before; got != remote {
+			t.Errorf("Evaluate(%q) called server = %v; want %v", path, got, remote)
+		}
+	}
+	evaluate("docs/a.md", "https://example.com/a", "", true)
+	evaluate("x", "", CodeNoMatch, true)
+
+	if err := c.Pull(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	evaluate("docs/a.md", "https://example.com/a", "", false)
+	evaluate("x", "", CodeNoMatch, false)
+	// The client has no resolver, so the server evaluates $dest.
+	evaluate("wiki/a.md", "https://wiki.example.com/a", "", true)
+
+	c.Drop("docs")
+	evaluate("docs/a.md", "https://example.com/a", "", true)
+
+	if err := c.Pull(ctx, "nope"); err == nil {
+		t.Error("Pull(nope) succeeded")
+	}
+	resps, err := c.EvaluateBatch(ctx, []EvaluateRequest{{Map: "docs", Path: "docs/b.md"}, {Map: "nope", Path: "x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resps[0].Link != "https://example.com/b" || resps[1].Code != CodeMapUnavailable {
+		t.Errorf("EvaluateBatch() = %+v, %+v", resps[0], resps[1])
+	}
+	if _, err := (&Client{BaseURL: srv.URL + "/missing"}).GetMap(ctx, "docs"); err == nil {
+		t.Error("GetMap() against a bad URL succeeded")
+	}
+}
+
+func TestClientPullETag(t *testing.T) {
+	src := "docs/$1.md https://example.com/$1\n"
+	s := &Service{Maps: &linkmap.MapSet{
+		Load: func(string) ([]byte, error) { return []byte(src), nil },
+	}}
+	h := s.Handler(0)
+	var notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotModified {
+			atomic.AddInt32(&notModified, 1)
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := c.Pull(ctx, "docs"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if notModified != 1 {
+		t.Errorf("second Pull got %d 304 responses; want 1", notModified)
+	}
+	src = "docs/$1.md https://example.org/$1\n"
+	if err := s.Maps.Reload("docs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Pull(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := c.Evaluate(ctx, &EvaluateRequest{Map: "docs", Path: "docs/a.md"})
+	if notModified != 1 || resp.Link != "https://example.org/a" {
+		t.Errorf("Pull after a change: %d 304 responses, link %q", notModified, resp.Link)
+	}
+}
+
+func TestClientPullLarge(t *testing.T) {
+	// The rules of a map can be much larger than a request may be.
+	var src strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&src, "docs/p%d/$1.md https://example.com/p%d/$1\n", i, i)
+	}
+	s := &Service{Maps: &linkmap.MapSet{
+		Load: func(string) ([]byte, error) { return []byte(src.String()), nil },
+	}}
+	srv := httptest.NewServer(s.Handler(0))
+	defer srv.Close()
+	c := &Client{BaseURL: srv.URL}
+	ctx := context.Background()
+	if err := c.Pull(ctx, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := c.Evaluate(ctx, &EvaluateRequest{Map: "docs", Path: "docs/p19999/a.md"}); resp.Link != "https://example.com/p19999/a" {
+		t.Errorf("Evaluate() = %+v", resp)
+	}
+}
+
+func TestMapLinkmapTextOutputs(t *testing.T) {
+	msg := &Map{Rules: []Rule{{Input: "docs/$1.md", Output: `https://example.com/{{index . "1"}}`}}}
+	m, err := msg.Linkmap(linkmap.WithTextOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, err := m.Evaluate("docs/a.md"); link != "https://example.com/a" || err != nil {
+		t.Errorf("Evaluate() = %q, %v", link, err)
+	}
+}
+
+func TestClientSandbox(t *testing.T) {
+	s := testService()
+	public := httptest.NewServer(s.Handler(0))
+	defer public.Close()
+	admin := httptest.NewServer(s.AdminHandler(0))
+	defer admin.Close()
+	req := &SandboxRequest{Source: "docs/$1.md https://example.org/$1\n", Map: "docs", Paths: []string{"docs/a.md"}}
+	if _, err := (&Client{BaseURL: public.URL}).Sandbox(context.Background(), req); err == nil {
+		t.Error("Sandbox() against the public API succeeded")
+	}
+	resp, err := (&Client{BaseURL: public.URL, AdminURL: admin.URL}).Sandbox(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Results[0].Changed {
+		t.Errorf("Sandbox() = %+v", resp.Results[0])
+	}
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAPI is the OpenAPI 3 description of the HTTP API served by Handler.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+const (
+	// maxBodyBytes bounds request bodies.
+	maxBodyBytes = 1 << 20
+	// defaultMaxBatch is the default limit on paths per batch request.
+	defaultMaxBatch = 1000
+)
+
+// BatchRequest is the body of POST /batch.
+type BatchRequest struct {
+	Requests []EvaluateRequest `json:"requests"`
+}
+
+// BatchResponse is the response to POST /batch.
+type BatchResponse struct {
+	Responses []*EvaluateResponse `json:"responses"`
+}
+
+// An apiError is the body of an error response which is not an
+// EvaluateResponse.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Handler returns an http.Handler serving the public JSON API described by
+// OpenAPI:
+//
+//	POST /evaluate        evaluate a path
+//	POST /batch           evaluate many paths
+//	GET  /rules           list a map's rules (?map=key), with an ETag
+//	GET  /stats           report a map's statistics (?map=key)
+//	GET  /healthz         report MapSet health
+//	GET  /openapi.yaml
+//
+// maxBatch limits the number of paths in a batch request; if it is zero, a
+// default of 1000 is used. Operations which change or reveal more than the
+// maps' rules are served by AdminHandler.
+func (s *Service) Handler(maxBatch int) http.Handler {
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatch
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/evaluate", method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req EvaluateRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		resp := s.evaluate(&req)
+		writeJSON(w, httpStatus(resp.Code), resp)
+	}))
+	mux.HandleFunc("/batch", method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req BatchRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		if len(req.Requests) > maxBatch {
+			writeJSON(w, http.StatusRequestEntityTooLarge, apiError{fmt.Sprintf("at most %d requests per batch", maxBatch)})
+			return
+		}
+		resp := BatchResponse{Responses: make([]*EvaluateResponse, len(req.Requests))}
+		for i := range req.Requests {
+			resp.Responses[i] = s.evaluate(&req.Requests[i])
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}))
+	mux.HandleFunc("/rules", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		m, err := s.GetMap(r.Context(), r.URL.Query().Get("map"))
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{err.Error()})
+			return
+		}
+		// The rules' line numbers may change while the hash does not, but
+		// the representations are then equivalent, as weak ETags allow.
+		writeTagged(w, r, `W/"`+m.Hash[:32]+`"`, m)
+	}))
+	mux.HandleFunc("/stats", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		m, err := s.Maps.Get(r.URL.Query().Get("map"))
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, apiError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, m.Stats())
+	}))
+	mux.Handle("/healthz", s.Maps.HealthHandler())
+	mux.HandleFunc("/openapi.yaml", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(OpenAPI)
+	}))
+	return mux
+}
+
+// AdminHandler returns an http.Handler serving the admin operations of the
+// API, which reload maps, evaluate candidate maps or reveal the paths
+// requested:
+//
+//	POST /sandbox         evaluate paths against a candidate map
+//	POST /reload          reload a map from its source (?map=key)
+//	GET  /divergences     report divergences found by the Shadow (?map=key)
+//	GET  /debug           report MapSet debug information
+//	GET  /healthz         report MapSet health
+//
+// It must not be reachable by the public: serve it on a private address,
+// or behind authentication. maxBatch limits the number of paths in a
+// sandbox request; if it is zero, a default of 1000 is used.
+func (s *Service) AdminHandler(maxBatch int) http.Handler {
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatch
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sandbox", method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		var req SandboxRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		if len(req.Paths) > maxBatch {
+			writeJSON(w, http.StatusRequestEntityTooLarge, apiError{fmt.Sprintf("at most %d paths per request", maxBatch)})
+			return
+		}
+		resp, err := s.Sandbox(r.Context(), &req)
+		var serr *SourceError
+		switch {
+		case errors.As(err, &serr):
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusServiceUnavailable, apiError{err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, resp)
+		}
+	}))
+	mux.HandleFunc("/reload", method(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Maps.Reload(r.URL.Query().Get("map")); err != nil {
+			// The previous map, if any, is still served.
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("/divergences", method(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		if s.Shadow == nil {
+			writeJSON(w, http.StatusNotFound, apiError{"shadow traffic is not enabled"})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.Shadow.Report(r.URL.Query().Get("map")))
+	}))
+	mux.Handle("/debug", s.Maps.DebugHandler())
+	mux.Handle("/healthz", s.Maps.HealthHandler())
+	return mux
+}
+
+// method restricts h to requests with the given method.
+func method(m string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != m {
+			w.Header().Set("Allow", m)
+			writeJSON(w, http.StatusMethodNotAllowed, apiError{"method not allowed"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// decode decodes the JSON request body into v, writing an error response
+// and returning false if it is invalid.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{"invalid request body: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeTagged writes v as JSON with an ETag, so that clients polling for
+// changes can revalidate with If-None-Match and get an empty 304 Not
+// Modified response if nothing changed.
+func writeTagged(w http.ResponseWriter, r *http.Request, etag string, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, apiError{err.Error()})
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(buf, '\n'))
+}
+
+// etagMatch reports whether an If-None-Match header matches etag, with
+// the weak comparison RFC 9110 prescribes for it.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// httpStatus returns the HTTP status for an evaluation error code.
+func httpStatus(code ErrorCode) int {
+	switch code {
+	case "":
+		return http.StatusOK
+	case CodeNoMatch, CodeIgnored:
+		return http.StatusNotFound
+	case CodeInvalidPath, CodeUnsafeLink:
+		return http.StatusBadRequest
+	case CodeMapUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := testService().Handler(2)
+	tests := []struct {
+		method, target, body string
+		status               int
+		contains             string
+	}{
+		{"POST", "/evaluate", `{"map":"docs","path":"docs/a.md"}`, http.StatusOK, `"link":"https://example.com/a"`},
+		{"POST", "/evaluate", `{"map":"docs","path":"x"}`, http.StatusNotFound, `"code":"NO_MATCH"`},
+		{"POST", "/evaluate", `{"map":"docs","path":"../x"}`, http.StatusBadRequest, `"code":"INVALID_PATH"`},
+		{"POST", "/evaluate", `{"map":"nope","path":"x"}`, http.StatusServiceUnavailable, `"code":"MAP_UNAVAILABLE"`},
+		{"POST", "/evaluate", `{"map":"docs","paths":[]}`, http.StatusBadRequest, `invalid request body`},
+		{"GET", "/evaluate", ``, http.StatusMethodNotAllowed, `method not allowed`},
+		{"POST", "/batch", `{"requests":[{"map":"docs","path":"docs/a.md"},{"map":"docs","path":"x"}]}`, http.StatusOK, `"code":"NO_MATCH"`},
+		{"POST", "/batch", `{"requests":[{},{},{}]}`, http.StatusRequestEntityTooLarge, `at most 2`},
+		{"GET", "/rules?map=docs", ``, http.StatusOK, `"input":"docs/$1.md"`},
+		{"GET", "/rules?map=nope", ``, http.StatusServiceUnavailable, `no such map`},
+		{"GET", "/stats?map=docs", ``, http.StatusOK, `"Rules":1`},
+		{"GET", "/healthz", ``, http.StatusOK, `"ok"`},
+		{"GET", "/openapi.yaml", ``, http.StatusOK, `openapi: 3`},
+		// Admin operations are not public.
+		{"POST", "/reload?map=docs", ``, http.StatusNotFound, ``},
+		{"GET", "/debug", ``, http.StatusNotFound, ``},
+		{"POST", "/sandbox", `{"source":"","paths":[]}`, http.StatusNotFound, ``},
+		{"GET", "/divergences", ``, http.StatusNotFound, ``},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s %s = %d %s; want %d containing %s", tt.method, tt.target, rec.Code, rec.Body, tt.status, tt.contains)
+		}
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	h := testService().AdminHandler(2)
+	tests := []struct {
+		method, target, body string
+		status               int
+		contains             string
+	}{
+		{"POST", "/sandbox", `{"source":"docs/$1.md https://example.org/$1\n","map":"docs","paths":["docs/a.md"]}`, http.StatusOK, `"changed":true`},
+		{"POST", "/sandbox", `{"source":"docs/$1.md\n","paths":["docs/a.md"]}`, http.StatusUnprocessableEntity, `invalid candidate map`},
+		{"POST", "/sandbox", `{"source":"","map":"nope","paths":[]}`, http.StatusServiceUnavailable, `no such map`},
+		{"POST", "/sandbox", `{"source":"","paths":["a","b","c"]}`, http.StatusRequestEntityTooLarge, `at most 2`},
+		{"POST", "/reload?map=docs", ``, http.StatusNoContent, ``},
+		{"POST", "/reload?map=nope", ``, http.StatusUnprocessableEntity, `no such map`},
+		{"GET", "/healthz", ``, http.StatusServiceUnavailable, `"failing"`},
+		{"GET", "/debug", ``, http.StatusOK, `"maps"`},
+		{"POST", "/evaluate", `{"map":"docs","path":"docs/a.md"}`, http.StatusNotFound, ``},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s %s = %d %s; want %d containing %s", tt.method, tt.target, rec.Code, rec.Body, tt.status, tt.contains)
+		}
+	}
+}
+
+func TestHandlerRulesETag(t *testing.T) {
+	h := testService().Handler(0)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/rules?map=docs", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET /rules = %d, ETag %q", rec.Code, etag)
+	}
+	for _, inm := range []string{etag, `"other", ` + etag, strings.TrimPrefix(etag, "W/"), "*"} {
+		req := httptest.NewRequest("GET", "/rules?map=docs", nil)
+		req.Header.Set("If-None-Match", inm)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("GET /rules with If-None-Match %s = %d %q; want 304", inm, rec.Code, rec.Body)
+		}
+	}
+	req := httptest.NewRequest("GET", "/rules?map=docs", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /rules with a stale ETag = %d; want 200", rec.Code)
+	}
+}
+
+func TestHandlerBatchOrder(t *testing.T) {
+	h := testService().Handler(0)
+	rec := httptest.NewRecorder()
+	body := `{"requests":[{"map":"docs","path":"docs/b.md"},{"map":"docs","path":"docs/a.md"}]}`
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/batch", strings.NewReader(body)))
+	var resp BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Responses) != 2 || resp.Responses[0].Path != "docs/b.md" || resp.Responses[1].Link != "https://example.com/a" {
+		t.Errorf("batch responses = %+v", resp.Responses)
+	}
+}
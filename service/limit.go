@@ -0,0 +1,149 @@
+package service
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxClients is the number of clients a Limiter tracks, so that traffic
+// from many addresses costs neither memory nor time. Beyond it, the least
+// recently seen client is forgotten once its bucket has refilled, which
+// loses nothing; until then, new clients are refused, so that a client
+// rotating through addresses cannot escape its rate by having its buckets
+// forgotten.
+const maxClients = 10000
+
+// A Limiter protects a public endpoint from abuse: it rate limits requests
+// per client IP address, with a token bucket, and rejects requests from
+// blocked user agents. IPv6 clients are limited by /64 network, as each
+// may hold many addresses. Its zero value allows everything.
+type Limiter struct {
+	// Rate is the number of requests per second allowed per client, on
+	// average. If zero, requests are not rate limited.
+	Rate float64
+	// Burst is the number of requests a client may make at once. If zero,
+	// it is Rate rounded up.
+	Burst int
+	// BlockAgents holds substrings of the User-Agent header, compared
+	// without regard to case, whose requests are rejected. An empty
+	// substring blocks requests without a User-Agent.
+	BlockAgents []string
+	// ClientIP returns the address requests are limited by. If nil, it is
+	// the host of the request's RemoteAddr; a server behind a proxy sets
+	// it to read the header the proxy adds.
+	ClientIP func(*http.Request) string
+
+	mu      sync.Mutex
+	clients map[string]*list.Element
+	// lru orders the buckets, from the most recently seen client.
+	lru list.List
+	// now returns the current time.
+	now func() time.Time
+}
+
+// A bucket is a client's allowance of requests.
+type bucket struct {
+	client string
+	tokens float64
+	last   time.Time
+}
+
+// Handler returns h guarded by l. Rejected requests get a JSON error, with
+// status 403 for a blocked user agent, or 429 and a Retry-After header for
+// a client over its rate.
+func (l *Limiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.blocked(r.UserAgent()) {
+			writeJSON(w, http.StatusForbidden, apiError{"user agent not allowed"})
+			return
+		}
+		if wait := l.reserve(clientKey(l.clientIP(r))); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, apiError{"rate limit exceeded"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) blocked(agent string) bool {
+	agent = strings.ToLower(agent)
+	for _, b := range l.BlockAgents {
+		if b == "" && agent == "" || b != "" && strings.Contains(agent, strings.ToLower(b)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	if l.ClientIP != nil {
+		return l.ClientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientKey returns the key limiting the client at ip: the address itself,
+// or the /64 network of an IPv6 address.
+func clientKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() != nil {
+		return ip
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// reserve takes a token from the bucket of client, returning zero if it
+// had one and otherwise how long until it will.
+func (l *Limiter) reserve(client string) time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	burst := float64(l.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(l.Rate)
+	}
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.clients == nil {
+		l.clients = make(map[string]*list.Element)
+	}
+	var b *bucket
+	if e := l.clients[client]; e != nil {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+	} else {
+		if len(l.clients) >= maxClients {
+			oldest := l.lru.Back()
+			ob := oldest.Value.(*bucket)
+			if missing := burst - (ob.tokens + now.Sub(ob.last).Seconds()*l.Rate); missing > 0 {
+				return time.Duration(missing / l.Rate * float64(time.Second))
+			}
+			l.lru.Remove(oldest)
+			delete(l.clients, ob.client)
+		}
+		b = &bucket{client: client, tokens: burst, last: now}
+		l.clients[client] = l.lru.PushFront(b)
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
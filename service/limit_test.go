@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &Limiter{
+		Rate:        1,
+		Burst:       2,
+		BlockAgents: []string{"BadBot", ""},
+		now:         func() time.Time { return now },
+	}
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(addr, agent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/evaluate", nil)
+		req.RemoteAddr = addr
+		if agent != "" {
+			req.Header.Set("User-Agent", agent)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	steps := []struct {
+		advance     time.Duration
+		addr, agent string
+		status      int
+	}{
+		{0, "10.0.0.1:1", "curl/8", http.StatusOK},
+		{0, "10.0.0.1:2", "curl/8", http.StatusOK},
+		{0, "10.0.0.1:3", "curl/8", http.StatusTooManyRequests},
+		{0, "10.0.0.2:1", "curl/8", http.StatusOK},
+		{500 * time.Millisecond, "10.0.0.1:1", "curl/8", http.StatusTooManyRequests},
+		{500 * time.Millisecond, "10.0.0.1:1", "curl/8", http.StatusOK},
+		{0, "10.0.0.3:1", "Mozilla/5.0 (compatible; badbot/1.0)", http.StatusForbidden},
+		{0, "10.0.0.3:1", "", http.StatusForbidden},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		rec := do(s.addr, s.agent)
+		if rec.Code != s.status {
+			t.Errorf("step %d: status %d; want %d", i, rec.Code, s.status)
+		}
+		if s.status == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("step %d: no Retry-After header", i)
+		}
+	}
+}
+
+func TestLimiterClients(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &Limiter{Rate: 1, now: func() time.Time { return now }}
+	for i := 0; i < maxClients; i++ {
+		l.reserve(fmt.Sprint(i))
+	}
+	// Every bucket is empty, so a client rotating through new addresses
+	// is refused rather than having them forgotten.
+	for i := maxClients; i < maxClients+100; i++ {
+		if wait := l.reserve(fmt.Sprint(i)); wait != time.Second {
+			t.Fatalf("new client %d with a full table: wait = %v; want 1s", i, wait)
+		}
+	}
+	if len(l.clients) != maxClients || l.lru.Len() != maxClients {
+		t.Errorf("tracking %d clients; want %d", len(l.clients), maxClients)
+	}
+	if wait := l.reserve("1"); wait == 0 {
+		t.Error("a tracked client was forgotten")
+	}
+
+	// Once the least recently seen bucket has refilled, it is forgotten.
+	now = now.Add(time.Second)
+	if wait := l.reserve("new"); wait != 0 {
+		t.Errorf("new client after the oldest bucket refilled: wait = %v", wait)
+	}
+	if _, ok := l.clients["0"]; ok || len(l.clients) != maxClients {
+		t.Errorf("the least recently seen client was kept, tracking %d clients", len(l.clients))
+	}
+	if wait := l.reserve("new"); wait == 0 {
+		t.Error("the most recent client was forgotten")
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct{ ip, want string }{
+		{"10.0.0.1", "10.0.0.1"},
+		{"::ffff:10.0.0.1", "::ffff:10.0.0.1"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2::ffff", "2001:db8:1:2::/64"},
+		{"not an address", "not an address"},
+	}
+	for _, tt := range tests {
+		if got := clientKey(tt.ip); got != tt.want {
+			t.Errorf("clientKey(%q) = %q; want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/operandinc/linkmap"
+)
+
+// A LogEntry records a single evaluation, for structured logs of redirect
+// traffic.
+type LogEntry struct {
+	Time time.Time `json:"time"`
+	Map  string    `json:"map,omitempty"`
+	Path string    `json:"path"`
+	// Rule is the rule which matched, in linkmap syntax, and Origin where
+	// it was defined.
+	Rule   string `json:"rule,omitempty"`
+	Origin string `json:"origin,omitempty"`
+	Link   string `json:"link,omitempty"`
+	// Status is the redirect status of the rule, if it sets one.
+	Status int       `json:"status,omitempty"`
+	Code   ErrorCode `json:"code,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	// Latency is the time taken by the evaluation. It is written to JSON
+	// in milliseconds.
+	Latency time.Duration `json:"-"`
+}
+
+// NewLogEntry returns the log entry for an evaluation against the map with
+// the given key.
+func NewLogEntry(key string, res linkmap.Result, start time.Time) LogEntry {
+	e := LogEntry{Time: start, Map: key, Path: res.Path, Link: res.Link, Latency: time.Since(start)}
+	if r := res.Rule; r != nil {
+		e.Rule, e.Origin, e.Status = r.String(), r.Origin(), r.Status
+	}
+	if res.Err != nil {
+		e.Link, e.Code, e.Error = "", Code(res.Err), res.Err.Error()
+	}
+	return e
+}
+
+// MarshalJSON implements json.Marshaler, writing the latency in
+// milliseconds.
+func (e LogEntry) MarshalJSON() ([]byte, error) {
+	type entry LogEntry
+	return json.Marshal(struct {
+		entry
+		LatencyMS float64 `json:"latency_ms"`
+	}{entry(e), float64(e.Latency) / float64(time.Millisecond)})
+}
+
+func (e LogEntry) String() string {
+	s := fmt.Sprintf("%s %s", e.Map, e.Path)
+	if e.Map == "" {
+		s = e.Path
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %s (%v)", s, e.Code, e.Latency)
+	}
+	return fmt.Sprintf("%s -> %s [%s] (%v)", s, e.Link, e.Origin, e.Latency)
+}
+
+// JSONLog returns a function for Service.Log which writes entries to w as
+// JSON, one per line. It is safe for concurrent use.
+func JSONLog(w io.Writer) func(LogEntry) {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	}
+}
+
+// TextLog returns a function for Service.Log which writes entries to w in
+// a human-readable form, one per line. It is safe for concurrent use.
+func TextLog(w io.Writer) func(LogEntry) {
+	var mu sync.Mutex
+	return func(e LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s\n", e.Time.Format(time.RFC3339), e)
+	}
+}
+
+// NewLog returns the log for a format named by a command-line flag: the
+// empty string for none, "text" for TextLog or "json" for JSONLog.
+func NewLog(format string, w io.Writer) (func(LogEntry), error) {
+	switch format {
+	case "":
+		return nil, nil
+	case "text":
+		return TextLog(w), nil
+	case "json":
+		return JSONLog(w), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
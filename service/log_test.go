@@ -0,0 +1,64 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONLog(t *testing.T) {
+	var buf bytes.Buffer
+	s := testService()
+	s.Log = JSONLog(&buf)
+	for _, req := range []EvaluateRequest{
+		{Map: "docs", Path: "docs/a.md"},
+		{Map: "docs", Path: "other/a.md"},
+		{Map: "nope", Path: "docs/a.md"},
+	} {
+		if _, err := s.Evaluate(context.Background(), &req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("log = %q; want 3 lines", buf.String())
+	}
+	want := []map[string]interface{}{
+		{"map": "docs", "path": "docs/a.md", "rule": "docs/$1.md https://example.com/$1", "origin": "line 1", "link": "https://example.com/a"},
+		{"map": "docs", "path": "other/a.md", "code": "NO_MATCH"},
+		{"map": "nope", "path": "docs/a.md", "code": "MAP_UNAVAILABLE"},
+	}
+	for i, l := range lines {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &got); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		for k, v := range want[i] {
+			if got[k] != v {
+				t.Errorf("line %d: %s = %v; want %v", i, k, got[k], v)
+			}
+		}
+		if _, ok := got["latency_ms"].(float64); !ok || got["time"] == nil {
+			t.Errorf("line %d = %s; want time and latency_ms", i, l)
+		}
+	}
+}
+
+func TestNewLog(t *testing.T) {
+	var buf bytes.Buffer
+	for _, format := range []string{"", "text", "json"} {
+		if _, err := NewLog(format, &buf); err != nil {
+			t.Errorf("NewLog(%q) = %v", format, err)
+		}
+	}
+	if _, err := NewLog("xml", &buf); err == nil {
+		t.Error("NewLog(xml) succeeded")
+	}
+	log, _ := NewLog("text", &buf)
+	log(LogEntry{Map: "docs", Path: "a.md", Code: CodeNoMatch})
+	if !strings.Contains(buf.String(), "docs a.md: NO_MATCH") {
+		t.Errorf("TextLog wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Redirector serves HTTP redirects: it evaluates the path of each GET or
+// HEAD request, without its leading slash, against a map and redirects to
+// the link, with the status of the rule which matched or 302 Found.
+// Evaluation failures get a plain text error with the status Handler uses
+// for them, unless Next handles misses.
+type Redirector struct {
+	Service *Service
+	// Map is the key of the map evaluated.
+	Map string
+	// PreserveQuery carries the request's query parameters over to the
+	// link, e.g. so that utm_ parameters survive for analytics. A
+	// parameter which the link sets itself keeps the link's values,
+	// unless OverrideQuery is set, in which case it takes the request's.
+	// Fragments need no such option: browsers never send them, but keep
+	// the request's fragment when following a link which has none.
+	PreserveQuery bool
+	OverrideQuery bool
+	// Next, if set, handles requests which are not redirected: those
+	// whose paths match no rule, are ignored or are not valid map paths,
+	// such as "/" and "/blog/", and those with other methods than GET and
+	// HEAD. This lets a Redirector sit in front of an existing site as
+	// middleware, e.g. with a custom 404 page as Next.
+	Next http.Handler
+	// Header is added to every redirect, e.g. for Strict-Transport-Security.
+	Header http.Header
+	// CacheControl is the Cache-Control header of redirects by status, for
+	// rules which do not set their own, e.g. so that permanent redirects
+	// are cached at the edge while temporary ones are not. A header with a
+	// max-age directive is accompanied by an Expires header for HTTP/1.0
+	// caches.
+	CacheControl map[int]string
+}
+
+// ServeHTTP implements http.Handler.
+func (rd *Redirector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if rd.Next != nil {
+			rd.Next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := rd.Service.evaluate(&EvaluateRequest{Map: rd.Map, Path: strings.TrimPrefix(r.URL.Path, "/")})
+	if rd.Next != nil && (resp.Code == CodeNoMatch || resp.Code == CodeIgnored || resp.Code == CodeInvalidPath) {
+		rd.Next.ServeHTTP(w, r)
+		return
+	}
+	if resp.Code != "" {
+		http.Error(w, resp.Error, httpStatus(resp.Code))
+		return
+	}
+	link := resp.Link
+	if rd.PreserveQuery && r.URL.RawQuery != "" {
+		link = mergeQuery(link, r.URL.Query(), rd.OverrideQuery)
+	}
+	status := http.StatusFound
+	if resp.Rule != nil && resp.Rule.Status != 0 {
+		status = resp.Rule.Status
+	}
+	for k, v := range rd.Header {
+		w.Header()[k] = v
+	}
+	cc := rd.CacheControl[status]
+	if resp.Rule != nil && resp.Rule.CacheControl != "" {
+		cc = resp.Rule.CacheControl
+	}
+	if cc != "" {
+		w.Header().Set("Cache-Control", cc)
+		if age, ok := maxAge(cc); ok {
+			w.Header().Set("Expires", time.Now().Add(age).UTC().Format(http.TimeFormat))
+		}
+	}
+	http.Redirect(w, r, link, status)
+}
+
+// maxAge returns the max-age directive of a Cache-Control header.
+func maxAge(cc string) (time.Duration, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(d), "=")
+		if !ok || !strings.EqualFold(k, "max-age") {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.Trim(v, `"`))
+		if err != nil || secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	return 0, false
+}
+
+// mergeQuery adds the parameters of query to link. Parameters the link
+// already has keep their values unless override is set. The link's query
+// is re-encoded, with parameters sorted by name, if it changes.
+func mergeQuery(link string, query url.Values, override bool) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	q := u.Query()
+	for k, vs := range query {
+		if _, ok := q[k]; ok && !override {
+			continue
+		}
+		q[k] = vs
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
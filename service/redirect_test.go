@@ -0,0 +1,145 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/operandinc/linkmap"
+)
+
+func TestRedirector(t *testing.T) {
+	tests := []struct {
+		rd       Redirector
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{Redirector{}, "GET", "/docs/a.md?utm_source=x", http.StatusFound, "https://example.com/a"},
+		{Redirector{PreserveQuery: true}, "GET", "/docs/a.md?utm_source=x&b=1&b=2", http.StatusFound, "https://example.com/a?b=1&b=2&utm_source=x"},
+		{Redirector{PreserveQuery: true}, "HEAD", "/docs/a.md", http.StatusFound, "https://example.com/a"},
+		{Redirector{}, "GET", "/other", http.StatusNotFound, ""},
+		{Redirector{}, "POST", "/docs/a.md", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		tt.rd.Service, tt.rd.Map = testService(), "docs"
+		rec := httptest.NewRecorder()
+		tt.rd.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s = %d %q; want %d %q", tt.method, tt.target, rec.Code, rec.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestRedirectorNext(t *testing.T) {
+	rd := &Redirector{
+		Service: testService(),
+		Map:     "docs",
+		Next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}
+	rd.Service.Maps.Load = func(string) ([]byte, error) {
+		return []byte("docs/$1.md https://example.com/$1\nunsafe/$1 $1\n"), nil
+	}
+	rd.Service.Maps.Options = []linkmap.Option{linkmap.WithAllowlist(linkmap.Allowlist{})}
+	tests := []struct {
+		method, target string
+		status         int
+	}{
+		{"GET", "/docs/a.md", http.StatusFound},
+		{"GET", "/other", http.StatusTeapot},
+		{"POST", "/docs/a.md", http.StatusTeapot},
+		{"GET", "/blog/", http.StatusTeapot},
+		{"GET", "/unsafe/https:evil.example", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rd.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s = %d; want %d", tt.method, tt.target, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestRedirectorHeaders(t *testing.T) {
+	rd := &Redirector{
+		Service:      testService(),
+		Map:          "docs",
+		Header:       http.Header{"Strict-Transport-Security": {"max-age=63072000"}},
+		CacheControl: map[int]string{http.StatusMovedPermanently: "public, max-age=86400", http.StatusFound: "no-store"},
+	}
+	rd.Service.Maps.Parse = linkmap.ParseTOML
+	rd.Service.Maps.Load = func(string) ([]byte, error) {
+		return []byte(`[[rule]]
+from = "docs/$1.md"
+to = "https://example.com/$1"
+status = 301
+
+[[rule]]
+from = "blog/$1.md"
+to = "https://example.com/blog/$1"
+status = 301
+cache_control = "public, max-age=60"
+
+[[rule]]
+from = "tmp/$1"
+to = "https://example.com/tmp/$1"
+`), nil
+	}
+	tests := []struct {
+		target, cc string
+		expires    bool
+	}{
+		{"/docs/a.md", "public, max-age=86400", true},
+		{"/blog/a.md", "public, max-age=60", true},
+		{"/tmp/a", "no-store", false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rd.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+		h := rec.Header()
+		if h.Get("Cache-Control") != tt.cc || (h.Get("Expires") != "") != tt.expires || h.Get("Strict-Transport-Security") == "" {
+			t.Errorf("GET %s headers = %v; want Cache-Control %q", tt.target, h, tt.cc)
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	tests := []struct {
+		cc   string
+		want time.Duration
+		ok   bool
+	}{
+		{"public, max-age=60", time.Minute, true},
+		{"s-maxage=10, MAX-AGE=\"5\"", 5 * time.Second, true},
+		{"no-store", 0, false},
+		{"max-age=-1", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := maxAge(tt.cc); got != tt.want || ok != tt.ok {
+			t.Errorf("maxAge(%q) = %v, %v; want %v, %v", tt.cc, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMergeQuery(t *testing.T) {
+	query := map[string][]string{"utm_source": {"news"}, "lang": {"fr"}}
+	tests := []struct {
+		link     string
+		override bool
+		want     string
+	}{
+		{"https://example.com/a", false, "https://example.com/a?lang=fr&utm_source=news"},
+		{"https://example.com/a?lang=en#top", false, "https://example.com/a?lang=en&utm_source=news#top"},
+		{"https://example.com/a?lang=en#top", true, "https://example.com/a?lang=fr&utm_source=news#top"},
+		{"/a", false, "/a?lang=fr&utm_source=news"},
+	}
+	for _, tt := range tests {
+		if got := mergeQuery(tt.link, query, tt.override); got != tt.want {
+			t.Errorf("mergeQuery(%q, %v) = %q; want %q", tt.link, tt.override, got, tt.want)
+		}
+	}
+}
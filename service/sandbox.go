@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/operandinc/linkmap"
+)
+
+// sandboxTimeout bounds the evaluation of each path against a candidate
+// map, which may have been written to be slow.
+const sandboxTimeout = 10 * time.Millisecond
+
+// SandboxRequest mirrors linkmap.v1.SandboxRequest.
+type SandboxRequest struct {
+	// Source is the candidate map, in the format and with the options of
+	// the MapSet's maps.
+	Source string `json:"source"`
+	// Map, if set, is the key of the live map to compare the candidate
+	// with.
+	Map   string   `json:"map,omitempty"`
+	Paths []string `json:"paths"`
+}
+
+// SandboxResponse mirrors linkmap.v1.SandboxResponse.
+type SandboxResponse struct {
+	// Hash is the candidate's linkmap.Map.Hash.
+	Hash    string          `json:"hash"`
+	Results []SandboxResult `json:"results"`
+}
+
+// SandboxResult mirrors linkmap.v1.SandboxResult.
+type SandboxResult struct {
+	Candidate *EvaluateResponse `json:"candidate"`
+	// Live is the live map's response, if the request named one.
+	Live *EvaluateResponse `json:"live,omitempty"`
+	// Changed reports whether the candidate's link or error code differs
+	// from the live map's.
+	Changed bool `json:"changed,omitempty"`
+}
+
+// A SourceError reports a candidate map which does not parse.
+type SourceError struct {
+	Err error
+}
+
+func (e *SourceError) Error() string {
+	return "invalid candidate map: " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// Sandbox evaluates paths against a candidate map given in the request,
+// for authors to test a change before merging it, and, if the request
+// names a live map, compares the results with the live map's. The
+// candidate is discarded afterwards: it does not replace the live map,
+// and its evaluations are not logged. Sandbox returns a *SourceError if
+// the candidate does not parse, and an error from the MapSet if the live
+// map cannot be loaded. The candidate is parsed within SandboxLimits and
+// without text/template outputs, which are programs, even if the MapSet's
+// options accept them; each evaluation against it is given a budget of
+// 10ms.
+func (s *Service) Sandbox(ctx context.Context, req *SandboxRequest) (*SandboxResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	parse := s.Maps.Parse
+	if parse == nil {
+		parse = linkmap.Parse
+	}
+	limits := s.SandboxLimits
+	if limits == (linkmap.Limits{}) {
+		limits = linkmap.DefaultLimits
+	}
+	opts := append(append([]linkmap.Option(nil), s.Maps.Options...), linkmap.WithLimits(limits), linkmap.WithoutTextOutputs(), linkmap.WithBudget(0, sandboxTimeout))
+	candidate, err := parse(strings.NewReader(req.Source), opts...)
+	if err != nil {
+		return nil, &SourceError{Err: err}
+	}
+	var live *linkmap.Map
+	if req.Map != "" {
+		if live, err = s.Maps.Get(req.Map); err != nil {
+			return nil, fmt.Errorf("loading map %q: %w", req.Map, err)
+		}
+	}
+	resp := &SandboxResponse{Hash: candidate.Hash(), Results: make([]SandboxResult, len(req.Paths))}
+	for i, p := range req.Paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res := SandboxResult{Candidate: response(candidate.Lookup(p))}
+		if live != nil {
+			res.Live = response(live.Lookup(p))
+			res.Changed = res.Live.Link != res.Candidate.Link || res.Live.Code != res.Candidate.Code
+		}
+		resp.Results[i] = res
+	}
+	return resp, nil
+}
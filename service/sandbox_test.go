@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/operandinc/linkmap"
+)
+
+func TestSandbox(t *testing.T) {
+	s := testService()
+	resp, err := s.Sandbox(context.Background(), &SandboxRequest{
+		Source: "docs/$1.md https://example.com/$1\nguides/$1.md https://example.com/guides/$1\n",
+		Map:    "docs",
+		Paths:  []string{"docs/a.md", "guides/b.md", "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		link    string
+		code    ErrorCode
+		changed bool
+	}{
+		{"https://example.com/a", "", false},
+		{"https://example.com/guides/b", "", true},
+		{"", CodeNoMatch, false},
+	}
+	if len(resp.Results) != len(want) || resp.Hash == "" {
+		t.Fatalf("Sandbox() = %+v", resp)
+	}
+	for i, w := range want {
+		r := resp.Results[i]
+		if r.Candidate.Link != w.link || r.Candidate.Code != w.code || r.Changed != w.changed || r.Live == nil {
+			t.Errorf("result %d = %+v, live %+v; want %+v", i, r.Candidate, r.Live, w)
+		}
+	}
+	if link, _ := s.Maps.Evaluate("docs", "guides/b.md"); link != "" {
+		t.Errorf("the candidate replaced the live map: guides/b.md evaluates to %q", link)
+	}
+
+	_, err = s.Sandbox(context.Background(), &SandboxRequest{Source: "docs/$1.md\n"})
+	var serr *SourceError
+	if !errors.As(err, &serr) {
+		t.Errorf("Sandbox() with an invalid source: error = %v; want a *SourceError", err)
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	s := testService()
+	s.SandboxLimits = linkmap.Limits{MaxRules: 1}
+	_, err := s.Sandbox(context.Background(), &SandboxRequest{Source: "a/$1 /a/$1\nb/$1 /b/$1\n"})
+	var lerr *linkmap.LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != "MaxRules" {
+		t.Errorf("Sandbox() with too many rules: error = %v; want a MaxRules *LimitError", err)
+	}
+}
+
+func TestSandboxTemplates(t *testing.T) {
+	s := testService()
+	s.Maps.Options = append(s.Maps.Options, linkmap.WithTmplOutputs())
+	var serr *SourceError
+	_, err := s.Sandbox(context.Background(), &SandboxRequest{Source: "a/$1 tmpl:{{range .}}{{range .}}{{.}}{{end}}{{end}}\n"})
+	if !errors.As(err, &serr) {
+		t.Errorf("Sandbox() with a text/template output: error = %v; want a *SourceError", err)
+	}
+	_, err = s.Sandbox(context.Background(), &SandboxRequest{Source: "a/$1 /" + strings.Repeat("$1/", 100) + "\n"})
+	var lerr *linkmap.LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != "MaxSegments" {
+		t.Errorf("Sandbox() with an oversized template: error = %v; want a MaxSegments *LimitError", err)
+	}
+}
@@ -0,0 +1,215 @@
+// Package service implements the linkmap evaluation service described by
+// proto/linkmap/v1/linkmap.proto, independently of any transport. The types
+// mirror the protocol buffer messages so that the gRPC server in module
+// github.com/operandinc/linkmap/grpcservice, or the HTTP API in this
+// package, only converts between representations.
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/operandinc/linkmap"
+)
+
+// A Service evaluates paths against the maps in a MapSet.
+type Service struct {
+	Maps *linkmap.MapSet
+	// Log, if set, is called after every evaluation, e.g. with JSONLog.
+	Log func(LogEntry)
+	// Shadow, if set, compares every evaluation with candidate maps.
+	Shadow *Shadow
+	// SandboxLimits bound the candidate maps of Sandbox, which come from
+	// requests. If zero, linkmap.DefaultLimits apply.
+	SandboxLimits linkmap.Limits
+}
+
+// EvaluateRequest mirrors linkmap.v1.EvaluateRequest.
+type EvaluateRequest struct {
+	Map  string `json:"map"`
+	Path string `json:"path"`
+}
+
+// EvaluateResponse mirrors linkmap.v1.EvaluateResponse.
+type EvaluateResponse struct {
+	Path  string            `json:"path"`
+	Link  string            `json:"link,omitempty"`
+	Rule  *Rule             `json:"rule,omitempty"`
+	Vars  map[string]string `json:"vars,omitempty"`
+	Code  ErrorCode         `json:"code,omitempty"`
+	Error string            `json:"error,omitempty"`
+}
+
+// ErrorCode mirrors linkmap.v1.ErrorCode, without its prefix.
+type ErrorCode string
+
+// Error codes. The empty code means success.
+const (
+	CodeNoMatch        ErrorCode = "NO_MATCH"
+	CodeInvalidPath    ErrorCode = "INVALID_PATH"
+	CodeIgnored        ErrorCode = "IGNORED"
+	CodeBudgetExceeded ErrorCode = "BUDGET_EXCEEDED"
+	CodeMapUnavailable ErrorCode = "MAP_UNAVAILABLE"
+	CodeInternal       ErrorCode = "INTERNAL"
+	CodeUnsafeLink     ErrorCode = "UNSAFE_LINK"
+)
+
+// Rule mirrors linkmap.v1.Rule.
+type Rule struct {
+	Input        string              `json:"input"`
+	Output       string              `json:"output"`
+	Line         int                 `json:"line,omitempty"`
+	Source       string              `json:"source,omitempty"`
+	Status       int                 `json:"status,omitempty"`
+	CacheControl string              `json:"cache_control,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Conditions   []linkmap.Condition `json:"conditions,omitempty"`
+	Weighted     []WeightedOutput    `json:"weighted,omitempty"`
+	Scheduled    []ScheduledOutput   `json:"scheduled,omitempty"`
+}
+
+// WeightedOutput mirrors linkmap.v1.WeightedOutput.
+type WeightedOutput struct {
+	Output string `json:"output"`
+	Weight int    `json:"weight"`
+}
+
+// ScheduledOutput mirrors linkmap.v1.ScheduledOutput.
+type ScheduledOutput struct {
+	At     time.Time `json:"at"`
+	Output string    `json:"output"`
+}
+
+// Map mirrors linkmap.v1.Map.
+type Map struct {
+	Rules []Rule `json:"rules"`
+	// Hash is the map's linkmap.Map.Hash.
+	Hash string `json:"hash,omitempty"`
+}
+
+// NewRule converts a rule to its message form.
+func NewRule(r linkmap.Rule) Rule {
+	var weighted []WeightedOutput
+	for _, w := range r.Weighted {
+		weighted = append(weighted, WeightedOutput{Output: w.Output.String(), Weight: w.Weight})
+	}
+	var scheduled []ScheduledOutput
+	for _, s := range r.Scheduled {
+		scheduled = append(scheduled, ScheduledOutput{At: s.At, Output: s.Output.String()})
+	}
+	return Rule{
+		Input:        r.Input.String(),
+		Output:       r.Output.String(),
+		Line:         r.Line,
+		Source:       r.Source,
+		Status:       r.Status,
+		CacheControl: r.CacheControl,
+		Description:  r.Description,
+		Conditions:   r.Conditions,
+		Weighted:     weighted,
+		Scheduled:    scheduled,
+	}
+}
+
+// Evaluate evaluates a single path. Evaluation failures are reported in the
+// response; the error is only set if ctx is done.
+func (s *Service) Evaluate(ctx context.Context, req *EvaluateRequest) (*EvaluateResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.evaluate(req), nil
+}
+
+func (s *Service) evaluate(req *EvaluateRequest) *EvaluateResponse {
+	start := time.Now()
+	if _, err := s.Maps.Get(req.Map); err != nil {
+		resp := &EvaluateResponse{Path: req.Path, Code: CodeMapUnavailable, Error: err.Error()}
+		if s.Log != nil {
+			s.Log(LogEntry{Time: start, Map: req.Map, Path: req.Path, Code: resp.Code, Error: resp.Error, Latency: time.Since(start)})
+		}
+		return resp
+	}
+	res := s.Maps.Lookup(req.Map, req.Path)
+	if s.Log != nil {
+		s.Log(NewLogEntry(req.Map, res, start))
+	}
+	resp := response(res)
+	if s.Shadow != nil {
+		s.Shadow.compare(req, resp, start)
+	}
+	return resp
+}
+
+// response converts an evaluation result to its message form.
+func response(res linkmap.Result) *EvaluateResponse {
+	resp := &EvaluateResponse{Path: res.Path, Link: res.Link, Vars: res.Vars}
+	if res.Rule != nil {
+		r := NewRule(*res.Rule)
+		resp.Rule = &r
+	}
+	if res.Err != nil {
+		resp.Link, resp.Code, resp.Error = "", Code(res.Err), res.Err.Error()
+	}
+	return resp
+}
+
+// Code classifies an evaluation error.
+func Code(err error) ErrorCode {
+	var budget *linkmap.BudgetError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, linkmap.ErrNoMatches):
+		return CodeNoMatch
+	case errors.Is(err, linkmap.ErrIgnored):
+		return CodeIgnored
+	case errors.Is(err, linkmap.ErrInvalidPath):
+		return CodeInvalidPath
+	case errors.Is(err, linkmap.ErrUnsafeLink):
+		return CodeUnsafeLink
+	case errors.As(err, &budget):
+		return CodeBudgetExceeded
+	default:
+		return CodeInternal
+	}
+}
+
+// EvaluateBatch evaluates requests from recv until it returns io.EOF,
+// passing each response to send in order, as the streaming EvaluateBatch
+// RPC does.
+func (s *Service) EvaluateBatch(ctx context.Context, recv func() (*EvaluateRequest, error), send func(*EvaluateResponse) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		req, err := recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := send(s.evaluate(req)); err != nil {
+			return err
+		}
+	}
+}
+
+// GetMap returns the rules of the map with the given key, in evaluation
+// order.
+func (s *Service) GetMap(ctx context.Context, key string) (*Map, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m, err := s.Maps.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	resp := &Map{Rules: []Rule{}, Hash: m.Hash()}
+	for _, r := range m.Rules() {
+		resp.Rules = append(resp.Rules, NewRule(r))
+	}
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/operandinc/linkmap"
+)
+
+func testService() *Service {
+	sources := map[string]string{
+		"docs": "docs/$1.md https://example.com/$1\n",
+	}
+	return &Service{Maps: &linkmap.MapSet{
+		Load: func(key string) ([]byte, error) {
+			src, ok := sources[key]
+			if !ok {
+				return nil, errors.New("no such map")
+			}
+			return []byte(src), nil
+		},
+	}}
+}
+
+func TestEvaluate(t *testing.T) {
+	s := testService()
+	tests := []struct {
+		req  EvaluateRequest
+		link string
+		code ErrorCode
+	}{
+		{EvaluateRequest{Map: "docs", Path: "docs/a.md"}, "https://example.com/a", ""},
+		{EvaluateRequest{Map: "docs", Path: "other/a.md"}, "", CodeNoMatch},
+		{EvaluateRequest{Map: "docs", Path: "/abs"}, "", CodeInvalidPath},
+		{EvaluateRequest{Map: "nope", Path: "docs/a.md"}, "", CodeMapUnavailable},
+	}
+	for _, tt := range tests {
+		resp, err := s.Evaluate(context.Background(), &tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Link != tt.link || resp.Code != tt.code {
+			t.Errorf("Evaluate(%+v) = %+v; want link %q, code %q", tt.req, resp, tt.link, tt.code)
+		}
+	}
+	resp, _ := s.Evaluate(context.Background(), &EvaluateRequest{Map: "docs", Path: "docs/a.md"})
+	if resp.Rule == nil || resp.Rule.Input != "docs/$1.md" || resp.Vars["1"] != "a" {
+		t.Errorf("Evaluate() rule = %+v, vars = %v", resp.Rule, resp.Vars)
+	}
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	s := testService()
+	reqs := []*EvaluateRequest{
+		{Map: "docs", Path: "docs/a.md"},
+		{Map: "docs", Path: "docs/b.md"},
+		{Map: "docs", Path: "x"},
+	}
+	var got []*EvaluateResponse
+	recv := func() (*EvaluateRequest, error) {
+		if len(reqs) == 0 {
+			return nil, io.EOF
+		}
+		req := reqs[0]
+		reqs = reqs[1:]
+		return req, nil
+	}
+	send := func(resp *EvaluateResponse) error {
+		got = append(got, resp)
+		return nil
+	}
+	if err := s.EvaluateBatch(context.Background(), recv, send); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1].Link != "https://example.com/b" || got[2].Code != CodeNoMatch {
+		t.Errorf("EvaluateBatch() responses = %+v", got)
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	s := testService()
+	m, err := s.GetMap(context.Background(), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Rules) != 1 || m.Rules[0].Output != "https://example.com/$1" || m.Rules[0].Line != 1 {
+		t.Errorf("GetMap() = %+v", m)
+	}
+	if _, err := s.GetMap(context.Background(), "nope"); err == nil {
+		t.Errorf("GetMap(nope) succeeded")
+	}
+}
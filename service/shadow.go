@@ -0,0 +1,112 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/operandinc/linkmap"
+)
+
+// defaultShadowKeep is the default number of divergences a Shadow keeps.
+const defaultShadowKeep = 1000
+
+// A Shadow evaluates the requests a Service serves against candidate maps
+// as well, to validate large changes to maps on real traffic: the live
+// result is served, and each request for which the candidate gives a
+// different link or error code is recorded as a Divergence for review.
+// Candidate evaluations are neither logged nor served, but add to the time
+// each request takes. A Shadow is safe
+// for concurrent use; its configuration fields must not be changed after
+// first use.
+type Shadow struct {
+	// Maps holds the candidate maps, under the keys of the live maps they
+	// would replace. Requests for maps it cannot load are not compared.
+	Maps *linkmap.MapSet
+	// Record, if set, is called with each divergence, e.g. to log it.
+	Record func(Divergence)
+	// Keep is the number of recent divergences Divergences returns; if
+	// zero, 1000 are kept.
+	Keep int
+
+	mu       sync.Mutex
+	recent   []Divergence
+	compared uint64
+	diverged uint64
+}
+
+// A Divergence is a request for which a candidate map disagrees with the
+// live one.
+type Divergence struct {
+	Time time.Time `json:"time"`
+	Map  string    `json:"map,omitempty"`
+	Path string    `json:"path"`
+	// Link and Code are the live map's response, and CandidateLink and
+	// CandidateCode the candidate's.
+	Link          string    `json:"link,omitempty"`
+	Code          ErrorCode `json:"code,omitempty"`
+	CandidateLink string    `json:"candidate_link,omitempty"`
+	CandidateCode ErrorCode `json:"candidate_code,omitempty"`
+}
+
+// ShadowReport is the response to GET /divergences.
+type ShadowReport struct {
+	// Compared counts the requests evaluated against a candidate map, and
+	// Diverged those whose results differed.
+	Compared    uint64       `json:"compared"`
+	Diverged    uint64       `json:"diverged"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// compare evaluates req against the candidate map and records a
+// divergence from the live response, if any.
+func (sh *Shadow) compare(req *EvaluateRequest, live *EvaluateResponse, start time.Time) {
+	m, err := sh.Maps.Get(req.Map)
+	if err != nil {
+		return
+	}
+	cand := response(m.Lookup(req.Path))
+	diverged := cand.Link != live.Link || cand.Code != live.Code
+	sh.mu.Lock()
+	sh.compared++
+	if !diverged {
+		sh.mu.Unlock()
+		return
+	}
+	d := Divergence{
+		Time:          start,
+		Map:           req.Map,
+		Path:          req.Path,
+		Link:          live.Link,
+		Code:          live.Code,
+		CandidateLink: cand.Link,
+		CandidateCode: cand.Code,
+	}
+	sh.diverged++
+	keep := sh.Keep
+	if keep <= 0 {
+		keep = defaultShadowKeep
+	}
+	if len(sh.recent) >= keep {
+		sh.recent = append(sh.recent[:0], sh.recent[len(sh.recent)-keep+1:]...)
+	}
+	sh.recent = append(sh.recent, d)
+	sh.mu.Unlock()
+	if sh.Record != nil {
+		sh.Record(d)
+	}
+}
+
+// Report returns the counts of compared and diverging requests, with the
+// most recent divergences, oldest first, for the map with the given key,
+// or for all maps if key is empty.
+func (sh *Shadow) Report(key string) ShadowReport {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	r := ShadowReport{Compared: sh.compared, Diverged: sh.diverged, Divergences: []Divergence{}}
+	for _, d := range sh.recent {
+		if key == "" || d.Map == key {
+			r.Divergences = append(r.Divergences, d)
+		}
+	}
+	return r
+}
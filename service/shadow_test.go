@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/operandinc/linkmap"
+)
+
+func TestShadow(t *testing.T) {
+	s := testService()
+	var recorded []Divergence
+	s.Shadow = &Shadow{
+		Maps: &linkmap.MapSet{Load: func(key string) ([]byte, error) {
+			if key != "docs" {
+				return nil, errors.New("no candidate")
+			}
+			return []byte("docs/old/$1.md https://example.com/archive/$1\ndocs/$1.md https://example.com/$1\n"), nil
+		}},
+		Record: func(d Divergence) { recorded = append(recorded, d) },
+		Keep:   2,
+	}
+	for _, p := range []string{"docs/a.md", "docs/old/a.md", "docs/old/b.md", "docs/old/c.md", "x"} {
+		resp, err := s.Evaluate(context.Background(), &EvaluateRequest{Map: "docs", Path: p})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "https://example.com/" + strings.TrimSuffix(strings.TrimPrefix(p, "docs/"), ".md"); p != "x" && resp.Link != want {
+			t.Errorf("Evaluate(%q) = %q; want the live link %q", p, resp.Link, want)
+		}
+	}
+	if len(recorded) != 3 {
+		t.Fatalf("recorded %+v; want 3 divergences", recorded)
+	}
+	if d := recorded[0]; d.Path != "docs/old/a.md" || d.Link != "https://example.com/old/a" || d.CandidateLink != "https://example.com/archive/a" {
+		t.Errorf("divergence = %+v", d)
+	}
+	r := s.Shadow.Report("docs")
+	if r.Compared != 5 || r.Diverged != 3 || len(r.Divergences) != 2 || r.Divergences[1].Path != "docs/old/c.md" {
+		t.Errorf("Report() = %+v; want 5 compared, 3 diverged, the last 2 kept", r)
+	}
+	if r := s.Shadow.Report("other"); len(r.Divergences) != 0 {
+		t.Errorf("Report(%q) = %+v; want no divergences", "other", r)
+	}
+
+	rec := httptest.NewRecorder()
+	s.AdminHandler(0).ServeHTTP(rec, httptest.NewRequest("GET", "/divergences?map=docs", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"candidate_link":"https://example.com/archive/c"`) {
+		t.Errorf("GET /divergences = %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	testService().AdminHandler(0).ServeHTTP(rec, httptest.NewRequest("GET", "/divergences", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /divergences without a Shadow = %d; want 404", rec.Code)
+	}
+}
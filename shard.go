@@ -0,0 +1,198 @@
+package linkmap
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ShardFile is the name of the compiled file of each shard of a
+// ShardedMap, as found by LoadShards: the shard for the prefix "docs/api"
+// is "docs/api/shard.linkmap.bin", and that of the remaining paths is
+// "shard.linkmap.bin".
+const ShardFile = "shard" + BinaryExt
+
+// A ShardedMap is a map split into shards by input prefix, such as "docs"
+// or "docs/api", so that the shards of a huge map can be loaded, and
+// reloaded, concurrently. A path is evaluated against the shard of the
+// longest prefix enclosing it, or against the shard for the remaining
+// paths, registered as "", if none does; the result is the same as that of
+// the map which was split. A ShardedMap is safe for concurrent use.
+type ShardedMap struct {
+	shards map[string]*Map
+	// prefixes holds the keys of shards, longest first.
+	prefixes []string
+}
+
+// Shard splits the map into shards by the given prefixes. Each shard
+// holds the rules which can match the paths it is used for: rules whose
+// inputs start with a literal prefix go only into the shard of the longest
+// enclosing prefix, while rules which could match paths under several
+// prefixes, such as "$1/index.md", are copied into each of them.
+func (m *Map) Shard(prefixes ...string) (*ShardedMap, error) {
+	s := &ShardedMap{shards: make(map[string]*Map)}
+	for _, p := range append([]string{""}, prefixes...) {
+		if p != "" && (!fs.ValidPath(p) || p == ".") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPath, p)
+		}
+		if _, ok := s.shards[p]; ok {
+			return nil, fmt.Errorf("linkmap: duplicate shard prefix %q", p)
+		}
+		s.shards[p] = nil
+		s.prefixes = append(s.prefixes, p)
+	}
+	s.sortPrefixes()
+	for _, p := range s.prefixes {
+		var rules []Rule
+		for _, r := range m.rules {
+			if s.holds(p, literalPrefix(r.Input)) {
+				rules = append(rules, r)
+			}
+		}
+		s.shards[p] = m.withRules(rules)
+	}
+	return s, nil
+}
+
+// holds reports whether the shard for prefix p needs a rule whose inputs
+// start with lit.
+func (s *ShardedMap) holds(p, lit string) bool {
+	if p != "" && !strings.HasPrefix(lit, p+"/") && !strings.HasPrefix(p+"/", lit) {
+		return false
+	}
+	for _, q := range s.prefixes {
+		if len(q) > len(p) && strings.HasPrefix(lit, q+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// literalPrefix returns the literal text every path matched by t starts
+// with.
+func literalPrefix(t Template) string {
+	if t.re != nil {
+		// The anchored expression has no literal prefix; that of the
+		// source is the same.
+		re, err := regexp.Compile(t.re.src)
+		if err != nil {
+			return ""
+		}
+		lit, _ := re.LiteralPrefix()
+		return lit
+	}
+	if t.segs.len() == 0 || t.segs.at(0).typ != segmentTypeString {
+		return ""
+	}
+	return t.segs.at(0).val
+}
+
+func (s *ShardedMap) sortPrefixes() {
+	sort.Slice(s.prefixes, func(i, j int) bool {
+		if len(s.prefixes[i]) != len(s.prefixes[j]) {
+			return len(s.prefixes[i]) > len(s.prefixes[j])
+		}
+		return s.prefixes[i] < s.prefixes[j]
+	})
+}
+
+// LoadShards loads the shards of a map from the ShardFiles in fsys, as
+// written by linkmap shard, in parallel. Options are as for ParseBinary.
+func LoadShards(fsys fs.FS, opts ...Option) (*ShardedMap, error) {
+	var files []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == ShardFile {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	maps := make([]*Map, len(files))
+	errs := make([]error, len(files))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			data, err := fs.ReadFile(fsys, file)
+			if err == nil {
+				maps[i], err = ParseBinary(data, opts...)
+			}
+			if err != nil {
+				errs[i] = sourceError(file, err)
+			}
+		}(i, file)
+	}
+	wg.Wait()
+	s := &ShardedMap{shards: make(map[string]*Map, len(files))}
+	for i, file := range files {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		p := path.Dir(file)
+		if p == "." {
+			p = ""
+		}
+		s.shards[p] = maps[i]
+		s.prefixes = append(s.prefixes, p)
+	}
+	if _, ok := s.shards[""]; !ok {
+		return nil, fmt.Errorf("linkmap: no %s for the remaining paths", ShardFile)
+	}
+	s.sortPrefixes()
+	return s, nil
+}
+
+// Prefixes returns the prefixes of the shards, longest first, ending with
+// "" for the remaining paths.
+func (s *ShardedMap) Prefixes() []string {
+	return append([]string(nil), s.prefixes...)
+}
+
+// Shard returns the shard for prefix, or nil if there is none.
+func (s *ShardedMap) Shard(prefix string) *Map {
+	return s.shards[prefix]
+}
+
+// ShardFor returns the prefix of the shard fpath is evaluated against,
+// along with the shard. Prefixes are matched against paths as the rules
+// see them, after canonicalization and relative to the base, if any.
+func (s *ShardedMap) ShardFor(fpath string) (string, *Map) {
+	rest := s.shards[""]
+	if p, err := rest.inputPath(fpath); err == nil {
+		if p, _, err = rest.rebase(p, nil); err == nil {
+			fpath = p
+		}
+	}
+	for _, p := range s.prefixes {
+		if p == "" || strings.HasPrefix(fpath, p+"/") {
+			return p, s.shards[p]
+		}
+	}
+	return "", s.shards[""]
+}
+
+// Lookup evaluates fpath against its shard, as Map.Lookup does.
+func (s *ShardedMap) Lookup(fpath string) Result {
+	_, m := s.ShardFor(fpath)
+	return m.Lookup(fpath)
+}
+
+// Evaluate is like Lookup but returns only the link.
+func (s *ShardedMap) Evaluate(fpath string) (string, error) {
+	res := s.Lookup(fpath)
+	return res.Link, res.Err
+}
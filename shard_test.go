@@ -0,0 +1,70 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestShard(t *testing.T) {
+	const src = `docs/api/$1.md https://api.example.com/$1
+$1/index.md https://example.com/$1
+docs/$1.md https://example.com/docs/$1
+blog/$1.md https://example.com/blog/$1
+re:docs/(.+)\.txt https://example.com/txt/$1
+$1.md https://example.com/$1
+`
+	m, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Shard("docs", "docs/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(s.Prefixes(), ","); got != "docs/api,docs," {
+		t.Errorf("Prefixes() = %q", got)
+	}
+	if n := len(s.Shard("docs/api").Rules()); n != 5 {
+		t.Errorf("shard docs/api has %d rules; want 5", n)
+	}
+	if n := len(s.Shard("").Rules()); n != 3 {
+		t.Errorf("shard \"\" has %d rules; want 3", n)
+	}
+	fsys := fstest.MapFS{}
+	for _, p := range s.Prefixes() {
+		data, err := s.Shard(p).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := ShardFile
+		if p != "" {
+			name = p + "/" + ShardFile
+		}
+		fsys[name] = &fstest.MapFile{Data: data}
+	}
+	loaded, err := LoadShards(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{"docs/api/a.md", "docs/api/index.md", "docs/a.md", "docs/index.md", "docs/a.txt", "blog/a.md", "blog/index.md", "a.md", "x/y"}
+	for _, sm := range []*ShardedMap{s, loaded} {
+		for _, p := range paths {
+			want, wantErr := m.Evaluate(p)
+			if link, err := sm.Evaluate(p); link != want || (err == nil) != (wantErr == nil) {
+				t.Errorf("Evaluate(%q) = %q, %v; want %q, %v", p, link, err, want, wantErr)
+			}
+		}
+	}
+
+	if _, err := m.Shard("docs", "docs"); err == nil {
+		t.Error("Shard() with a duplicate prefix succeeded")
+	}
+	if _, err := m.Shard("../docs"); err == nil {
+		t.Error("Shard() with an invalid prefix succeeded")
+	}
+	delete(fsys, ShardFile)
+	if _, err := LoadShards(fsys); err == nil {
+		t.Error("LoadShards() without the remaining shard succeeded")
+	}
+}
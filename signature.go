@@ -0,0 +1,111 @@
+package linkmap
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// ErrSignature is returned when a linkmap's signature is missing, malformed
+// or not made by a trusted key.
+var ErrSignature = errors.New("linkmap: invalid signature")
+
+// Sign returns a detached signature of src made with key, for the map
+// named mapKey at the given version. The map key and version are signed
+// along with the source, so that a signed map cannot be served under
+// another key, nor replayed in place of a later version (see SignedLoad).
+// The signature is the version and the base64-encoded Ed25519 signature,
+// separated by a space and followed by a newline, suitable for storing
+// next to the linkmap, e.g. as "linkmap.sig".
+func Sign(key ed25519.PrivateKey, mapKey string, version uint64, src []byte) []byte {
+	sig := ed25519.Sign(key, signedMessage(mapKey, version, src))
+	out := strconv.AppendUint(nil, version, 10)
+	out = append(out, ' ')
+	out = append(out, base64.StdEncoding.EncodeToString(sig)...)
+	return append(out, '\n')
+}
+
+// signedMessage returns the message signed for src as the map mapKey at
+// version. The key is length-prefixed so that the message is unambiguous.
+func signedMessage(mapKey string, version uint64, src []byte) []byte {
+	msg := []byte(fmt.Sprintf("linkmap signature\n%d:%s\n%d\n", len(mapKey), mapKey, version))
+	return append(msg, src...)
+}
+
+// ParsePublicKey decodes a base64-encoded Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("linkmap: invalid public key %q", s)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// VerifySignature checks that sig, as produced by Sign, is a signature of
+// src as the map mapKey by one of keys, and returns the version it was
+// signed at. It returns an error wrapping ErrSignature otherwise. Keys of
+// the wrong size are ignored.
+func VerifySignature(mapKey string, src, sig []byte, keys ...ed25519.PublicKey) (uint64, error) {
+	v, enc, ok := bytes.Cut(bytes.TrimSpace(sig), []byte(" "))
+	if !ok {
+		return 0, fmt.Errorf("%w: malformed signature", ErrSignature)
+	}
+	version, err := strconv.ParseUint(string(v), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed signature version %q", ErrSignature, v)
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(enc))
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return 0, fmt.Errorf("%w: malformed signature", ErrSignature)
+	}
+	msg := signedMessage(mapKey, version, src)
+	for _, k := range keys {
+		// Verify panics on keys of the wrong size.
+		if len(k) == ed25519.PublicKeySize && ed25519.Verify(k, msg, raw) {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: not signed by a trusted key for %q", ErrSignature, mapKey)
+}
+
+// SignedLoad wraps load so that each source is only returned if the
+// signature returned by loadSig for the same key verifies against one of
+// keys, for that key. It is intended for MapSet.Load, so that tampered
+// maps are never applied:
+//
+//	s := &linkmap.MapSet{Load: linkmap.SignedLoad(fetchMap, fetchSig, releaseKey)}
+//
+// A source signed at a lower version than one the returned function has
+// already loaded for the same key is rejected, so that an old map cannot
+// be rolled back to by replaying its signature.
+func SignedLoad(load, loadSig func(key string) ([]byte, error), keys ...ed25519.PublicKey) func(key string) ([]byte, error) {
+	var (
+		mu       sync.Mutex
+		versions = make(map[string]uint64)
+	)
+	return func(key string) ([]byte, error) {
+		src, err := load(key)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := loadSig(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignature, err)
+		}
+		version, err := VerifySignature(key, src, sig, keys...)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if last, ok := versions[key]; ok && version < last {
+			return nil, fmt.Errorf("%w: version %d of %q is older than the loaded version %d", ErrSignature, version, key, last)
+		}
+		versions[key] = version
+		return src, nil
+	}
+}
@@ -0,0 +1,94 @@
+package linkmap
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, otherPriv, _ := ed25519.GenerateKey(nil)
+	src := []byte("docs/$1.md https://example.com/$1\n")
+	sig := Sign(priv, "docs", 7, src)
+
+	tests := []struct {
+		name string
+		key  string
+		src  []byte
+		sig  []byte
+		keys []ed25519.PublicKey
+		ok   bool
+	}{
+		{"valid", "docs", src, sig, []ed25519.PublicKey{pub}, true},
+		{"second key", "docs", src, sig, []ed25519.PublicKey{otherPub, pub}, true},
+		{"short key", "docs", src, sig, []ed25519.PublicKey{pub[:8], pub}, true},
+		{"tampered", "docs", []byte("docs/$1.md https://evil.example/$1\n"), sig, []ed25519.PublicKey{pub}, false},
+		{"other map", "blog", src, sig, []ed25519.PublicKey{pub}, false},
+		{"other version", "docs", src, append([]byte("8"), sig[1:]...), []ed25519.PublicKey{pub}, false},
+		{"untrusted key", "docs", src, Sign(otherPriv, "docs", 7, src), []ed25519.PublicKey{pub}, false},
+		{"malformed", "docs", src, []byte("not a signature"), []ed25519.PublicKey{pub}, false},
+		{"no version", "docs", src, sig[2:], []ed25519.PublicKey{pub}, false},
+		{"no keys", "docs", src, sig, nil, false},
+		{"only short keys", "docs", src, sig, []ed25519.PublicKey{nil, pub[:8]}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := VerifySignature(tt.key, tt.src, tt.sig, tt.keys...)
+			if tt.ok && (err != nil || version != 7) {
+				t.Errorf("VerifySignature() = %d, %v; want version 7", version, err)
+			}
+			if !tt.ok && !errors.Is(err, ErrSignature) {
+				t.Errorf("VerifySignature() = %v; want ErrSignature", err)
+			}
+		})
+	}
+}
+
+func TestSignedLoad(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := "docs/$1.md https://example.com/$1\n"
+	sources := map[string]string{
+		"good":  good,
+		"bad":   "docs/$1.md https://evil.example/$1\n",
+		"moved": good,
+	}
+	sigs := map[string][]byte{
+		"good":  Sign(priv, "good", 2, []byte(good)),
+		"bad":   Sign(priv, "bad", 1, []byte(good)),
+		"moved": Sign(priv, "good", 2, []byte(good)),
+	}
+	load := SignedLoad(
+		func(k string) ([]byte, error) { return []byte(sources[k]), nil },
+		func(k string) ([]byte, error) { return sigs[k], nil },
+		key,
+	)
+	s := &MapSet{Load: load}
+	if _, err := s.Get("good"); err != nil {
+		t.Errorf("Get(good) error: %v", err)
+	}
+	for _, k := range []string{"bad", "moved"} {
+		if _, err := s.Get(k); !errors.Is(err, ErrSignature) {
+			t.Errorf("Get(%s) = %v; want ErrSignature", k, err)
+		}
+	}
+
+	// Rolling back to an older version is rejected; newer ones load.
+	old := "docs/$1.md https://old.example.com/$1\n"
+	sources["good"], sigs["good"] = old, Sign(priv, "good", 1, []byte(old))
+	if _, err := load("good"); !errors.Is(err, ErrSignature) {
+		t.Errorf("load(good) at an older version = %v; want ErrSignature", err)
+	}
+	sources["good"], sigs["good"] = old, Sign(priv, "good", 3, []byte(old))
+	if _, err := load("good"); err != nil {
+		t.Errorf("load(good) at a newer version error: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the size and shape of a Map.
+type Stats struct {
+	// Rules is the number of rules.
+	Rules int
+	// Variables is the number of variables across all input templates.
+	Variables int
+	// ExtensionGroups is the number of extension groups across all input
+	// templates.
+	ExtensionGroups int
+	// MaxDepth is the largest number of path elements in an input template.
+	MaxDepth int
+	// Specificity counts rules by the number of segments in their input
+	// template. Rules with more segments are tried first.
+	Specificity map[int]int
+	// Matches holds the number of times each rule matched, in evaluation
+	// order. It is nil unless the map was parsed with WithCounters.
+	Matches []RuleCount
+	// Prefilter describes the map's prefilter. It is nil unless the map
+	// was parsed with WithPrefilter and every input starts with literal
+	// text.
+	Prefilter *PrefilterStats
+}
+
+// A RuleCount is the number of times a rule matched.
+type RuleCount struct {
+	Rule    Rule   `json:"rule"`
+	Matches uint64 `json:"matches"`
+}
+
+// Stats returns statistics about the map.
+func (m *Map) Stats() Stats {
+	s := Stats{
+		Rules:       len(m.rules),
+		Specificity: make(map[int]int),
+		Prefilter:   m.prefilter.stats(),
+	}
+	for _, r := range m.rules {
+		for _, seg := range r.Input.segs.refs {
+			switch seg.typ {
+			case segmentTypeVariable:
+				s.Variables++
+			case segmentTypeExtension:
+				s.ExtensionGroups++
+			}
+		}
+		if depth := strings.Count(r.Input.String(), "/") + 1; depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+		s.Specificity[r.Input.segs.len()]++
+	}
+	if m.counters != nil {
+		s.Matches = make([]RuleCount, len(m.rules))
+		for i, r := range m.rules {
+			s.Matches[i] = RuleCount{Rule: r, Matches: atomic.LoadUint64(&m.counters[i])}
+		}
+	}
+	return s
+}
+
+// count records a match of the i'th rule, if counters are enabled.
+func (m *Map) count(i int) {
+	if m.counters != nil {
+		atomic.AddUint64(&m.counters[i], 1)
+	}
+}
+
+// A CounterSnapshot is a copy of a map's match counters at a point in time.
+// It is suitable for encoding as JSON.
+type CounterSnapshot struct {
+	Time  time.Time   `json:"time"`
+	Rules []RuleCount `json:"rules"`
+}
+
+// Unused returns the rules which have not matched.
+func (s CounterSnapshot) Unused() []Rule {
+	var rules []Rule
+	for _, c := range s.Rules {
+		if c.Matches == 0 {
+			rules = append(rules, c.Rule)
+		}
+	}
+	return rules
+}
+
+// SnapshotCounters returns the current match counters. If the map was not
+// parsed with WithCounters, the snapshot has no rules.
+func (m *Map) SnapshotCounters() CounterSnapshot {
+	return CounterSnapshot{Time: time.Now(), Rules: m.Stats().Matches}
+}
+
+// ResetCounters sets the match counters to zero and returns their values
+// immediately beforehand. Matches which happen concurrently are counted in
+// either the snapshot or the fresh counters, never lost.
+func (m *Map) ResetCounters() CounterSnapshot {
+	s := CounterSnapshot{Time: time.Now()}
+	if m.counters == nil {
+		return s
+	}
+	s.Rules = make([]RuleCount, len(m.rules))
+	for i, r := range m.rules {
+		s.Rules[i] = RuleCount{Rule: r, Matches: atomic.SwapUint64(&m.counters[i], 0)}
+	}
+	return s
+}
+
+// RestoreCounters sets the match counters of the map's rules to those of a
+// snapshot, e.g. one taken before the map was reloaded. Each counter in
+// the snapshot is restored to a rule of the same source form, preferring
+// one of the same origin when several have it, or else to the rule of the
+// same origin, if its source form changed. Each rule is restored at most
+// once, and rules which are restored from no counter keep their counts.
+// Counters which match no rule are reported in a *RestoreError, after the
+// others are restored.
+func (m *Map) RestoreCounters(s CounterSnapshot) error {
+	if m.counters == nil {
+		return errors.New("linkmap: the map was not parsed WithCounters")
+	}
+	forms := make(map[string][]int, len(m.rules))
+	origins := make(map[string]int, len(m.rules))
+	for i, r := range m.rules {
+		forms[r.String()] = append(forms[r.String()], i)
+		if o := r.Origin(); o != "" {
+			origins[o] = i
+		}
+	}
+	used := make([]bool, len(m.rules))
+	restore := func(c RuleCount, i int) {
+		used[i] = true
+		atomic.StoreUint64(&m.counters[i], c.Matches)
+	}
+	// Match by source form first, so that a rule keeps its counter even if
+	// another took its line.
+	var rest []RuleCount
+	for _, c := range s.Rules {
+		found := -1
+		for _, i := range forms[c.Rule.String()] {
+			if used[i] {
+				continue
+			}
+			if found < 0 || m.rules[i].Origin() == c.Rule.Origin() {
+				found = i
+			}
+		}
+		if found < 0 {
+			rest = append(rest, c)
+			continue
+		}
+		restore(c, found)
+	}
+	var unmatched []RuleCount
+	for _, c := range rest {
+		i, ok := origins[c.Rule.Origin()]
+		if !ok || used[i] {
+			unmatched = append(unmatched, c)
+			continue
+		}
+		restore(c, i)
+	}
+	if unmatched != nil {
+		return &RestoreError{Unmatched: unmatched}
+	}
+	return nil
+}
+
+// A RestoreError reports the counters of a snapshot which RestoreCounters
+// could not restore to any rule.
+type RestoreError struct {
+	Unmatched []RuleCount
+}
+
+func (e *RestoreError) Error() string {
+	r := e.Unmatched[0].Rule
+	msg := fmt.Sprintf("linkmap: no rule to restore the counter of %q", r.String())
+	if o := r.Origin(); o != "" {
+		msg += " from " + o
+	}
+	if n := len(e.Unmatched) - 1; n > 0 {
+		msg += fmt.Sprintf(" and %d more", n)
+	}
+	return msg
+}
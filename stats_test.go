@@ -0,0 +1,130 @@
+package linkmap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	const src = "foo/$1.{md,mdx} https://example.com/posts/$1\n" +
+		"bar/$1/baz/$2.{html} https://example.com/$1/$2\n"
+	m, err := Parse(strings.NewReader(src), WithCounters())
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, p := range []string{"foo/a.md", "foo/b.mdx", "bar/a/baz/b.html", "nope"} {
+		m.Evaluate(p)
+	}
+	s := m.Stats()
+	if s.Rules != 2 || s.Variables != 3 || s.ExtensionGroups != 2 || s.MaxDepth != 4 {
+		t.Errorf("Stats() = %+v", s)
+	}
+	if s.Specificity[4] != 1 || s.Specificity[6] != 1 {
+		t.Errorf("Stats().Specificity = %v", s.Specificity)
+	}
+	want := map[string]uint64{"foo/$1.{md,mdx}": 2, "bar/$1/baz/$2.{html}": 1}
+	if len(s.Matches) != 2 {
+		t.Fatalf("Stats().Matches = %v; want 2 counts", s.Matches)
+	}
+	for _, c := range s.Matches {
+		if c.Matches != want[c.Rule.Input.String()] {
+			t.Errorf("matches of %s = %d; want %d", c.Rule.Input, c.Matches, want[c.Rule.Input.String()])
+		}
+	}
+}
+
+func TestStatsWithoutCounters(t *testing.T) {
+	m, err := Parse(strings.NewReader("foo/$1 https://example.com/$1\n"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	m.Evaluate("foo/a")
+	if s := m.Stats(); s.Matches != nil {
+		t.Errorf("Stats().Matches = %v; want nil", s.Matches)
+	}
+}
+
+func TestResetCounters(t *testing.T) {
+	const src = "foo/$1 https://example.com/foo/$1\nbar/$1 https://example.com/bar/$1\n"
+	m, err := Parse(strings.NewReader(src), WithCounters())
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	m.Evaluate("foo/a")
+	s := m.ResetCounters()
+	if unused := s.Unused(); len(unused) != 1 || unused[0].Line != 2 {
+		t.Errorf("ResetCounters().Unused() = %v; want rule on line 2", unused)
+	}
+	if unused := m.SnapshotCounters().Unused(); len(unused) != 2 {
+		t.Errorf("SnapshotCounters().Unused() after reset = %v; want 2 rules", unused)
+	}
+}
+
+func TestCounterSnapshotJSON(t *testing.T) {
+	m, err := Parse(strings.NewReader("foo/$1 https://example.com/$1\n"), WithCounters())
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	m.Evaluate("foo/a")
+	b, err := json.Marshal(m.SnapshotCounters())
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	var got CounterSnapshot
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) error: %v", b, err)
+	}
+	if len(got.Rules) != 1 || got.Rules[0].Matches != 1 || got.Rules[0].Rule.String() != "foo/$1 https://example.com/$1" {
+		t.Errorf("round trip = %+v", got)
+	}
+}
+
+func TestRestoreCounters(t *testing.T) {
+	const src = "foo/$1 https://example.com/foo/$1\nbar/$1 https://example.com/bar/$1\nbaz/$1 https://example.com/baz/$1\n"
+	m, err := Parse(strings.NewReader(src), WithCounters(), WithSource("a.linkmap"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	for _, p := range []string{"foo/a", "foo/b", "bar/a", "baz/a"} {
+		m.Evaluate(p)
+	}
+	b, err := json.Marshal(m.SnapshotCounters())
+	if err != nil {
+		t.Fatalf("json.Marshal() error: %v", err)
+	}
+	var s CounterSnapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("json.Unmarshal(%s) error: %v", b, err)
+	}
+
+	// A reload moves foo, changes bar's output and drops baz.
+	const reloaded = "new/$1 https://example.com/new/$1\nbar/$1 https://example.com/bar2/$1\nfoo/$1 https://example.com/foo/$1\n"
+	m2, err := Parse(strings.NewReader(reloaded), WithCounters(), WithSource("a.linkmap"))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	err = m2.RestoreCounters(s)
+	var rerr *RestoreError
+	if !errors.As(err, &rerr) || len(rerr.Unmatched) != 1 || rerr.Unmatched[0].Rule.Input.String() != "baz/$1" {
+		t.Errorf("RestoreCounters() error = %v; want baz/$1 unmatched", err)
+	}
+	want := map[string]uint64{"new/$1": 0, "bar/$1": 1, "foo/$1": 2}
+	for _, c := range m2.SnapshotCounters().Rules {
+		if c.Matches != want[c.Rule.Input.String()] {
+			t.Errorf("restored matches of %s = %d; want %d", c.Rule.Input, c.Matches, want[c.Rule.Input.String()])
+		}
+	}
+
+	m3, _ := Parse(strings.NewReader(src), WithCounters())
+	if err := m3.RestoreCounters(s); err != nil {
+		t.Errorf("RestoreCounters() into the same rules error = %v", err)
+	}
+	if got := m3.SnapshotCounters().Rules; got[0].Matches != 2 || got[2].Matches != 1 {
+		t.Errorf("restored counters = %v", got)
+	}
+	if err := m.RestoreCounters(CounterSnapshot{}); err != nil {
+		t.Errorf("RestoreCounters(empty) error = %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package linkmap
+
+import (
+	"sort"
+	"strings"
+)
+
+// A Suggestion is a rule which nearly matched a path.
+type Suggestion struct {
+	// Input and Output are the source forms of the rule's templates.
+	Input  string
+	Output string
+	// Shape is the input template with variables shown as "…", e.g.
+	// "docs/….md". It describes the paths the rule expects.
+	Shape string
+	// Distance is the number of single-byte edits needed to make the
+	// literal parts of the path match the rule.
+	Distance int
+}
+
+// Suggest returns up to n rules closest to fpath, nearest first. Variables
+// match anything, so distance is measured on literal segments only; rules
+// with regular expression inputs have none and are left out. If fpath
+// already matches a rule, Suggest returns nil. Unlike an evaluation, this
+// check does not count as a match (see WithCounters).
+func (m *Map) Suggest(fpath string, n int) []Suggestion {
+	if m.matches(fpath) {
+		return nil
+	}
+	sugs := make([]Suggestion, 0, len(m.rules))
+	for _, r := range m.rules {
+		if r.Input.re != nil {
+			continue
+		}
+		sugs = append(sugs, Suggestion{
+			Input:    r.Input.String(),
+			Output:   r.Output.String(),
+			Shape:    r.Input.segs.shape(),
+			Distance: r.Input.segs.distance(fpath),
+		})
+	}
+	sort.SliceStable(sugs, func(i, j int) bool {
+		return sugs[i].Distance < sugs[j].Distance
+	})
+	if n >= 0 && len(sugs) > n {
+		sugs = sugs[:n]
+	}
+	return sugs
+}
+
+// matches reports whether fpath evaluates to a link, as Lookup would find
+// it, without counting the match or affecting the prefilter's statistics.
+func (m *Map) matches(fpath string) bool {
+	var (
+		at  func(*Rule) (string, bool)
+		ctx *EvalContext
+	)
+	if m.urlInputs && isURL(fpath) {
+		u, err := parseURLInput(fpath)
+		if err != nil {
+			return false
+		}
+		at, ctx = u.form, ctx.with("query", u.query)
+	} else {
+		p, err := m.inputPath(fpath)
+		if err != nil || m.ignore.Match(p) {
+			return false
+		}
+		if p, _, err = m.rebase(p, nil); err == nil {
+			at = func(*Rule) (string, bool) { return p, true }
+		}
+	}
+	if at != nil {
+		for i := range m.rules {
+			in, ok := at(&m.rules[i])
+			if !ok {
+				continue
+			}
+			if res, ok := m.try(&m.rules[i], in, nil, ctx); ok {
+				return res.Err == nil
+			}
+		}
+	}
+	return m.next != nil && m.next.matches(fpath)
+}
+
+func (tmpl template) shape() string {
+	var b strings.Builder
+	for _, t := range tmpl.segments() {
+		if t.typ == segmentTypeVariable {
+			b.WriteString("…")
+		} else {
+			b.WriteString(t.val)
+		}
+	}
+	return b.String()
+}
+
+// distance returns the edit distance between s and the closest string
+// matched by the template, treating variables and custom segments as
+// wildcards.
+func (tmpl template) distance(s string) int {
+	// row[j] is the cost of matching the segments so far against s[:j].
+	row := make([]int, len(s)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for _, t := range tmpl.segments() {
+		switch t.typ {
+		case segmentTypeString:
+			row = editRow(row, t.val, s)
+		case segmentTypeVariable, segmentTypeCustom:
+			wildcardRow(row)
+		case segmentTypeExtension:
+			var best []int
+			for _, ext := range t.extensions() {
+				pre := strings.TrimSuffix(ext, "*")
+				next := editRow(row, pre, s)
+				if pre != ext {
+					next = append([]int(nil), next...)
+					wildcardRow(next)
+				}
+				if best == nil {
+					best = append([]int(nil), next...)
+					continue
+				}
+				for j := range best {
+					if next[j] < best[j] {
+						best[j] = next[j]
+					}
+				}
+			}
+			row = best
+		}
+	}
+	return row[len(s)]
+}
+
+// wildcardRow extends a row of the edit distance table, in place, by a
+// segment which matches any text.
+func wildcardRow(row []int) {
+	for j := 1; j < len(row); j++ {
+		if row[j-1] < row[j] {
+			row[j] = row[j-1]
+		}
+	}
+}
+
+// editRow extends a row of the edit distance table by the literal lit.
+func editRow(prev []int, lit, s string) []int {
+	for i := 0; i < len(lit); i++ {
+		next := make([]int, len(prev))
+		next[0] = prev[0] + 1
+		for j := 1; j < len(prev); j++ {
+			cost := 1
+			if lit[i] == s[j-1] {
+				cost = 0
+			}
+			next[j] = prev[j-1] + cost
+			if prev[j]+1 < next[j] {
+				next[j] = prev[j] + 1
+			}
+			if next[j-1]+1 < next[j] {
+				next[j] = next[j-1] + 1
+			}
+		}
+		prev = next
+	}
+	return prev
+}
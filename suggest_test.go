@@ -0,0 +1,60 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	m, err := Parse(strings.NewReader(
+		"docs/$1.{md,mdx} https://example.com/docs/$1\n" +
+			"blog/$1/$2.md https://example.com/blog/$1/$2\n",
+	))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	cases := []struct {
+		path     string
+		input    string
+		shape    string
+		distance int
+	}{
+		{path: "doc/intro.md", input: "docs/$1.{md,mdx}", shape: "docs/….{md,mdx}", distance: 1},
+		{path: "docs/intro.mdz", input: "docs/$1.{md,mdx}", shape: "docs/….{md,mdx}", distance: 1},
+		{path: "blogs/2022/hello.md", input: "blog/$1/$2.md", shape: "blog/…/….md", distance: 1},
+	}
+	for _, c := range cases {
+		sugs := m.Suggest(c.path, 1)
+		if len(sugs) != 1 {
+			t.Errorf("Suggest(%q) = %v; want 1 suggestion", c.path, sugs)
+			continue
+		}
+		if got := sugs[0]; got.Input != c.input || got.Shape != c.shape || got.Distance != c.distance {
+			t.Errorf("Suggest(%q) = %+v; want input %q, shape %q, distance %d", c.path, got, c.input, c.shape, c.distance)
+		}
+	}
+	if sugs := m.Suggest("docs/intro.md", 1); sugs != nil {
+		t.Errorf("Suggest(matching path) = %v; want nil", sugs)
+	}
+}
+
+func TestSuggestDoesNotCount(t *testing.T) {
+	m, err := Parse(strings.NewReader(
+		"re:^posts/([0-9]+)$ https://example.com/p/$1\n"+
+			"docs/$1.md https://example.com/docs/$1\n",
+	), WithCounters())
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if sugs := m.Suggest("docs/intro.md", -1); sugs != nil {
+		t.Errorf("Suggest(matching path) = %v; want nil", sugs)
+	}
+	if sugs := m.Suggest("posts/x", -1); len(sugs) != 1 || sugs[0].Input != "docs/$1.md" {
+		t.Errorf("Suggest() = %v; want only the docs rule", sugs)
+	}
+	for _, c := range m.SnapshotCounters().Rules {
+		if c.Matches != 0 {
+			t.Errorf("Suggest() counted %d matches of %s", c.Matches, c.Rule.Input)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CodeUnusedSuppression is reported by Linter for ignore comments which
+// suppress no diagnostic, such as those left behind after the rule was
+// fixed.
+const CodeUnusedSuppression = "unused-suppression"
+
+// ignoreDirective begins a comment which suppresses diagnostics for the
+// rule which follows it, e.g. "#linkmap:ignore similar-input". A space may
+// follow the '#'.
+const ignoreDirective = "linkmap:ignore"
+
+// A suppression is a code listed by an ignore comment.
+type suppression struct {
+	code string
+	// rule is the zero-based line of the rule it applies to.
+	rule int
+	// tok locates the code in the comment on line.
+	tok  token
+	line int
+	// codes is the number of codes in the comment.
+	codes int
+	used  bool
+}
+
+// suppressions returns the codes listed by ignore comments in text. A run
+// of comment lines directly above a rule applies to it; comments followed
+// by a blank line or the end of the text apply to nothing.
+func suppressions(text string) []*suppression {
+	var all, pending []*suppression
+	for n, l := range strings.Split(text, "\n") {
+		switch lineKind(l) {
+		case BlankLine:
+			all, pending = append(all, pending...), nil
+		case CommentLine:
+			off := 1
+			if strings.HasPrefix(l, "# ") {
+				off = 2
+			}
+			rest := strings.TrimPrefix(l[off:], ignoreDirective)
+			if rest == l[off:] || rest != "" && rest[0] != ' ' {
+				continue
+			}
+			// Codes are separated by spaces or commas.
+			toks := fields(strings.ReplaceAll(rest, ",", " "))
+			for _, tok := range toks {
+				tok.start += len(l) - len(rest)
+				pending = append(pending, &suppression{code: tok.text, rule: -1, tok: tok, line: n, codes: len(toks)})
+			}
+		case RuleLine:
+			for _, s := range pending {
+				s.rule = n
+			}
+			all, pending = append(all, pending...), nil
+		}
+	}
+	return append(all, pending...)
+}
+
+// suppress removes the diagnostics suppressed by ignore comments in text.
+// If enabled is set, it adds a diagnostic for each enabled code which
+// suppressed nothing; codes which are turned off cannot be expected to.
+func suppress(diags []Diagnostic, text string, enabled func(code string) bool) []Diagnostic {
+	sups := suppressions(text)
+	if len(sups) == 0 {
+		return diags
+	}
+	kept := diags[:0]
+	for _, d := range diags {
+		suppressed := false
+		for _, s := range sups {
+			if s.rule == d.Range.Start.Line && s.code == d.Code {
+				s.used, suppressed = true, true
+			}
+		}
+		if !suppressed {
+			kept = append(kept, d)
+		}
+	}
+	if enabled == nil {
+		return kept
+	}
+	for _, s := range sups {
+		if s.used || !enabled(s.code) {
+			continue
+		}
+		msg := fmt.Errorf("%s is not reported for the rule below", s.code)
+		if s.rule < 0 {
+			msg = fmt.Errorf("ignore comment for %s is not directly above a rule", s.code)
+		}
+		d := s.tok.diagnostic(s.line, CodeUnusedSuppression, msg)
+		d.Severity = SeverityWarning
+		if s.codes == 1 {
+			d.Fixes = []Fix{{
+				Title: "Remove ignore comment",
+				Edits: []TextEdit{{
+					Range: Range{Start: Position{s.line, 0}, End: Position{s.line + 1, 0}},
+				}},
+			}}
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
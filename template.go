@@ -0,0 +1,164 @@
+package linkmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Template is one side of a linkmap rule, such as "docs/$1.{md,mdx}".
+// The zero value is an empty template.
+type Template struct {
+	segs template
+	// text is set for outputs in text/template syntax.
+	text *textOutput
+	// re is set for inputs written as regular expressions.
+	re *regexpInput
+	// prefix is set for templates ending in '*'; see WithPrefixMatching.
+	prefix bool
+}
+
+// ParseTemplate parses a single template. Options other than WithSegment,
+// WithTmplOutputs and WithTextOutputs have no effect.
+//
+// A template prefixed with "tmpl:" is a Go text/template, which may only be
+// used as an output. It is executed with the matched variables as a map, so
+// that a named variable is written {{.slug}} and a numbered one
+// {{index . "1"}}. Besides the builtins, the functions default, lower,
+// upper, trimPrefix, trimSuffix, replace, contains, hasPrefix, hasSuffix,
+// pathEscape and queryEscape are available. In a linkmap file, such an
+// output extends to the end of the line and may contain spaces:
+//
+//	docs/$slug.md tmpl:https://example.com/{{if hasPrefix "v2-" .slug}}v2/{{end}}{{.slug}}
+//
+// Such templates are only accepted WithTmplOutputs. They may not invoke
+// other templates or range over anything but the variables, and fail to
+// execute once they or a function they call builds more than 16KB.
+func ParseTemplate(s string, opts ...Option) (Template, error) {
+	m := newMap(opts)
+	parse := m.parseOutput
+	if strings.HasPrefix(s, rePrefix) {
+		parse = m.parseInput
+	}
+	t, err := parse(s)
+	if err != nil {
+		return Template{}, fmt.Errorf("linkmap: failed to parse template %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// ParseInput parses an input template, such as the first field of a rule.
+// Unlike ParseTemplate, it never parses a text output, whatever the
+// options: WithTextOutputs applies to outputs alone.
+func ParseInput(s string, opts ...Option) (Template, error) {
+	t, err := newMap(opts).parseInput(s)
+	if err != nil {
+		return Template{}, fmt.Errorf("linkmap: failed to parse input %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// String returns the source form of the template.
+func (t Template) String() string {
+	switch {
+	case t.text != nil:
+		return textPrefix + t.text.src
+	case t.re != nil:
+		return rePrefix + t.re.src
+	}
+	if t.prefix {
+		return t.segs.String() + restMarker
+	}
+	return t.segs.String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Template) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Template) UnmarshalText(text []byte) error {
+	parsed, err := ParseTemplate(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Variables returns the names of the template's variables, without the
+// leading '$', in the order they appear. For a regular expression, these
+// are the numbers of its groups followed by the names of its named groups.
+// It returns nil for text/template outputs.
+func (t Template) Variables() []string {
+	if t.re != nil {
+		return t.re.variables()
+	}
+	return t.segs.variables()
+}
+
+// Match reports whether s matches the template and, if so, returns the
+// values of its variables keyed by name. A template ending in '*' matches
+// s if it matches a prefix of s; see WithPrefixMatching.
+func (t Template) Match(s string) (map[string]string, bool) {
+	if t.re != nil {
+		return t.re.match(s)
+	}
+	if t.prefix {
+		vars, _, ok := t.matchRest(s)
+		return vars, ok
+	}
+	return t.segs.match(s)
+}
+
+// Build is the inverse of Match: it constructs the string which the
+// template would match with the given variables, keyed by name without the
+// leading '$'. Extension groups produce their first alternative, which
+// must not be a wildcard. An error is returned if a variable is missing or
+// if its value would not be recovered by matching the result, e.g.
+// because it contains the literal text that follows it.
+func (t Template) Build(vars map[string]string) (string, error) {
+	if t.text != nil || t.re != nil {
+		return "", fmt.Errorf("linkmap: cannot build %q", t)
+	}
+	var b strings.Builder
+	for _, s := range t.segs.segments() {
+		switch s.typ {
+		case segmentTypeString:
+			b.WriteString(s.val)
+		case segmentTypeVariable, segmentTypeCustom:
+			val, ok := vars[s.name()]
+			if !ok {
+				return "", fmt.Errorf("linkmap: missing variable %s", s.val)
+			}
+			b.WriteString(val)
+		case segmentTypeExtension:
+			ext := s.extensions()[0]
+			if strings.HasSuffix(ext, "*") {
+				return "", fmt.Errorf("linkmap: cannot build wildcard extension %q", ext)
+			}
+			b.WriteString(ext)
+		case segmentTypeDest:
+			return "", fmt.Errorf("linkmap: cannot build %s", s.val)
+		}
+	}
+	built := b.String()
+	got, ok := t.segs.match(built)
+	if !ok {
+		return "", fmt.Errorf("linkmap: %q does not match template %q", built, t)
+	}
+	for _, name := range t.Variables() {
+		if got[name] != vars[name] {
+			return "", fmt.Errorf("linkmap: value %q for %s cannot be matched unambiguously", vars[name], name)
+		}
+	}
+	return built, nil
+}
+
+// expand builds an output from the variables bound by matching an input.
+func (t Template) expand(vars map[string]string, r Resolver) (string, error) {
+	if t.text != nil {
+		return t.text.execute(vars)
+	}
+	return t.segs.applyWith(vars, r)
+}
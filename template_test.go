@@ -0,0 +1,64 @@
+package linkmap
+
+import "testing"
+
+func TestBuild(t *testing.T) {
+	cases := []struct {
+		tmpl    string
+		vars    map[string]string
+		expect  string
+		wantErr bool
+	}{
+		{
+			tmpl:   "foo/posts/$1.{md,mdx}",
+			vars:   map[string]string{"1": "abc"},
+			expect: "foo/posts/abc.md",
+		},
+		{
+			tmpl:   "foo/$1/bar/$2.{html}",
+			vars:   map[string]string{"1": "abc", "2": "xyz"},
+			expect: "foo/abc/bar/xyz.html",
+		},
+		{
+			tmpl:    "foo/$1/bar/$2.{html}",
+			vars:    map[string]string{"1": "abc"},
+			wantErr: true,
+		},
+		{
+			tmpl:    "foo/posts/$1.{md,mdx}",
+			vars:    map[string]string{"1": "a.b"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		tmpl, err := ParseTemplate(c.tmpl)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) error: %v", c.tmpl, err)
+		}
+		got, err := tmpl.Build(c.vars)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("Build(%v) = %q; want error", c.vars, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Build(%v) error: %v", c.vars, err)
+		} else if got != c.expect {
+			t.Errorf("Build(%v) = %q; want %q", c.vars, got, c.expect)
+		}
+	}
+}
+
+func TestParseInput(t *testing.T) {
+	in, err := ParseInput("docs/$1.md", WithTextOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := in.String(); got != "docs/$1.md" {
+		t.Errorf("ParseInput() = %q", got)
+	}
+	if _, err := ParseInput("docs/$*/x"); err == nil {
+		t.Error("ParseInput() accepted a splat before the end")
+	}
+}
@@ -0,0 +1,314 @@
+package linkmap
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// textPrefix marks an output template written in text/template syntax.
+const textPrefix = "tmpl:"
+
+// maxTextOutput bounds the length of the link a text/template output
+// builds, and of every string its functions return.
+const maxTextOutput = 16 << 10
+
+var (
+	errTextDisabled = errors.New("text/template outputs are not enabled; see WithTmplOutputs")
+	errTextTooLong  = fmt.Errorf("text/template output is longer than %d bytes", maxTextOutput)
+)
+
+// textFuncs are the functions available to text/template outputs, in
+// addition to the text/template builtins. Like their sprig counterparts,
+// they take the value being operated on last so they can be used in
+// pipelines, e.g. {{.slug | trimSuffix "-draft" | lower}}. The builtins
+// which build strings are replaced by versions whose results are bounded,
+// like those of the other functions, by maxTextOutput.
+var textFuncs = texttemplate.FuncMap{
+	"default": func(def, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	"lower":      bounded(strings.ToLower),
+	"upper":      bounded(strings.ToUpper),
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace": func(old, new, s string) (string, error) {
+		if len(s)+strings.Count(s, old)*(len(new)-len(old)) > maxTextOutput {
+			return "", errTextTooLong
+		}
+		return strings.ReplaceAll(s, old, new), nil
+	},
+	"contains":    func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":   func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":   func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"pathEscape":  bounded(url.PathEscape),
+	"queryEscape": bounded(url.QueryEscape),
+	"print":       boundedArgs(fmt.Sprint),
+	"println":     boundedArgs(fmt.Sprintln),
+	"printf": func(format string, args ...interface{}) (string, error) {
+		return checkText(fmt.Sprintf(format, args...))
+	},
+	"html":     boundedArgs(texttemplate.HTMLEscaper),
+	"js":       boundedArgs(texttemplate.JSEscaper),
+	"urlquery": boundedArgs(texttemplate.URLQueryEscaper),
+}
+
+// checkText returns an error if s is longer than maxTextOutput.
+func checkText(s string) (string, error) {
+	if len(s) > maxTextOutput {
+		return "", errTextTooLong
+	}
+	return s, nil
+}
+
+// bounded returns fn with its result checked by checkText.
+func bounded(fn func(string) string) func(string) (string, error) {
+	return func(s string) (string, error) {
+		return checkText(fn(s))
+	}
+}
+
+// boundedArgs is bounded for functions of any arguments.
+func boundedArgs(fn func(...interface{}) string) func(...interface{}) (string, error) {
+	return func(args ...interface{}) (string, error) {
+		return checkText(fn(args...))
+	}
+}
+
+// A textOutput is an output template in text/template syntax.
+type textOutput struct {
+	src  string
+	tmpl *texttemplate.Template
+}
+
+// WithTmplOutputs accepts output templates prefixed with "tmpl:" as Go
+// text/templates, which are otherwise rejected. See ParseTemplate. Text
+// outputs are programs: though their execution is bounded, they should
+// only be accepted from maps as trusted as the program's own code.
+func WithTmplOutputs() Option {
+	return func(m *Map) {
+		m.tmplOutputs = true
+	}
+}
+
+// WithTextOutputs makes every output template a Go text/template, as if
+// each were prefixed with "tmpl:". It implies WithTmplOutputs.
+func WithTextOutputs() Option {
+	return func(m *Map) {
+		m.textOutputs, m.tmplOutputs = true, true
+	}
+}
+
+// WithoutTextOutputs rejects text/template outputs, overriding
+// WithTmplOutputs and WithTextOutputs given earlier, e.g. for maps from
+// untrusted sources parsed with the options of trusted ones.
+func WithoutTextOutputs() Option {
+	return func(m *Map) {
+		m.textOutputs, m.tmplOutputs = false, false
+	}
+}
+
+func parseText(src string) (*textOutput, error) {
+	tmpl, err := texttemplate.New("output").Funcs(textFuncs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("text/template outputs cannot define templates")
+	}
+	if err := checkTextNode(tmpl.Tree.Root, false); err != nil {
+		return nil, err
+	}
+	return &textOutput{src: src, tmpl: tmpl}, nil
+}
+
+// checkTextNode rejects the constructs which could make a text/template
+// output run for long: invoking templates, which may recurse, and range
+// over anything but the variables or one of them, which would allow range
+// over a large integer. rebound is set within with and range actions,
+// where dot no longer holds the variables.
+func checkTextNode(n parse.Node, rebound bool) error {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := checkTextNode(c, rebound); err != nil {
+				return err
+			}
+		}
+	case *parse.TemplateNode:
+		return fmt.Errorf("text/template outputs cannot invoke templates: %s", n)
+	case *parse.IfNode:
+		if err := checkTextNode(n.List, rebound); err != nil {
+			return err
+		}
+		return checkTextNode(n.ElseList, rebound)
+	case *parse.WithNode:
+		if err := checkTextNode(n.List, true); err != nil {
+			return err
+		}
+		return checkTextNode(n.ElseList, rebound)
+	case *parse.RangeNode:
+		if !rangesOverVars(n.Pipe, rebound) {
+			return fmt.Errorf("text/template outputs can only range over the variables: %s", n.Pipe)
+		}
+		if err := checkTextNode(n.List, true); err != nil {
+			return err
+		}
+		return checkTextNode(n.ElseList, rebound)
+	}
+	return nil
+}
+
+// rangesOverVars reports whether a range pipeline is dot, holding the
+// variables unless rebound, or a field, which holds a string.
+func rangesOverVars(p *parse.PipeNode, rebound bool) bool {
+	if len(p.Cmds) != 1 || len(p.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch p.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return !rebound
+	case *parse.FieldNode:
+		return true
+	}
+	return false
+}
+
+// uses reports whether the template may use the variable name: whether it
+// refers to a field of that name, has a string constant equal to it, as in
+// {{index . "1"}}, or ranges over all the variables. It errs on the side of
+// reporting a use.
+func (t *textOutput) uses(name string) bool {
+	var walk func(n parse.Node) bool
+	walk = func(n parse.Node) bool {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return false
+			}
+			for _, c := range n.Nodes {
+				if walk(c) {
+					return true
+				}
+			}
+		case *parse.ActionNode:
+			return walk(n.Pipe)
+		case *parse.IfNode:
+			return walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		case *parse.WithNode:
+			return walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		case *parse.RangeNode:
+			return rangesOverVars(n.Pipe, false) || walk(n.Pipe) || walk(n.List) || walk(n.ElseList)
+		case *parse.PipeNode:
+			if n == nil {
+				return false
+			}
+			for _, c := range n.Cmds {
+				if walk(c) {
+					return true
+				}
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				if walk(a) {
+					return true
+				}
+			}
+		case *parse.ChainNode:
+			return walk(n.Node)
+		case *parse.FieldNode:
+			return n.Ident[0] == name
+		case *parse.VariableNode:
+			return len(n.Ident) > 1 && n.Ident[0] == "$" && n.Ident[1] == name
+		case *parse.StringNode:
+			return n.Text == name
+		}
+		return false
+	}
+	return walk(t.tmpl.Tree.Root)
+}
+
+// parseOutput parses an output template, which may be a text/template.
+func (m *Map) parseOutput(s string) (Template, error) {
+	if m.textOutputs || strings.HasPrefix(s, textPrefix) {
+		if !m.tmplOutputs {
+			return Template{}, errTextDisabled
+		}
+		text, err := parseText(strings.TrimPrefix(s, textPrefix))
+		if err != nil {
+			return Template{}, err
+		}
+		return Template{text: text}, nil
+	}
+	s, prefix := m.cutRestMarker(s)
+	segs, err := parseTemplateIn(s, m.kinds, m.arena)
+	if err != nil {
+		return Template{}, err
+	}
+	return Template{segs: segs, prefix: prefix}, nil
+}
+
+func (t *textOutput) execute(vars map[string]string) (string, error) {
+	var w limitedBuilder
+	if err := t.tmpl.Execute(&w, vars); err != nil {
+		if w.err != nil {
+			return "", w.err
+		}
+		return "", err
+	}
+	return w.b.String(), nil
+}
+
+// A limitedBuilder is a strings.Builder which fails writes beyond
+// maxTextOutput, stopping the execution of a text/template.
+type limitedBuilder struct {
+	b   strings.Builder
+	err error
+}
+
+func (w *limitedBuilder) Write(p []byte) (int, error) {
+	if w.b.Len()+len(p) > maxTextOutput {
+		w.err = errTextTooLong
+		return 0, w.err
+	}
+	return w.b.Write(p)
+}
+
+// approx approximates the template for static checks: its literal text is
+// kept, and each action is treated as a variable.
+func (t *textOutput) approx() template {
+	var segs []segment
+	rest := t.src
+	for rest != "" {
+		before, after, ok := strings.Cut(rest, "{{")
+		if before != "" {
+			segs = append(segs, segment{typ: segmentTypeString, val: before})
+		}
+		if !ok {
+			break
+		}
+		segs = append(segs, segment{typ: segmentTypeVariable, val: "$_"})
+		_, rest, _ = strings.Cut(after, "}}")
+	}
+	return makeTemplate(segs)
+}
+
+// ruleFields splits a rule line into its input and output tokens. A
+// text/template output extends to the end of the line and may contain
+// spaces.
+func ruleFields(l string, text bool) []token {
+	toks := fields(l)
+	if len(toks) > 2 && (text || strings.HasPrefix(toks[1].text, textPrefix)) {
+		toks = append(toks[:1], token{text: strings.TrimRight(l[toks[1].start:], " \t"), start: toks[1].start})
+	}
+	return toks
+}
@@ -0,0 +1,128 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTextOutputs(t *testing.T) {
+	src := strings.Join([]string{
+		`docs/$slug.md tmpl:https://example.com/{{if hasPrefix "v2-" .slug}}v2/{{trimPrefix "v2-" .slug}}{{else}}{{.slug}}{{end}}`,
+		`blog/$1/$2.md tmpl:https://example.com/{{index . "1" | upper}}/{{index . "2" | default "index"}}`,
+		`bad/$1.md tmpl:https://example.com/{{.missing}}`,
+		`plain/$1.md https://example.com/plain/$1`,
+	}, "\n")
+	m, err := Parse(strings.NewReader(src), WithTmplOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"docs/v2-intro.md", "https://example.com/v2/intro", false},
+		{"docs/intro.md", "https://example.com/intro", false},
+		{"blog/news/hello.md", "https://example.com/NEWS/hello", false},
+		{"bad/x.md", "", true},
+		{"plain/x.md", "https://example.com/plain/x", false},
+	}
+	for _, tt := range tests {
+		got, err := m.Evaluate(tt.path)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q, error %v", tt.path, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	var b strings.Builder
+	m.WriteTo(&b)
+	m2, err := Parse(strings.NewReader(b.String()), WithTmplOutputs())
+	if err != nil {
+		t.Fatalf("Parse(WriteTo()) error: %v", err)
+	}
+	if got, _ := m2.Evaluate("docs/v2-intro.md"); got != "https://example.com/v2/intro" {
+		t.Errorf("round trip: Evaluate() = %q", got)
+	}
+	if diags := Analyze(src, WithTmplOutputs()); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+	if diags := Analyze("docs/$1.md tmpl:{{.x", WithTmplOutputs()); len(diags) != 1 || diags[0].Code != CodeInvalidTemplate {
+		t.Errorf("Analyze(invalid text template) = %v", diags)
+	}
+}
+
+func TestWithTextOutputs(t *testing.T) {
+	src := `docs/$slug.md https://example.com/{{.slug | replace "_" "-"}}`
+	m, err := Parse(strings.NewReader(src), WithTextOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.Evaluate("docs/a_b.md"); err != nil || got != "https://example.com/a-b" {
+		t.Errorf("Evaluate() = %q, %v", got, err)
+	}
+	if _, err := Parse(strings.NewReader(src)); err == nil {
+		t.Errorf("Parse() without WithTextOutputs succeeded")
+	}
+}
+
+func TestLintTextOutputs(t *testing.T) {
+	l := &Linter{AllowedHosts: []string{"example.com"}, Options: []Option{WithTmplOutputs()}}
+	src := "docs/$1.md tmpl:https://evil.example/{{index . \"1\"}}\n"
+	if diags := l.Lint(src); len(diags) != 1 || diags[0].Code != CodeDisallowedHost {
+		t.Errorf("Lint() = %v; want disallowed-host", diags)
+	}
+}
+
+func TestTextOutputsDisabled(t *testing.T) {
+	src := "docs/$1.md tmpl:https://example.com/{{index . \"1\"}}\n"
+	if _, err := Parse(strings.NewReader(src)); err == nil || !strings.Contains(err.Error(), "WithTmplOutputs") {
+		t.Errorf("Parse() without WithTmplOutputs = %v", err)
+	}
+	if _, err := Parse(strings.NewReader(src), WithTmplOutputs(), WithoutTextOutputs()); err == nil {
+		t.Errorf("Parse() with WithoutTextOutputs succeeded")
+	}
+	m, err := Parse(strings.NewReader(src), WithTmplOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseBinary(data); err == nil {
+		t.Errorf("ParseBinary() without WithTmplOutputs succeeded")
+	}
+}
+
+func TestTextOutputLimits(t *testing.T) {
+	for _, src := range []string{
+		`{{range 30000000}}x{{end}}`,
+		`{{range $i, $c := "abc"}}{{end}}`,
+		`{{with 5}}{{range .}}x{{end}}{{end}}`,
+		`{{define "a"}}{{template "a"}}{{end}}{{template "a"}}`,
+		`{{block "b" .}}x{{end}}`,
+	} {
+		if _, err := ParseTemplate(textPrefix+src, WithTmplOutputs()); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded", src)
+		}
+	}
+	if _, err := ParseTemplate(`tmpl:{{range .}}{{.}}{{end}}{{range .slug}}x{{end}}`, WithTmplOutputs()); err != nil {
+		t.Errorf("ParseTemplate(range over the variables) = %v", err)
+	}
+
+	long := strings.Repeat("a", 1000)
+	for _, src := range []string{
+		`docs/$1 tmpl:{{index . "1" | replace "a" "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}}`,
+		`docs/$1 tmpl:{{printf "%99999s" "x"}}`,
+		`docs/$1 tmpl:{{range .}}` + strings.Repeat(`{{index $ "1"}}`, 20) + `{{end}}`,
+	} {
+		m, err := Parse(strings.NewReader(src), WithTmplOutputs())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Evaluate("docs/" + long); !errors.Is(err, errTextTooLong) {
+			t.Errorf("Evaluate() with %q = %v; want %v", src, err, errTextTooLong)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package linkmap
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ParseTOML parses a linkmap in TOML format, in which each rule is a
+// [[rule]] table:
+//
+//	[[rule]]
+//	from = "docs/$slug.md"
+//	to = "https://example.com/docs/$slug"
+//	status = 301
+//	cache_control = "public, max-age=86400"
+//	description = "Documentation pages"
+//	conditions = { mime = "text/*" }
+//
+// from and to are required and use the same template syntax as the text
+// format. Conditions may also be given as a [rule.conditions] table. A
+// [rule.weighted] table gives weighted outputs (see Rule.Weighted), each
+// a key with its percentage:
+//
+//	[rule.weighted]
+//	"https://new.example.com/docs/$slug" = 10
+//
+// and a [rule.scheduled] table scheduled outputs (see Rule.Scheduled),
+// each keyed by its cutover time in RFC 3339 format:
+//
+//	[rule.scheduled]
+//	"2024-06-01T09:00:00Z" = "https://docs.example.com/$slug"
+//
+// Only the subset of TOML needed for this schema is supported: single-line
+// strings, integers and inline tables of strings.
+func ParseTOML(reader io.Reader, opts ...Option) (*Map, error) {
+	m := newMap(opts)
+	m.arena = &segmentArena{}
+	buf, err := m.limits.readSource(reader)
+	if err != nil {
+		return nil, err
+	}
+	src, _, _, err := decodeSource(buf)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		rules []Rule
+		// table is the table keys are added to: the current rule, or its
+		// conditions.
+		table string
+		set   map[string]bool
+	)
+	finish := func() error {
+		if len(rules) == 0 {
+			return nil
+		}
+		r := &rules[len(rules)-1]
+		if !set["from"] || !set["to"] {
+			return fmt.Errorf("linkmap: toml line %d: rule requires from and to", r.Line)
+		}
+		if err := r.checkWeights(); err != nil {
+			return fmt.Errorf("linkmap: toml line %d: %v", r.Line, err)
+		}
+		if err := m.limits.checkRule(len(rules), *r); err != nil {
+			return err
+		}
+		return nil
+	}
+	for n, l := range strings.Split(src, "\n") {
+		n++
+		if err := m.limits.checkLine(n, l); err != nil {
+			return nil, err
+		}
+		l = strings.TrimSpace(l)
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("linkmap: toml line %d: %s", n, fmt.Sprintf(format, args...))
+		}
+		switch {
+		case l == "" || l[0] == '#':
+			continue
+		case l[0] == '[':
+			header, rest, ok := strings.Cut(l, "]")
+			if strings.HasPrefix(l, "[[") {
+				header, rest, ok = strings.Cut(l[1:], "]]")
+			}
+			if !ok || !isTOMLComment(rest) {
+				return nil, errorf("invalid table header")
+			}
+			switch strings.TrimSpace(header[1:]) {
+			case "rule":
+				if !strings.HasPrefix(l, "[[") {
+					return nil, errorf("rule must be an array of tables, [[rule]]")
+				}
+				if err := finish(); err != nil {
+					return nil, err
+				}
+				rules = append(rules, Rule{Line: n, Source: m.source})
+				table, set = "rule", make(map[string]bool)
+			case "rule.conditions":
+				if len(rules) == 0 || strings.HasPrefix(l, "[[") || set["conditions"] {
+					return nil, errorf("unexpected table %s", l)
+				}
+				table, set["conditions"] = "conditions", true
+			case "rule.weighted":
+				if len(rules) == 0 || strings.HasPrefix(l, "[[") || set["weighted"] {
+					return nil, errorf("unexpected table %s", l)
+				}
+				table, set["weighted"] = "weighted", true
+			case "rule.scheduled":
+				if len(rules) == 0 || strings.HasPrefix(l, "[[") || set["scheduled"] {
+					return nil, errorf("unexpected table %s", l)
+				}
+				table, set["scheduled"] = "scheduled", true
+			default:
+				return nil, errorf("unknown table %s", l)
+			}
+			continue
+		}
+		if len(rules) == 0 {
+			return nil, errorf("key outside of a [[rule]] table")
+		}
+		key, rest, err := tomlKey(l)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		val, rest, err := tomlValue(rest)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		if !isTOMLComment(rest) {
+			return nil, errorf("unexpected %q after value", rest)
+		}
+		r := &rules[len(rules)-1]
+		if table != "rule" {
+			// Keys of the rule's subtables are set as table.key, apart
+			// from the rule's own keys.
+			if set[table+"."+key] {
+				return nil, errorf("duplicate key %s in [rule.%s]", key, table)
+			}
+			set[table+"."+key] = true
+		}
+		if table == "conditions" {
+			s, ok := val.(string)
+			if !ok {
+				return nil, errorf("condition %s must be a string", key)
+			}
+			c, err := condition(key, s)
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			r.Conditions = append(r.Conditions, c)
+			continue
+		}
+		if table == "weighted" {
+			weight, ok := val.(int)
+			if !ok {
+				return nil, errorf("weight of %s must be an integer", key)
+			}
+			out, err := m.parseOutput(key)
+			if err != nil {
+				return nil, errorf("failed to parse template %q: %v", key, err)
+			}
+			r.Weighted = append(r.Weighted, WeightedOutput{Output: out, Weight: weight})
+			continue
+		}
+		if table == "scheduled" {
+			at, err := parseCutover(key)
+			if err != nil {
+				return nil, errorf("%v", err)
+			}
+			s, ok := val.(string)
+			if !ok {
+				return nil, errorf("scheduled output at %s must be a string", key)
+			}
+			out, err := m.parseOutput(s)
+			if err != nil {
+				return nil, errorf("failed to parse template %q: %v", s, err)
+			}
+			r.Scheduled = append(r.Scheduled, ScheduledOutput{At: at, Output: out})
+			continue
+		}
+		if set[key] {
+			return nil, errorf("duplicate key %s", key)
+		}
+		set[key] = true
+		if err := m.setTOMLKey(r, key, val); err != nil {
+			return nil, errorf("%v", err)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return New(rules, opts...), nil
+}
+
+// setTOMLKey sets a field of r from a key of its [[rule]] table.
+func (m *Map) setTOMLKey(r *Rule, key string, val interface{}) error {
+	str, isStr := val.(string)
+	switch key {
+	case "from", "to", "description", "cache_control":
+		if !isStr {
+			return fmt.Errorf("%s must be a string", key)
+		}
+	}
+	var err error
+	switch key {
+	case "from":
+		if r.Input, err = m.parseInput(str); err != nil {
+			return fmt.Errorf("failed to parse template %q: %w", str, err)
+		}
+	case "to":
+		if r.Output, err = m.parseOutput(str); err != nil {
+			return fmt.Errorf("failed to parse template %q: %w", str, err)
+		}
+	case "description":
+		r.Description = str
+	case "cache_control":
+		r.CacheControl = str
+	case "status":
+		status, ok := val.(int)
+		if !ok || status < 100 || status > 599 {
+			return fmt.Errorf("status must be an HTTP status code")
+		}
+		r.Status = status
+	case "conditions":
+		table, ok := val.(map[string]string)
+		if !ok {
+			return fmt.Errorf("conditions must be a table of strings")
+		}
+		keys := make([]string, 0, len(table))
+		for k := range table {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			c, err := condition(k, table[k])
+			if err != nil {
+				return err
+			}
+			r.Conditions = append(r.Conditions, c)
+		}
+	default:
+		return fmt.Errorf("unknown key %s", key)
+	}
+	return nil
+}
+
+func condition(name, pattern string) (Condition, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return Condition{}, fmt.Errorf("invalid pattern %q for condition %s", pattern, name)
+	}
+	return Condition{Var: name, Pattern: pattern}, nil
+}
+
+// tomlKey parses a bare or quoted key followed by '='.
+func tomlKey(s string) (key, rest string, err error) {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		v, rest, err := tomlValue(s)
+		if err != nil {
+			return "", "", err
+		}
+		key = v.(string)
+		s = rest
+	} else {
+		i := 0
+		for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' ||
+			s[i] >= '0' && s[i] <= '9' || s[i] == '_' || s[i] == '-') {
+			i++
+		}
+		if i == 0 {
+			return "", "", fmt.Errorf("expected a key")
+		}
+		key, s = s[:i], s[i:]
+	}
+	s = strings.TrimLeft(s, " \t")
+	if !strings.HasPrefix(s, "=") {
+		return "", "", fmt.Errorf("expected '=' after key %s", key)
+	}
+	return key, strings.TrimLeft(s[1:], " \t"), nil
+}
+
+// tomlValue parses a string, integer or inline table of strings at the
+// start of s.
+func tomlValue(s string) (val interface{}, rest string, err error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("expected a value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, "", fmt.Errorf("multi-line strings are not supported")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '"':
+		return tomlBasicString(s)
+	case s[0] == '{':
+		table := make(map[string]string)
+		s = strings.TrimLeft(s[1:], " \t")
+		for !strings.HasPrefix(s, "}") {
+			key, rest, err := tomlKey(s)
+			if err != nil {
+				return nil, "", err
+			}
+			v, rest, err := tomlValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			str, ok := v.(string)
+			if !ok {
+				return nil, "", fmt.Errorf("inline table values must be strings")
+			}
+			if _, dup := table[key]; dup {
+				return nil, "", fmt.Errorf("duplicate key %s", key)
+			}
+			table[key] = str
+			s = strings.TrimLeft(rest, " \t")
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimLeft(s[1:], " \t")
+			} else if !strings.HasPrefix(s, "}") {
+				return nil, "", fmt.Errorf("expected ',' or '}' in inline table")
+			}
+		}
+		return table, s[1:], nil
+	default:
+		end := strings.IndexAny(s, " \t#")
+		if end < 0 {
+			end = len(s)
+		}
+		i, err := strconv.Atoi(strings.ReplaceAll(s[:end], "_", ""))
+		if err != nil {
+			return nil, "", fmt.Errorf("unsupported value %q", s[:end])
+		}
+		return i, s[end:], nil
+	}
+}
+
+// tomlBasicString parses a double-quoted string at the start of s.
+func tomlBasicString(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", fmt.Errorf("unterminated string")
+			}
+			i++
+			switch e := s[i]; e {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(e)
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if i+size >= len(s) {
+					return "", "", fmt.Errorf("invalid escape")
+				}
+				code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(code)) {
+					return "", "", fmt.Errorf("invalid escape \\%c%s", e, s[i+1:i+1+size])
+				}
+				b.WriteRune(rune(code))
+				i += size
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// isTOMLComment reports whether s is empty apart from whitespace and a
+// comment.
+func isTOMLComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// WriteTOML writes the map's rules in TOML format, in evaluation order.
+func (m *Map) WriteTOML(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for i, r := range m.rules {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString("[[rule]]\n")
+		fmt.Fprintf(&b, "from = %s\n", tomlQuote(r.Input.String()))
+		fmt.Fprintf(&b, "to = %s\n", tomlQuote(r.Output.String()))
+		if r.Status != 0 {
+			fmt.Fprintf(&b, "status = %d\n", r.Status)
+		}
+		if r.CacheControl != "" {
+			fmt.Fprintf(&b, "cache_control = %s\n", tomlQuote(r.CacheControl))
+		}
+		if r.Description != "" {
+			fmt.Fprintf(&b, "description = %s\n", tomlQuote(r.Description))
+		}
+		if len(r.Conditions) > 0 {
+			b.WriteString("\n[rule.conditions]\n")
+			for _, c := range r.Conditions {
+				fmt.Fprintf(&b, "%s = %s\n", tomlQuote(c.Var), tomlQuote(c.Pattern))
+			}
+		}
+		if len(r.Weighted) > 0 {
+			b.WriteString("\n[rule.weighted]\n")
+			for _, w := range r.Weighted {
+				fmt.Fprintf(&b, "%s = %d\n", tomlQuote(w.Output.String()), w.Weight)
+			}
+		}
+		if len(r.Scheduled) > 0 {
+			b.WriteString("\n[rule.scheduled]\n")
+			for _, s := range r.Scheduled {
+				fmt.Fprintf(&b, "%s = %s\n", tomlQuote(s.At.Format(time.RFC3339Nano)), tomlQuote(s.Output.String()))
+			}
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// tomlQuote returns s as a TOML basic string.
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\u%04X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package linkmap
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTOML = `# Redirects
+[[rule]]
+from = "docs/$slug.md"
+to = "https://example.com/docs/$slug"
+status = 301
+cache_control = "public, max-age=86400"
+description = "Documentation \"pages\""
+
+[[rule]]
+from = 'assets/$1'
+to = "https://img.example.com/$1"  # images only
+conditions = { mime = "image/*" }
+
+[[rule]]
+from = "assets/$1"
+to = "tmpl:https://cdn.example.com/{{index . \"1\"}}"
+
+[rule.conditions]
+"1" = "*.css"
+`
+
+func TestParseTOML(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(testTOML), WithTmplOutputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"docs/intro.md", "https://example.com/docs/intro"},
+		{"assets/logo.png", "https://img.example.com/logo.png"},
+		{"assets/site.css", "https://cdn.example.com/site.css"},
+		{"assets/app.js", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := m.Evaluate(tt.path); got != tt.want {
+			t.Errorf("Evaluate(%q) = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+	res := m.Lookup("docs/intro.md")
+	if res.Rule.Status != 301 || res.Rule.Description != `Documentation "pages"` || res.Rule.Line != 2 {
+		t.Errorf("Lookup().Rule = %+v", res.Rule)
+	}
+
+	var b strings.Builder
+	if _, err := m.WriteTOML(&b); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := ParseTOML(strings.NewReader(b.String()), WithTmplOutputs())
+	if err != nil {
+		t.Fatalf("ParseTOML(WriteTOML()) error: %v\n%s", err, b.String())
+	}
+	if len(m2.Rules()) != 3 {
+		t.Fatalf("round trip has %d rules", len(m2.Rules()))
+	}
+	if cc := m2.Rules()[0].CacheControl; cc != "public, max-age=86400" {
+		t.Errorf("round trip: CacheControl = %q", cc)
+	}
+	for _, tt := range tests {
+		if got, _ := m2.Evaluate(tt.path); got != tt.want {
+			t.Errorf("round trip: Evaluate(%q) = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"missing to", "[[rule]]\nfrom = \"a\"\n", "line 1: rule requires from and to"},
+		{"unknown key", "[[rule]]\nfrom = \"a\"\nto = \"b\"\ncolor = \"red\"\n", "line 4: unknown key color"},
+		{"bad status", "[[rule]]\nfrom = \"a\"\nto = \"b\"\nstatus = 42\n", "line 4: status"},
+		{"bad template", "[[rule]]\nfrom = \"$\"\nto = \"b\"\n", "line 2: failed to parse template"},
+		{"outside table", "from = \"a\"\n", "line 1: key outside"},
+		{"single table", "[rule]\n", "line 1: rule must be an array"},
+		{"duplicate", "[[rule]]\nfrom = \"a\"\nfrom = \"b\"\n", "line 3: duplicate key from"},
+		{"duplicate condition", "[[rule]]\nfrom = \"a\"\nto = \"b\"\n[rule.conditions]\nmime = \"text/*\"\nmime = \"image/*\"\n", "line 6: duplicate key mime in [rule.conditions]"},
+		{"duplicate inline condition", "[[rule]]\nfrom = \"a\"\nto = \"b\"\nconditions = { mime = \"text/*\", mime = \"image/*\" }\n", "line 4: duplicate key mime"},
+		{"bad pattern", "[[rule]]\nfrom = \"a\"\nto = \"b\"\nconditions = { x = \"[\" }\n", "line 4: invalid pattern"},
+		{"unterminated", "[[rule]]\nfrom = \"a\n", "line 2: unterminated string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTOML(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseTOML() error = %v; want %q", err, tt.want)
+			}
+		})
+	}
+}
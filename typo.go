@@ -0,0 +1,143 @@
+package linkmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodeSimilarInput is reported by Linter for input templates which differ
+// from another rule's by a small typo, such as "posts" and "post", which
+// is usually a copy-paste mistake.
+const CodeSimilarInput = "similar-input"
+
+// minTypoLen is the length below which path elements are too short for a
+// small edit to suggest a typo: "v1" and "v2" are deliberately different.
+const minTypoLen = 3
+
+// typoRule is a rule considered by checkTypos.
+type typoRule struct {
+	line  int
+	tok   token
+	shape string
+	elems []string
+}
+
+// checkTypos reports rules whose input templates have the same shape as
+// an earlier rule's, with the same variables and extensions, and differ
+// from it in a single path element by a small edit distance.
+func checkTypos(rules []typoRule, source string) []Diagnostic {
+	var diags []Diagnostic
+	byShape := make(map[string][]int)
+	for i := range rules {
+		shape := rules[i].shape
+		for _, j := range byShape[shape] {
+			a, b, ok := typoPair(rules[j].elems, rules[i].elems)
+			if !ok {
+				continue
+			}
+			d := rules[i].tok.diagnostic(rules[i].line, CodeSimilarInput,
+				fmt.Errorf("input template differs from line %d only by %q for %q; is this a typo?", rules[j].line+1, b, a))
+			d.Severity = SeverityWarning
+			d.Related = []Related{{
+				File:    source,
+				Range:   rules[j].tok.rangeAt(rules[j].line),
+				Message: "the similar rule",
+			}}
+			diags = append(diags, d)
+			break
+		}
+		byShape[shape] = append(byShape[shape], i)
+	}
+	return diags
+}
+
+// typoShape splits an input template into the path elements of its
+// literals and returns them along with a key which is equal for templates
+// of the same shape. Regular expressions have no shape.
+func typoShape(t Template) (shape string, elems []string, ok bool) {
+	if t.re != nil {
+		return "", nil, false
+	}
+	var b strings.Builder
+	for _, s := range t.segs.segments() {
+		switch s.typ {
+		case segmentTypeString:
+			parts := strings.Split(s.val, "/")
+			elems = append(elems, parts...)
+			b.WriteString("/" + strconv.Itoa(len(parts)))
+		case segmentTypeVariable:
+			// Variable names do not matter.
+			b.WriteString("$")
+		default:
+			b.WriteString(s.val)
+		}
+	}
+	return b.String(), elems, true
+}
+
+// typoPair reports whether a and b differ in exactly one element, by a
+// small edit which is not just a change of digits, and returns the
+// differing elements.
+func typoPair(a, b []string) (string, string, bool) {
+	diff := -1
+	for i := range a {
+		if a[i] != b[i] {
+			if diff >= 0 {
+				return "", "", false
+			}
+			diff = i
+		}
+	}
+	if diff < 0 {
+		return "", "", false
+	}
+	x, y := a[diff], b[diff]
+	if len(x) < minTypoLen || len(y) < minTypoLen || stripDigits(x) == stripDigits(y) {
+		return "", "", false
+	}
+	max := 1
+	if len(x) >= 8 && len(y) >= 8 {
+		max = 2
+	}
+	if levenshtein(x, y) > max {
+		return "", "", false
+	}
+	return x, y, true
+}
+
+func stripDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// levenshtein returns the edit distance between a and b, in bytes.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,147 @@
+package linkmap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WithURLInputs lets the map evaluate absolute URLs as well as paths, so
+// that it can drive URL migration tables, with rules whose inputs are URLs:
+//
+//	https://old.example.com/docs/$1 https://example.com/guides/$1
+//	//blog.example.com/$y/$m/$slug https://example.com/blog/$slug
+//	https://example.com/search?q=$q https://example.com/find?query=$q
+//
+// A URL is matched in a canonical form: its scheme and host are lowercased,
+// default ports, userinfo and the fragment are dropped, and an empty path
+// becomes "/". Only inputs containing "//" match URLs, and an input
+// starting with "//" matches URLs of any scheme. The query, with its
+// parameters sorted by name, is matched only by inputs containing a literal
+// '?', or written as regular expressions; other inputs match the URL
+// without it, and their outputs may pass it on as $query, as in
+// https://example.com/$1{if:$query}?$query{end}. Paths are evaluated as
+// usual, so a map may mix file and URL rules. File variables, the base
+// directory and ignore patterns only apply to paths.
+func WithURLInputs() Option {
+	return func(m *Map) {
+		m.urlInputs = true
+	}
+}
+
+// isURL reports whether s is an absolute URL rather than a path.
+func isURL(s string) bool {
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return false
+	}
+	for j, r := range s[:i] {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (j == 0 || !(r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return true
+}
+
+// A urlInput is a URL in the canonical form inputs are matched against.
+type urlInput struct {
+	// scheme is the scheme, with its colon; rest the host and path, from
+	// the "//"; and query the sorted query, without its '?'.
+	scheme, rest, query string
+}
+
+func parseURLInput(s string) (urlInput, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Opaque != "" {
+		return urlInput{}, fmt.Errorf("%w: %q is not a valid URL", ErrInvalidPath, s)
+	}
+	return newURLInput(u), nil
+}
+
+// newURLInput returns the canonical form of u, which has a host, built from
+// its components.
+func newURLInput(u *url.URL) urlInput {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !(scheme == "http" && port == "80" || scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	return urlInput{scheme: scheme + ":", rest: "//" + host + p, query: u.Query().Encode()}
+}
+
+// form returns the text the input of r is matched against, or false if r
+// is a rule for paths, whose input has no "//".
+func (u urlInput) form(r *Rule) (string, bool) {
+	if t := r.Input; t.re != nil && !strings.Contains(t.re.src, "//") || t.re == nil && !t.segs.hasLiteral("//") {
+		return "", false
+	}
+	s := u.rest
+	if t := r.Input; t.re != nil || t.segs.hasLiteral("?") {
+		if u.query != "" {
+			s += "?" + u.query
+		}
+	}
+	if r.Input.segs.len() > 0 && r.Input.segs.at(0).typ == segmentTypeString && strings.HasPrefix(r.Input.segs.at(0).val, "//") {
+		return s, true
+	}
+	return u.scheme + s, true
+}
+
+// hasLiteral reports whether a literal segment of the template contains s.
+func (tmpl template) hasLiteral(s string) bool {
+	for i := 0; i < tmpl.len(); i++ {
+		if seg := tmpl.at(i); seg.typ == segmentTypeString && strings.Contains(seg.val, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupURL is lookup for an absolute URL.
+func (m *Map) lookupURL(ctx *EvalContext, rawURL string) Result {
+	u, err := parseURLInput(rawURL)
+	if err != nil {
+		return Result{Path: rawURL, Err: err}
+	}
+	return m.lookupURLInput(ctx, rawURL, u)
+}
+
+func (m *Map) lookupURLInput(ctx *EvalContext, rawURL string, u urlInput) Result {
+	res, done := m.scan(ctx.with("query", u.query), rawURL, u.form, nil)
+	if done || m.next == nil {
+		return res
+	}
+	return m.next.lookupURLInput(ctx, rawURL, u)
+}
+
+// EvaluateURL evaluates u against the rules for URLs, as described for
+// WithURLInputs, building its canonical form from its components rather
+// than its string form, so that ports, userinfo and escaped characters in
+// the path are handled exactly as url.URL parsed them. A URL without a
+// host, such as that of an incoming request, is evaluated as its unescaped
+// path without the leading '/'. The link is returned parsed, resolved against u
+// if it is relative. EvaluateURL does not need WithURLInputs.
+func (m *Map) EvaluateURL(u *url.URL) (*url.URL, error) {
+	var res Result
+	if u.Host == "" {
+		res = m.Lookup(strings.TrimPrefix(u.Path, "/"))
+	} else {
+		res = m.lookupURLInput(nil, u.String(), newURLInput(u))
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	link, err := url.Parse(res.Link)
+	if err != nil {
+		return nil, fmt.Errorf("linkmap: parsing link %q: %w", res.Link, err)
+	}
+	return u.ResolveReference(link), nil
+}
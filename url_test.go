@@ -0,0 +1,85 @@
+package linkmap
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestURLInputs(t *testing.T) {
+	m, err := Parse(strings.NewReader(`https://old.example.com/docs/$1 https://example.com/guides/$1{if:$query}?$query{end}
+//blog.example.com/$y/$m/$slug https://example.com/blog/$slug
+https://example.com/search?q=$q https://example.com/find?query=$q
+re:https?://(www\.)?example\.org/(?P<page>[a-z]+)\?ref=x https://example.com/$page
+docs/$1.md https://example.com/guides/$1
+$1 https://example.com/files/$1
+`), WithURLInputs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ in, want string }{
+		{"https://old.example.com/docs/intro", "https://example.com/guides/intro"},
+		{"HTTPS://Old.Example.com:443/docs/intro#top", "https://example.com/guides/intro"},
+		{"https://user:pw@old.example.com/docs/intro?b=2&a=1", "https://example.com/guides/intro?a=1&b=2"},
+		{"http://blog.example.com/2019/04/hello", "https://example.com/blog/hello"},
+		{"https://blog.example.com/2019/04/hello", "https://example.com/blog/hello"},
+		{"https://example.com/search?q=linkmap", "https://example.com/find?query=linkmap"},
+		{"http://www.example.org/about?ref=x", "https://example.com/about"},
+		{"docs/intro.md", "https://example.com/guides/intro"},
+		{"a/b", "https://example.com/files/a/b"},
+	}
+	for _, tt := range tests {
+		if link, err := m.Evaluate(tt.in); link != tt.want || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.in, link, err, tt.want)
+		}
+	}
+	for _, in := range []string{"https://other.example.com/docs/intro", "https://example.com/search"} {
+		if _, err := m.Evaluate(in); !errors.Is(err, ErrNoMatches) {
+			t.Errorf("Evaluate(%q) error = %v; want ErrNoMatches", in, err)
+		}
+	}
+	if results := m.EvaluateAll("https://old.example.com/docs/intro"); len(results) != 1 {
+		t.Errorf("EvaluateAll() = %v", results)
+	}
+	plain, err := Parse(strings.NewReader("$1 https://example.com/$1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Evaluate("https://example.com/a"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Evaluate() of a URL without WithURLInputs: err = %v", err)
+	}
+	if diags := Analyze("https://a.example.com/$1 https://b.example.com/$1?$query\n", WithURLInputs()); len(diags) != 0 {
+		t.Errorf("Analyze() = %v", diags)
+	}
+}
+
+func TestEvaluateURL(t *testing.T) {
+	m, err := Parse(strings.NewReader(`https://old.example.com/docs/$1 https://example.com/guides/$1
+//example.com:8080/a%2Fb/$1 /new/$1
+https://example.com/search?q=$q https://example.com/find?query=$q
+docs/$1.md /guides/$1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		u    url.URL
+		want string
+	}{
+		{url.URL{Scheme: "https", Host: "OLD.example.com:443", Path: "/docs/intro"}, "https://example.com/guides/intro"},
+		{url.URL{Scheme: "https", User: url.UserPassword("u", "p"), Host: "old.example.com", Path: "/docs/intro"}, "https://example.com/guides/intro"},
+		{url.URL{Scheme: "http", Host: "example.com:8080", Path: "/a/b/c", RawPath: "/a%2Fb/c"}, "http://example.com:8080/new/c"},
+		{url.URL{Scheme: "https", Host: "example.com", Path: "/search", RawQuery: "q=x"}, "https://example.com/find?query=x"},
+		{url.URL{Path: "/docs/intro.md"}, "/guides/intro"},
+	}
+	for _, tt := range tests {
+		got, err := m.EvaluateURL(&tt.u)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("EvaluateURL(%s) = %v, %v; want %s", tt.u.String(), got, err, tt.want)
+		}
+	}
+	if _, err := m.EvaluateURL(&url.URL{Scheme: "http", Host: "example.com:8080", Path: "/a/b/c"}); !errors.Is(err, ErrNoMatches) {
+		t.Errorf("EvaluateURL() with an unescaped slash: err = %v; want ErrNoMatches", err)
+	}
+}
@@ -0,0 +1,76 @@
+package linkmap
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A VarError reports a variable whose value a validator rejected (see
+// Map.ValidateVar). It is the error of a path which no other rule
+// matched, and so also matches ErrNoMatches.
+type VarError struct {
+	// Rule is the rule whose match bound the variable.
+	Rule Rule
+	// Var is the variable's name and Value its value.
+	Var, Value string
+	// Err is the validator's error.
+	Err error
+}
+
+func (e *VarError) Error() string {
+	msg := fmt.Sprintf("invalid $%s %q: %v", e.Var, e.Value, e.Err)
+	if origin := e.Rule.Origin(); origin != "" {
+		msg = origin + ": " + msg
+	}
+	return "linkmap: " + msg
+}
+
+func (e *VarError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrNoMatches.
+func (e *VarError) Is(target error) bool { return target == ErrNoMatches }
+
+// ValidateVar registers fn to check the values of the variable name, after
+// a rule's input matches and before its link is built. A rule whose match
+// binds an invalid value, such as an overlong slug, is skipped as if it
+// had not matched, so that later rules can handle the path; if none does,
+// the error is a *VarError. Variables may have several validators. It must
+// not be called once the map is in use; WithVarValidator registers
+// validators as the map is parsed.
+func (m *Map) ValidateVar(name string, fn func(string) error) {
+	if m.validators == nil {
+		m.validators = make(map[string][]func(string) error)
+	}
+	m.validators[name] = append(m.validators[name], fn)
+}
+
+// WithVarValidator registers a validator for a variable, as
+// Map.ValidateVar does.
+func WithVarValidator(name string, fn func(string) error) Option {
+	return func(m *Map) {
+		m.ValidateVar(name, fn)
+	}
+}
+
+// validate checks the variables bound by matching r, returning a
+// *VarError for the first invalid one, in order of name.
+func (m *Map) validate(r *Rule, vars map[string]string) error {
+	if len(m.validators) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if len(m.validators[name]) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, fn := range m.validators[name] {
+			if err := fn(vars[name]); err != nil {
+				return &VarError{Rule: *r, Var: name, Value: vars[name], Err: err}
+			}
+		}
+	}
+	return nil
+}
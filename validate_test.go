@@ -0,0 +1,50 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateVar(t *testing.T) {
+	errLong := errors.New("too long")
+	m, err := Parse(strings.NewReader("posts/$id.md https://example.com/p/$id\nposts/$slug.md https://example.com/s/$slug\n"),
+		WithVarValidator("id", func(s string) error {
+			if strings.Trim(s, "0123456789") != "" {
+				return errors.New("not a number")
+			}
+			return nil
+		}),
+		WithSource("links"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ValidateVar("slug", func(s string) error {
+		if len(s) > 8 {
+			return errLong
+		}
+		return nil
+	})
+	tests := []struct {
+		path, link string
+	}{
+		{"posts/42.md", "https://example.com/p/42"},
+		{"posts/hello.md", "https://example.com/s/hello"},
+	}
+	for _, tt := range tests {
+		if link, err := m.Evaluate(tt.path); link != tt.link || err != nil {
+			t.Errorf("Evaluate(%q) = %q, %v; want %q", tt.path, link, err, tt.link)
+		}
+	}
+	_, err = m.Evaluate("posts/much-too-long.md")
+	var verr *VarError
+	if !errors.As(err, &verr) || verr.Var != "id" || !errors.Is(err, ErrNoMatches) {
+		t.Fatalf("Evaluate(posts/much-too-long.md) = %v; want a *VarError for $id", err)
+	}
+	if want := `linkmap: links:1: invalid $id "much-too-long": not a number`; err.Error() != want {
+		t.Errorf("Error() = %q; want %q", err, want)
+	}
+	if steps := m.Explain("posts/much-too-long.md").Steps; len(steps) != 2 || !strings.Contains(steps[1].Reason, "too long") {
+		t.Errorf("Explain() steps = %+v", steps)
+	}
+}
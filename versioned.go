@@ -0,0 +1,67 @@
+package linkmap
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoVersion is returned by VersionedMap when no map was in force at the
+// requested time.
+var ErrNoVersion = errors.New("linkmap: no map in force at the given time")
+
+// A VersionedMap holds the maps in force over time, so that links can be
+// resolved with the rules that applied at a given moment. It is safe for
+// concurrent use.
+type VersionedMap struct {
+	mu       sync.RWMutex
+	versions []version // sorted by from
+}
+
+type version struct {
+	from time.Time
+	m    *Map
+}
+
+// Add records that m is in force from the given time until the next
+// version. Adding a map with the same time as an existing one replaces it.
+func (v *VersionedMap) Add(from time.Time, m *Map) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	i := sort.Search(len(v.versions), func(i int) bool {
+		return !v.versions[i].from.Before(from)
+	})
+	if i < len(v.versions) && v.versions[i].from.Equal(from) {
+		v.versions[i].m = m
+		return
+	}
+	v.versions = append(v.versions, version{})
+	copy(v.versions[i+1:], v.versions[i:])
+	v.versions[i] = version{from: from, m: m}
+}
+
+// At returns the map in force at t and the time it came into force. It
+// returns ErrNoVersion if t is before the first version.
+func (v *VersionedMap) At(t time.Time) (*Map, time.Time, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	i := sort.Search(len(v.versions), func(i int) bool {
+		return v.versions[i].from.After(t)
+	})
+	if i == 0 {
+		return nil, time.Time{}, ErrNoVersion
+	}
+	return v.versions[i-1].m, v.versions[i-1].from, nil
+}
+
+// EvaluateAt evaluates fpath against the map in force at t, as it was at
+// t: scheduled outputs (see Rule.Scheduled) are chosen by t rather than by
+// the map's clock.
+func (v *VersionedMap) EvaluateAt(t time.Time, fpath string) (string, error) {
+	m, _, err := v.At(t)
+	if err != nil {
+		return "", err
+	}
+	return m.EvaluateContext(&EvalContext{Time: t}, fpath)
+}
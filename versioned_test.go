@@ -0,0 +1,70 @@
+package linkmap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionedMap(t *testing.T) {
+	parse := func(src string) *Map {
+		m, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", src, err)
+		}
+		return m
+	}
+	jan := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	apr := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	var v VersionedMap
+	v.Add(apr, parse("docs/$1.md https://new.example.com/$1\n"))
+	v.Add(jan, parse("docs/$1.md https://old.example.com/$1\n"))
+
+	cases := []struct {
+		at      time.Time
+		expect  string
+		wantErr error
+	}{
+		{at: jan.Add(-time.Hour), wantErr: ErrNoVersion},
+		{at: jan, expect: "https://old.example.com/a"},
+		{at: apr.Add(-time.Second), expect: "https://old.example.com/a"},
+		{at: apr, expect: "https://new.example.com/a"},
+		{at: apr.AddDate(1, 0, 0), expect: "https://new.example.com/a"},
+	}
+	for _, c := range cases {
+		got, err := v.EvaluateAt(c.at, "docs/a.md")
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("EvaluateAt(%v) error = %v; want %v", c.at, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.expect {
+			t.Errorf("EvaluateAt(%v) = %q, %v; want %q", c.at, got, err, c.expect)
+		}
+	}
+}
+
+func TestVersionedMapScheduled(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(scheduledSrc), WithClock(func() time.Time { return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v VersionedMap
+	v.Add(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), m)
+	tests := []struct{ at, want string }{
+		{"2024-06-01T08:59:59Z", "https://canary.example.com/intro"},
+		{"2024-06-15T00:00:00Z", "https://docs.example.com/intro"},
+		{"2024-07-02T00:00:00Z", "https://example.com/docs/intro"},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse(time.RFC3339, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link, err := v.EvaluateAt(at, "docs/intro.md"); link != tt.want || err != nil {
+			t.Errorf("EvaluateAt(%s) = %q, %v; want %q", tt.at, link, err, tt.want)
+		}
+	}
+}
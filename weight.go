@@ -0,0 +1,112 @@
+package linkmap
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// A WeightedOutput is an output which a rule builds links with for a share
+// of evaluations, as in gradual migrations and A/B cutovers of published
+// URLs.
+type WeightedOutput struct {
+	Output Template `json:"output"`
+	// Weight is the percentage of evaluations, from 1 to 100.
+	Weight int `json:"weight"`
+}
+
+// weightRand picks outputs for evaluations without a seed.
+var weightRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// outputs returns the rule's Output followed by its weighted and scheduled
+// outputs.
+func (r Rule) outputs() []Template {
+	outs := []Template{r.Output}
+	for _, w := range r.Weighted {
+		outs = append(outs, w.Output)
+	}
+	for _, s := range r.Scheduled {
+		outs = append(outs, s.Output)
+	}
+	return outs
+}
+
+// rewriteOutputs returns r with fn applied to each of its outputs.
+func (r Rule) rewriteOutputs(fn func(Template) Template) Rule {
+	r.Output = fn(r.Output)
+	if r.Weighted != nil {
+		weighted := make([]WeightedOutput, len(r.Weighted))
+		for i, w := range r.Weighted {
+			weighted[i] = WeightedOutput{Output: fn(w.Output), Weight: w.Weight}
+		}
+		r.Weighted = weighted
+	}
+	if r.Scheduled != nil {
+		scheduled := make([]ScheduledOutput, len(r.Scheduled))
+		for i, s := range r.Scheduled {
+			scheduled[i] = ScheduledOutput{At: s.At, Output: fn(s.Output)}
+		}
+		r.Scheduled = scheduled
+	}
+	return r
+}
+
+// output returns the output to build the link of an evaluation in ctx
+// with, at the time of ctx or else the time now returns if the rule has
+// scheduled outputs.
+// Otherwise, the evaluation falls in one of 100 buckets, derived from the
+// seed of ctx or at random, and the weighted outputs take the first
+// buckets in order, so that raising a weight keeps the seeds its output
+// had.
+func (r *Rule) output(ctx *EvalContext, now func() time.Time) Template {
+	if len(r.Scheduled) > 0 {
+		t := ctx.fixedTime()
+		if t.IsZero() {
+			t = now()
+		}
+		if out, ok := r.scheduled(t); ok {
+			return out
+		}
+	}
+	if len(r.Weighted) == 0 {
+		return r.Output
+	}
+	var n int
+	if ctx != nil && ctx.Seed != "" {
+		h := fnv.New32a()
+		h.Write([]byte(ctx.Seed))
+		n = int(h.Sum32() % 100)
+	} else {
+		weightRand.Lock()
+		n = weightRand.Intn(100)
+		weightRand.Unlock()
+	}
+	for _, w := range r.Weighted {
+		if n < w.Weight {
+			return w.Output
+		}
+		n -= w.Weight
+	}
+	return r.Output
+}
+
+// checkWeights checks that the weights of the rule's weighted outputs are
+// percentages which add up to at most 100.
+func (r *Rule) checkWeights() error {
+	sum := 0
+	for _, w := range r.Weighted {
+		if w.Weight < 1 || w.Weight > 100 {
+			return fmt.Errorf("weight %d of %q is not a percentage from 1 to 100", w.Weight, w.Output)
+		}
+		sum += w.Weight
+	}
+	if sum > 100 {
+		return fmt.Errorf("weights add up to %d%%, more than 100%%", sum)
+	}
+	return nil
+}
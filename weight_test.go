@@ -0,0 +1,117 @@
+package linkmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const weightedSrc = `[[rule]]
+from = "docs/$slug.md"
+to = "https://old.example.com/$slug"
+
+[rule.weighted]
+"https://new.example.com/$slug" = 10
+`
+
+func TestWeightedOutputs(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(weightedSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 2000
+	newLinks := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		ctx := &EvalContext{Seed: fmt.Sprintf("user-%d", i)}
+		link, err := m.EvaluateContext(ctx, "docs/intro.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch link {
+		case "https://new.example.com/intro":
+			newLinks[ctx.Seed] = true
+		case "https://old.example.com/intro":
+		default:
+			t.Fatalf("EvaluateContext() = %q", link)
+		}
+		if again, _ := m.EvaluateContext(ctx, "docs/intro.md"); again != link {
+			t.Fatalf("seed %q evaluated to %q, then %q", ctx.Seed, link, again)
+		}
+	}
+	if got := len(newLinks); got < n/20 || got > n*3/20 {
+		t.Errorf("%d of %d seeds got the 10%% output", got, n)
+	}
+
+	// Raising the weight keeps the seeds which had the output.
+	m, err = ParseTOML(strings.NewReader(strings.Replace(weightedSrc, "= 10", "= 50", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := range newLinks {
+		if link, _ := m.EvaluateContext(&EvalContext{Seed: seed}, "docs/intro.md"); link != "https://new.example.com/intro" {
+			t.Errorf("seed %q moved to %q at 50%%", seed, link)
+		}
+	}
+
+	links := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		link, _ := m.Evaluate("docs/intro.md")
+		links[link] = true
+	}
+	if len(links) != 2 {
+		t.Errorf("unseeded evaluations gave %v; want both outputs", links)
+	}
+}
+
+func TestWeightedOutputsFormats(t *testing.T) {
+	m, err := ParseTOML(strings.NewReader(weightedSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := m.WriteTOML(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != weightedSrc {
+		t.Errorf("WriteTOML() = %q; want %q", b.String(), weightedSrc)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := ParseBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin.Hash() != m.Hash() {
+		t.Error("binary round trip changed the map")
+	}
+	plain := New([]Rule{{Input: m.rules[0].Input, Output: m.rules[0].Output}})
+	if plain.Hash() == m.Hash() {
+		t.Error("Hash() ignores weighted outputs")
+	}
+	moved, err := m.RewriteOutputs(func(t Template) Template {
+		out, _ := ParseTemplate(strings.Replace(t.String(), "example.com", "example.org", 1))
+		return out
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := moved.Rules()[0].Weighted[0].Output.String(); got != "https://new.example.org/$slug" {
+		t.Errorf("RewriteOutputs() left weighted output %q", got)
+	}
+}
+
+func TestWeightedOutputsErrors(t *testing.T) {
+	for _, tt := range []struct{ weighted, want string }{
+		{`"https://a.example.com/$slug" = 0`, "not a percentage"},
+		{`"https://a.example.com/$slug" = 60` + "\n" + `"https://b.example.com/$slug" = 50`, "more than 100%"},
+		{`"https://a.example.com/$slug" = "10"`, "must be an integer"},
+	} {
+		src := strings.Replace(weightedSrc, `"https://new.example.com/$slug" = 10`, tt.weighted, 1)
+		if _, err := ParseTOML(strings.NewReader(src)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseTOML(%q) error = %v; want %q", tt.weighted, err, tt.want)
+		}
+	}
+}